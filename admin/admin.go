@@ -0,0 +1,251 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/log"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/**
+/healthz 只表示进程可以响应请求，供orchestrator判断是否需要重启
+/readyz 执行所有的Check，Critical的Check失败时返回503，供负载均衡判断是否转发请求
+/admin/* 需要在header中携带 Authorization: Bearer <token>，没有配置token时admin api不可用
+admin api可以解锁keystore，所以默认只监听127.0.0.1，需要从其他机器访问时配置Host
+*/
+
+const defaultHost = "127.0.0.1"
+
+const checkTimeout = 3 * time.Second
+
+//Backend is implemented by node, the admin api operates the components through it
+type Backend interface {
+	Components() []ComponentStatus
+	PauseMatcher() error
+	ResumeMatcher() error
+	ReloadTokens() error
+	UnlockAccount(address common.Address, passphrase string) error
+}
+
+//ComponentStatus describes a component enabled on the node, the state it reports by itself is in Detail
+type ComponentStatus struct {
+	Name   string `json:"name"`
+	Detail string `json:"detail,omitempty"`
+}
+
+//Check is a dependency of node, the node isn't ready if a critical check fails
+type Check struct {
+	Name     string
+	Critical bool
+	Check    func() error
+}
+
+type CheckResult struct {
+	Name     string `json:"name"`
+	Critical bool   `json:"critical"`
+	Ok       bool   `json:"ok"`
+	Err      string `json:"err,omitempty"`
+}
+
+type AdminService struct {
+	options config.AdminOptions
+	backend Backend
+	checks  []Check
+	mux     *http.ServeMux
+	server  *http.Server
+}
+
+func NewAdminService(options config.AdminOptions, backend Backend, checks []Check) *AdminService {
+	s := &AdminService{options: options, backend: backend, checks: checks}
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/healthz", s.healthz)
+	s.mux.HandleFunc("/readyz", s.readyz)
+	s.mux.HandleFunc("/admin/status", s.authorized("GET", s.status))
+	s.mux.HandleFunc("/admin/matcher/pause", s.authorized("POST", s.pauseMatcher))
+	s.mux.HandleFunc("/admin/matcher/resume", s.authorized("POST", s.resumeMatcher))
+	s.mux.HandleFunc("/admin/tokens/reload", s.authorized("POST", s.reloadTokens))
+	s.mux.HandleFunc("/admin/accounts/unlock", s.authorized("POST", s.unlockAccount))
+	return s
+}
+
+func (s *AdminService) Handler() http.Handler {
+	return s.mux
+}
+
+func (s *AdminService) Start() {
+	if "" == s.options.Port {
+		return
+	}
+	address := s.listenAddress()
+	listener, err := net.Listen("tcp", address)
+	if nil != err {
+		log.Errorf("admin, listen on:%s err:%s", address, err.Error())
+		return
+	}
+	s.server = &http.Server{Handler: s.mux}
+	go s.server.Serve(listener)
+	log.Infof("admin endpoint opened on %s", address)
+}
+
+func (s *AdminService) listenAddress() string {
+	host := s.options.Host
+	if "" == host {
+		host = defaultHost
+	}
+	return net.JoinHostPort(host, s.options.Port)
+}
+
+func (s *AdminService) Stop() {
+	if nil != s.server {
+		s.server.Close()
+	}
+}
+
+//RunChecks runs the checks concurrently, the check which doesn't return in time is failed
+func (s *AdminService) RunChecks() (bool, []CheckResult) {
+	type indexedResult struct {
+		idx    int
+		result CheckResult
+	}
+
+	results := make([]CheckResult, len(s.checks))
+	done := make(chan indexedResult, len(s.checks))
+	for idx, check := range s.checks {
+		results[idx] = CheckResult{Name: check.Name, Critical: check.Critical, Err: "timeout"}
+		go func(idx int, check Check) {
+			result := CheckResult{Name: check.Name, Critical: check.Critical, Ok: true}
+			if err := check.Check(); nil != err {
+				result.Ok = false
+				result.Err = err.Error()
+			}
+			done <- indexedResult{idx: idx, result: result}
+		}(idx, check)
+	}
+
+	timeout := time.After(checkTimeout)
+	for remained := len(s.checks); remained > 0; remained-- {
+		select {
+		case res := <-done:
+			results[res.idx] = res.result
+		case <-timeout:
+			remained = 0
+		}
+	}
+
+	ready := true
+	for _, result := range results {
+		if result.Critical && !result.Ok {
+			ready = false
+		}
+	}
+	return ready, results
+}
+
+func (s *AdminService) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *AdminService) readyz(w http.ResponseWriter, r *http.Request) {
+	ready, results := s.RunChecks()
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJson(w, code, map[string]interface{}{"ready": ready, "checks": results})
+}
+
+func (s *AdminService) authorized(method string, handle func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if "" == s.options.Token {
+			writeJson(w, http.StatusForbidden, map[string]string{"error": "admin api is disabled"})
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.options.Token)) != 1 {
+			writeJson(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		if r.Method != method {
+			writeJson(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		if res, err := handle(r); nil != err {
+			log.Errorf("admin, %s %s err:%s", r.Method, r.URL.Path, err.Error())
+			writeJson(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		} else {
+			log.Infof("admin, %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			writeJson(w, http.StatusOK, res)
+		}
+	}
+}
+
+func (s *AdminService) status(r *http.Request) (interface{}, error) {
+	ready, results := s.RunChecks()
+	return map[string]interface{}{"ready": ready, "checks": results, "components": s.backend.Components()}, nil
+}
+
+func (s *AdminService) pauseMatcher(r *http.Request) (interface{}, error) {
+	return map[string]string{"matcher": "paused"}, s.backend.PauseMatcher()
+}
+
+func (s *AdminService) resumeMatcher(r *http.Request) (interface{}, error) {
+	return map[string]string{"matcher": "resumed"}, s.backend.ResumeMatcher()
+}
+
+func (s *AdminService) reloadTokens(r *http.Request) (interface{}, error) {
+	return map[string]string{"tokens": "reloaded"}, s.backend.ReloadTokens()
+}
+
+type unlockRequest struct {
+	Address    string `json:"address"`
+	Passphrase string `json:"passphrase"`
+}
+
+func (s *AdminService) unlockAccount(r *http.Request) (interface{}, error) {
+	var req unlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); nil != err {
+		return nil, fmt.Errorf("invalid request:%s", err.Error())
+	}
+	if !common.IsHexAddress(req.Address) {
+		return nil, errors.New("invalid address:" + req.Address)
+	}
+	address := common.HexToAddress(req.Address)
+	if err := s.backend.UnlockAccount(address, req.Passphrase); nil != err {
+		return nil, err
+	}
+	return map[string]string{"unlocked": address.Hex()}, nil
+}
+
+func writeJson(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); nil != err {
+		log.Errorf("admin, write response err:%s", err.Error())
+	}
+}
@@ -0,0 +1,134 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package admin_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Loopring/relay/admin"
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/log"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+type backend struct {
+	paused   bool
+	unlocked common.Address
+}
+
+func (b *backend) Components() []admin.ComponentStatus {
+	return []admin.ComponentStatus{{Name: "miner", Detail: "matching"}}
+}
+
+func (b *backend) PauseMatcher() error {
+	b.paused = true
+	return nil
+}
+
+func (b *backend) ResumeMatcher() error {
+	b.paused = false
+	return nil
+}
+
+func (b *backend) ReloadTokens() error {
+	return errors.New("token file not found")
+}
+
+func (b *backend) UnlockAccount(address common.Address, passphrase string) error {
+	b.unlocked = address
+	return nil
+}
+
+func request(handler http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if "" != token {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminService(t *testing.T) {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+
+	redisDown := true
+	b := &backend{}
+	checks := []admin.Check{
+		{Name: "mysql", Critical: true, Check: func() error { return nil }},
+		{Name: "redis", Critical: true, Check: func() error {
+			if redisDown {
+				return errors.New("connection refused")
+			}
+			return nil
+		}},
+		{Name: "ipfs", Critical: false, Check: func() error { return errors.New("ipfs node is down") }},
+	}
+	handler := admin.NewAdminService(config.AdminOptions{Token: "secret"}, b, checks).Handler()
+
+	if w := request(handler, "GET", "/healthz", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("healthz should be ok, but got:%d", w.Code)
+	}
+	if w := request(handler, "GET", "/readyz", "", ""); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "connection refused") {
+		t.Fatalf("readyz should fail when redis is down, got:%d %s", w.Code, w.Body.String())
+	}
+	redisDown = false
+	if w := request(handler, "GET", "/readyz", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("readyz should ignore the non critical check, got:%d %s", w.Code, w.Body.String())
+	}
+
+	if w := request(handler, "POST", "/admin/matcher/pause", "", ""); w.Code != http.StatusUnauthorized || b.paused {
+		t.Fatalf("admin api requires token, got:%d", w.Code)
+	}
+	if w := request(handler, "POST", "/admin/matcher/pause", "wrong", ""); w.Code != http.StatusUnauthorized || b.paused {
+		t.Fatalf("admin api requires the right token, got:%d", w.Code)
+	}
+	if w := request(handler, "GET", "/admin/matcher/pause", "secret", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("pause requires POST, got:%d", w.Code)
+	}
+	if w := request(handler, "POST", "/admin/matcher/pause", "secret", ""); w.Code != http.StatusOK || !b.paused {
+		t.Fatalf("matcher should be paused, got:%d", w.Code)
+	}
+	if w := request(handler, "POST", "/admin/matcher/resume", "secret", ""); w.Code != http.StatusOK || b.paused {
+		t.Fatalf("matcher should be resumed, got:%d", w.Code)
+	}
+	if w := request(handler, "POST", "/admin/tokens/reload", "secret", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("the error of reloading should be returned, got:%d", w.Code)
+	}
+	if w := request(handler, "POST", "/admin/accounts/unlock", "secret", `{"address":"0x1234"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid address should be rejected, got:%d", w.Code)
+	}
+	address := "0x750aD4351bB728ceC7d639A9511F9D6488f1E259"
+	if w := request(handler, "POST", "/admin/accounts/unlock", "secret", `{"address":"`+address+`","passphrase":"1"}`); w.Code != http.StatusOK || b.unlocked != common.HexToAddress(address) {
+		t.Fatalf("account should be unlocked, got:%d %s", w.Code, w.Body.String())
+	}
+	if w := request(handler, "GET", "/admin/status", "secret", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"components"`) {
+		t.Fatalf("unexpected status:%d %s", w.Code, w.Body.String())
+	}
+
+	disabled := admin.NewAdminService(config.AdminOptions{}, b, checks).Handler()
+	if w := request(disabled, "POST", "/admin/matcher/pause", "", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("admin api should be disabled without token, got:%d", w.Code)
+	}
+}
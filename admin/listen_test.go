@@ -0,0 +1,37 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package admin
+
+import (
+	"testing"
+
+	"github.com/Loopring/relay/config"
+)
+
+func TestAdminService_ListenAddress(t *testing.T) {
+	s := NewAdminService(config.AdminOptions{Port: "8091"}, nil, nil)
+	if address := s.listenAddress(); address != "127.0.0.1:8091" {
+		t.Fatalf("admin should listen on loopback by default, but got:%s", address)
+	}
+
+	s = NewAdminService(config.AdminOptions{Host: "0.0.0.0", Port: "8091"}, nil, nil)
+	if address := s.listenAddress(); address != "0.0.0.0:8091" {
+		t.Fatalf("expected address:0.0.0.0:8091, but got:%s", address)
+	}
+}
@@ -19,7 +19,9 @@
 package cache
 
 import (
+	"github.com/Loopring/relay/cache/memory"
 	myredis "github.com/Loopring/relay/cache/redis"
+	"github.com/Loopring/relay/config"
 	"fmt"
 	"qiniupkg.com/x/errors.v7"
 )
@@ -43,6 +45,10 @@ type Cache interface {
 
 	HMGet(key string, fields ...[]byte) ([][]byte, error)
 
+	//HMUpdate reads fields of the hash and sets the fields returned by update atomically, nothing is set if update returns empty,
+	//update may be called more than once when the key is changed by other clients at the same time
+	HMUpdate(key string, ttl int64, fields [][]byte, update func(values [][]byte) [][]byte) error
+
 	HDel(key string, fields ...[]byte) (int64, error)
 
 	HGetAll(key string) ([][]byte, error)
@@ -66,6 +72,13 @@ type Cache interface {
 }
 
 func NewCache(cfg interface{}) {
+	if options, ok := cfg.(config.RedisOptions); ok && options.Engine == "memory" {
+		memoryCache := &memory.MemoryCacheImpl{}
+		memoryCache.Initialize(cfg)
+		cache = memoryCache
+		return
+	}
+
 	redisCache := &myredis.RedisCacheImpl{}
 	redisCache.Initialize(cfg)
 	if redisCache == nil {
@@ -77,6 +90,11 @@ func NewCache(cfg interface{}) {
 	cache = redisCache
 }
 
+//SetCache replaces the cache used by the package functions, such as a MemoryCacheImpl in tests
+func SetCache(c Cache) {
+	cache = c
+}
+
 func Set(key string, value []byte, ttl int64) error { return cache.Set(key, value, ttl) }
 func Get(key string) ([]byte, error)                { return cache.Get(key) }
 func Del(key string) error                          { return cache.Del(key) }
@@ -92,6 +110,10 @@ func HMGet(key string, fields ...[]byte) ([][]byte, error) {
 	return cache.HMGet(key, fields...)
 }
 
+func HMUpdate(key string, ttl int64, fields [][]byte, update func(values [][]byte) [][]byte) error {
+	return cache.HMUpdate(key, ttl, fields, update)
+}
+
 func HGetAll(key string) ([][]byte, error) {
 	return cache.HGetAll(key)
 }
@@ -0,0 +1,590 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cleanInterval = 60 * time.Second
+
+var wrongTypeErr = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+type itemType int
+
+const (
+	typeString itemType = iota
+	typeHash
+	typeSet
+	typeZSet
+)
+
+type item struct {
+	typ      itemType
+	value    []byte
+	hash     map[string][]byte
+	set      map[string]bool
+	zset     map[string]float64
+	expireAt time.Time
+}
+
+func (i *item) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && !now.Before(i.expireAt)
+}
+
+func (i *item) empty() bool {
+	switch i.typ {
+	case typeHash:
+		return len(i.hash) == 0
+	case typeSet:
+		return len(i.set) == 0
+	case typeZSet:
+		return len(i.zset) == 0
+	}
+	return false
+}
+
+//MemoryCacheImpl keeps all data in process with the same semantics of redis,
+//it is used by tests and the relay that runs on single node without redis
+type MemoryCacheImpl struct {
+	mtx   sync.Mutex
+	items map[string]*item
+	stop  chan struct{}
+}
+
+func (impl *MemoryCacheImpl) Initialize(cfg interface{}) {
+	impl.items = make(map[string]*item)
+	impl.stop = make(chan struct{})
+	go impl.clean()
+}
+
+//Stop stops the goroutine that removes expired keys
+func (impl *MemoryCacheImpl) Stop() {
+	close(impl.stop)
+}
+
+func (impl *MemoryCacheImpl) clean() {
+	ticker := time.NewTicker(cleanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-impl.stop:
+			return
+		case now := <-ticker.C:
+			impl.mtx.Lock()
+			for key, i := range impl.items {
+				if i.expired(now) {
+					delete(impl.items, key)
+				}
+			}
+			impl.mtx.Unlock()
+		}
+	}
+}
+
+//get returns the item that isn't expired, mtx must be held
+func (impl *MemoryCacheImpl) get(key string) *item {
+	i, exists := impl.items[key]
+	if !exists {
+		return nil
+	}
+	if i.expired(time.Now()) {
+		delete(impl.items, key)
+		return nil
+	}
+	return i
+}
+
+//getOrCreate returns the item of typ, a new one is created if the key doesn't exist
+func (impl *MemoryCacheImpl) getOrCreate(key string, typ itemType) (*item, error) {
+	if i := impl.get(key); nil != i {
+		if i.typ != typ {
+			return nil, wrongTypeErr
+		}
+		return i, nil
+	}
+	i := &item{typ: typ}
+	switch typ {
+	case typeHash:
+		i.hash = make(map[string][]byte)
+	case typeSet:
+		i.set = make(map[string]bool)
+	case typeZSet:
+		i.zset = make(map[string]float64)
+	}
+	impl.items[key] = i
+	return i, nil
+}
+
+//getTyped returns nil without error if the key doesn't exist
+func (impl *MemoryCacheImpl) getTyped(key string, typ itemType) (*item, error) {
+	i := impl.get(key)
+	if nil != i && i.typ != typ {
+		return nil, wrongTypeErr
+	}
+	return i, nil
+}
+
+func (impl *MemoryCacheImpl) expire(i *item, ttl int64) {
+	if ttl > 0 {
+		i.expireAt = time.Now().Add(time.Duration(ttl) * time.Second)
+	}
+}
+
+//removeIfEmpty removes the key like redis when the last element of hash, set or zset is removed
+func (impl *MemoryCacheImpl) removeIfEmpty(key string, i *item) {
+	if i.empty() {
+		delete(impl.items, key)
+	}
+}
+
+func copyBytes(data []byte) []byte {
+	res := make([]byte, len(data))
+	copy(res, data)
+	return res
+}
+
+func (impl *MemoryCacheImpl) Get(key string) ([]byte, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	if i, err := impl.getTyped(key, typeString); nil != err {
+		return []byte{}, err
+	} else if nil == i {
+		return []byte{}, fmt.Errorf("no this key:%s", key)
+	} else {
+		return copyBytes(i.value), nil
+	}
+}
+
+func (impl *MemoryCacheImpl) Exists(key string) (bool, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	return nil != impl.get(key), nil
+}
+
+func (impl *MemoryCacheImpl) Set(key string, value []byte, ttl int64) error {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	//set discards the old value and ttl whatever the type is
+	i := &item{typ: typeString, value: copyBytes(value)}
+	impl.expire(i, ttl)
+	impl.items[key] = i
+	return nil
+}
+
+func (impl *MemoryCacheImpl) Del(key string) error {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	delete(impl.items, key)
+	return nil
+}
+
+func (impl *MemoryCacheImpl) Dels(keys []string) error {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	for _, key := range keys {
+		delete(impl.items, key)
+	}
+	return nil
+}
+
+func (impl *MemoryCacheImpl) Keys(keyFormat string) ([][]byte, error) {
+	reg, err := globToRegexp(keyFormat)
+	if nil != err {
+		return [][]byte{}, err
+	}
+
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	res := [][]byte{}
+	now := time.Now()
+	for key, i := range impl.items {
+		if i.expired(now) {
+			delete(impl.items, key)
+		} else if reg.MatchString(key) {
+			res = append(res, []byte(key))
+		}
+	}
+	return res, nil
+}
+
+func (impl *MemoryCacheImpl) HMSet(key string, ttl int64, args ...[]byte) error {
+	if len(args)%2 != 0 {
+		return errors.New("the length of `args` must be even")
+	}
+
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getOrCreate(key, typeHash)
+	if nil != err {
+		return err
+	}
+	for idx := 0; idx < len(args); idx += 2 {
+		i.hash[string(args[idx])] = copyBytes(args[idx+1])
+	}
+	impl.removeIfEmpty(key, i)
+	impl.expire(i, ttl)
+	return nil
+}
+
+func (impl *MemoryCacheImpl) HMGet(key string, fields ...[]byte) ([][]byte, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getTyped(key, typeHash)
+	if nil != err {
+		return [][]byte{}, err
+	}
+	res := [][]byte{}
+	for _, field := range fields {
+		if nil == i {
+			res = append(res, []byte{})
+		} else if value, exists := i.hash[string(field)]; exists {
+			res = append(res, copyBytes(value))
+		} else {
+			res = append(res, []byte{})
+		}
+	}
+	return res, nil
+}
+
+func (impl *MemoryCacheImpl) HMUpdate(key string, ttl int64, fields [][]byte, update func(values [][]byte) [][]byte) error {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getTyped(key, typeHash)
+	if nil != err {
+		return err
+	}
+	values := [][]byte{}
+	for _, field := range fields {
+		if nil == i {
+			values = append(values, []byte{})
+		} else if value, exists := i.hash[string(field)]; exists {
+			values = append(values, copyBytes(value))
+		} else {
+			values = append(values, []byte{})
+		}
+	}
+	args := update(values)
+	if len(args) == 0 {
+		return nil
+	}
+	if len(args)%2 != 0 {
+		return errors.New("the length of `args` must be even")
+	}
+	if nil == i {
+		i, _ = impl.getOrCreate(key, typeHash)
+	}
+	for idx := 0; idx < len(args); idx += 2 {
+		i.hash[string(args[idx])] = copyBytes(args[idx+1])
+	}
+	impl.expire(i, ttl)
+	return nil
+}
+
+func (impl *MemoryCacheImpl) HDel(key string, fields ...[]byte) (int64, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getTyped(key, typeHash)
+	if nil != err || nil == i {
+		return 0, err
+	}
+	var count int64
+	for _, field := range fields {
+		if _, exists := i.hash[string(field)]; exists {
+			delete(i.hash, string(field))
+			count++
+		}
+	}
+	impl.removeIfEmpty(key, i)
+	return count, nil
+}
+
+func (impl *MemoryCacheImpl) HGetAll(key string) ([][]byte, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getTyped(key, typeHash)
+	res := [][]byte{}
+	if nil != err || nil == i {
+		return res, err
+	}
+	for field, value := range i.hash {
+		res = append(res, []byte(field), copyBytes(value))
+	}
+	return res, nil
+}
+
+func (impl *MemoryCacheImpl) HVals(key string) ([][]byte, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getTyped(key, typeHash)
+	res := [][]byte{}
+	if nil != err || nil == i {
+		return res, err
+	}
+	for _, value := range i.hash {
+		res = append(res, copyBytes(value))
+	}
+	return res, nil
+}
+
+func (impl *MemoryCacheImpl) HExists(key string, field []byte) (bool, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getTyped(key, typeHash)
+	if nil != err || nil == i {
+		return false, err
+	}
+	_, exists := i.hash[string(field)]
+	return exists, nil
+}
+
+func (impl *MemoryCacheImpl) SAdd(key string, ttl int64, members ...[]byte) error {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getOrCreate(key, typeSet)
+	if nil != err {
+		return err
+	}
+	for _, member := range members {
+		i.set[string(member)] = true
+	}
+	impl.removeIfEmpty(key, i)
+	impl.expire(i, ttl)
+	return nil
+}
+
+func (impl *MemoryCacheImpl) SCard(key string) (int64, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getTyped(key, typeSet)
+	if nil != err || nil == i {
+		return 0, err
+	}
+	return int64(len(i.set)), nil
+}
+
+func (impl *MemoryCacheImpl) SRem(key string, members ...[]byte) (int64, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getTyped(key, typeSet)
+	if nil != err || nil == i {
+		return 0, err
+	}
+	var count int64
+	for _, member := range members {
+		if i.set[string(member)] {
+			delete(i.set, string(member))
+			count++
+		}
+	}
+	impl.removeIfEmpty(key, i)
+	return count, nil
+}
+
+func (impl *MemoryCacheImpl) SMembers(key string) ([][]byte, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getTyped(key, typeSet)
+	res := [][]byte{}
+	if nil != err || nil == i {
+		return res, err
+	}
+	for member := range i.set {
+		res = append(res, []byte(member))
+	}
+	return res, nil
+}
+
+func (impl *MemoryCacheImpl) SIsMember(key string, member []byte) (bool, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getTyped(key, typeSet)
+	if nil != err || nil == i {
+		return false, err
+	}
+	return i.set[string(member)], nil
+}
+
+//ZAdd accepts args as score1, member1, score2, member2...
+func (impl *MemoryCacheImpl) ZAdd(key string, ttl int64, args ...[]byte) error {
+	if len(args)%2 != 0 {
+		return errors.New("the length of `args` must be even")
+	}
+	scores := make([]float64, len(args)/2)
+	for idx := 0; idx < len(args); idx += 2 {
+		score, err := strconv.ParseFloat(string(args[idx]), 64)
+		if nil != err {
+			return errors.New("ERR value is not a valid float")
+		}
+		scores[idx/2] = score
+	}
+
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getOrCreate(key, typeZSet)
+	if nil != err {
+		return err
+	}
+	for idx, score := range scores {
+		i.zset[string(args[idx*2+1])] = score
+	}
+	impl.removeIfEmpty(key, i)
+	impl.expire(i, ttl)
+	return nil
+}
+
+type zsetMember struct {
+	member string
+	score  float64
+}
+
+//sortedMembers sorts by score, and then by member lexicographically like redis
+func sortedMembers(i *item) []zsetMember {
+	members := make([]zsetMember, 0, len(i.zset))
+	for member, score := range i.zset {
+		members = append(members, zsetMember{member: member, score: score})
+	}
+	sort.Slice(members, func(a, b int) bool {
+		if members[a].score != members[b].score {
+			return members[a].score < members[b].score
+		}
+		return members[a].member < members[b].member
+	})
+	return members
+}
+
+func (impl *MemoryCacheImpl) ZRange(key string, start, stop int64, withScores bool) ([][]byte, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getTyped(key, typeZSet)
+	res := [][]byte{}
+	if nil != err || nil == i {
+		return res, err
+	}
+	members := sortedMembers(i)
+	length := int64(len(members))
+	if start < 0 {
+		start = length + start
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+	for idx := start; idx <= stop; idx++ {
+		res = append(res, []byte(members[idx].member))
+		if withScores {
+			res = append(res, []byte(strconv.FormatFloat(members[idx].score, 'f', -1, 64)))
+		}
+	}
+	return res, nil
+}
+
+func (impl *MemoryCacheImpl) ZRemRangeByScore(key string, start, stop int64) (int64, error) {
+	impl.mtx.Lock()
+	defer impl.mtx.Unlock()
+
+	i, err := impl.getTyped(key, typeZSet)
+	if nil != err || nil == i {
+		return 0, err
+	}
+	var count int64
+	for member, score := range i.zset {
+		if score >= float64(start) && score <= float64(stop) {
+			delete(i.zset, member)
+			count++
+		}
+	}
+	impl.removeIfEmpty(key, i)
+	return count, nil
+}
+
+//globToRegexp converts the pattern of redis command keys, supports *, ?, [...] and \x
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	var buf strings.Builder
+	buf.WriteString("^")
+	runes := []rune(pattern)
+	for idx := 0; idx < len(runes); idx++ {
+		switch c := runes[idx]; c {
+		case '*':
+			buf.WriteString("(?s:.*)")
+		case '?':
+			buf.WriteString("(?s:.)")
+		case '\\':
+			if idx+1 < len(runes) {
+				idx++
+				buf.WriteString(regexp.QuoteMeta(string(runes[idx])))
+			} else {
+				buf.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		case '[':
+			end := idx + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end == len(runes) {
+				buf.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := string(runes[idx+1 : end])
+			if strings.HasPrefix(class, "^") {
+				class = "^" + strings.Replace(class[1:], `\`, `\\`, -1)
+			} else {
+				class = strings.Replace(class, `\`, `\\`, -1)
+			}
+			buf.WriteString("[" + class + "]")
+			idx = end
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
@@ -0,0 +1,173 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package memory_test
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Loopring/relay/cache"
+	"github.com/Loopring/relay/config"
+)
+
+func newCache() {
+	cache.NewCache(config.RedisOptions{Engine: "memory"})
+}
+
+func TestMemoryCacheImpl_SetExpire(t *testing.T) {
+	newCache()
+
+	if err := cache.Set("test_expire", []byte("hahhah"), 1); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if data, err := cache.Get("test_expire"); nil != err || string(data) != "hahhah" {
+		t.Fatalf("unexpected value:%s, err:%v", string(data), err)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+
+	if _, err := cache.Get("test_expire"); nil == err {
+		t.Fatalf("key should be expired")
+	}
+	if exists, _ := cache.Exists("test_expire"); exists {
+		t.Fatalf("key should be expired")
+	}
+}
+
+func TestMemoryCacheImpl_Hash(t *testing.T) {
+	newCache()
+
+	if err := cache.HMSet("test_hash", 0, []byte("f1"), []byte("v1"), []byte("f2"), []byte("v2")); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if err := cache.HMSet("test_hash", 0, []byte("f1")); nil == err {
+		t.Fatalf("odd args should be rejected")
+	}
+
+	values, _ := cache.HMGet("test_hash", []byte("f1"), []byte("f3"))
+	if len(values) != 2 || string(values[0]) != "v1" || len(values[1]) != 0 {
+		t.Fatalf("unexpected values:%v", values)
+	}
+	if all, _ := cache.HGetAll("test_hash"); len(all) != 4 {
+		t.Fatalf("unexpected length of hgetall:%d", len(all))
+	}
+	if exists, _ := cache.HExists("test_hash", []byte("f2")); !exists {
+		t.Fatalf("f2 should exist")
+	}
+	if _, err := cache.SMembers("test_hash"); nil == err {
+		t.Fatalf("should return wrong type error")
+	}
+
+	if count, _ := cache.HDel("test_hash", []byte("f1"), []byte("f2"), []byte("f3")); count != 2 {
+		t.Fatalf("expect 2 fields deleted, got %d", count)
+	}
+	if exists, _ := cache.Exists("test_hash"); exists {
+		t.Fatalf("empty hash should be removed")
+	}
+}
+
+func TestMemoryCacheImpl_HMUpdate(t *testing.T) {
+	newCache()
+
+	incr := func(values [][]byte) [][]byte {
+		count, _ := strconv.Atoi(string(values[0]))
+		return [][]byte{[]byte("count"), []byte(strconv.Itoa(count + 1))}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cache.HMUpdate("test_hmupdate", 0, [][]byte{[]byte("count")}, incr); nil != err {
+				t.Errorf("err:%s", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+	if values, _ := cache.HMGet("test_hmupdate", []byte("count")); string(values[0]) != "100" {
+		t.Fatalf("count should be 100, got:%s", string(values[0]))
+	}
+
+	if err := cache.HMUpdate("test_hmupdate_none", 0, [][]byte{[]byte("count")}, func(values [][]byte) [][]byte { return nil }); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if exists, _ := cache.Exists("test_hmupdate_none"); exists {
+		t.Fatalf("nothing should be set")
+	}
+}
+
+func TestMemoryCacheImpl_Set(t *testing.T) {
+	newCache()
+
+	cache.SAdd("test_set", 0, []byte("m1"), []byte("m2"), []byte("m1"))
+	if count, _ := cache.SCard("test_set"); count != 2 {
+		t.Fatalf("expect 2 members, got %d", count)
+	}
+	if ok, _ := cache.SIsMember("test_set", []byte("m2")); !ok {
+		t.Fatalf("m2 should be member")
+	}
+	if count, _ := cache.SRem("test_set", []byte("m2"), []byte("m3")); count != 1 {
+		t.Fatalf("expect 1 member removed, got %d", count)
+	}
+	if members, _ := cache.SMembers("test_set"); len(members) != 1 || string(members[0]) != "m1" {
+		t.Fatalf("unexpected members:%v", members)
+	}
+}
+
+func TestMemoryCacheImpl_ZSet(t *testing.T) {
+	newCache()
+
+	cache.ZAdd("test_zset", 0, []byte("3"), []byte("c"), []byte("1"), []byte("a"), []byte("2"), []byte("b"))
+	if data, _ := cache.ZRange("test_zset", -1, -1, false); len(data) != 1 || string(data[0]) != "c" {
+		t.Fatalf("unexpected last member:%v", data)
+	}
+	if data, _ := cache.ZRange("test_zset", 0, 1, true); len(data) != 4 || string(data[0]) != "a" || string(data[3]) != "2" {
+		t.Fatalf("unexpected range with scores:%v", data)
+	}
+	if count, _ := cache.ZRemRangeByScore("test_zset", 0, 2); count != 2 {
+		t.Fatalf("expect 2 members removed, got %d", count)
+	}
+	if data, _ := cache.ZRange("test_zset", 0, -1, false); len(data) != 1 || string(data[0]) != "c" {
+		t.Fatalf("unexpected members:%v", data)
+	}
+}
+
+func TestMemoryCacheImpl_Keys(t *testing.T) {
+	newCache()
+
+	cache.Set("ring_0x01", []byte("1"), 0)
+	cache.Set("ring_0x02", []byte("2"), 0)
+	cache.Set("order_0x01", []byte("3"), 0)
+
+	keys, _ := cache.Keys("ring_*")
+	res := []string{}
+	for _, key := range keys {
+		res = append(res, string(key))
+	}
+	sort.Strings(res)
+	if len(res) != 2 || res[0] != "ring_0x01" || res[1] != "ring_0x02" {
+		t.Fatalf("unexpected keys:%v", res)
+	}
+	if keys, _ := cache.Keys("ring_0x0[13]"); len(keys) != 1 {
+		t.Fatalf("unexpected keys:%d", len(keys))
+	}
+}
@@ -27,6 +27,9 @@ import (
 	"time"
 )
 
+//hmUpdateRetries is the max times of HMUpdate retried when the key is changed after watch
+const hmUpdateRetries = 10
+
 type RedisCacheImpl struct {
 	options config.RedisOptions
 	pool    *redis.Pool
@@ -273,6 +276,68 @@ func (impl *RedisCacheImpl) HMGet(key string, fields ...[]byte) ([][]byte, error
 	return res, err
 }
 
+//HMUpdate uses watch and multi, the transaction is aborted and retried if the key is changed by others
+func (impl *RedisCacheImpl) HMUpdate(key string, ttl int64, fields [][]byte, update func(values [][]byte) [][]byte) error {
+	conn := impl.pool.Get()
+	defer conn.Close()
+
+	for retry := 0; retry < hmUpdateRetries; retry++ {
+		if _, err := conn.Do("watch", key); nil != err {
+			log.Errorf(" key:%s, err:%s", key, err.Error())
+			return err
+		}
+		vs := []interface{}{}
+		vs = append(vs, key)
+		for _, v := range fields {
+			vs = append(vs, v)
+		}
+		reply, err := redis.Values(conn.Do("hmget", vs...))
+		if nil != err {
+			conn.Do("unwatch")
+			log.Errorf(" key:%s, err:%s", key, err.Error())
+			return err
+		}
+		values := [][]byte{}
+		for _, r := range reply {
+			if nil == r {
+				values = append(values, []byte{})
+			} else {
+				values = append(values, r.([]byte))
+			}
+		}
+
+		args := update(values)
+		if len(args) == 0 {
+			_, err := conn.Do("unwatch")
+			return err
+		}
+		if len(args)%2 != 0 {
+			conn.Do("unwatch")
+			return errors.New("the length of `args` must be even")
+		}
+		hs := []interface{}{}
+		hs = append(hs, key)
+		for _, v := range args {
+			hs = append(hs, v)
+		}
+		conn.Send("multi")
+		conn.Send("hmset", hs...)
+		if ttl > 0 {
+			conn.Send("expire", key, ttl)
+		}
+		res, err := conn.Do("exec")
+		if nil != err {
+			log.Errorf(" key:%s, err:%s", key, err.Error())
+			return err
+		}
+		//exec returns nil if the key is changed after watch
+		if nil != res {
+			return nil
+		}
+	}
+	return fmt.Errorf("key:%s is changed by others %d times", key, hmUpdateRetries)
+}
+
 func (impl *RedisCacheImpl) ZRange(key string, start, stop int64, withScores bool) ([][]byte, error) {
 
 	//log.Info("[REDIS-ZRANGE] key : " + key)
@@ -118,6 +118,30 @@ func TestRedisCacheImpl_HMSet(t *testing.T) {
 	t.Logf("time2: %d", (end1 - end))
 }
 
+func TestRedisCacheImpl_HMUpdate(t *testing.T) {
+	cache.NewCache(test.Cfg().Redis)
+	cache.Del("test_hmupdate")
+
+	incr := func(values [][]byte) [][]byte {
+		count, _ := strconv.Atoi(string(values[0]))
+		return [][]byte{[]byte("count"), []byte(strconv.Itoa(count + 1))}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cache.HMUpdate("test_hmupdate", 10, [][]byte{[]byte("count")}, incr); nil != err {
+				t.Errorf(err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+	if values, _ := cache.HMGet("test_hmupdate", []byte("count")); string(values[0]) != "20" {
+		t.Fatalf("count should be 20, got:%s", string(values[0]))
+	}
+}
+
 func TestRedisCacheImpl_BenchSyncPool(t *testing.T) {
 	cache.NewCache(test.Cfg().Redis)
 
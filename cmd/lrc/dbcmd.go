@@ -0,0 +1,120 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Loopring/relay/cmd/utils"
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/log"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func dbCommands() cli.Command {
+	c := cli.Command{
+		Name:     "db",
+		Usage:    "manage the schema of database",
+		Category: "database commands:",
+		Subcommands: []cli.Command{
+			cli.Command{
+				Name:   "migrate",
+				Usage:  "apply the pending migrations",
+				Action: migrateDb,
+				Flags: []cli.Flag{
+					cli.Int64Flag{
+						Name:  "to",
+						Usage: "the target version, default is the latest",
+					},
+				},
+			},
+			cli.Command{
+				Name:   "status",
+				Usage:  "list the migrations and whether they are applied",
+				Action: dbStatus,
+			},
+			cli.Command{
+				Name:   "rollback",
+				Usage:  "revert the latest applied migrations",
+				Action: rollbackDb,
+				Flags: []cli.Flag{
+					cli.IntFlag{
+						Name:  "steps",
+						Usage: "how many migrations to revert",
+						Value: 1,
+					},
+				},
+			},
+		},
+	}
+	return c
+}
+
+func newRdsService(ctx *cli.Context) *dao.RdsServiceImpl {
+	globalConfig := config.LoadConfig(ctx.GlobalString("config"))
+	log.Initialize(globalConfig.Log)
+	return dao.NewRdsService(globalConfig.Mysql)
+}
+
+func migrateDb(ctx *cli.Context) {
+	rds := newRdsService(ctx)
+	applied, err := rds.Migrate(ctx.Int64("to"))
+	for _, m := range applied {
+		fmt.Fprintf(ctx.App.Writer, "migrated version:%d %s\n", m.Version, m.Description)
+	}
+	if nil != err {
+		utils.ExitWithErr(ctx.App.Writer, err)
+	}
+	if len(applied) == 0 {
+		fmt.Fprintln(ctx.App.Writer, "no pending migration")
+	}
+}
+
+func dbStatus(ctx *cli.Context) {
+	rds := newRdsService(ctx)
+	states, err := rds.MigrationStatus()
+	if nil != err {
+		utils.ExitWithErr(ctx.App.Writer, err)
+	}
+	for _, state := range states {
+		if state.Applied {
+			fmt.Fprintf(ctx.App.Writer, "%d\tapplied at %s\t%s\n", state.Version, time.Unix(state.AppliedAt, 0).Format(time.RFC3339), state.Description)
+		} else {
+			fmt.Fprintf(ctx.App.Writer, "%d\tpending\t%s\n", state.Version, state.Description)
+		}
+	}
+}
+
+func rollbackDb(ctx *cli.Context) {
+	steps := ctx.Int("steps")
+	if steps <= 0 {
+		utils.ExitWithErr(ctx.App.Writer, errors.New("steps must be positive"))
+	}
+	rds := newRdsService(ctx)
+	reverted, err := rds.Rollback(steps)
+	for _, m := range reverted {
+		fmt.Fprintf(ctx.App.Writer, "rolled back version:%d %s\n", m.Version, m.Description)
+	}
+	if nil != err {
+		utils.ExitWithErr(ctx.App.Writer, err)
+	}
+}
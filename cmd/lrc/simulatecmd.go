@@ -0,0 +1,224 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/Loopring/relay/cmd/utils"
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/simulator"
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func simulateCommand() cli.Command {
+	c := cli.Command{
+		Name:     "simulate",
+		Usage:    "replay the orders of snapshot through matcher and evaluator without eth node, redis and mysql",
+		Category: "miner commands",
+		Action:   simulate,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "config,c",
+				Usage: "config file, miner, timing_matcher and market_cap options are used",
+			},
+			cli.StringFlag{
+				Name:  "orders",
+				Usage: "json or csv export of table order",
+			},
+			cli.StringFlag{
+				Name:  "fills",
+				Usage: "json or csv export of table fill_event, used to compare with history",
+			},
+			cli.StringFlag{
+				Name:  "prices",
+				Usage: "json or csv file of token prices, fields:address,symbol,decimals,price",
+			},
+			cli.StringFlag{
+				Name:  "balances",
+				Usage: "json or csv file of balances, fields:owner,token,amount. the balance not in it is enough to fill all orders",
+			},
+			cli.StringFlag{
+				Name:  "lrc",
+				Usage: "address of lrc, default is the token with symbol LRC in prices",
+			},
+			cli.StringFlag{
+				Name:  "weth",
+				Usage: "address of weth used as the price of eth, default is the token with symbol WETH in prices",
+			},
+			cli.StringFlag{
+				Name:  "gas-price",
+				Usage: "gas price in wei, default is the min gas price of miner",
+			},
+			cli.Int64Flag{
+				Name:  "start",
+				Usage: "unix time of the first round, default is the create time of the first order",
+			},
+			cli.Int64Flag{
+				Name:  "end",
+				Usage: "unix time of the last round, default is the create time of the last order",
+			},
+			cli.Int64Flag{
+				Name:  "duration",
+				Usage: "milliseconds between rounds, default is timing_matcher.duration",
+			},
+			cli.StringFlag{
+				Name:  "strategy",
+				Usage: "ring selection strategy: greedy, max_volume, round_robin or optimal",
+			},
+			cli.Int64Flag{
+				Name:  "cvs-threshold",
+				Usage: "overrides miner.rate_ratio_cvs_threshold",
+			},
+			cli.Float64Flag{
+				Name:  "subsidy",
+				Usage: "overrides miner.subsidy",
+			},
+			cli.Float64Flag{
+				Name:  "wallet-split",
+				Usage: "overrides miner.wallet_split",
+			},
+			cli.BoolFlag{
+				Name:  "rings",
+				Usage: "print every ring",
+			},
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "print the report as json",
+			},
+		},
+	}
+	return c
+}
+
+func simulate(ctx *cli.Context) {
+	file := ctx.GlobalString("config")
+	if ctx.IsSet("config") {
+		file = ctx.String("config")
+	}
+	globalConfig := config.LoadConfig(file)
+	logger := log.Initialize(globalConfig.Log)
+	defer func() {
+		if nil != logger {
+			logger.Sync()
+		}
+	}()
+
+	if "" == ctx.String("orders") || "" == ctx.String("prices") {
+		utils.ExitWithErr(ctx.App.Writer, errors.New("orders and prices are required"))
+	}
+
+	options := simulator.Options{}
+	options.Miner = globalConfig.Miner
+	if nil == options.Miner.TimingMatcher {
+		options.Miner.TimingMatcher = &config.TimingMatcher{}
+	}
+	options.DustOrderValue = globalConfig.OrderManager.DustOrderValue
+	options.Currency = globalConfig.MarketCap.Currency
+	options.StartTime = ctx.Int64("start")
+	options.EndTime = ctx.Int64("end")
+	if ctx.IsSet("duration") {
+		options.Miner.TimingMatcher.Duration = ctx.Int64("duration")
+	}
+	if ctx.IsSet("strategy") {
+		options.Miner.TimingMatcher.SelectionStrategy = ctx.String("strategy")
+	}
+	if ctx.IsSet("cvs-threshold") {
+		options.Miner.RateRatioCVSThreshold = ctx.Int64("cvs-threshold")
+	}
+	if ctx.IsSet("subsidy") {
+		options.Miner.Subsidy = ctx.Float64("subsidy")
+	}
+	if ctx.IsSet("wallet-split") {
+		options.Miner.WalletSplit = ctx.Float64("wallet-split")
+	}
+	options.GasPrice = big.NewInt(options.Miner.MinGasLimit)
+	if ctx.IsSet("gas-price") {
+		if gasPrice, ok := new(big.Int).SetString(ctx.String("gas-price"), 0); !ok {
+			utils.ExitWithErr(ctx.App.Writer, fmt.Errorf("invalid gas price:%s", ctx.String("gas-price")))
+		} else {
+			options.GasPrice = gasPrice
+		}
+	}
+
+	prices, err := simulator.LoadPrices(ctx.String("prices"))
+	if nil != err {
+		utils.ExitWithErr(ctx.App.Writer, err)
+	}
+	options.LrcAddress = tokenAddressOfPrices(ctx.String("lrc"), "LRC", prices)
+	options.EthAddress = tokenAddressOfPrices(ctx.String("weth"), "WETH", prices)
+
+	orderModels, err := simulator.LoadOrders(ctx.String("orders"))
+	if nil != err {
+		utils.ExitWithErr(ctx.App.Writer, err)
+	}
+	orders, err := simulator.ConvertOrders(orderModels)
+	if nil != err {
+		utils.ExitWithErr(ctx.App.Writer, err)
+	}
+
+	fills := []*dao.FillEvent{}
+	if "" != ctx.String("fills") {
+		if fills, err = simulator.LoadFills(ctx.String("fills")); nil != err {
+			utils.ExitWithErr(ctx.App.Writer, err)
+		}
+	}
+
+	balances := []*simulator.Balance{}
+	if "" != ctx.String("balances") {
+		if balances, err = simulator.LoadBalances(ctx.String("balances")); nil != err {
+			utils.ExitWithErr(ctx.App.Writer, err)
+		}
+	}
+
+	s, err := simulator.NewSimulator(options, orders, fills, prices, balances)
+	if nil != err {
+		utils.ExitWithErr(ctx.App.Writer, err)
+	}
+	report := s.Run()
+
+	if ctx.Bool("json") {
+		if data, err := json.MarshalIndent(report, "", "  "); nil != err {
+			utils.ExitWithErr(ctx.App.Writer, err)
+		} else {
+			fmt.Fprintln(ctx.App.Writer, string(data))
+		}
+	} else {
+		report.Print(ctx.App.Writer, ctx.Bool("rings"))
+	}
+}
+
+func tokenAddressOfPrices(address, symbol string, prices []*simulator.TokenPrice) common.Address {
+	if common.IsHexAddress(address) {
+		return common.HexToAddress(address)
+	}
+	for _, price := range prices {
+		if strings.ToUpper(price.Symbol) == symbol {
+			return common.HexToAddress(price.Address)
+		}
+	}
+	return common.Address{}
+}
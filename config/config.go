@@ -88,18 +88,59 @@ type GlobalConfig struct {
 	MarketCap      MarketCapOptions
 	UserManager    UserManagerOptions
 	AccountManager AccountManagerOptions
+	EventEmitter   EventEmitterOptions
+	Metrics        MetricsOptions
+	Admin          AdminOptions
+	RateLimit      RateLimitOptions
+	Rest           RestOptions
+}
+
+type AdminOptions struct {
+	Host  string //the listen address, default is 127.0.0.1, it should be exposed only to a trusted network
+	Port  string //the health and admin endpoints are disabled if it is empty
+	Token string //the admin api is disabled if it is empty
+}
+
+type MetricsOptions struct {
+	Port string //the /metrics endpoint is disabled if it is empty
+}
+
+type EventEmitterOptions struct {
+	DurableDir      string //the events of DurableTopics are persisted in it, durable is disabled if it is empty
+	DurableTopics   []string
+	RetryInterval   int64 //seconds between redeliveries of the failed events
+	MaxRedeliveries int   //the event is skipped after failed so many times, 0 means never
+	FlushInterval   int64 //seconds between syncing the events and offsets to disk, the latest ones may be lost if the machine crashes
+	SegmentSize     int64 //MB of every log file, the file is deleted after all the subscribers have consumed it
+	Retention       int64 //hours the log file is kept even though it isn't consumed, 0 means forever
 }
 
 type AccountManagerOptions struct {
 	CacheDuration int64
 }
 
+type RateLimitOptions struct {
+	IpCapacity       int64            //max tokens of the bucket of each ip, 0 means no limit
+	IpRefillRate     float64          //tokens added to the bucket per second
+	ApiKeyCapacity   int64            //max tokens of the bucket of each api key, 0 means no limit
+	ApiKeyRefillRate float64          //tokens added to the bucket per second
+	ApiKeys          []string         //the clients with unknown api key are limited by ip
+	TrustProxy       bool             //use the address appended by trusted proxy to X-Forwarded-For as the ip of client
+	ProxyHops        int              //number of trusted proxies in front of relay, the ip of client is the ProxyHops-th address from the right of X-Forwarded-For, default is 1
+	MethodCosts      map[string]int64 //tokens consumed by the method of WalletServiceImpl, such as GetDepth, default is 1
+}
+
 type JsonrpcOptions struct {
 	Port string
 }
 
+type RestOptions struct {
+	Port string //the rest api under /v2 is disabled if it is empty
+}
+
 type WebsocketOptions struct {
-	Port string
+	Port   string //socketio
+	WsPort string //plain websocket, it is disabled if it is empty
 }
 
 func (c *GlobalConfig) defaultConfig() {
@@ -130,6 +171,17 @@ func (opts IpfsOptions) Url() string {
 type AccessorOptions struct {
 	RawUrls           []string `required:"true"`
 	FetchTxRetryCount int
+	ChainId           int64 //eth_chainId is called if it's not set
+	GasOracle         GasOracleOptions
+}
+
+type GasOracleOptions struct {
+	Type             string  //block_sample, fee_history or fixed, default is block_sample
+	FeeHistoryBlocks int     //the count of blocks used by fee_history
+	RewardPercentile float64 //the percentile of priority fees used as tip by fee_history
+	GasPrice         int64   //the gas price of fixed, it's used as base fee if TipCap is set
+	TipCap           int64   //the priority fee of fixed
+	DynamicFeeTx     bool    //send type-2 txs if the chain supports eip-1559
 }
 
 type ExtractorOptions struct {
@@ -139,6 +191,11 @@ type ExtractorOptions struct {
 	ForkWaitingTime    int64
 	Debug              bool
 	Open               bool
+	Mode               string //block or logs, logs mode only fetches the txs which emit the events or call the methods of loaded contracts
+	LogBlockRange      int    //number of blocks fetched by one eth_getLogs in logs mode
+	BackfillWorkers    int    //blocks fetched concurrently while catching up in block mode, backfill is disabled if it's less than 2
+	BackfillWindow     int    //max number of blocks fetched but not committed
+	FinalityDepth      uint64 //blocks deeper than it below the best block are final, the deeper chain fork isn't rolled back
 }
 
 type KeyStoreOptions struct {
@@ -173,6 +230,8 @@ type TimingMatcher struct {
 	DelayedNumber                int64
 	MaxCacheRoundsLength         int
 	LagForCleanSubmitCacheBlocks int64
+	SelectionStrategy            string //greedy, max_volume, round_robin or optimal, default is greedy
+	SelectionBatchSize           int    //the max count of candidate rings that optimal strategy can search, default is 12
 }
 
 type PercentMinerAddress struct {
@@ -206,13 +265,34 @@ type MarketOptions struct {
 	TokenFile             string
 	OldVersionWethAddress string
 	CronJobLock           bool
+	Exchanges             []ExchangeOptions //binance, okex and huobi are used if it's empty
+}
+
+type ExchangeOptions struct {
+	Name       string  //the name of registered exchange adapter
+	BaseUrl    string  //the default url of adapter is used if it's empty
+	Interval   int     //seconds between syncing tickers
+	RateLimit  float64 //max requests per second
+	RetryCount int     //the times of retrying failed request
+	Timeout    int     //seconds of each request
 }
 
 type MarketCapOptions struct {
-	BaseUrl  string
-	Currency string
-	Duration int
-	IsSync   bool
+	BaseUrl     string
+	Currency    string
+	Duration    int
+	IsSync      bool
+	Provider    string //coinmarketcap or aggregate, default is coinmarketcap
+	Aggregation string //median or weighted, it's used by aggregate
+	MinFeeds    int    //the price is rejected if the count of feeds having fresh price is less than it, WETH requires at most the count of feeds quoted by currency
+	Feeds       []PriceFeedOptions
+}
+
+type PriceFeedOptions struct {
+	Type   string  //coinmarketcap, exchange, fill or file
+	Weight float64 //the weight of prices used by weighted aggregation
+	MaxAge int64   //seconds, the price updated before it is stale, 0 means never
+	File   string  //the price file of file feed, it's the format of coinmarketcap
 }
 
 type GatewayFiltersOptions struct {
@@ -234,9 +314,18 @@ type GatewayFiltersOptions struct {
 type GateWayOptions struct {
 	IsBroadcast      bool
 	MaxBroadcastTime int
+	Broadcaster      string //only ipfs is supported now, default is ipfs
+	SeenTtl          int    //seconds, order hashes seen within it will not be emitted or forwarded again
+	Session          SessionOptions
+}
+
+type SessionOptions struct {
+	ChallengeTtl int64 //seconds, a challenge can be used once in it
+	TokenTtl     int64 //seconds
 }
 
 type MysqlOptions struct {
+	Driver             string //mysql or sqlite3, default is mysql. sqlite3 requires building with -tags sqlite3, DbName is the path of database file for it and ":memory:" is supported
 	Hostname           string
 	Port               string
 	User               string
@@ -250,6 +339,7 @@ type MysqlOptions struct {
 }
 
 type RedisOptions struct {
+	Engine      string //redis or memory, default is redis. memory is only used by tests and the relay of single node
 	Host        string
 	Port        string
 	Password    string
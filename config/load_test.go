@@ -0,0 +1,29 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package config
+
+import "testing"
+
+func TestLoadConfig(t *testing.T) {
+	c := LoadConfig("relay.toml")
+	//the shipped relay.toml should be decoded into the float fields
+	if 20 != c.RateLimit.IpRefillRate || 200 != c.RateLimit.ApiKeyRefillRate {
+		t.Fatalf("refill rates of rate_limit should be loaded, got:%+v", c.RateLimit)
+	}
+}
@@ -88,6 +88,10 @@ func (c EthKSCrypto) Sign(hashPre []byte, signerAddr common.Address) ([]byte, er
 	return c.ks.SignHash(signer, hash)
 }
 
+func (c EthKSCrypto) SignHash(hash []byte, signerAddr common.Address) ([]byte, error) {
+	return c.ks.SignHash(accounts.Account{Address: signerAddr}, hash)
+}
+
 func (c EthKSCrypto) UnlockAccount(acc accounts.Account, passphrase string) error {
 	c.unlockedAccounts[acc.Address] = true
 	return c.ks.Unlock(acc, passphrase)
@@ -117,6 +121,10 @@ func (c EthPrivateKeyCrypto) Sign(hashPre []byte, signerAddr common.Address) ([]
 	return ethCrypto.Sign(hash, c.privateKey)
 }
 
+func (c EthPrivateKeyCrypto) SignHash(hash []byte, signerAddr common.Address) ([]byte, error) {
+	return ethCrypto.Sign(hash, c.privateKey)
+}
+
 func (c EthPrivateKeyCrypto) Address() common.Address {
 	return ethCrypto.PubkeyToAddress(c.privateKey.PublicKey)
 }
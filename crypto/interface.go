@@ -33,6 +33,8 @@ type Crypto interface {
 	GenerateHash(data ...[]byte) []byte
 	//签名
 	Sign(hash []byte, signer common.Address) ([]byte, error)
+	//对hash直接签名，不添加以太坊消息前缀，用于签名交易
+	SignHash(hash []byte, signer common.Address) ([]byte, error)
 	//签名恢复到地址
 	SigToAddress(hash, sig []byte) ([]byte, error)
 	//生成sig
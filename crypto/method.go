@@ -40,6 +40,10 @@ func Sign(hash []byte, signer common.Address) ([]byte, error) {
 	return crypto.Sign(hash, signer)
 }
 
+func SignHash(hash []byte, signer common.Address) ([]byte, error) {
+	return crypto.SignHash(hash, signer)
+}
+
 func SigToAddress(hash, sig []byte) ([]byte, error) {
 	return crypto.SigToAddress(hash, sig)
 }
@@ -18,10 +18,14 @@
 
 package dao
 
-import "qiniupkg.com/x/errors.v7"
+import (
+	"qiniupkg.com/x/errors.v7"
+	"time"
+)
 
 const (
-	TrendUpdateType = "last_trend__proof_time"
+	TrendUpdateType       = "last_trend__proof_time"
+	ExtractorBackfillType = "extractor_backfill_block"
 )
 
 // common check point table
@@ -43,3 +47,14 @@ func (s *RdsServiceImpl) QueryCheckPointByType(businessType string) (point Check
 		return points[0], nil
 	}
 }
+
+//SaveCheckPoint creates the check point of businessType if it doesn't exist
+func (s *RdsServiceImpl) SaveCheckPoint(businessType string, checkPoint int64) error {
+	point, err := s.QueryCheckPointByType(businessType)
+	if err != nil {
+		point = CheckPoint{BusinessType: businessType, CreateTime: time.Now().Unix()}
+	}
+	point.CheckPoint = checkPoint
+	point.ModifyTime = time.Now().Unix()
+	return s.db.Save(&point).Error
+}
@@ -26,6 +26,9 @@ import (
 	"time"
 )
 
+//sqlite3Supported is set by sqlite.go, which is only built with -tags sqlite3 because go-sqlite3 requires cgo
+var sqlite3Supported = false
+
 type PageResult struct {
 	Data      []interface{} `json:"data"`
 	PageIndex int           `json:"pageIndex"`
@@ -46,10 +49,28 @@ func NewRdsService(options config.MysqlOptions) *RdsServiceImpl {
 		return options.TablePrefix + defaultTableName
 	}
 
-	url := options.User + ":" + options.Password + "@tcp(" + options.Hostname + ":" + options.Port + ")/" + options.DbName + "?charset=utf8&parseTime=True"
-	db, err := gorm.Open("mysql", url)
+	var (
+		db  *gorm.DB
+		err error
+	)
+	switch options.Driver {
+	case "", "mysql":
+		url := options.User + ":" + options.Password + "@tcp(" + options.Hostname + ":" + options.Port + ")/" + options.DbName + "?charset=utf8&parseTime=True"
+		db, err = gorm.Open("mysql", url)
+	case "sqlite3":
+		if !sqlite3Supported {
+			log.Fatalf("sqlite3 is not supported, the relay should be built with -tags sqlite3")
+		}
+		db, err = gorm.Open("sqlite3", options.DbName)
+		//sqlite only allows one writer, and every connection of ":memory:" opens a new database
+		options.MaxOpenConnections = 1
+		options.MaxIdleConnections = 1
+		options.ConnMaxLifetime = 0
+	default:
+		log.Fatalf("unsupported database driver:%s", options.Driver)
+	}
 	if err != nil {
-		log.Fatalf("mysql connection error:%s", err.Error())
+		log.Fatalf("%s connection error:%s", options.Driver, err.Error())
 	}
 
 	db.DB().SetConnMaxLifetime(time.Duration(options.ConnMaxLifetime) * time.Second)
@@ -63,37 +84,18 @@ func NewRdsService(options config.MysqlOptions) *RdsServiceImpl {
 	return impl
 }
 
+//Ping checks whether the database is reachable
+func (s *RdsServiceImpl) Ping() error {
+	return s.db.DB().Ping()
+}
+
+//Prepare creates tables and migrates schema to the latest version
 func (s *RdsServiceImpl) Prepare() {
-	var tables []interface{}
-
-	// create tables if not exists
-	tables = append(tables, &Order{})
-	tables = append(tables, &Block{})
-	tables = append(tables, &RingMinedEvent{})
-	tables = append(tables, &FillEvent{})
-	tables = append(tables, &CancelEvent{})
-	tables = append(tables, &CutOffEvent{})
-	tables = append(tables, &CutOffPairEvent{})
-	tables = append(tables, &Trend{})
-	tables = append(tables, &WhiteList{})
-	tables = append(tables, &RingSubmitInfo{})
-	tables = append(tables, &FilledOrder{})
-	tables = append(tables, &Transaction{})
-	tables = append(tables, &TransactionEntity{})
-	tables = append(tables, &TransactionView{})
-	tables = append(tables, &CheckPoint{})
-	//tables = append(tables, &RingMinedMethod{})
-
-	for _, t := range tables {
-		if ok := s.db.HasTable(t); !ok {
-			if err := s.db.CreateTable(t).Error; err != nil {
-				log.Fatalf("create mysql table error:%s", err.Error())
-			}
+	if applied, err := s.Migrate(0); nil != err {
+		log.Fatalf("migrate database error:%s", err.Error())
+	} else {
+		for _, m := range applied {
+			log.Infof("database migrated to version:%d, %s", m.Version, m.Description)
 		}
 	}
-
-	// auto migrate to keep schema update to date
-	// AutoMigrate will ONLY create tables, missing columns and missing indexes,
-	// and WON'T change existing column's type or delete unused columns to protect your data
-	s.db.AutoMigrate(tables...)
 }
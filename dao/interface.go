@@ -26,8 +26,12 @@ import (
 )
 
 type RdsService interface {
-	// create tables
+	// create tables and migrate schema
 	Prepare()
+	Migrate(target int64) ([]*Migration, error)
+	Rollback(steps int) ([]*Migration, error)
+	MigrationStatus() ([]MigrationState, error)
+	Ping() error
 
 	// base functions
 	Add(item interface{}) error
@@ -50,6 +54,7 @@ type RdsService interface {
 	GetCutoffPairOrders(owner, token1, token2 common.Address, cutoffTime *big.Int) ([]Order, error)
 	SetCutOffOrders(orderHashList []common.Hash, blockNumber *big.Int) error
 	GetOrderBook(protocol, tokenS, tokenB common.Address, length int) ([]Order, error)
+	GetActiveOrderBook(delegate, tokenS, tokenB common.Address, fromId, limit int) ([]Order, error)
 	OrderPageQuery(query map[string]interface{}, statusList []int, pageIndex, pageSize int) (PageResult, error)
 	UpdateBroadcastTimeByHash(hash string, bt int) error
 	UpdateOrderWhileRollbackCutoff(orderhash common.Hash, status types.OrderStatus, blockNumber *big.Int) error
@@ -104,6 +109,8 @@ type RdsService interface {
 	//UpdateRingSubmitInfoFailed(ringhashs []common.Hash, err string) error
 
 	UpdateRingSubmitInfoResult(submitResult *types.RingSubmitResultEvent) error
+	UpdateRingSubmitInfoReplaced(txHash, replacedBy common.Hash) error
+	GetPendingRingSubmitInfos(miner common.Address) ([]*RingSubmitInfo, error)
 	GetRingForSubmitByHash(ringhash common.Hash) (RingSubmitInfo, error)
 	GetRingHashesByTxHash(txHash common.Hash) ([]*RingSubmitInfo, error)
 	RingMinedPageQuery(query map[string]interface{}, pageIndex, pageSize int) (res PageResult, err error)
@@ -133,4 +140,5 @@ type RdsService interface {
 
 	// checkpoint
 	QueryCheckPointByType(businessType string) (point CheckPoint, err error)
+	SaveCheckPoint(businessType string, checkPoint int64) error
 }
@@ -0,0 +1,228 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+/**
+表结构的每次修改都要注册一个新的Migration，版本号递增，不要修改已经发布的Migration
+Migration中不要使用当前的model，要使用当时表结构的副本(例如 migration_v1.go)，否则model修改后同一个版本会建出不同的表
+mysql的DDL会隐式提交事务，所以Up和Down不在同一个事务中执行，每个Migration要保证可以重复执行，
+需要修改数据的Migration可以在Up或Down中自己开启事务。执行成功后在schema_versions中记录或删除对应的版本
+*/
+
+//Migration is a versioned step of schema, Down can be nil if it can't be rolled back
+type Migration struct {
+	Version     int64
+	Description string
+	Up          func(db *gorm.DB) error
+	Down        func(db *gorm.DB) error
+}
+
+//SchemaVersion records the migrations have been applied
+type SchemaVersion struct {
+	ID          int    `gorm:"column:id;primary_key;"`
+	Version     int64  `gorm:"column:version;type:bigint;unique_index"`
+	Description string `gorm:"column:description;type:varchar(200)"`
+	AppliedAt   int64  `gorm:"column:applied_at;type:bigint"`
+}
+
+type MigrationState struct {
+	Version     int64
+	Description string
+	Applied     bool
+	AppliedAt   int64
+}
+
+var (
+	migrations    []*Migration
+	migrationsMtx sync.RWMutex
+)
+
+//RegisterMigration must be called in init, it panics if the version has been registered
+func RegisterMigration(migration *Migration) {
+	migrationsMtx.Lock()
+	defer migrationsMtx.Unlock()
+
+	if migration.Version <= 0 || nil == migration.Up {
+		panic(fmt.Sprintf("invalid migration, version:%d", migration.Version))
+	}
+	for _, m := range migrations {
+		if m.Version == migration.Version {
+			panic(fmt.Sprintf("migration version:%d has been registered", migration.Version))
+		}
+	}
+	migrations = append(migrations, migration)
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+}
+
+//Migrations returns all the registered migrations ordered by version
+func Migrations() []*Migration {
+	migrationsMtx.RLock()
+	defer migrationsMtx.RUnlock()
+
+	res := make([]*Migration, len(migrations))
+	copy(res, migrations)
+	return res
+}
+
+func (s *RdsServiceImpl) appliedVersions() (map[int64]SchemaVersion, error) {
+	if err := s.db.AutoMigrate(&SchemaVersion{}).Error; nil != err {
+		return nil, err
+	}
+	var versions []SchemaVersion
+	if err := s.db.Order("version").Find(&versions).Error; nil != err {
+		return nil, err
+	}
+	res := make(map[int64]SchemaVersion)
+	for _, v := range versions {
+		res[v.Version] = v
+	}
+	return res, nil
+}
+
+//Migrate applies the pending migrations whose version isn't greater than target, all of them if target is 0
+func (s *RdsServiceImpl) Migrate(target int64) ([]*Migration, error) {
+	applied, err := s.appliedVersions()
+	if nil != err {
+		return nil, err
+	}
+
+	res := []*Migration{}
+	for _, m := range Migrations() {
+		if target > 0 && m.Version > target {
+			break
+		}
+		if _, exists := applied[m.Version]; exists {
+			continue
+		}
+		if err := m.Up(s.db); nil != err {
+			return res, fmt.Errorf("migrate to version:%d error:%s", m.Version, err.Error())
+		}
+		version := &SchemaVersion{Version: m.Version, Description: m.Description, AppliedAt: time.Now().Unix()}
+		if err := s.db.Create(version).Error; nil != err {
+			return res, err
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+//Rollback reverts the latest applied migrations by steps
+func (s *RdsServiceImpl) Rollback(steps int) ([]*Migration, error) {
+	applied, err := s.appliedVersions()
+	if nil != err {
+		return nil, err
+	}
+	registered := make(map[int64]*Migration)
+	for _, m := range Migrations() {
+		registered[m.Version] = m
+	}
+	versions := []int64{}
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+	//checks all the steps before reverting, so an irreversible version stops the rollback before any change
+	reverting := []*Migration{}
+	for i := 0; i < steps && i < len(versions); i++ {
+		m, exists := registered[versions[i]]
+		if !exists {
+			return nil, fmt.Errorf("version:%d isn't registered in this release", versions[i])
+		}
+		if nil == m.Down {
+			return nil, fmt.Errorf("version:%d can't be rolled back", m.Version)
+		}
+		reverting = append(reverting, m)
+	}
+
+	res := []*Migration{}
+	for _, m := range reverting {
+		if err := m.Down(s.db); nil != err {
+			return res, fmt.Errorf("rollback version:%d error:%s", m.Version, err.Error())
+		}
+		if err := s.db.Where("version = ?", m.Version).Delete(&SchemaVersion{}).Error; nil != err {
+			return res, err
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+//MigrationStatus returns the states of registered migrations and the applied ones unknown by this release
+func (s *RdsServiceImpl) MigrationStatus() ([]MigrationState, error) {
+	applied, err := s.appliedVersions()
+	if nil != err {
+		return nil, err
+	}
+
+	res := []MigrationState{}
+	for _, m := range Migrations() {
+		state := MigrationState{Version: m.Version, Description: m.Description}
+		if v, exists := applied[m.Version]; exists {
+			state.Applied = true
+			state.AppliedAt = v.AppliedAt
+			delete(applied, m.Version)
+		}
+		res = append(res, state)
+	}
+	for _, v := range applied {
+		res = append(res, MigrationState{Version: v.Version, Description: v.Description, Applied: true, AppliedAt: v.AppliedAt})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Version < res[j].Version })
+	return res, nil
+}
+
+func init() {
+	//the tables created by Prepare before migration is introduced are kept,
+	//AutoMigrate only adds the missing columns and indexes.
+	//it can't be rolled back, dropping the base tables deletes all the data of relay
+	RegisterMigration(&Migration{
+		Version:     1,
+		Description: "create base tables",
+		Up: func(db *gorm.DB) error {
+			tables := v1Tables()
+			for _, t := range tables {
+				if ok := db.HasTable(t); !ok {
+					if err := db.CreateTable(t).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return db.AutoMigrate(tables...).Error
+		},
+	})
+
+	RegisterMigration(&Migration{
+		Version:     2,
+		Description: "add nonce and replacement of ring submit tx",
+		Up:          migrateV2Up,
+		Down:        migrateV2Down,
+	})
+}
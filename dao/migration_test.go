@@ -0,0 +1,141 @@
+//go:build sqlite3
+// +build sqlite3
+
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao_test
+
+import (
+	"testing"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/dao"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jinzhu/gorm"
+)
+
+func TestRdsServiceImpl_Migrate(t *testing.T) {
+	s := newSqliteService()
+
+	type memo struct {
+		ID   int    `gorm:"column:id;primary_key;"`
+		Memo string `gorm:"column:memo;type:text"`
+	}
+	dao.RegisterMigration(&dao.Migration{
+		Version:     1000,
+		Description: "create memo table",
+		Up: func(db *gorm.DB) error {
+			return db.AutoMigrate(&memo{}).Error
+		},
+		Down: func(db *gorm.DB) error {
+			return db.DropTableIfExists(&memo{}).Error
+		},
+	})
+
+	if states, err := s.MigrationStatus(); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	} else if len(states) != len(dao.Migrations()) || !states[0].Applied || states[len(states)-1].Applied {
+		t.Fatalf("unexpected states:%+v", states)
+	}
+
+	if applied, err := s.Migrate(0); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	} else if len(applied) != 1 || applied[0].Version != 1000 {
+		t.Fatalf("unexpected applied migrations:%d", len(applied))
+	}
+	if err := s.Add(&memo{Memo: "migrated"}); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if applied, _ := s.Migrate(0); len(applied) != 0 {
+		t.Fatalf("migration should only be applied once")
+	}
+
+	if reverted, err := s.Rollback(1); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	} else if len(reverted) != 1 || reverted[0].Version != 1000 {
+		t.Fatalf("unexpected reverted migrations:%d", len(reverted))
+	}
+	if states, _ := s.MigrationStatus(); states[len(states)-1].Applied {
+		t.Fatalf("version 1000 should be pending after rollback")
+	}
+	if err := s.Add(&memo{Memo: "rollback"}); nil == err {
+		t.Fatalf("memo table should be dropped")
+	}
+}
+
+func TestRdsServiceImpl_MigrateVersions(t *testing.T) {
+	newSqliteService()
+	s := dao.NewRdsService(config.MysqlOptions{Driver: "sqlite3", DbName: ":memory:", TablePrefix: "lpr_"})
+
+	if _, err := s.Migrate(1); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if err := s.Add(&dao.Order{OrderHash: "0x01"}); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if err := s.Add(&dao.RingSubmitInfo{RingHash: "0x01", Nonce: "1"}); nil == err {
+		t.Fatalf("the columns added by version 2 shouldn't be created by version 1")
+	}
+
+	if _, err := s.Migrate(2); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if err := s.Add(&dao.RingSubmitInfo{RingHash: "0x01", Nonce: "1"}); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+
+	if reverted, err := s.Rollback(2); nil == err || len(reverted) != 0 {
+		t.Fatalf("rollback reaching version 1 should be refused before reverting any version")
+	}
+	if states, _ := s.MigrationStatus(); !states[0].Applied || !states[1].Applied {
+		t.Fatalf("unexpected states:%+v", states)
+	}
+}
+
+func TestRdsServiceImpl_RollbackVersion2(t *testing.T) {
+	newSqliteService()
+	s := dao.NewRdsService(config.MysqlOptions{Driver: "sqlite3", DbName: ":memory:", TablePrefix: "lpr_"})
+
+	ringhash := common.HexToHash("0x02")
+	if _, err := s.Migrate(2); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if err := s.Add(&dao.RingSubmitInfo{RingHash: ringhash.Hex(), Miner: "0xa1", Nonce: "1"}); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+
+	//sqlite can't drop columns, the table is rebuilt with the data
+	if reverted, err := s.Rollback(1); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	} else if len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Fatalf("unexpected reverted migrations:%d", len(reverted))
+	}
+	if err := s.Add(&dao.RingSubmitInfo{RingHash: ringhash.Hex(), Nonce: "2"}); nil == err {
+		t.Fatalf("the columns added by version 2 should be dropped")
+	}
+
+	if _, err := s.Migrate(2); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if info, err := s.GetRingForSubmitByHash(ringhash); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	} else if info.Miner != "0xa1" || info.Nonce != "" {
+		t.Fatalf("the data of version 1 columns should be kept, miner:%s, nonce:%s", info.Miner, info.Nonce)
+	}
+}
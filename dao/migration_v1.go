@@ -0,0 +1,375 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import (
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+/**
+版本1的表结构，是引入Migration之前Prepare创建的表，从当时的model复制而来，之后修改model不要修改这里
+表名与model相同，json等与表结构无关的tag已去掉；Order的side和order_type原来的tag格式错误，gorm按照字段名和默认类型建列，这里保持一致
+*/
+
+type orderV1 struct {
+	ID                    int     `gorm:"column:id;primary_key;"`
+	Protocol              string  `gorm:"column:protocol;type:varchar(42)"`
+	DelegateAddress       string  `gorm:"column:delegate_address;type:varchar(42)"`
+	Owner                 string  `gorm:"column:owner;type:varchar(42)"`
+	AuthAddress           string  `gorm:"column:auth_address;type:varchar(42)"`
+	PrivateKey            string  `gorm:"column:priv_key;type:varchar(128)"`
+	WalletAddress         string  `gorm:"column:wallet_address;type:varchar(42)"`
+	OrderHash             string  `gorm:"column:order_hash;type:varchar(82)"`
+	TokenS                string  `gorm:"column:token_s;type:varchar(42)"`
+	TokenB                string  `gorm:"column:token_b;type:varchar(42)"`
+	AmountS               string  `gorm:"column:amount_s;type:varchar(40)"`
+	AmountB               string  `gorm:"column:amount_b;type:varchar(40)"`
+	CreateTime            int64   `gorm:"column:create_time;type:bigint"`
+	ValidSince            int64   `gorm:"column:valid_since;type:bigint"`
+	ValidUntil            int64   `gorm:"column:valid_until;type:bigint"`
+	LrcFee                string  `gorm:"column:lrc_fee;type:varchar(40)"`
+	BuyNoMoreThanAmountB  bool    `gorm:"column:buy_nomore_than_amountb"`
+	MarginSplitPercentage uint8   `gorm:"column:margin_split_percentage;type:tinyint(4)"`
+	V                     uint8   `gorm:"column:v;type:tinyint(4)"`
+	R                     string  `gorm:"column:r;type:varchar(66)"`
+	S                     string  `gorm:"column:s;type:varchar(66)"`
+	PowNonce              uint64  `gorm:"column:pow_nonce;type:bigint"`
+	Price                 float64 `gorm:"column:price;type:decimal(28,16);"`
+	UpdatedBlock          int64   `gorm:"column:updated_block;type:bigint"`
+	DealtAmountS          string  `gorm:"column:dealt_amount_s;type:varchar(40)"`
+	DealtAmountB          string  `gorm:"column:dealt_amount_b;type:varchar(40)"`
+	CancelledAmountS      string  `gorm:"column:cancelled_amount_s;type:varchar(40)"`
+	CancelledAmountB      string  `gorm:"column:cancelled_amount_b;type:varchar(40)"`
+	SplitAmountS          string  `gorm:"column:split_amount_s;type:varchar(40)"`
+	SplitAmountB          string  `gorm:"column:split_amount_b;type:varchar(40)"`
+	Status                uint8   `gorm:"column:status;type:tinyint(4)"`
+	MinerBlockMark        int64   `gorm:"column:miner_block_mark;type:bigint"`
+	BroadcastTime         int     `gorm:"column:broadcast_time;type:bigint"`
+	Market                string  `gorm:"column:market;type:varchar(40)"`
+	Side                  string
+	OrderType             string
+}
+
+func (orderV1) TableName(db *gorm.DB) string { return gorm.DefaultTableNameHandler(db, "orders") }
+
+type blockV1 struct {
+	ID          int    `gorm:"column:id;primary_key"`
+	BlockNumber int64  `gorm:"column:block_number;type:bigint"`
+	BlockHash   string `gorm:"column:block_hash;type:varchar(82)"`
+	ParentHash  string `gorm:"column:parent_hash;type:varchar(82)"`
+	CreateTime  int64  `gorm:"column:create_time"`
+	Fork        bool   `gorm:"column:fork;"`
+}
+
+func (blockV1) TableName(db *gorm.DB) string { return gorm.DefaultTableNameHandler(db, "blocks") }
+
+type ringMinedEventV1 struct {
+	ID                 int    `gorm:"column:id;primary_key"`
+	Protocol           string `gorm:"column:contract_address;type:varchar(42)"`
+	DelegateAddress    string `gorm:"column:delegate_address;type:varchar(42)"`
+	RingIndex          string `gorm:"column:ring_index;type:varchar(40)"`
+	RingHash           string `gorm:"column:ring_hash;type:varchar(82)"`
+	TxHash             string `gorm:"column:tx_hash;type:varchar(82)"`
+	Miner              string `gorm:"column:miner;type:varchar(42);"`
+	FeeRecipient       string `gorm:"column:fee_recipient;type:varchar(42)"`
+	IsRinghashReserved bool   `gorm:"column:is_ring_hash_reserved;"`
+	BlockNumber        int64  `gorm:"column:block_number;type:bigint"`
+	TotalLrcFee        string `gorm:"column:total_lrc_fee;type:varchar(40)"`
+	TradeAmount        int    `gorm:"column:trade_amount"`
+	Time               int64  `gorm:"column:time;type:bigint"`
+	Fork               bool   `gorm:"column:fork"`
+	Status             uint8  `gorm:"column:status;type:tinyint(4)"`
+	GasLimit           string `gorm:"column:gas_limit;type:varchar(50)"`
+	GasUsed            string `gorm:"column:gas_used;type:varchar(50)"`
+	GasPrice           string `gorm:"column:gas_price;type:varchar(50)"`
+	Err                string `gorm:"column:err;type:text"`
+}
+
+func (ringMinedEventV1) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "ring_mined_events")
+}
+
+type fillEventV1 struct {
+	ID              int    `gorm:"column:id;primary_key;"`
+	Protocol        string `gorm:"column:contract_address;type:varchar(42)"`
+	DelegateAddress string `gorm:"column:delegate_address;type:varchar(42)"`
+	Owner           string `gorm:"column:owner;type:varchar(42)"`
+	RingIndex       int64  `gorm:"column:ring_index;"`
+	BlockNumber     int64  `gorm:"column:block_number"`
+	CreateTime      int64  `gorm:"column:create_time"`
+	RingHash        string `gorm:"column:ring_hash;varchar(82)"`
+	FillIndex       int64  `gorm:"column:fill_index"`
+	TxHash          string `gorm:"column:tx_hash;type:varchar(82)"`
+	PreOrderHash    string `gorm:"column:pre_order_hash;varchar(82)"`
+	NextOrderHash   string `gorm:"column:next_order_hash;varchar(82)"`
+	OrderHash       string `gorm:"column:order_hash;type:varchar(82)"`
+	AmountS         string `gorm:"column:amount_s;type:varchar(40)"`
+	AmountB         string `gorm:"column:amount_b;type:varchar(40)"`
+	TokenS          string `gorm:"column:token_s;type:varchar(42)"`
+	TokenB          string `gorm:"column:token_b;type:varchar(42)"`
+	LrcReward       string `gorm:"column:lrc_reward;type:varchar(40)"`
+	LrcFee          string `gorm:"column:lrc_fee;type:varchar(40)"`
+	SplitS          string `gorm:"column:split_s;type:varchar(40)"`
+	SplitB          string `gorm:"column:split_b;type:varchar(40)"`
+	Market          string `gorm:"column:market;type:varchar(42)"`
+	LogIndex        int64  `gorm:"column:log_index"`
+	Fork            bool   `gorm:"column:fork"`
+	Side            string `gorm:"column:side"`
+	OrderType       string `gorm:"column:order_type"`
+}
+
+func (fillEventV1) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "fill_events")
+}
+
+type cancelEventV1 struct {
+	ID              int    `gorm:"column:id;primary_key;"`
+	Protocol        string `gorm:"column:contract_address;type:varchar(42)"`
+	DelegateAddress string `gorm:"column:delegate_address;type:varchar(42)"`
+	OrderHash       string `gorm:"column:order_hash;type:varchar(82)"`
+	TxHash          string `gorm:"column:tx_hash;type:varchar(82)"`
+	BlockNumber     int64  `gorm:"column:block_number"`
+	CreateTime      int64  `gorm:"column:create_time"`
+	AmountCancelled string `gorm:"column:amount_cancelled;type:varchar(40)"`
+	LogIndex        int64  `gorm:"column:log_index"`
+	Fork            bool   `gorm:"column:fork"`
+}
+
+func (cancelEventV1) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "cancel_events")
+}
+
+type cutOffEventV1 struct {
+	ID              int    `gorm:"column:id;primary_key;"`
+	Protocol        string `gorm:"column:contract_address;type:varchar(42)"`
+	DelegateAddress string `gorm:"column:delegate_address;type:varchar(42)"`
+	Owner           string `gorm:"column:owner;type:varchar(42)"`
+	TxHash          string `gorm:"column:tx_hash;type:varchar(82)"`
+	OrderHashList   string `gorm:"column:order_hash_list;type:text"`
+	BlockNumber     int64  `gorm:"column:block_number"`
+	Cutoff          int64  `gorm:"column:cutoff"`
+	LogIndex        int64  `gorm:"column:log_index"`
+	Fork            bool   `gorm:"column:fork"`
+	CreateTime      int64  `gorm:"column:create_time"`
+}
+
+func (cutOffEventV1) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "cut_off_events")
+}
+
+type cutOffPairEventV1 struct {
+	ID              int    `gorm:"column:id;primary_key;"`
+	Protocol        string `gorm:"column:contract_address;type:varchar(42)"`
+	DelegateAddress string `gorm:"column:delegate_address;type:varchar(42)"`
+	Owner           string `gorm:"column:owner;type:varchar(42)"`
+	Token1          string `gorm:"column:token1;type:varchar(42)"`
+	Token2          string `gorm:"column:token2;type:varchar(42)"`
+	TxHash          string `gorm:"column:tx_hash;type:varchar(82)"`
+	OrderHashList   string `gorm:"column:order_hash_list;type:text"`
+	BlockNumber     int64  `gorm:"column:block_number"`
+	LogIndex        int64  `gorm:"column:log_index"`
+	Cutoff          int64  `gorm:"column:cutoff"`
+	CreateTime      int64  `gorm:"column:create_time"`
+	Fork            bool   `gorm:"column:fork"`
+}
+
+func (cutOffPairEventV1) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "cut_off_pair_events")
+}
+
+type trendV1 struct {
+	ID         int     `gorm:"column:id;primary_key;"`
+	Market     string  `gorm:"column:market;type:varchar(42);unique_index:market_intervals_start"`
+	Intervals  string  `gorm:"column:intervals;type:varchar(42);unique_index:market_intervals_start"`
+	Vol        float64 `gorm:"column:vol;type:float"`
+	Amount     float64 `gorm:"column:amount;type:float"`
+	CreateTime int64   `gorm:"column:create_time;type:bigint"`
+	UpdateTime int64   `gorm:"column:update_time;type:bigint"`
+	Open       float64 `gorm:"column:open;type:float"`
+	Close      float64 `gorm:"column:close;type:float"`
+	High       float64 `gorm:"column:high;type:float"`
+	Low        float64 `gorm:"column:low;type:float"`
+	Start      int64   `gorm:"column:start;type:bigint;unique_index:market_intervals_start"`
+	End        int64   `gorm:"column:end;type:bigint"`
+}
+
+func (trendV1) TableName(db *gorm.DB) string { return gorm.DefaultTableNameHandler(db, "trends") }
+
+type whiteListV1 struct {
+	ID         int    `gorm:"column:id;primary_key;"`
+	Owner      string `gorm:"column:owner;varchar(42);unique_index"`
+	CreateTime int64  `gorm:"column:create_time"`
+	IsDeleted  bool   `gorm:"column:is_deleted"`
+}
+
+func (whiteListV1) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "white_lists")
+}
+
+type ringSubmitInfoV1 struct {
+	ID               int    `gorm:"column:id;primary_key;"`
+	RingHash         string `gorm:"column:ringhash;type:varchar(82)"`
+	UniqueId         string `gorm:"column:unique_id;type:varchar(82)"`
+	ProtocolAddress  string `gorm:"column:protocol_address;type:varchar(42)"`
+	OrdersCount      int64  `gorm:"column:order_count;type:bigint"`
+	ProtocolData     string `gorm:"column:protocol_data;type:text"`
+	ProtocolGas      string `gorm:"column:protocol_gas;type:varchar(50)"`
+	ProtocolGasPrice string `gorm:"column:protocol_gas_price;type:varchar(50)"`
+	ProtocolUsedGas  string `gorm:"column:protocol_used_gas;type:varchar(50)"`
+	ProtocolTxHash   string `gorm:"column:protocol_tx_hash;type:varchar(82)"`
+
+	Status      int       `gorm:"column:status;type:int"`
+	RingIndex   string    `gorm:"column:ring_index;type:varchar(50)"`
+	BlockNumber string    `gorm:"column:block_number;type:varchar(50)"`
+	Miner       string    `gorm:"column:miner;type:varchar(42)"`
+	Err         string    `gorm:"column:err;type:text"`
+	CreateTime  time.Time `gorm:"column:create_time;type:TIMESTAMP;default:CURRENT_TIMESTAMP"`
+}
+
+func (ringSubmitInfoV1) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "ring_submit_infos")
+}
+
+type filledOrderV1 struct {
+	ID               int    `gorm:"column:id;primary_key;"`
+	RingHash         string `gorm:"column:ringhash;type:varchar(82)"`
+	OrderHash        string `gorm:"column:orderhash;type:varchar(82)"`
+	FeeSelection     uint8  `gorm:"column:fee_selection"`
+	RateAmountS      string `gorm:"column:rate_amount_s;type:text"`
+	AvailableAmountS string `gorm:"column:available_amount_s;type:text"`
+	AvailableAmountB string `gorm:"column:available_amount_b;type:text"`
+	FillAmountS      string `gorm:"column:fill_amount_s;type:text"`
+	FillAmountB      string `gorm:"column:fill_amount_b;type:text"`
+	LrcReward        string `gorm:"column:lrc_reward;type:text"`
+	LrcFee           string `gorm:"column:lrc_fee;type:text"`
+	FeeS             string `gorm:"column:fee_s;type:text"`
+	LegalFee         string `gorm:"column:legal_fee;type:text"`
+	SPrice           string `gorm:"column:s_price;type:text"`
+	BPrice           string `gorm:"column:b_price;type:text"`
+}
+
+func (filledOrderV1) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "filled_orders")
+}
+
+type transactionV1 struct {
+	ID          int    `gorm:"column:id;primary_key;"`
+	Protocol    string `gorm:"column:protocol;type:varchar(42)"`
+	Symbol      string `gorm:"column:symbol;type:varchar(20)"`
+	Owner       string `gorm:"column:owner;type:varchar(42)"`
+	From        string `gorm:"column:tx_from;type:varchar(42)"`
+	To          string `gorm:"column:tx_to;type:varchar(42)"`
+	RawFrom     string `gorm:"column:raw_from;type:varchar(42)"`
+	RawTo       string `gorm:"column:raw_to;type:varchar(42)"`
+	TxHash      string `gorm:"column:tx_hash;type:varchar(82)"`
+	Content     string `gorm:"column:content;type:text"`
+	BlockNumber int64  `gorm:"column:block_number"`
+	TxIndex     int64  `gorm:"column:tx_index"`
+	LogIndex    int64  `gorm:"column:tx_log_index"`
+	Value       string `gorm:"column:amount;type:varchar(64)"`
+	Type        uint8  `gorm:"column:tx_type"`
+	Status      uint8  `gorm:"column:status"`
+	GasLimit    string `gorm:"column:gas_limit;type:varchar(40)"`
+	GasUsed     string `gorm:"column:gas_used;type:varchar(40)"`
+	GasPrice    string `gorm:"column:gas_price;type:varchar(40)"`
+	Nonce       string `gorm:"column:nonce;type:varchar(40)"`
+	CreateTime  int64  `gorm:"column:create_time"`
+	UpdateTime  int64  `gorm:"column:update_time"`
+	Fork        bool   `gorm:"column:fork"`
+}
+
+func (transactionV1) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "transactions")
+}
+
+type transactionEntityV1 struct {
+	ID          int    `gorm:"column:id;primary_key;"`
+	Protocol    string `gorm:"column:protocol;type:varchar(42)"`
+	From        string `gorm:"column:tx_from;type:varchar(42)"`
+	To          string `gorm:"column:tx_to;type:varchar(42)"`
+	BlockNumber int64  `gorm:"column:block_number"`
+	TxHash      string `gorm:"column:tx_hash;type:varchar(82)"`
+	LogIndex    int64  `gorm:"column:tx_log_index"`
+	Value       string `gorm:"column:amount;type:varchar(64)"`
+	Content     string `gorm:"column:content;type:text"`
+	Status      uint8  `gorm:"column:status"`
+	GasLimit    string `gorm:"column:gas_limit;type:varchar(40)"`
+	GasUsed     string `gorm:"column:gas_used;type:varchar(40)"`
+	GasPrice    string `gorm:"column:gas_price;type:varchar(40)"`
+	Nonce       int64  `gorm:"column:nonce"`
+	BlockTime   int64  `gorm:"column:block_time"`
+	Fork        bool   `gorm:"column:fork"`
+}
+
+func (transactionEntityV1) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "transaction_entities")
+}
+
+type transactionViewV1 struct {
+	ID          int    `gorm:"column:id;primary_key;"`
+	Symbol      string `gorm:"column:symbol;type:varchar(20)"`
+	Owner       string `gorm:"column:owner;type:varchar(42)"`
+	TxHash      string `gorm:"column:tx_hash;type:varchar(82)"`
+	BlockNumber int64  `gorm:"column:block_number"`
+	LogIndex    int64  `gorm:"column:tx_log_index"`
+	Amount      string `gorm:"column:amount;type:varchar(40)"`
+	Nonce       int64  `gorm:"column:nonce"`
+	Type        uint8  `gorm:"column:tx_type"`
+	Status      uint8  `gorm:"column:status"`
+	CreateTime  int64  `gorm:"column:create_time"`
+	UpdateTime  int64  `gorm:"column:update_time"`
+	Fork        bool   `gorm:"column:fork"`
+}
+
+func (transactionViewV1) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "transaction_views")
+}
+
+type checkPointV1 struct {
+	ID           int    `gorm:"column:id;primary_key;"`
+	BusinessType string `gorm:"column:business_type;type:varchar(42);unique_index"`
+	CheckPoint   int64  `gorm:"column:check_point;type:bigint"`
+	CreateTime   int64  `gorm:"column:create_time;type:bigint"`
+	ModifyTime   int64  `gorm:"column:modify_time;type:bigint"`
+}
+
+func (checkPointV1) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "check_points")
+}
+
+func v1Tables() []interface{} {
+	return []interface{}{
+		&orderV1{},
+		&blockV1{},
+		&ringMinedEventV1{},
+		&fillEventV1{},
+		&cancelEventV1{},
+		&cutOffEventV1{},
+		&cutOffPairEventV1{},
+		&trendV1{},
+		&whiteListV1{},
+		&ringSubmitInfoV1{},
+		&filledOrderV1{},
+		&transactionV1{},
+		&transactionEntityV1{},
+		&transactionViewV1{},
+		&checkPointV1{},
+	}
+}
@@ -0,0 +1,105 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+/**
+版本2的表结构，在版本1的ring_submit_infos上增加了提交交易的nonce和替换信息，之后修改model不要修改这里
+sqlite(3.35之前)不支持DROP COLUMN，回滚时按照版本1的结构重建表并复制数据
+*/
+
+type ringSubmitInfoV2 struct {
+	ringSubmitInfoV1
+
+	Nonce          string `gorm:"column:nonce;type:varchar(50)"`
+	ReplacedTxHash string `gorm:"column:replaced_tx_hash;type:varchar(82)"`
+	ReplaceType    string `gorm:"column:replace_type;type:varchar(20)"`
+	ReplacedBy     string `gorm:"column:replaced_by;type:varchar(82)"`
+}
+
+func (ringSubmitInfoV2) TableName(db *gorm.DB) string {
+	return gorm.DefaultTableNameHandler(db, "ring_submit_infos")
+}
+
+var ringSubmitInfoV2Columns = []string{"nonce", "replaced_tx_hash", "replace_type", "replaced_by"}
+
+func migrateV2Up(db *gorm.DB) error {
+	return db.AutoMigrate(&ringSubmitInfoV2{}).Error
+}
+
+func migrateV2Down(db *gorm.DB) error {
+	if "sqlite3" == db.Dialect().GetName() {
+		return rebuildSqliteTable(db, &ringSubmitInfoV1{})
+	}
+	for _, column := range ringSubmitInfoV2Columns {
+		if !db.Dialect().HasColumn(db.NewScope(&ringSubmitInfoV2{}).TableName(), column) {
+			continue
+		}
+		if err := db.Model(&ringSubmitInfoV2{}).DropColumn(column).Error; nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+//rebuildSqliteTable recreates the table with the columns of model and keeps the data of them,
+//sqlite executes DDL in transaction, so the table isn't changed if it fails
+func rebuildSqliteTable(db *gorm.DB, model interface{}) (err error) {
+	scope := db.NewScope(model)
+	table := scope.TableName()
+	backup := table + "_rebuilding"
+
+	columns := []string{}
+	for _, field := range scope.GetModelStruct().StructFields {
+		if field.IsNormal && !field.IsIgnored {
+			columns = append(columns, scope.Quote(field.DBName))
+		}
+	}
+
+	tx := db.Begin()
+	if nil != tx.Error {
+		return tx.Error
+	}
+	defer func() {
+		if nil != err {
+			tx.Rollback()
+		}
+	}()
+
+	if err = tx.Exec(fmt.Sprintf("ALTER TABLE %s RENAME TO %s", scope.Quote(table), scope.Quote(backup))).Error; nil != err {
+		return err
+	}
+	if err = tx.CreateTable(model).Error; nil != err {
+		return err
+	}
+	selected := strings.Join(columns, ",")
+	if err = tx.Exec(fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", scope.Quote(table), selected, selected, scope.Quote(backup))).Error; nil != err {
+		return err
+	}
+	if err = tx.Exec(fmt.Sprintf("DROP TABLE %s", scope.Quote(backup))).Error; nil != err {
+		return err
+	}
+	return tx.Commit().Error
+}
@@ -310,6 +310,28 @@ func (s *RdsServiceImpl) GetOrderBook(delegate, tokenS, tokenB common.Address, l
 	return list, err
 }
 
+//GetActiveOrderBook pages through the unexpired orders of the book by id, including the ones valid in future
+func (s *RdsServiceImpl) GetActiveOrderBook(delegate, tokenS, tokenB common.Address, fromId, limit int) ([]Order, error) {
+	var (
+		list []Order
+		err  error
+	)
+
+	filterStatus := []types.OrderStatus{types.ORDER_NEW, types.ORDER_PARTIAL}
+	nowtime := time.Now().Unix()
+	err = s.db.Where("delegate_address = ?", delegate.Hex()).
+		Where("token_s = ? and token_b = ?", tokenS.Hex(), tokenB.Hex()).
+		Where("status in (?)", filterStatus).
+		Where("order_type = ? ", types.ORDER_TYPE_MARKET).
+		Where("valid_until >= ? ", nowtime).
+		Where("id > ?", fromId).
+		Order("id asc").
+		Limit(limit).
+		Find(&list).Error
+
+	return list, err
+}
+
 func (s *RdsServiceImpl) OrderPageQuery(query map[string]interface{}, statusList []int, pageIndex, pageSize int) (PageResult, error) {
 	var (
 		orders        []Order
@@ -19,13 +19,17 @@ package dao_test
 
 import (
 	"fmt"
+	"github.com/Loopring/relay/config"
 	"github.com/Loopring/relay/crypto"
 	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/log"
 	"github.com/Loopring/relay/types"
+	"go.uber.org/zap"
 	"testing"
 )
 
 func TestRdsServiceImpl_NewOrder(t *testing.T) {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
 	o := dao.Order{}
 	o.ID = 1
 	o.Protocol = "0xC01172a87f6cC20E1E3b9aD13a9E715Fbc2D5AA9"
@@ -136,6 +136,11 @@ type RingSubmitInfo struct {
 	Miner       string    `gorm:"column:miner;type:varchar(42)"`
 	Err         string    `gorm:"column:err;type:text"`
 	CreateTime  time.Time `gorm:"column:create_time;type:TIMESTAMP;default:CURRENT_TIMESTAMP"`
+
+	Nonce          string `gorm:"column:nonce;type:varchar(50)"`
+	ReplacedTxHash string `gorm:"column:replaced_tx_hash;type:varchar(82)"`
+	ReplaceType    string `gorm:"column:replace_type;type:varchar(20)"`
+	ReplacedBy     string `gorm:"column:replaced_by;type:varchar(82)"`
 }
 
 func getBigIntString(v *big.Int) string {
@@ -157,6 +162,11 @@ func (info *RingSubmitInfo) ConvertDown(typesInfo *types.RingSubmitInfo, err err
 	info.ProtocolGasPrice = getBigIntString(typesInfo.ProtocolGasPrice)
 	info.Miner = typesInfo.Miner.Hex()
 	info.ProtocolTxHash = typesInfo.SubmitTxHash.Hex()
+	info.Nonce = getBigIntString(typesInfo.Nonce)
+	if !types.IsZeroHash(typesInfo.ReplacedTxHash) {
+		info.ReplacedTxHash = typesInfo.ReplacedTxHash.Hex()
+	}
+	info.ReplaceType = typesInfo.ReplaceType
 	if nil != err {
 		info.Err = err.Error()
 	}
@@ -176,6 +186,12 @@ func (info *RingSubmitInfo) ConvertUp(typesInfo *types.RingSubmitInfo) error {
 	typesInfo.ProtocolGasPrice.SetString(info.ProtocolGasPrice, 0)
 	typesInfo.SubmitTxHash = common.HexToHash(info.ProtocolTxHash)
 	typesInfo.Miner = common.HexToAddress(info.Miner)
+	if "" != info.Nonce {
+		typesInfo.Nonce = new(big.Int)
+		typesInfo.Nonce.SetString(info.Nonce, 0)
+	}
+	typesInfo.ReplacedTxHash = common.HexToHash(info.ReplacedTxHash)
+	typesInfo.ReplaceType = info.ReplaceType
 	return nil
 }
 
@@ -216,6 +232,22 @@ func (s *RdsServiceImpl) UpdateRingSubmitInfoResult(submitResult *types.RingSubm
 //	return dbForUpdate.Update("protocol_tx_hash", txHash).Error
 //}
 
+//UpdateRingSubmitInfoReplaced records the tx which replaces txHash with the same nonce
+func (s *RdsServiceImpl) UpdateRingSubmitInfoReplaced(txHash, replacedBy common.Hash) error {
+	dbForUpdate := s.db.Model(&RingSubmitInfo{}).Where("protocol_tx_hash = ?", txHash.Hex())
+	return dbForUpdate.Update("replaced_by", replacedBy.Hex()).Error
+}
+
+//GetPendingRingSubmitInfos returns the pending txs of miner which haven't been replaced
+func (s *RdsServiceImpl) GetPendingRingSubmitInfos(miner common.Address) ([]*RingSubmitInfo, error) {
+	var infos []*RingSubmitInfo
+	err := s.db.Where("miner = ? and status = ? and nonce <> '' and (replaced_by is null or replaced_by = '')", miner.Hex(), uint8(types.TX_STATUS_PENDING)).
+		Order("id asc").
+		Find(&infos).
+		Error
+	return infos, err
+}
+
 func (s *RdsServiceImpl) GetRingForSubmitByHash(ringhash common.Hash) (ringForSubmit RingSubmitInfo, err error) {
 	err = s.db.Where("ringhash = ? ", ringhash.Hex()).First(&ringForSubmit).Error
 	return
@@ -1,3 +1,6 @@
+//go:build integration
+// +build integration
+
 /*
 
   Copyright 2017 Loopring Project Ltd (Loopring Foundation).
@@ -23,6 +26,7 @@ import (
 	"github.com/Loopring/relay/crypto"
 	"github.com/Loopring/relay/dao"
 	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/types"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
@@ -60,7 +64,12 @@ func TestNewRing(t *testing.T) {
 	info.ProtocolAddress = common.HexToAddress("0xB5FAB0B11776AAD5cE60588C16bd59DCfd61a1c2").Hex()
 	info.ProtocolData = "0x9812ad890"
 
-	if err := s.UpdateRingSubmitInfoRegistryTxHash([]common.Hash{common.HexToHash(info.RingHash)}, "0x3c88ebf05254fb82e7ecd10c237036eb4cd0846e1ad8059ca72af40344a9d7d2"); nil != err {
+	result := &types.RingSubmitResultEvent{
+		RingHash: common.HexToHash(info.RingHash),
+		TxHash:   common.HexToHash("0x3c88ebf05254fb82e7ecd10c237036eb4cd0846e1ad8059ca72af40344a9d7d2"),
+		Status:   types.TX_STATUS_PENDING,
+	}
+	if err := s.UpdateRingSubmitInfoResult(result); nil != err {
 		t.Error(err)
 	}
 }
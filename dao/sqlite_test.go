@@ -0,0 +1,178 @@
+//go:build sqlite3
+// +build sqlite3
+
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao_test
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+func newSqliteService() *dao.RdsServiceImpl {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+	s := dao.NewRdsService(config.MysqlOptions{Driver: "sqlite3", DbName: ":memory:", TablePrefix: "lpr_"})
+	s.Prepare()
+	return s
+}
+
+func TestSqlite_Order(t *testing.T) {
+	s := newSqliteService()
+
+	hash := common.HexToHash("0x01")
+	owner := common.HexToAddress("0xa1")
+	ord := &dao.Order{
+		OrderHash:    hash.Hex(),
+		Owner:        owner.Hex(),
+		AmountS:      "100",
+		AmountB:      "10",
+		DealtAmountS: "0",
+		DealtAmountB: "0",
+		ValidSince:   1,
+		ValidUntil:   time.Now().Unix() + 3600,
+		Status:       uint8(types.ORDER_NEW),
+		LrcFee:       "10",
+	}
+	if err := s.Add(ord); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+
+	if err := s.UpdateOrderWhileFill(hash, types.ORDER_PARTIAL, big.NewInt(50), big.NewInt(5), big.NewInt(0), big.NewInt(0), big.NewInt(10)); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if res, err := s.GetOrderByHash(hash); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	} else if res.DealtAmountS != "50" || res.Status != uint8(types.ORDER_PARTIAL) || res.UpdatedBlock != 10 {
+		t.Fatalf("unexpected order:%+v", res)
+	}
+
+	if list, err := s.GetFrozenLrcFee(owner, []types.OrderStatus{types.ORDER_NEW, types.ORDER_PARTIAL}); nil != err || len(list) != 1 {
+		t.Fatalf("unexpected frozen lrc fee orders:%d, err:%v", len(list), err)
+	}
+	if res, err := s.OrderPageQuery(map[string]interface{}{"owner": owner.Hex()}, []int{}, 1, 10); nil != err || res.Total != 1 {
+		t.Fatalf("unexpected page result:%+v, err:%v", res, err)
+	}
+}
+
+func TestSqlite_RingMined(t *testing.T) {
+	s := newSqliteService()
+
+	for i, blockNumber := range []int64{10, 11} {
+		event := &dao.RingMinedEvent{TxHash: common.BigToHash(big.NewInt(int64(i + 1))).Hex(), BlockNumber: blockNumber}
+		if err := s.Add(event); nil != err {
+			t.Fatalf("err:%s", err.Error())
+		}
+	}
+	if err := s.RollBackRingMined(10, 11); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if _, err := s.FindRingMined(common.BigToHash(big.NewInt(1)).Hex()); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if _, err := s.FindRingMined(common.BigToHash(big.NewInt(2)).Hex()); nil == err {
+		t.Fatalf("forked ring mined event should not be found")
+	}
+}
+
+func TestSqlite_Ring(t *testing.T) {
+	s := newSqliteService()
+
+	info := &dao.RingSubmitInfo{RingHash: common.HexToHash("0x11").Hex(), ProtocolTxHash: common.HexToHash("0x12").Hex()}
+	if err := s.Add(info); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if err := s.UpdateRingSubmitInfoSubmitUsedGas(info.ProtocolTxHash, big.NewInt(200000)); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if list, err := s.GetRingHashesByTxHash(common.HexToHash("0x12")); nil != err || len(list) != 1 || list[0].ProtocolUsedGas != "200000" {
+		t.Fatalf("unexpected ring submit infos:%v, err:%v", list, err)
+	}
+
+	miner := common.HexToAddress("0xb1")
+	pending := &dao.RingSubmitInfo{RingHash: common.HexToHash("0x13").Hex(), ProtocolTxHash: common.HexToHash("0x14").Hex(), Miner: miner.Hex(), Nonce: "3", Status: int(types.TX_STATUS_PENDING)}
+	if err := s.Add(pending); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if list, err := s.GetPendingRingSubmitInfos(miner); nil != err || len(list) != 1 {
+		t.Fatalf("unexpected pending ring submit infos:%d, err:%v", len(list), err)
+	}
+	replacement := &dao.RingSubmitInfo{RingHash: pending.RingHash, ProtocolTxHash: common.HexToHash("0x15").Hex(), Miner: miner.Hex(), Nonce: "3", Status: int(types.TX_STATUS_PENDING), ReplacedTxHash: pending.ProtocolTxHash, ReplaceType: "resubmit"}
+	if err := s.Add(replacement); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if err := s.UpdateRingSubmitInfoReplaced(common.HexToHash("0x14"), common.HexToHash("0x15")); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if list, err := s.GetPendingRingSubmitInfos(miner); nil != err || len(list) != 1 || list[0].ProtocolTxHash != replacement.ProtocolTxHash {
+		t.Fatalf("only the replacement should be pending, got:%v, err:%v", list, err)
+	}
+}
+
+func TestSqlite_TransactionEntity(t *testing.T) {
+	s := newSqliteService()
+
+	from := common.HexToAddress("0xa1").Hex()
+	tx := &dao.TransactionEntity{TxHash: common.HexToHash("0x21").Hex(), From: from, Nonce: 1, Status: uint8(types.TX_STATUS_PENDING), BlockNumber: 10}
+	if err := s.Add(tx); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if list, err := s.GetPendingTxEntity(from, 1); nil != err || len(list) != 1 {
+		t.Fatalf("unexpected pending txs:%d, err:%v", len(list), err)
+	}
+	if err := s.SetPendingTxEntityFailed([]string{tx.TxHash}); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if res, err := s.FindTxEntity(tx.TxHash, 0); nil != err || res.Status != uint8(types.TX_STATUS_FAILED) {
+		t.Fatalf("unexpected tx:%+v, err:%v", res, err)
+	}
+	if err := s.RollBackTxEntity(9, 10); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if _, err := s.FindTxEntity(tx.TxHash, 0); nil == err {
+		t.Fatalf("forked tx should not be found")
+	}
+}
+
+func TestSqlite_CheckPoint(t *testing.T) {
+	s := newSqliteService()
+
+	if _, err := s.QueryCheckPointByType(dao.ExtractorBackfillType); nil == err {
+		t.Fatalf("check point shouldn't exist")
+	}
+	if err := s.SaveCheckPoint(dao.ExtractorBackfillType, 100); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if err := s.SaveCheckPoint(dao.ExtractorBackfillType, 200); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if point, err := s.QueryCheckPointByType(dao.ExtractorBackfillType); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	} else if point.CheckPoint != 200 || point.CreateTime == 0 {
+		t.Fatalf("unexpected check point:%+v", point)
+	}
+}
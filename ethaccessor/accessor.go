@@ -37,6 +37,13 @@ func BlockNumber(result interface{}) error {
 	return accessor.RetryCall("latest", 5, result, "eth_blockNumber")
 }
 
+func AvailableNodes() []string {
+	if nil == accessor || nil == accessor.MutilClient {
+		return []string{}
+	}
+	return accessor.AvailableNodes()
+}
+
 func GetBalance(result interface{}, address common.Address, blockNumber string) error {
 	return accessor.RetryCall(blockNumber, 2, result, "eth_getBalance", address, blockNumber)
 }
@@ -75,6 +82,15 @@ func GetTransactionReceipt(result interface{}, txHash string, blockParameter str
 	return accessor.RetryCall(blockParameter, 2, result, "eth_getTransactionReceipt", txHash)
 }
 
+func GetLogs(result *[]Log, filter *LogFilter) error {
+	return accessor.RetryCall(filter.ToBlock, 2, result, "eth_getLogs", filter)
+}
+
+//BatchBlocksWithTxObject returns the blocks in [startNumber, endNumber] with txs but without receipts
+func BatchBlocksWithTxObject(startNumber, endNumber *big.Int) ([]*BlockWithTxObject, error) {
+	return accessor.BatchBlocksWithTxObject(endNumber.String(), 5, startNumber, endNumber)
+}
+
 func GetTransactionByHash(result types.CheckNull, txHash string, blockParameter string) error {
 	for _, c := range accessor.clients {
 		if err := c.client.Call(result, "eth_getTransactionByHash", txHash); nil == err {
@@ -88,8 +104,17 @@ func GetTransactionByHash(result types.CheckNull, txHash string, blockParameter
 
 // lgh: 根据最小值和最大值估计 gas 油费标准
 func EstimateGasPrice(minGasPrice, maxGasPrice *big.Int) *big.Int {
-	// lgh: GasPrice 根据一定的算法找出最优的 gas 油费标准
-	return accessor.gasPriceEvaluator.GasPrice(minGasPrice, maxGasPrice)
+	// lgh: GasOracle 根据一定的算法找出最优的 gas 油费标准
+	var price *big.Int
+	if fee := SuggestFee(); nil != fee {
+		price = fee.EffectiveGasPrice()
+	}
+	return limitGasPrice(price, minGasPrice, maxGasPrice)
+}
+
+//SuggestFee returns nil if the gas oracle isn't ready
+func SuggestFee() *GasFee {
+	return accessor.gasOracle().SuggestFee()
 }
 
 func GetBlockTransactionCountByHash(result interface{}, blockHash string, blockParameter string) error {
@@ -125,6 +150,26 @@ func SignAndSendTransaction(
 	return accessor.ContractSendTransactionByData("latest", sender, to, gas, gasPrice, value, callData, needPreExe)
 }
 
+//SendTransaction signs and sends the tx, the nonce used by it is returned to replace it later.
+//maxFeePerGas is the fee cap of type-2 tx, it should be got by MaxFeePerGas
+func SendTransaction(sender, to common.Address, gas, gasPrice, maxFeePerGas, value *big.Int, callData []byte) (string, *big.Int, error) {
+	return accessor.ContractSendTransactionWithNonce("latest", sender, to, gas, gasPrice, maxFeePerGas, value, callData, false)
+}
+
+//MaxFeePerGas returns the max price the tx sent with gasPrice may pay, it's gasPrice if legacy tx is used
+func MaxFeePerGas(gasPrice, maxGasPrice *big.Int) *big.Int {
+	if accessor.dynamicFeeTx {
+		if fee := SuggestFee(); nil != fee && fee.IsDynamic() {
+			return fee.MaxFeePerGas(gasPrice, maxGasPrice)
+		}
+	}
+	return new(big.Int).Set(gasPrice)
+}
+
+func ReplaceTransaction(sender, to common.Address, gas, gasPrice, tipCap, feeCap, value *big.Int, callData []byte, nonce *big.Int) (string, error) {
+	return accessor.ReplaceTransaction(sender, to, gas, gasPrice, tipCap, feeCap, value, callData, nonce)
+}
+
 func ContractSendTransactionMethod(routeParam string, a *abi.ABI, contractAddress common.Address) func(sender common.Address, methodName string, gas, gasPrice, value *big.Int, args ...interface{}) (string, error) {
 	return accessor.ContractSendTransactionMethod(routeParam, a, contractAddress)
 }
@@ -276,6 +321,11 @@ func Initialize(accessorOptions config.AccessorOptions, commonOptions config.Com
 		accessor.fetchTxRetryCount = 60
 	}
 	accessor.AddressNonce = make(map[common.Address]*big.Int)
+	accessor.oracle = NewGasOracle(accessorOptions.GasOracle)
+	accessor.dynamicFeeTx = accessorOptions.GasOracle.DynamicFeeTx
+	if accessorOptions.ChainId > 0 {
+		accessor.chainId = big.NewInt(accessorOptions.ChainId)
+	}
 	accessor.MutilClient = NewMutilClient(accessorOptions.RawUrls)
 	if nil != err {
 		return err
@@ -365,7 +415,50 @@ func Initialize(accessorOptions config.AccessorOptions, commonOptions config.Com
 	return nil
 }
 
+//StartGasOracle starts the gas oracle set by config, it's only started once
+func StartGasOracle() {
+	accessor.oracleOnce.Do(func() {
+		accessor.gasOracle().Start()
+	})
+}
+
+func StopGasOracle() {
+	if nil != accessor {
+		accessor.gasOracle().Stop()
+	}
+}
+
+//SetGasOracle replaces the gas oracle, such as overriding the fee manually by FixedGasOracle
+func SetGasOracle(oracle GasOracle) {
+	accessor.oracleMtx.Lock()
+	previous := accessor.oracle
+	accessor.oracle = oracle
+	accessor.oracleMtx.Unlock()
+
+	if nil != previous {
+		previous.Stop()
+	}
+	//StartGasOracle shouldn't start it again
+	accessor.oracleOnce.Do(func() {})
+	oracle.Start()
+}
+
+//IncludeGasPriceEvaluator uses the block sample oracle
 func IncludeGasPriceEvaluator() {
-	accessor.gasPriceEvaluator = &GasPriceEvaluator{}
-	accessor.gasPriceEvaluator.start()
+	SetGasOracle(&GasPriceEvaluator{})
+}
+
+//InitializeOffline is used when there isn't any eth node, such as simulating rings with history orders.
+//only the protocol addresses and a fixed gas price are available, all the rpc methods can't be called.
+func InitializeOffline(protocolAddresses []*ProtocolAddress, gasPrice *big.Int) {
+	accessor = &ethNodeAccessor{}
+	accessor.mtx = sync.RWMutex{}
+	accessor.AddressNonce = make(map[common.Address]*big.Int)
+	accessor.ProtocolAddresses = make(map[common.Address]*ProtocolAddress)
+	accessor.DelegateAddresses = make(map[common.Address]bool)
+	for _, impl := range protocolAddresses {
+		accessor.ProtocolAddresses[impl.ContractAddress] = impl
+		accessor.DelegateAddresses[impl.DelegateAddress] = true
+	}
+	accessor.oracle = NewFixedGasOracle(gasPrice, nil)
 }
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"github.com/Loopring/relay/cache"
 	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/metrics"
 	"github.com/Loopring/relay/types"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
@@ -40,6 +41,20 @@ const (
 	cacheDuration      = 86400 * 3
 )
 
+var (
+	requestDuration = metrics.NewSummaryVec("relay_accessor_request_duration_seconds", "Duration of the requests to eth nodes.", "node", "method")
+	requestErrors   = metrics.NewCounterVec("relay_accessor_request_errors_total", "Failed requests to eth nodes.", "node", "method")
+	nodeBlockNumber = metrics.NewGaugeVec("relay_accessor_node_block_number", "Latest block number reported by eth nodes.", "node")
+)
+
+//observeRequest records the latency and error of the request to node
+func observeRequest(node, method string, start time.Time, err error) {
+	requestDuration.Observe(time.Since(start).Seconds(), node, method)
+	if nil != err {
+		requestErrors.Inc(node, method)
+	}
+}
+
 type MutilClient struct {
 	clients       map[string]*RpcClient
 	downedClients map[string]*RpcClient
@@ -156,11 +171,15 @@ func (mc *MutilClient) syncBlockNumber() {
 	for _, client := range mc.clients {
 		var blockNumber types.Big
 		// lgh: 同步最新的区块号。从 clients 中依次得出它们不同的 blockNumber
-		if err := client.client.Call(&blockNumber, "eth_blockNumber"); nil != err {
+		start := time.Now()
+		err := client.client.Call(&blockNumber, "eth_blockNumber")
+		observeRequest(client.url, "eth_blockNumber", start, err)
+		if nil != err {
 			mc.downedClients[client.url] = client
 		} else {
 			delete(mc.downedClients, client.url) // 删除指针内存
 			client.blockNumber = blockNumber.BigInt()
+			nodeBlockNumber.Set(float64(blockNumber.Int64()), client.url)
 			blockNumberStr := blockNumber.BigInt().String()
 			// 具备缓存时间 cacheDuration
 			// 下面就把 每个 Geth-client 对于的最新 blockNumber 的 url 保存下来了
@@ -171,6 +190,17 @@ func (mc *MutilClient) syncBlockNumber() {
 	}
 }
 
+//AvailableNodes returns the urls of eth nodes which replied eth_blockNumber in the latest sync
+func (mc *MutilClient) AvailableNodes() []string {
+	urls := []string{}
+	for url := range mc.clients {
+		if _, exists := mc.downedClients[url]; !exists {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
 func (mc *MutilClient) startSyncBlockNumber() {
 	go func() {
 		for {
@@ -248,7 +278,9 @@ func (mc *MutilClient) Call(routeParam string, result interface{}, method string
 			return "", errors.New("there isn't an usable ethnode")
 		}
 		log.Debugf("rpcClient:%s, %s ,%s", rpcClient.url,method, routeParam)
+		start := time.Now()
 		err = rpcClient.client.Call(result, method, args...)
+		observeRequest(rpcClient.url, method, start, err)
 		if err != nil {
 			log.Debugf("rpc Call err :%s", err.Error())
 		}else{
@@ -263,7 +295,9 @@ func (mc *MutilClient) BatchCall(routeParam string, b []rpc.BatchElem) (node str
 	if nil == rpcClient {
 		return "", errors.New("there isn't an usable ethnode")
 	}
+	start := time.Now()
 	err = rpcClient.client.BatchCall(b)
+	observeRequest(rpcClient.url, "batch", start, err)
 	return rpcClient.url, err
 }
 
@@ -279,10 +313,21 @@ type ethNodeAccessor struct {
 	DelegateAddresses map[common.Address]bool
 
 	*MutilClient
-	gasPriceEvaluator *GasPriceEvaluator
 	mtx               sync.RWMutex
 	AddressNonce      map[common.Address]*big.Int
 	fetchTxRetryCount int
+
+	oracle       GasOracle
+	oracleMtx    sync.RWMutex
+	oracleOnce   sync.Once
+	dynamicFeeTx bool
+	chainId      *big.Int
+}
+
+func (accessor *ethNodeAccessor) gasOracle() GasOracle {
+	accessor.oracleMtx.RLock()
+	defer accessor.oracleMtx.RUnlock()
+	return accessor.oracle
 }
 
 type AddressNonce struct {
@@ -1,3 +1,6 @@
+//go:build integration
+// +build integration
+
 /*
 
   Copyright 2017 Loopring Project Ltd (Loopring Foundation).
@@ -89,7 +92,7 @@ func TestEthNodeAccessor_EthTransfer(t *testing.T) {
 	sender := account1
 	receiver := account2
 	amount := new(big.Int).Mul(big.NewInt(1e18), big.NewInt(1))
-	if hash, err := ethaccessor.SignAndSendTransaction(sender, receiver, gas, gasPrice, amount, []byte("test")); err != nil {
+	if hash, err := ethaccessor.SignAndSendTransaction(sender, receiver, gas, gasPrice, amount, []byte("test"), false); err != nil {
 		t.Errorf(err.Error())
 	} else {
 		t.Logf("txhash:%s", hash)
@@ -0,0 +1,141 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ethaccessor
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+/**
+EIP-1559 的 type-2 交易，vendor 中的 go-ethereum 只支持 legacy 交易，所以在这里编码和签名
+raw = 0x02 || rlp([chainId, nonce, maxPriorityFeePerGas, maxFeePerGas, gas, to, value, data, accessList, yParity, r, s])
+签名的 hash = keccak256(0x02 || rlp([chainId, nonce, maxPriorityFeePerGas, maxFeePerGas, gas, to, value, data, accessList]))
+*/
+
+const DynamicFeeTxType = 0x02
+
+type accessTuple struct {
+	Address     common.Address
+	StorageKeys []common.Hash
+}
+
+type DynamicFeeTx struct {
+	ChainID   *big.Int
+	Nonce     uint64
+	GasTipCap *big.Int
+	GasFeeCap *big.Int
+	Gas       *big.Int
+	To        *common.Address
+	Value     *big.Int
+	Data      []byte
+
+	V, R, S *big.Int
+}
+
+type unsignedDynamicFeeTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        *big.Int
+	To         *common.Address
+	Value      *big.Int
+	Data       []byte
+	AccessList []accessTuple
+}
+
+type signedDynamicFeeTx struct {
+	ChainID    *big.Int
+	Nonce      uint64
+	GasTipCap  *big.Int
+	GasFeeCap  *big.Int
+	Gas        *big.Int
+	To         *common.Address
+	Value      *big.Int
+	Data       []byte
+	AccessList []accessTuple
+	V, R, S    *big.Int
+}
+
+func (tx *DynamicFeeTx) SigningHash() (common.Hash, error) {
+	data, err := rlp.EncodeToBytes(&unsignedDynamicFeeTx{
+		ChainID:    tx.ChainID,
+		Nonce:      tx.Nonce,
+		GasTipCap:  tx.GasTipCap,
+		GasFeeCap:  tx.GasFeeCap,
+		Gas:        tx.Gas,
+		To:         tx.To,
+		Value:      tx.Value,
+		Data:       tx.Data,
+		AccessList: []accessTuple{},
+	})
+	if nil != err {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(ethCrypto.Keccak256([]byte{DynamicFeeTxType}, data)), nil
+}
+
+//WithSignature sets the signature in the [R || S || V] format, V is 0 or 1
+func (tx *DynamicFeeTx) WithSignature(sig []byte) error {
+	if len(sig) != 65 {
+		return errors.New("wrong size of signature")
+	}
+	tx.R = new(big.Int).SetBytes(sig[:32])
+	tx.S = new(big.Int).SetBytes(sig[32:64])
+	tx.V = new(big.Int).SetUint64(uint64(sig[64]))
+	return nil
+}
+
+//MarshalBinary returns the raw data used by eth_sendRawTransaction
+func (tx *DynamicFeeTx) MarshalBinary() ([]byte, error) {
+	if nil == tx.V || nil == tx.R || nil == tx.S {
+		return nil, errors.New("tx hasn't been signed")
+	}
+	data, err := rlp.EncodeToBytes(&signedDynamicFeeTx{
+		ChainID:    tx.ChainID,
+		Nonce:      tx.Nonce,
+		GasTipCap:  tx.GasTipCap,
+		GasFeeCap:  tx.GasFeeCap,
+		Gas:        tx.Gas,
+		To:         tx.To,
+		Value:      tx.Value,
+		Data:       tx.Data,
+		AccessList: []accessTuple{},
+		V:          tx.V,
+		R:          tx.R,
+		S:          tx.S,
+	})
+	if nil != err {
+		return nil, err
+	}
+	return append([]byte{DynamicFeeTxType}, data...), nil
+}
+
+func (tx *DynamicFeeTx) Hash() (common.Hash, error) {
+	data, err := tx.MarshalBinary()
+	if nil != err {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(ethCrypto.Keccak256(data)), nil
+}
@@ -0,0 +1,257 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ethaccessor
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+/**
+GasOracle 提供交易的费用：
+block_sample: 原有的 GasPriceEvaluator，取最近30个块中交易的 gasPrice
+fee_history: 通过 eth_feeHistory 获取下一个块的 baseFee 和 RewardPercentile 分位的 priority fee，
+             链不支持 eth_feeHistory 时使用 eth_gasPrice
+fixed: 固定的费用，也可以在运行时通过 SetGasOracle 手动覆盖
+*/
+
+const (
+	GasOracle_BlockSample = "block_sample"
+	GasOracle_FeeHistory  = "fee_history"
+	GasOracle_Fixed       = "fixed"
+
+	feeHistoryInterval = 10 * time.Second
+)
+
+//GasFee is legacy if BaseFee is nil
+type GasFee struct {
+	GasPrice *big.Int
+	BaseFee  *big.Int
+	TipCap   *big.Int
+	FeeCap   *big.Int
+}
+
+func (fee *GasFee) IsDynamic() bool {
+	return nil != fee.BaseFee && nil != fee.TipCap
+}
+
+//EffectiveGasPrice is the price paid per gas
+func (fee *GasFee) EffectiveGasPrice() *big.Int {
+	if fee.IsDynamic() {
+		return new(big.Int).Add(fee.BaseFee, fee.TipCap)
+	}
+	if nil == fee.GasPrice {
+		return nil
+	}
+	return new(big.Int).Set(fee.GasPrice)
+}
+
+//MaxFeePerGas is the fee cap of type-2 tx, it's limited by maxGasPrice, but isn't less than gasPrice, which is the price expected to pay.
+//The tx pays baseFee + tip if the fee cap is enough, so it keeps being valid while the base fee rises
+func (fee *GasFee) MaxFeePerGas(gasPrice, maxGasPrice *big.Int) *big.Int {
+	feeCap := fee.EffectiveGasPrice()
+	if nil != fee.FeeCap {
+		feeCap = new(big.Int).Set(fee.FeeCap)
+	}
+	if nil != maxGasPrice && maxGasPrice.Sign() > 0 && feeCap.Cmp(maxGasPrice) > 0 {
+		feeCap.Set(maxGasPrice)
+	}
+	if nil != gasPrice && feeCap.Cmp(gasPrice) < 0 {
+		feeCap.Set(gasPrice)
+	}
+	return feeCap
+}
+
+type GasOracle interface {
+	Start()
+	Stop()
+	//SuggestFee returns nil if the oracle isn't ready
+	SuggestFee() *GasFee
+}
+
+func NewGasOracle(options config.GasOracleOptions) GasOracle {
+	switch options.Type {
+	case GasOracle_FeeHistory:
+		return NewFeeHistoryOracle(options.FeeHistoryBlocks, options.RewardPercentile)
+	case GasOracle_Fixed:
+		return NewFixedGasOracle(big.NewInt(options.GasPrice), big.NewInt(options.TipCap))
+	default:
+		return &GasPriceEvaluator{}
+	}
+}
+
+//limitGasPrice returns the price between minGasPrice and maxGasPrice, maxGasPrice is used if price is nil
+func limitGasPrice(price, minGasPrice, maxGasPrice *big.Int) *big.Int {
+	gasPrice := new(big.Int)
+	if nil == price || price.Sign() <= 0 {
+		if nil != maxGasPrice {
+			gasPrice.Set(maxGasPrice)
+		}
+		return gasPrice
+	}
+	if nil != maxGasPrice && maxGasPrice.Cmp(price) < 0 {
+		gasPrice.Set(maxGasPrice)
+	} else if nil != minGasPrice && minGasPrice.Cmp(price) > 0 {
+		gasPrice.Set(minGasPrice)
+	} else {
+		gasPrice.Set(price)
+	}
+	return gasPrice
+}
+
+type FixedGasOracle struct {
+	mtx sync.RWMutex
+	fee *GasFee
+}
+
+//NewFixedGasOracle returns an oracle of legacy fee if tipCap isn't positive
+func NewFixedGasOracle(gasPrice, tipCap *big.Int) *FixedGasOracle {
+	oracle := &FixedGasOracle{}
+	oracle.Set(gasPrice, tipCap)
+	return oracle
+}
+
+//Set changes the fee, gasPrice is used as the base fee when tipCap is positive
+func (oracle *FixedGasOracle) Set(gasPrice, tipCap *big.Int) {
+	oracle.mtx.Lock()
+	defer oracle.mtx.Unlock()
+
+	fee := &GasFee{GasPrice: new(big.Int).Set(gasPrice)}
+	if nil != tipCap && tipCap.Sign() > 0 {
+		fee.BaseFee = new(big.Int).Set(gasPrice)
+		fee.TipCap = new(big.Int).Set(tipCap)
+		fee.FeeCap = new(big.Int).Mul(gasPrice, big.NewInt(2))
+		fee.FeeCap.Add(fee.FeeCap, tipCap)
+	}
+	oracle.fee = fee
+}
+
+func (oracle *FixedGasOracle) Start() {}
+
+func (oracle *FixedGasOracle) Stop() {}
+
+func (oracle *FixedGasOracle) SuggestFee() *GasFee {
+	oracle.mtx.RLock()
+	defer oracle.mtx.RUnlock()
+	return oracle.fee
+}
+
+type FeeHistoryOracle struct {
+	blocks     int
+	percentile float64
+
+	mtx      sync.RWMutex
+	fee      *GasFee
+	stopChan chan bool
+}
+
+func NewFeeHistoryOracle(blocks int, percentile float64) *FeeHistoryOracle {
+	if blocks <= 0 {
+		blocks = 20
+	}
+	if percentile <= 0 || percentile > 100 {
+		percentile = 50
+	}
+	return &FeeHistoryOracle{blocks: blocks, percentile: percentile}
+}
+
+type feeHistory struct {
+	OldestBlock   types.Big     `json:"oldestBlock"`
+	BaseFeePerGas []types.Big   `json:"baseFeePerGas"`
+	Reward        [][]types.Big `json:"reward"`
+}
+
+//suggestFee uses the base fee of the next block and the median of rewards as tip
+func (history *feeHistory) suggestFee() *GasFee {
+	if len(history.BaseFeePerGas) == 0 {
+		return nil
+	}
+	baseFee := history.BaseFeePerGas[len(history.BaseFeePerGas)-1].BigInt()
+	tips := []*big.Int{}
+	for _, rewards := range history.Reward {
+		if len(rewards) > 0 && rewards[0].BigInt().Sign() > 0 {
+			tips = append(tips, rewards[0].BigInt())
+		}
+	}
+	tip := big.NewInt(1e9)
+	if len(tips) > 0 {
+		sort.Slice(tips, func(i, j int) bool { return tips[i].Cmp(tips[j]) < 0 })
+		tip = tips[len(tips)/2]
+	}
+	feeCap := new(big.Int).Mul(baseFee, big.NewInt(2))
+	feeCap.Add(feeCap, tip)
+	return &GasFee{GasPrice: new(big.Int).Add(baseFee, tip), BaseFee: baseFee, TipCap: tip, FeeCap: feeCap}
+}
+
+func (oracle *FeeHistoryOracle) update() {
+	var history feeHistory
+	err := accessor.RetryCall("latest", 2, &history, "eth_feeHistory", hexutil.EncodeUint64(uint64(oracle.blocks)), "latest", []float64{oracle.percentile})
+	var fee *GasFee
+	if nil == err {
+		fee = history.suggestFee()
+	}
+	if nil == fee {
+		//the chain doesn't support eip-1559
+		var gasPrice types.Big
+		if err1 := accessor.RetryCall("latest", 2, &gasPrice, "eth_gasPrice"); nil != err1 {
+			log.Errorf("gasOracle, can't get fee, eth_feeHistory err:%v, eth_gasPrice err:%s", err, err1.Error())
+			return
+		}
+		fee = &GasFee{GasPrice: gasPrice.BigInt()}
+	}
+	log.Debugf("gasOracle, gasPrice:%s, dynamic:%t", fee.EffectiveGasPrice().String(), fee.IsDynamic())
+
+	oracle.mtx.Lock()
+	oracle.fee = fee
+	oracle.mtx.Unlock()
+}
+
+func (oracle *FeeHistoryOracle) Start() {
+	oracle.stopChan = make(chan bool, 1)
+	oracle.update()
+	go func() {
+		for {
+			select {
+			case <-oracle.stopChan:
+				return
+			case <-time.After(feeHistoryInterval):
+				oracle.update()
+			}
+		}
+	}()
+}
+
+func (oracle *FeeHistoryOracle) Stop() {
+	if nil != oracle.stopChan {
+		oracle.stopChan <- true
+	}
+}
+
+func (oracle *FeeHistoryOracle) SuggestFee() *GasFee {
+	oracle.mtx.RLock()
+	defer oracle.mtx.RUnlock()
+	return oracle.fee
+}
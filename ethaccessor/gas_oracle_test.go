@@ -0,0 +1,132 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ethaccessor_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/ethereum/go-ethereum/common"
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func TestGasOracle(t *testing.T) {
+	ethaccessor.InitializeOffline([]*ethaccessor.ProtocolAddress{}, big.NewInt(5e9))
+	if gasPrice := ethaccessor.EstimateGasPrice(big.NewInt(1e9), big.NewInt(20e9)); gasPrice.Cmp(big.NewInt(5e9)) != 0 {
+		t.Fatalf("gasPrice should be 5 gwei, but got:%s", gasPrice.String())
+	}
+	if fee := ethaccessor.SuggestFee(); fee.IsDynamic() {
+		t.Fatalf("fee of offline accessor should be legacy")
+	}
+
+	oracle := ethaccessor.NewFixedGasOracle(big.NewInt(30e9), big.NewInt(2e9))
+	ethaccessor.SetGasOracle(oracle)
+	fee := ethaccessor.SuggestFee()
+	if !fee.IsDynamic() || fee.FeeCap.Cmp(big.NewInt(62e9)) != 0 {
+		t.Fatalf("unexpected fee:%+v", fee)
+	}
+	if gasPrice := ethaccessor.EstimateGasPrice(big.NewInt(1e9), big.NewInt(20e9)); gasPrice.Cmp(big.NewInt(20e9)) != 0 {
+		t.Fatalf("gasPrice should be limited by max gas price, but got:%s", gasPrice.String())
+	}
+
+	oracle.Set(big.NewInt(3e9), nil)
+	if gasPrice := ethaccessor.EstimateGasPrice(big.NewInt(4e9), big.NewInt(20e9)); gasPrice.Cmp(big.NewInt(4e9)) != 0 {
+		t.Fatalf("gasPrice should be limited by min gas price, but got:%s", gasPrice.String())
+	}
+}
+
+func TestGasFee_MaxFeePerGas(t *testing.T) {
+	ethaccessor.InitializeOffline([]*ethaccessor.ProtocolAddress{}, big.NewInt(5e9))
+	if feeCap := ethaccessor.MaxFeePerGas(big.NewInt(5e9), big.NewInt(20e9)); feeCap.Cmp(big.NewInt(5e9)) != 0 {
+		t.Fatalf("max fee per gas of legacy tx should be the gas price, but got:%s", feeCap.String())
+	}
+
+	//baseFee:30 gwei, tip:2 gwei, feeCap:62 gwei
+	fee := ethaccessor.NewFixedGasOracle(big.NewInt(30e9), big.NewInt(2e9)).SuggestFee()
+	if feeCap := fee.MaxFeePerGas(fee.EffectiveGasPrice(), big.NewInt(100e9)); feeCap.Cmp(big.NewInt(62e9)) != 0 {
+		t.Fatalf("max fee per gas should be the fee cap of oracle, but got:%s", feeCap.String())
+	}
+	if feeCap := fee.MaxFeePerGas(fee.EffectiveGasPrice(), big.NewInt(40e9)); feeCap.Cmp(big.NewInt(40e9)) != 0 {
+		t.Fatalf("max fee per gas should be limited by max gas price, but got:%s", feeCap.String())
+	}
+	if feeCap := fee.MaxFeePerGas(big.NewInt(45e9), big.NewInt(40e9)); feeCap.Cmp(big.NewInt(45e9)) != 0 {
+		t.Fatalf("max fee per gas shouldn't be less than the gas price, but got:%s", feeCap.String())
+	}
+	if feeCap := fee.MaxFeePerGas(fee.EffectiveGasPrice(), nil); feeCap.Cmp(big.NewInt(62e9)) != 0 {
+		t.Fatalf("max fee per gas should be the fee cap of oracle, but got:%s", feeCap.String())
+	}
+}
+
+func TestDynamicFeeTx(t *testing.T) {
+	key, err := ethCrypto.GenerateKey()
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	to := common.HexToAddress("0x750aD4351bB728ceC7d639A9511F9D6488f1E259")
+	tx := &ethaccessor.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     7,
+		GasTipCap: big.NewInt(2e9),
+		GasFeeCap: big.NewInt(60e9),
+		Gas:       big.NewInt(500000),
+		To:        &to,
+		Value:     big.NewInt(0),
+		Data:      common.FromHex("0x1234"),
+	}
+	if _, err := tx.MarshalBinary(); nil == err {
+		t.Fatalf("unsigned tx can't be marshaled")
+	}
+
+	hash, err := tx.SigningHash()
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	sig, err := ethCrypto.Sign(hash.Bytes(), key)
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if err := tx.WithSignature(sig); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	raw, err := tx.MarshalBinary()
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if raw[0] != ethaccessor.DynamicFeeTxType {
+		t.Fatalf("raw tx should start with the type:%x", raw[0])
+	}
+
+	var fields []rlp.RawValue
+	if err := rlp.DecodeBytes(raw[1:], &fields); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if len(fields) != 12 {
+		t.Fatalf("type-2 tx should have 12 fields, but got:%d", len(fields))
+	}
+
+	pub, err := ethCrypto.Ecrecover(hash.Bytes(), sig)
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if common.BytesToAddress(ethCrypto.Keccak256(pub[1:])[12:]) != ethCrypto.PubkeyToAddress(key.PublicKey) {
+		t.Fatalf("signer can't be recovered from the signing hash")
+	}
+}
@@ -23,42 +23,43 @@ import (
 	"github.com/Loopring/relay/types"
 	"math/big"
 	"sort"
+	"sync"
 )
 
+//GasPriceEvaluator is the block sample GasOracle
 type GasPriceEvaluator struct {
 	Blocks []*BlockWithTxAndReceipt
 
+	mtx      sync.RWMutex
 	gasPrice *big.Int
 	stopChan chan bool
 }
 
 // lgh: all
 func (e *GasPriceEvaluator) GasPrice(minGasPrice, maxGasPrice *big.Int) *big.Int {
-	gasPrice := new(big.Int)
-	// gasPrice 默认是 bestGas 值
-	if nil != e.gasPrice {
-		if nil != maxGasPrice && maxGasPrice.Cmp(e.gasPrice) < 0 {
-			// maxGasPrice < 最好
-			gasPrice.Set(maxGasPrice) // maxGasPrice
-		} else if nil != minGasPrice && minGasPrice.Cmp(e.gasPrice) > 0 {
-			// minGasPrice > 最好 ， 且本身 minGasPrice < maxGasPrice
-			gasPrice.Set(minGasPrice) // minGasPrice
-		} else {
-			// minGasPrice < 最好 < maxGasPrice
-			gasPrice.Set(e.gasPrice) // gasPrice
-		}
-	} else {
-		gasPrice.Set(maxGasPrice)
-	}
-	// 上面的模式貌似:
+	// lgh: 模式:
 	// 最好 < minGasPrice < maxGasPrice ==> minGasPrice	==> 设定的最小
 	// minGasPrice < 最好 < maxGasPrice ==> 最好 			==> 中间
 	// minGasPrice < maxGasPrice < 最好 ==> maxGasPrice	==> 设定的最大
-	return gasPrice
+	var price *big.Int
+	if fee := e.SuggestFee(); nil != fee {
+		price = fee.GasPrice
+	}
+	return limitGasPrice(price, minGasPrice, maxGasPrice)
+}
+
+func (e *GasPriceEvaluator) SuggestFee() *GasFee {
+	e.mtx.RLock()
+	defer e.mtx.RUnlock()
+	if nil == e.gasPrice {
+		return nil
+	}
+	return &GasFee{GasPrice: new(big.Int).Set(e.gasPrice)}
 }
 
 // lgh: todo 理解它
-func (e *GasPriceEvaluator) start() {
+func (e *GasPriceEvaluator) Start() {
+	e.stopChan = make(chan bool, 1)
 	var blockNumber types.Big
 	// lgh: BlockNumber 获取新的一个区块
 	if err := BlockNumber(&blockNumber); nil == err {
@@ -74,7 +75,7 @@ func (e *GasPriceEvaluator) start() {
 					blockInterface, err := iterator.Next()
 					if nil == err {
 						blockWithTxAndReceipt := blockInterface.(*BlockWithTxAndReceipt)
-						log.Debugf("gasPriceEvaluator, blockNumber:%s, gasPrice:%s", blockWithTxAndReceipt.Number.BigInt().String(), e.gasPrice.String())
+						log.Debugf("gasPriceEvaluator, blockNumber:%s", blockWithTxAndReceipt.Number.BigInt().String())
 						e.Blocks = append(e.Blocks, blockWithTxAndReceipt)
 						if len(e.Blocks) > 30 {
 							e.Blocks = e.Blocks[1:]
@@ -85,7 +86,9 @@ func (e *GasPriceEvaluator) start() {
 								prices = append(prices, tx.GasPrice.BigInt())
 							}
 						}
+						e.mtx.Lock()
 						e.gasPrice = prices.bestGasPrice()
+						e.mtx.Unlock()
 					}
 				}
 			}
@@ -94,8 +97,10 @@ func (e *GasPriceEvaluator) start() {
 	}
 }
 
-func (e *GasPriceEvaluator) stop() {
-	e.stopChan <- true
+func (e *GasPriceEvaluator) Stop() {
+	if nil != e.stopChan {
+		e.stopChan <- true
+	}
 }
 
 type gasPrices []*big.Int
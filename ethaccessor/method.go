@@ -286,20 +286,56 @@ func (accessor *ethNodeAccessor) BatchTransactionRecipients(routeParam string, r
 	return nil
 }
 
+func (accessor *ethNodeAccessor) BatchBlocksWithTxObject(routeParam string, retry int, startNumber, endNumber *big.Int) ([]*BlockWithTxObject, error) {
+	if nil == startNumber || nil == endNumber || startNumber.Cmp(endNumber) > 0 || retry < 1 {
+		return nil, fmt.Errorf("ethaccessor:batchBlocksWithTxObject retry or block range invalid")
+	}
+
+	blocks := []*BlockWithTxObject{}
+	reqElems := []rpc.BatchElem{}
+	for number := new(big.Int).Set(startNumber); number.Cmp(endNumber) <= 0; number.Add(number, big.NewInt(1)) {
+		block := &BlockWithTxObject{}
+		blocks = append(blocks, block)
+		reqElems = append(reqElems, rpc.BatchElem{
+			Method: "eth_getBlockByNumber",
+			Args:   []interface{}{fmt.Sprintf("%#x", number), true},
+			Result: block,
+		})
+	}
+
+	if _, err := accessor.RetryBatchCall(routeParam, reqElems, retry); err != nil {
+		return nil, err
+	}
+
+	for idx, v := range reqElems {
+		if nil != v.Error {
+			return nil, v.Error
+		}
+		if blocks[idx].IsNull() {
+			return nil, fmt.Errorf("there isn't a block with number:%s", new(big.Int).Add(startNumber, big.NewInt(int64(idx))).String())
+		}
+	}
+
+	return blocks, nil
+}
+
 func (accessor *ethNodeAccessor) EstimateGas(
 	routeParam string,
 	callData []byte,
 	to common.Address) (gas, gasPrice *big.Int, err error) {
 
 	var gasBig, gasPriceBig types.Big
-	if nil == accessor.gasPriceEvaluator.gasPrice ||
-		accessor.gasPriceEvaluator.gasPrice.Cmp(big.NewInt(int64(0))) <= 0 {
+	var suggested *big.Int
+	if fee := accessor.gasOracle().SuggestFee(); nil != fee {
+		suggested = fee.EffectiveGasPrice()
+	}
+	if nil == suggested || suggested.Sign() <= 0 {
 		if err = accessor.RetryCall(routeParam, 2, &gasPriceBig, "eth_gasPrice");
 		nil != err {
 			return
 		}
 	} else {
-		gasPriceBig = new(types.Big).SetInt(accessor.gasPriceEvaluator.gasPrice)
+		gasPriceBig = new(types.Big).SetInt(suggested)
 	}
 
 	callArg := &CallArg{}
@@ -349,17 +385,24 @@ func (ethAccessor *ethNodeAccessor) SignAndSendTransaction(result interface{}, s
 }
 
 func (accessor *ethNodeAccessor) ContractSendTransactionByData(routeParam string, sender common.Address, to common.Address, gas, gasPrice, value *big.Int, callData []byte, needPreExe bool) (string, error) {
+	txHash, _, err := accessor.ContractSendTransactionWithNonce(routeParam, sender, to, gas, gasPrice, nil, value, callData, needPreExe)
+	return txHash, err
+}
+
+//ContractSendTransactionWithNonce is the same as ContractSendTransactionByData, but returns the nonce used by the tx.
+//maxFeePerGas is used by type-2 tx, the fee cap suggested by gas oracle is used if it's nil
+func (accessor *ethNodeAccessor) ContractSendTransactionWithNonce(routeParam string, sender common.Address, to common.Address, gas, gasPrice, maxFeePerGas, value *big.Int, callData []byte, needPreExe bool) (string, *big.Int, error) {
 	if nil == gasPrice || gasPrice.Cmp(big.NewInt(0)) <= 0 {
-		return "", errors.New("gasPrice must be setted.")
+		return "", nil, errors.New("gasPrice must be setted.")
 	}
 	if nil == gas || gas.Cmp(big.NewInt(0)) <= 0 {
-		return "", errors.New("gas must be setted.")
+		return "", nil, errors.New("gas must be setted.")
 	}
 	var txHash string
 	if needPreExe {
 		// lgh: 是否还需要估算一次 gas。目前最后提交环是不再需要
 		if estimagetGas, _, err := EstimateGas(callData, to, "latest"); nil != err {
-			return txHash, err
+			return txHash, nil, err
 		} else {
 			gas = estimagetGas
 		}
@@ -374,34 +417,118 @@ func (accessor *ethNodeAccessor) ContractSendTransactionByData(routeParam string
 	//if gas.Cmp(big.NewInt(int64(350000)))  {
 	gas.SetString("500000", 0) // lgh: todo 这里居然固定死了一次油费，记得解开
 	//}
-	transaction := ethTypes.NewTransaction(
-		nonce.Uint64(),
-		common.HexToAddress(to.Hex()),
-		value,
-		gas,
-		gasPrice,
-		callData)
-	if err := accessor.SignAndSendTransaction(&txHash, sender, transaction); nil != err {
+	if err := accessor.signAndSend(&txHash, sender, nonce, to, value, gas, gasPrice, nil, maxFeePerGas, callData); nil != err {
 		//if err.Error() == "nonce too low" {
 		// lgh: 如果提交出错，那么 resetAddressNonce 强制从以太坊获取 nonce 并刷新缓存
 		accessor.resetAddressNonce(sender)
 		nonce = accessor.addressCurrentNonce(sender)
-		transaction = ethTypes.NewTransaction(nonce.Uint64(),
-			common.HexToAddress(to.Hex()),
-			value,
-			gas,
-			gasPrice,
-			callData)
-		if err := accessor.SignAndSendTransaction(&txHash, sender, transaction); nil != err {
+		if err := accessor.signAndSend(&txHash, sender, nonce, to, value, gas, gasPrice, nil, maxFeePerGas, callData); nil != err {
 			log.Errorf("send raw transaction err:%s, manual check it please.", err.Error())
-			return "", err
+			return "", nil, err
 		}
 		//} else {
 		//
 		//}
 	}
 	accessor.addressNextNonce(sender)  // 内部进行了 nonce 的 ++，所以上面 addressCurrentNonce 就是 ++ 后的
-	return txHash, nil // lgh: 返回交易单的 hash
+	return txHash, nonce, nil // lgh: 返回交易单的 hash
+}
+
+//ReplaceTransaction sends a tx with the nonce of a pending tx, the nonce cache of sender isn't changed.
+//tipCap and feeCap are used by type-2 tx, they should be bumped from the pending one by the caller
+func (accessor *ethNodeAccessor) ReplaceTransaction(sender common.Address, to common.Address, gas, gasPrice, tipCap, feeCap, value *big.Int, callData []byte, nonce *big.Int) (string, error) {
+	if nil == gasPrice || gasPrice.Sign() <= 0 || nil == gas || gas.Sign() <= 0 {
+		return "", errors.New("gas and gasPrice must be setted.")
+	}
+	if nil == value {
+		value = big.NewInt(0)
+	}
+	var txHash string
+	if err := accessor.signAndSend(&txHash, sender, nonce, to, value, gas, gasPrice, tipCap, feeCap, callData); nil != err {
+		return "", err
+	}
+	return txHash, nil
+}
+
+//signAndSend sends a type-2 tx if it's enabled and the chain supports it, gasPrice is only used by legacy tx.
+//the tip and the fee cap suggested by gas oracle are used if they are nil, the tip isn't higher than the fee cap
+func (accessor *ethNodeAccessor) signAndSend(result interface{}, sender common.Address, nonce *big.Int, to common.Address, value, gas, gasPrice, maxPriorityFeePerGas, maxFeePerGas *big.Int, callData []byte) error {
+	if fee, chainId := accessor.dynamicFee(); nil != fee {
+		feeCap := maxFeePerGas
+		if nil == feeCap {
+			feeCap = fee.MaxFeePerGas(gasPrice, nil)
+		}
+		tipCap := new(big.Int).Set(fee.TipCap)
+		if nil != maxPriorityFeePerGas {
+			tipCap.Set(maxPriorityFeePerGas)
+		}
+		if tipCap.Cmp(feeCap) > 0 {
+			tipCap.Set(feeCap)
+		}
+		tx := &DynamicFeeTx{
+			ChainID:   chainId,
+			Nonce:     nonce.Uint64(),
+			GasTipCap: tipCap,
+			GasFeeCap: new(big.Int).Set(feeCap),
+			Gas:       new(big.Int).Set(gas),
+			To:        &to,
+			Value:     value,
+			Data:      callData,
+		}
+		return accessor.SignAndSendDynamicFeeTransaction(result, sender, tx)
+	}
+	transaction := ethTypes.NewTransaction(nonce.Uint64(), to, value, gas, gasPrice, callData)
+	return accessor.SignAndSendTransaction(result, sender, transaction)
+}
+
+//dynamicFee returns nil if the legacy tx should be used
+func (accessor *ethNodeAccessor) dynamicFee() (*GasFee, *big.Int) {
+	if !accessor.dynamicFeeTx {
+		return nil, nil
+	}
+	fee := accessor.gasOracle().SuggestFee()
+	if nil == fee || !fee.IsDynamic() {
+		return nil, nil
+	}
+	accessor.oracleMtx.RLock()
+	chainId := accessor.chainId
+	accessor.oracleMtx.RUnlock()
+	if nil == chainId {
+		var chainIdBig types.Big
+		if err := accessor.RetryCall("latest", 2, &chainIdBig, "eth_chainId"); nil != err {
+			log.Errorf("accessor, can't get chain id, legacy tx will be used, err:%s", err.Error())
+			return nil, nil
+		}
+		chainId = chainIdBig.BigInt()
+		accessor.oracleMtx.Lock()
+		accessor.chainId = chainId
+		accessor.oracleMtx.Unlock()
+	}
+	return fee, chainId
+}
+
+func (accessor *ethNodeAccessor) SignAndSendDynamicFeeTransaction(result interface{}, sender common.Address, tx *DynamicFeeTx) error {
+	hash, err := tx.SigningHash()
+	if nil != err {
+		return err
+	}
+	sig, err := crypto.SignHash(hash.Bytes(), sender)
+	if nil != err {
+		return err
+	}
+	if err := tx.WithSignature(sig); nil != err {
+		return err
+	}
+	txData, err := tx.MarshalBinary()
+	if nil != err {
+		return err
+	}
+	txHash, _ := tx.Hash()
+	log.Debugf("txhash:%s, type:2, nonce:%d, value:%s, gas:%s, maxFeePerGas:%s, maxPriorityFeePerGas:%s", txHash.Hex(), tx.Nonce, tx.Value.String(), tx.Gas.String(), tx.GasFeeCap.String(), tx.GasTipCap.String())
+	if err = accessor.RetryCall("latest", 2, result, "eth_sendRawTransaction", common.ToHex(txData)); nil != err {
+		log.Errorf("accessor, Sign and send transaction error:%s", err.Error())
+	}
+	return err
 }
 
 //gas, gasPrice can be set to nil
@@ -0,0 +1,93 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ethaccessor
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//the selector of Error(string)
+var revertSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+
+//the messages of eth node when eth_call reverts, old nodes don't return the reason
+var revertMessages = []string{"execution reverted", "always failing transaction", "invalid opcode", "vm exception"}
+
+//DecodeRevertReason decodes the abi encoded Error(string) returned by the reverted call
+func DecodeRevertReason(data []byte) (string, bool) {
+	if len(data) < 4+32+32 || !bytes.Equal(data[:4], revertSelector) {
+		return "", false
+	}
+	data = data[4:]
+	offset := new(big.Int).SetBytes(data[:32])
+	if !offset.IsUint64() || offset.Uint64()+32 > uint64(len(data)) {
+		return "", false
+	}
+	start := offset.Uint64()
+	length := new(big.Int).SetBytes(data[start : start+32])
+	if !length.IsUint64() || start+32+length.Uint64() > uint64(len(data)) {
+		return "", false
+	}
+	return string(data[start+32 : start+32+length.Uint64()]), true
+}
+
+//IsRevertError returns the reason if the error of eth_call means the tx reverted
+func IsRevertError(err error) (string, bool) {
+	if nil == err {
+		return "", false
+	}
+	msg := err.Error()
+	lower := strings.ToLower(msg)
+	for _, revertMsg := range revertMessages {
+		if idx := strings.Index(lower, revertMsg); idx >= 0 {
+			reason := strings.TrimSpace(strings.TrimPrefix(msg[idx+len(revertMsg):], ":"))
+			if "" == reason {
+				reason = msg
+			}
+			return reason, true
+		}
+	}
+	return "", false
+}
+
+//DryRunTransaction executes the tx by eth_call, err is returned only if the call can't be done
+func DryRunTransaction(from, to common.Address, gas, gasPrice *big.Int, data []byte, blockParameter string) (reverted bool, reason string, err error) {
+	arg := &CallArg{From: from, To: to, Data: common.ToHex(data)}
+	if nil != gas {
+		arg.Gas = *types.NewBigPtr(gas)
+	}
+	if nil != gasPrice {
+		arg.GasPrice = *types.NewBigPtr(gasPrice)
+	}
+	var result string
+	if err = Call(&result, arg, blockParameter); nil != err {
+		if reason, reverted = IsRevertError(err); reverted {
+			return reverted, reason, nil
+		}
+		return false, "", err
+	}
+	if reason, reverted = DecodeRevertReason(common.FromHex(result)); reverted {
+		return reverted, reason, nil
+	}
+	return false, "", nil
+}
@@ -0,0 +1,56 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package ethaccessor_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDecodeRevertReason(t *testing.T) {
+	//Error("not enough balance")
+	data := common.FromHex("0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000012" +
+		"6e6f7420656e6f7567682062616c616e63650000000000000000000000000000")
+	if reason, ok := ethaccessor.DecodeRevertReason(data); !ok || reason != "not enough balance" {
+		t.Fatalf("unexpected reason:%s", reason)
+	}
+	if _, ok := ethaccessor.DecodeRevertReason(data[:40]); ok {
+		t.Fatalf("truncated data can't be decoded")
+	}
+	if _, ok := ethaccessor.DecodeRevertReason(common.FromHex("0x0000000000000000000000000000000000000000000000000000000000000001")); ok {
+		t.Fatalf("normal return data isn't a revert")
+	}
+}
+
+func TestIsRevertError(t *testing.T) {
+	if reason, ok := ethaccessor.IsRevertError(errors.New("execution reverted: ring is invalid")); !ok || reason != "ring is invalid" {
+		t.Fatalf("unexpected reason:%s", reason)
+	}
+	if reason, ok := ethaccessor.IsRevertError(errors.New("VM Exception while processing transaction: revert")); !ok || "" == reason {
+		t.Fatalf("vm exception should be a revert")
+	}
+	if _, ok := ethaccessor.IsRevertError(errors.New("connection refused")); ok {
+		t.Fatalf("network error isn't a revert")
+	}
+}
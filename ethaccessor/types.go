@@ -79,6 +79,10 @@ type Transaction struct {
 	R                string    `json:"r"`
 	S                string    `json:"s"`
 	V                string    `json:"v"`
+
+	//only type-2 tx has the caps
+	MaxPriorityFeePerGas types.Big `json:"maxPriorityFeePerGas"`
+	MaxFeePerGas         types.Big `json:"maxFeePerGas"`
 }
 
 func (tx *Transaction) MethodId() string {
@@ -191,6 +195,14 @@ type BlockIterator struct {
 	confirms      uint64
 }
 
+//LogFilter is the filter of eth_getLogs, the logs match any of the addresses and any of the topics at each position
+type LogFilter struct {
+	FromBlock string           `json:"fromBlock"`
+	ToBlock   string           `json:"toBlock"`
+	Address   []common.Address `json:"address,omitempty"`
+	Topics    [][]common.Hash  `json:"topics,omitempty"`
+}
+
 type CallArg struct {
 	From     common.Address `json:"from"`
 	To       common.Address `json:"to"`
@@ -0,0 +1,565 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package eventemitter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Loopring/relay/log"
+)
+
+/**
+所有durable topic的事件按emit的顺序写入EventStore中的同一个log，每个subscriber(按名称区分，可以订阅多个topic)只有一个Cursor，
+按offset顺序读取事件并交给对应topic的Watcher，所以不同topic的事件(比如OrderFilled和ChainForkDetected)也按emit的顺序处理。
+处理失败后在retryInterval之后重新投递，超过maxRedeliveries次后跳过该事件(at-least-once)，在此之前该subscriber的后续事件都不会投递。
+subscriber订阅过但当前没有Watcher的topic(UnDurable或者重启后还未OnDurable)的事件会暂停投递，直到OnDurable或者DropDurable。
+subscriber已经追上最新事件时，Emit会像普通的Watcher一样同步调用它；subscriber正在投递时(比如正在重新投递)，事件由正在进行的投递处理。
+Cursor每批事件提交一次，EventStore每隔flushInterval写入磁盘并compact，所以重启后可能会重复投递最近的事件。
+事件用json序列化，事件的类型必须先通过RegisterEventType注册，未导出的字段不会被持久化。
+*/
+
+const deliverBatchSize = 100
+
+var (
+	store           EventStore
+	durableTopics   map[string]bool
+	subscribers     map[string]*durableSubscriber
+	durableMtx      sync.RWMutex
+	retryInterval   time.Duration
+	maxRedeliveries int
+	stopDurable     chan struct{}
+
+	eventTypes    map[string]reflect.Type
+	eventTypesMtx sync.RWMutex
+)
+
+type DurableOptions struct {
+	Topics          []string
+	RetryInterval   time.Duration
+	MaxRedeliveries int           //the event is skipped after failed so many times, 0 means redelivering until success
+	FlushInterval   time.Duration //the store is flushed and compacted in every interval
+}
+
+type durableSubscriber struct {
+	name            string
+	maxRedeliveries int
+	store           EventStore
+	stop            chan struct{}
+
+	mtx        sync.Mutex
+	watchers   map[string]*Watcher
+	cursor     Cursor
+	failures   int
+	seeks      int
+	delivering bool
+	pending    bool
+}
+
+//InitializeDurable enables the persistent log for topics, it must be called before On and Emit
+func InitializeDurable(eventStore EventStore, options DurableOptions) {
+	durableMtx.Lock()
+	defer durableMtx.Unlock()
+
+	store = eventStore
+	durableTopics = make(map[string]bool)
+	for _, topic := range options.Topics {
+		durableTopics[topic] = true
+	}
+	subscribers = make(map[string]*durableSubscriber)
+	retryInterval = options.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = time.Second
+	}
+	maxRedeliveries = options.MaxRedeliveries
+	stopDurable = make(chan struct{})
+
+	flushInterval := options.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+	go flush(eventStore, flushInterval, stopDurable)
+}
+
+//CloseDurable stops redelivering and closes the store, the store flushes itself when closing
+func CloseDurable() error {
+	durableMtx.Lock()
+	defer durableMtx.Unlock()
+
+	if nil == store {
+		return nil
+	}
+	close(stopDurable)
+	err := store.Close()
+	store = nil
+	durableTopics = nil
+	subscribers = nil
+	return err
+}
+
+func flush(s EventStore, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			durableMtx.RLock()
+			if s == store {
+				if err := s.Flush(); nil != err {
+					log.Errorf("eventemitter, can't flush event store, err:%s", err.Error())
+				} else if err := s.Compact(); nil != err {
+					log.Errorf("eventemitter, can't compact event store, err:%s", err.Error())
+				}
+			}
+			durableMtx.RUnlock()
+		}
+	}
+}
+
+func IsDurable(topic string) bool {
+	durableMtx.RLock()
+	defer durableMtx.RUnlock()
+
+	return durableTopics[topic]
+}
+
+//RegisterEventType registers the types of the events emitted to durable topics, such as &types.OrderFilledEvent{}
+func RegisterEventType(samples ...EventData) {
+	eventTypesMtx.Lock()
+	defer eventTypesMtx.Unlock()
+
+	for _, sample := range samples {
+		typ := reflect.TypeOf(sample)
+		eventTypes[typeName(typ)] = typ
+	}
+}
+
+//typeName contains the package path, the name of reflect.Type may be same in different packages
+func typeName(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Ptr:
+		return "*" + typeName(typ.Elem())
+	case reflect.Slice:
+		return "[]" + typeName(typ.Elem())
+	case reflect.Map:
+		return "map[" + typeName(typ.Key()) + "]" + typeName(typ.Elem())
+	}
+	if "" != typ.PkgPath() {
+		return typ.PkgPath() + "." + typ.Name()
+	}
+	return typ.String()
+}
+
+func encodeEvent(eventData EventData) (*StoredEvent, error) {
+	if nil == eventData {
+		return nil, errors.New("event can't be nil")
+	}
+	name := typeName(reflect.TypeOf(eventData))
+	eventTypesMtx.RLock()
+	_, exists := eventTypes[name]
+	eventTypesMtx.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("event type:%s isn't registered", name)
+	}
+	data, err := json.Marshal(eventData)
+	if nil != err {
+		return nil, err
+	}
+	return &StoredEvent{Type: name, Data: data, Time: time.Now().Unix()}, nil
+}
+
+func decodeEvent(event *StoredEvent) (EventData, error) {
+	eventTypesMtx.RLock()
+	typ, exists := eventTypes[event.Type]
+	eventTypesMtx.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("event type:%s isn't registered", event.Type)
+	}
+	value := reflect.New(typ)
+	if err := json.Unmarshal(event.Data, value.Interface()); nil != err {
+		return nil, err
+	}
+	return value.Elem().Interface(), nil
+}
+
+//OnDurable subscribes topic with the name of subscriber, the events emitted while it is stopped will be delivered after it subscribes again.
+//The events of all the topics subscribed with the same name are delivered in the order of emitting.
+//It is same as On if the topic isn't durable or the cursor of subscriber can't be read.
+func OnDurable(topic, subscriber string, watcher *Watcher) {
+	if !IsDurable(topic) {
+		On(topic, watcher)
+		return
+	}
+
+	sub, err := durableSubscriberOf(subscriber)
+	if nil == err {
+		err = sub.subscribe(topic, watcher)
+	}
+	if nil != err {
+		log.Errorf("eventemitter, subscriber:%s can't subscribe durable topic:%s, err:%s", subscriber, topic, err.Error())
+		On(topic, watcher)
+		return
+	}
+	go sub.deliver()
+}
+
+func durableSubscriberOf(subscriber string) (*durableSubscriber, error) {
+	durableMtx.Lock()
+	defer durableMtx.Unlock()
+
+	if sub, exists := subscribers[subscriber]; exists {
+		return sub, nil
+	}
+
+	cursor, committed, err := store.Cursor(subscriber)
+	if nil != err {
+		return nil, err
+	}
+	//the new subscriber only receives the events emitted after it subscribes
+	if !committed {
+		if cursor.Next, err = store.NextOffset(); nil != err {
+			return nil, err
+		}
+	}
+	sub := &durableSubscriber{
+		name:            subscriber,
+		maxRedeliveries: maxRedeliveries,
+		store:           store,
+		stop:            stopDurable,
+		watchers:        make(map[string]*Watcher),
+		cursor:          cursor,
+	}
+	subscribers[subscriber] = sub
+	go sub.redeliver(retryInterval)
+	return sub, nil
+}
+
+//UnDurable pauses the subscriber, the cursor is kept, and the events of the other topics after the first event of topic are held as well
+func UnDurable(topic, subscriber string, watcher *Watcher) {
+	//the watcher is subscribed by On if OnDurable failed
+	Un(topic, watcher)
+
+	if sub := subscriberOf(subscriber); nil != sub {
+		sub.mtx.Lock()
+		defer sub.mtx.Unlock()
+		delete(sub.watchers, topic)
+	}
+}
+
+//DropDurable unsubscribes topic permanently, its events aren't delivered to subscriber any more
+func DropDurable(topic, subscriber string, watcher *Watcher) error {
+	UnDurable(topic, subscriber, watcher)
+
+	durableMtx.RLock()
+	s := store
+	sub, exists := subscribers[subscriber]
+	durableMtx.RUnlock()
+	if nil == s {
+		return errors.New("durable isn't initialized")
+	}
+	if !exists {
+		cursor, committed, err := s.Cursor(subscriber)
+		if nil != err || !committed {
+			return err
+		}
+		delete(cursor.Topics, topic)
+		return s.Commit(subscriber, cursor)
+	}
+
+	sub.mtx.Lock()
+	delete(sub.cursor.Topics, topic)
+	err := sub.commit()
+	sub.mtx.Unlock()
+	go sub.deliver()
+	return err
+}
+
+func subscriberOf(subscriber string) *durableSubscriber {
+	durableMtx.RLock()
+	defer durableMtx.RUnlock()
+
+	return subscribers[subscriber]
+}
+
+//Seek resets the cursor of subscriber, the events from offset will be delivered again
+func Seek(subscriber string, offset int64) error {
+	durableMtx.RLock()
+	s := store
+	sub, exists := subscribers[subscriber]
+	durableMtx.RUnlock()
+	if nil == s {
+		return errors.New("durable isn't initialized")
+	}
+
+	if exists {
+		sub.mtx.Lock()
+		defer sub.mtx.Unlock()
+		sub.cursor.Next = offset
+		sub.failures = 0
+		sub.seeks++
+		return sub.commit()
+	}
+	cursor, _, err := s.Cursor(subscriber)
+	if nil != err {
+		return err
+	}
+	cursor.Next = offset
+	return s.Commit(subscriber, cursor)
+}
+
+//Replay reads the events of topics from offset in the order of emitting, and returns the next offset after the last handled event
+func Replay(topics []string, from int64, handle func(offset int64, topic string, eventData EventData) error) (int64, error) {
+	durableMtx.RLock()
+	s := store
+	replayed := make(map[string]bool)
+	for _, topic := range topics {
+		if !durableTopics[topic] {
+			durableMtx.RUnlock()
+			return from, fmt.Errorf("topic:%s isn't durable", topic)
+		}
+		replayed[topic] = true
+	}
+	durableMtx.RUnlock()
+
+	for {
+		events, err := s.Read(from, deliverBatchSize)
+		if nil != err {
+			return from, err
+		}
+		if len(events) == 0 {
+			return from, nil
+		}
+		for _, event := range events {
+			if replayed[event.Topic] {
+				eventData, err := decodeEvent(event)
+				if nil != err {
+					return from, err
+				}
+				if err := handle(event.Offset, event.Topic, eventData); nil != err {
+					return from, err
+				}
+			}
+			from = event.Offset + 1
+		}
+	}
+}
+
+//emitDurable persists the event, and delivers it to the subscribers
+func emitDurable(topic string, eventData EventData) {
+	durableMtx.RLock()
+	s := store
+	subs := []*durableSubscriber{}
+	for _, sub := range subscribers {
+		subs = append(subs, sub)
+	}
+	durableMtx.RUnlock()
+	if nil == s {
+		return
+	}
+
+	event, err := encodeEvent(eventData)
+	if nil != err {
+		log.Errorf("eventemitter, can't encode event of topic:%s, err:%s", topic, err.Error())
+		return
+	}
+	event.Topic = topic
+	if _, err := s.Append(event); nil != err {
+		log.Errorf("eventemitter, can't persist event of topic:%s, err:%s", topic, err.Error())
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, sub := range subs {
+		watcher := sub.watcherOf(topic)
+		if nil == watcher {
+			continue
+		}
+		if watcher.Concurrent {
+			go sub.deliver()
+		} else {
+			wg.Add(1)
+			go func(sub *durableSubscriber) {
+				defer wg.Done()
+				sub.deliver()
+			}(sub)
+		}
+	}
+	wg.Wait()
+}
+
+func (sub *durableSubscriber) subscribe(topic string, watcher *Watcher) error {
+	sub.mtx.Lock()
+	defer sub.mtx.Unlock()
+
+	sub.watchers[topic] = watcher
+	if nil == sub.cursor.Topics {
+		sub.cursor.Topics = make(map[string]int64)
+	}
+	if _, exists := sub.cursor.Topics[topic]; exists {
+		return nil
+	}
+	since, err := sub.store.NextOffset()
+	if nil != err {
+		return err
+	}
+	sub.cursor.Topics[topic] = since
+	return sub.commit()
+}
+
+func (sub *durableSubscriber) watcherOf(topic string) *Watcher {
+	sub.mtx.Lock()
+	defer sub.mtx.Unlock()
+	return sub.watchers[topic]
+}
+
+//commit must be called with mtx held
+func (sub *durableSubscriber) commit() error {
+	if sub.stopped() {
+		return nil
+	}
+	return sub.store.Commit(sub.name, sub.cursor)
+}
+
+//stopped returns true after CloseDurable, the store of subscriber has been closed
+func (sub *durableSubscriber) stopped() bool {
+	select {
+	case <-sub.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (sub *durableSubscriber) redeliver(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sub.stop:
+			return
+		case <-ticker.C:
+			sub.deliver()
+		}
+	}
+}
+
+//deliver handles the events from the cursor until the latest one, failed or paused.
+//Only one goroutine delivers at a time, the others mark it pending and return, so a watcher can emit to its own subscriber
+func (sub *durableSubscriber) deliver() {
+	sub.mtx.Lock()
+	if sub.delivering {
+		sub.pending = true
+		sub.mtx.Unlock()
+		return
+	}
+	sub.delivering = true
+	sub.mtx.Unlock()
+
+	for {
+		blocked := sub.deliverEvents()
+		sub.mtx.Lock()
+		//the blocked event is retried by redeliver after retryInterval
+		if blocked || !sub.pending {
+			sub.delivering = false
+			sub.pending = false
+			sub.mtx.Unlock()
+			return
+		}
+		sub.pending = false
+		sub.mtx.Unlock()
+	}
+}
+
+//deliverEvents returns true if an event failed or its watcher is absent
+func (sub *durableSubscriber) deliverEvents() bool {
+	sub.mtx.Lock()
+	defer sub.mtx.Unlock()
+	//the cursor is committed once for all the events delivered
+	next := sub.cursor.Next
+	defer func() {
+		if next == sub.cursor.Next {
+			return
+		}
+		if err := sub.commit(); nil != err {
+			log.Errorf("eventemitter, subscriber:%s can't commit cursor, err:%s", sub.name, err.Error())
+		}
+	}()
+
+	for {
+		if sub.stopped() {
+			return true
+		}
+		seeks := sub.seeks
+		events, err := sub.store.Read(sub.cursor.Next, deliverBatchSize)
+		if nil != err {
+			log.Errorf("eventemitter, subscriber:%s can't read events, err:%s", sub.name, err.Error())
+			return true
+		}
+		if len(events) == 0 {
+			return false
+		}
+		if events[0].Offset > sub.cursor.Next {
+			log.Warnf("eventemitter, subscriber:%s lost events:%d-%d, they have been dropped by retention", sub.name, sub.cursor.Next, events[0].Offset-1)
+		}
+		for _, event := range events {
+			if since, subscribed := sub.cursor.Topics[event.Topic]; subscribed && event.Offset >= since {
+				//the watcher is removed by UnDurable or isn't subscribed again after restart
+				watcher := sub.watchers[event.Topic]
+				if nil == watcher {
+					return true
+				}
+
+				//the watcher is called without mtx, it may emit or seek
+				sub.mtx.Unlock()
+				eventData, err := decodeEvent(event)
+				if nil == err {
+					err = watcher.Handle(eventData)
+				} else {
+					log.Errorf("eventemitter, subscriber:%s skips event:%d of topic:%s, err:%s", sub.name, event.Offset, event.Topic, err.Error())
+					err = nil
+				}
+				sub.mtx.Lock()
+
+				if seeks != sub.seeks {
+					break
+				}
+				if nil != err {
+					sub.failures++
+					if sub.maxRedeliveries <= 0 || sub.failures <= sub.maxRedeliveries {
+						log.Errorf("eventemitter, subscriber:%s failed to handle event:%d of topic:%s, failures:%d, err:%s", sub.name, event.Offset, event.Topic, sub.failures, err.Error())
+						return true
+					}
+					log.Errorf("eventemitter, subscriber:%s skips event:%d of topic:%s after %d failures, err:%s", sub.name, event.Offset, event.Topic, sub.failures, err.Error())
+				}
+				sub.failures = 0
+			}
+			sub.cursor.Next = event.Offset + 1
+		}
+	}
+}
+
+func init() {
+	eventTypes = make(map[string]reflect.Type)
+}
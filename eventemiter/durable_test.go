@@ -0,0 +1,381 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package eventemitter_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/eventemiter"
+	"github.com/Loopring/relay/log"
+	"go.uber.org/zap"
+)
+
+type DurableEvent struct {
+	Seq int
+}
+
+const (
+	durableTopic      = "DurableTest"
+	otherDurableTopic = "OtherDurableTest"
+)
+
+func initializeDurable(store eventemitter.EventStore, maxRedeliveries int) {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+	eventemitter.InitializeDurable(store, eventemitter.DurableOptions{
+		Topics:          []string{durableTopic, otherDurableTopic},
+		RetryInterval:   50 * time.Millisecond,
+		MaxRedeliveries: maxRedeliveries,
+	})
+	eventemitter.RegisterEventType(&DurableEvent{})
+}
+
+func TestOnDurable_Redeliver(t *testing.T) {
+	initializeDurable(eventemitter.NewMemoryEventStore(), 0)
+	defer eventemitter.CloseDurable()
+
+	received := make(chan int, 10)
+	failed := false
+	watcher := &eventemitter.Watcher{Concurrent: false, Handle: func(eventData eventemitter.EventData) error {
+		e := eventData.(*DurableEvent)
+		if e.Seq == 1 && !failed {
+			failed = true
+			return errors.New("handle failed")
+		}
+		received <- e.Seq
+		return nil
+	}}
+	eventemitter.OnDurable(durableTopic, "redeliver", watcher)
+	eventemitter.Emit(durableTopic, &DurableEvent{Seq: 0})
+	eventemitter.Emit(durableTopic, &DurableEvent{Seq: 1})
+	eventemitter.Emit(durableTopic, &DurableEvent{Seq: 2})
+
+	for expected := 0; expected < 3; expected++ {
+		select {
+		case seq := <-received:
+			if seq != expected {
+				t.Fatalf("expected event:%d, but got:%d", expected, seq)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event:%d isn't redelivered", expected)
+		}
+	}
+}
+
+func TestUnDurable_Resume(t *testing.T) {
+	initializeDurable(eventemitter.NewMemoryEventStore(), 0)
+	defer eventemitter.CloseDurable()
+
+	received := make(chan int, 10)
+	watcher := &eventemitter.Watcher{Concurrent: false, Handle: func(eventData eventemitter.EventData) error {
+		received <- eventData.(*DurableEvent).Seq
+		return nil
+	}}
+	eventemitter.OnDurable(durableTopic, "resume", watcher)
+	eventemitter.Emit(durableTopic, &DurableEvent{Seq: 0})
+	eventemitter.UnDurable(durableTopic, "resume", watcher)
+	eventemitter.Emit(durableTopic, &DurableEvent{Seq: 1})
+	eventemitter.Emit(durableTopic, &DurableEvent{Seq: 2})
+	if len(received) != 1 {
+		t.Fatalf("the events shouldn't be delivered after UnDurable, received:%d", len(received))
+	}
+
+	eventemitter.OnDurable(durableTopic, "resume", watcher)
+	for expected := 0; expected < 3; expected++ {
+		select {
+		case seq := <-received:
+			if seq != expected {
+				t.Fatalf("expected event:%d, but got:%d", expected, seq)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event:%d isn't delivered after OnDurable", expected)
+		}
+	}
+
+	seqs := []int{}
+	next, err := eventemitter.Replay([]string{durableTopic}, 1, func(offset int64, topic string, eventData eventemitter.EventData) error {
+		seqs = append(seqs, eventData.(*DurableEvent).Seq)
+		return nil
+	})
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if next != 3 || len(seqs) != 2 || seqs[0] != 1 || seqs[1] != 2 {
+		t.Fatalf("unexpected replay, next:%d, events:%v", next, seqs)
+	}
+}
+
+func TestOnDurable_Order(t *testing.T) {
+	initializeDurable(eventemitter.NewMemoryEventStore(), 0)
+	defer eventemitter.CloseDurable()
+
+	received := make(chan string, 10)
+	failures := 0
+	watcher := func(topic string) *eventemitter.Watcher {
+		return &eventemitter.Watcher{Concurrent: false, Handle: func(eventData eventemitter.EventData) error {
+			e := eventData.(*DurableEvent)
+			if topic == durableTopic && e.Seq == 0 && failures < 2 {
+				failures++
+				return errors.New("handle failed")
+			}
+			received <- fmt.Sprintf("%s:%d", topic, e.Seq)
+			return nil
+		}}
+	}
+	eventemitter.OnDurable(durableTopic, "order", watcher(durableTopic))
+	eventemitter.OnDurable(otherDurableTopic, "order", watcher(otherDurableTopic))
+	eventemitter.Emit(durableTopic, &DurableEvent{Seq: 0})
+	eventemitter.Emit(otherDurableTopic, &DurableEvent{Seq: 1})
+
+	//the event of the other topic waits for the failed one
+	for _, expected := range []string{durableTopic + ":0", otherDurableTopic + ":1"} {
+		select {
+		case event := <-received:
+			if event != expected {
+				t.Fatalf("expected event:%s, but got:%s", expected, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event:%s isn't delivered", expected)
+		}
+	}
+}
+
+func TestOnDurable_Restart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eventstore")
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	received := make(chan string, 10)
+	watcher := func(topic string) *eventemitter.Watcher {
+		return &eventemitter.Watcher{Concurrent: false, Handle: func(eventData eventemitter.EventData) error {
+			received <- fmt.Sprintf("%s:%d", topic, eventData.(*DurableEvent).Seq)
+			return nil
+		}}
+	}
+
+	store, err := eventemitter.NewFileEventStore(eventemitter.FileEventStoreOptions{Dir: dir})
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	initializeDurable(store, 0)
+	eventemitter.OnDurable(durableTopic, "restart", watcher(durableTopic))
+	eventemitter.OnDurable(otherDurableTopic, "restart", watcher(otherDurableTopic))
+	eventemitter.CloseDurable()
+
+	//the events are emitted while the subscriber is stopped
+	if store, err = eventemitter.NewFileEventStore(eventemitter.FileEventStoreOptions{Dir: dir}); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	initializeDurable(store, 0)
+	eventemitter.Emit(durableTopic, &DurableEvent{Seq: 0})
+	eventemitter.Emit(otherDurableTopic, &DurableEvent{Seq: 1})
+	eventemitter.Emit(durableTopic, &DurableEvent{Seq: 2})
+	eventemitter.CloseDurable()
+
+	if store, err = eventemitter.NewFileEventStore(eventemitter.FileEventStoreOptions{Dir: dir}); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	initializeDurable(store, 0)
+	defer eventemitter.CloseDurable()
+
+	//the subscriber waits for the watcher of the first event
+	eventemitter.OnDurable(otherDurableTopic, "restart", watcher(otherDurableTopic))
+	select {
+	case event := <-received:
+		t.Fatalf("event:%s is delivered before the previous event", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	eventemitter.OnDurable(durableTopic, "restart", watcher(durableTopic))
+	for _, expected := range []string{durableTopic + ":0", otherDurableTopic + ":1", durableTopic + ":2"} {
+		select {
+		case event := <-received:
+			if event != expected {
+				t.Fatalf("expected event:%s, but got:%s", expected, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event:%s isn't delivered after restart", expected)
+		}
+	}
+}
+
+func TestOnDurable_EmitInWatcher(t *testing.T) {
+	initializeDurable(eventemitter.NewMemoryEventStore(), 0)
+	defer eventemitter.CloseDurable()
+
+	received := make(chan int, 10)
+	watcher := &eventemitter.Watcher{Concurrent: false, Handle: func(eventData eventemitter.EventData) error {
+		e := eventData.(*DurableEvent)
+		if e.Seq == 0 {
+			eventemitter.Emit(durableTopic, &DurableEvent{Seq: 1})
+		}
+		received <- e.Seq
+		return nil
+	}}
+	eventemitter.OnDurable(durableTopic, "reentrant", watcher)
+	eventemitter.Emit(durableTopic, &DurableEvent{Seq: 0})
+
+	for expected := 0; expected < 2; expected++ {
+		select {
+		case seq := <-received:
+			if seq != expected {
+				t.Fatalf("expected event:%d, but got:%d", expected, seq)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event:%d isn't delivered", expected)
+		}
+	}
+}
+
+func TestFileEventStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eventstore")
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := eventemitter.NewFileEventStore(eventemitter.FileEventStoreOptions{Dir: dir})
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	for i := 0; i < 3; i++ {
+		if offset, err := store.Append(&eventemitter.StoredEvent{Topic: durableTopic, Type: "test", Data: []byte(`{"Seq":1}`)}); nil != err {
+			t.Fatalf("err:%s", err.Error())
+		} else if offset != int64(i) {
+			t.Fatalf("expected offset:%d, but got:%d", i, offset)
+		}
+	}
+
+	//the cursors are written by Flush
+	if err := store.Commit("sub", eventemitter.Cursor{Next: 2}); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if _, err := os.Stat(dir + "/cursors"); !os.IsNotExist(err) {
+		t.Fatalf("the cursors shouldn't be written before Flush")
+	}
+	if err := store.Flush(); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if _, err := os.Stat(dir + "/cursors"); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	store.Close()
+
+	//the broken line written by a crashed process is dropped
+	file, _ := os.OpenFile(dir+"/00000000000000000000.log", os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"offset":3,"ty`)
+	file.Close()
+
+	store, err = eventemitter.NewFileEventStore(eventemitter.FileEventStoreOptions{Dir: dir})
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	defer store.Close()
+	if next, _ := store.NextOffset(); next != 3 {
+		t.Fatalf("expected next offset:3, but got:%d", next)
+	}
+	if cursor, exists, _ := store.Cursor("sub"); !exists || cursor.Next != 2 {
+		t.Fatalf("expected committed offset:2, but got:%d", cursor.Next)
+	}
+	events, err := store.Read(1, 10)
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if len(events) != 2 || events[0].Offset != 1 || events[1].Offset != 2 || events[0].Topic != durableTopic {
+		t.Fatalf("unexpected events:%d", len(events))
+	}
+	if offset, _ := store.Append(&eventemitter.StoredEvent{Topic: durableTopic, Type: "test", Data: []byte(`{}`)}); offset != 3 {
+		t.Fatalf("expected offset:3, but got:%d", offset)
+	}
+}
+
+func TestFileEventStore_Compact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "eventstore")
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	//every segment contains one event
+	options := eventemitter.FileEventStoreOptions{Dir: dir, SegmentSize: 1}
+	store, err := eventemitter.NewFileEventStore(options)
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := store.Append(&eventemitter.StoredEvent{Topic: durableTopic, Type: "test", Data: []byte(`{}`)}); nil != err {
+			t.Fatalf("err:%s", err.Error())
+		}
+	}
+	store.Commit("sub1", eventemitter.Cursor{Next: 4})
+	store.Commit("sub2", eventemitter.Cursor{Next: 3})
+	if err := store.Compact(); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 3 {
+		t.Fatalf("expected 2 segments and cursors, but got %d files", len(files))
+	}
+	if events, _ := store.Read(0, 10); len(events) != 2 || events[0].Offset != 3 {
+		t.Fatalf("the events consumed by all the subscribers should be dropped, events:%d", len(events))
+	}
+	store.Close()
+
+	//the cursors are flushed before compacting
+	options.Retention = time.Nanosecond
+	if store, err = eventemitter.NewFileEventStore(options); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	defer store.Close()
+	if cursor, _, _ := store.Cursor("sub2"); cursor.Next != 3 {
+		t.Fatalf("expected committed offset:3, but got:%d", cursor.Next)
+	}
+
+	//the expired segments are dropped except the last one
+	if err := store.Compact(); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if events, _ := store.Read(0, 10); len(events) != 1 || events[0].Offset != 4 {
+		t.Fatalf("the expired events should be dropped, events:%d", len(events))
+	}
+	if offset, _ := store.Append(&eventemitter.StoredEvent{Topic: durableTopic, Type: "test", Data: []byte(`{}`)}); offset != 5 {
+		t.Fatalf("expected offset:5, but got:%d", offset)
+	}
+}
+
+func TestMemoryEventStore_Compact(t *testing.T) {
+	store := eventemitter.NewMemoryEventStore()
+	for i := 0; i < 5; i++ {
+		store.Append(&eventemitter.StoredEvent{Topic: durableTopic, Type: "test", Data: []byte(`{}`)})
+	}
+	store.Commit("sub", eventemitter.Cursor{Next: 3})
+	store.Compact()
+	if events, _ := store.Read(0, 10); len(events) != 2 || events[0].Offset != 3 {
+		t.Fatalf("the consumed events should be dropped, events:%d", len(events))
+	}
+	if offset, _ := store.Append(&eventemitter.StoredEvent{Topic: durableTopic, Type: "test", Data: []byte(`{}`)}); offset != 5 {
+		t.Fatalf("expected offset:5, but got:%d", offset)
+	}
+}
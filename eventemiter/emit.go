@@ -19,11 +19,13 @@
 package eventemitter
 
 import (
+	"context"
 	"github.com/Loopring/relay/log"
 	"sync"
 )
 
 //todo:more stronger if it has cache, but, the more the nearer to eventsourcing
+//the topics enabled by InitializeDurable are persisted, see durable.go
 
 type Topic string
 
@@ -66,8 +68,9 @@ const (
 	Miner_BatchSubmitRingHash_Method = "Miner_BatchSubmitRingHash_Method"
 
 	// Block
-	Block_New = "Block_New"
-	Block_End = "Block_End"
+	Block_New   = "Block_New"
+	Block_End   = "Block_End"
+	Block_Final = "Block_Final" //the blocks not greater than BlockNumber won't be rolled back
 
 	// Extractor
 	SyncChainComplete = "SyncChainComplete"
@@ -84,6 +87,7 @@ const (
 	PortfolioUpdated      = "PortfolioUpdated"
 	BalanceUpdated        = "BalanceUpdated"
 	DepthUpdated          = "DepthUpdated"
+	DepthDelta            = "DepthDelta"
 	TransactionUpdated    = "TransactionUpdated"
 )
 
@@ -120,6 +124,11 @@ func On(topic string, watcher *Watcher) {
 }
 
 func Emit(topic string, eventData EventData) {
+	if IsDurable(topic) {
+		emitDurable(topic, eventData)
+	}
+	publish(topic, eventData)
+
 	//should limit the count of watchers
 	var wg sync.WaitGroup
 	for _, ob := range watchers[topic] {
@@ -141,31 +150,13 @@ func Emit(topic string, eventData EventData) {
 	wg.Wait()
 }
 
-//todo: impl it
+//NewSerialWatcher handles the events of topic one by one in a single goroutine, Emit blocks when the queue is full
 func NewSerialWatcher(topic string, handle func(e EventData) error) (stopFunc func(), err error) {
-	dataChan := make(chan EventData)
-	go func() {
-		for {
-			select {
-			case event := <-dataChan:
-				handle(event)
-			}
-		}
-	}()
-
-	watcher := &Watcher{
-		Concurrent: false,
-		Handle: func(eventData EventData) error {
-			dataChan <- eventData
-			return nil
-		},
+	sub, err := Subscribe(context.Background(), topic, handle, SubscribeOptions{Overflow: OverflowBlock})
+	if nil != err {
+		return nil, err
 	}
-	On(topic, watcher)
-
-	return func() {
-		close(dataChan)
-		Un(topic, watcher)
-	}, nil
+	return sub.Unsubscribe, nil
 }
 
 func init() {
@@ -0,0 +1,486 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package eventemitter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type StoredEvent struct {
+	Offset int64           `json:"offset"`
+	Topic  string          `json:"topic"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+	Time   int64           `json:"time"`
+}
+
+//Cursor is the position of a subscriber in the log
+type Cursor struct {
+	Next   int64            `json:"next"`
+	Topics map[string]int64 `json:"topics"` //topic -> the offset from which it is subscribed
+}
+
+//EventStore persists the events of all the durable topics in one log and the cursors of subscribers,
+//offsets start from 0 and increase by 1 in the order of emitting, whatever the topic is
+type EventStore interface {
+	//Append sets the offset of event and returns it
+	Append(event *StoredEvent) (int64, error)
+
+	//Read returns at most limit events whose offset isn't less than from, the compacted events are skipped
+	Read(from int64, limit int) ([]*StoredEvent, error)
+
+	//NextOffset returns the offset of the next appended event
+	NextOffset() (int64, error)
+
+	//Cursor returns the cursor of subscriber, exists is false if it never commits
+	Cursor(subscriber string) (cursor Cursor, exists bool, err error)
+
+	//Commit may keep the cursor in memory until Flush
+	Commit(subscriber string, cursor Cursor) error
+
+	//Flush writes the appended events and the committed cursors to the disk
+	Flush() error
+
+	//Compact drops the events consumed by all the subscribers
+	Compact() error
+
+	Close() error
+}
+
+type MemoryEventStore struct {
+	mtx     sync.RWMutex
+	first   int64
+	events  []*StoredEvent
+	cursors map[string]Cursor
+}
+
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{cursors: make(map[string]Cursor)}
+}
+
+func (s *MemoryEventStore) Append(event *StoredEvent) (int64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	event.Offset = s.first + int64(len(s.events))
+	s.events = append(s.events, event)
+	return event.Offset, nil
+}
+
+func (s *MemoryEventStore) Read(from int64, limit int) ([]*StoredEvent, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	if from < s.first {
+		from = s.first
+	}
+	res := []*StoredEvent{}
+	for offset := from; offset-s.first < int64(len(s.events)) && len(res) < limit; offset++ {
+		res = append(res, s.events[offset-s.first])
+	}
+	return res, nil
+}
+
+func (s *MemoryEventStore) NextOffset() (int64, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	return s.first + int64(len(s.events)), nil
+}
+
+func (s *MemoryEventStore) Cursor(subscriber string) (Cursor, bool, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	cursor, exists := s.cursors[subscriber]
+	return copyCursor(cursor), exists, nil
+}
+
+func (s *MemoryEventStore) Commit(subscriber string, cursor Cursor) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.cursors[subscriber] = copyCursor(cursor)
+	return nil
+}
+
+func (s *MemoryEventStore) Flush() error {
+	return nil
+}
+
+func (s *MemoryEventStore) Compact() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	consumed := minCursor(s.cursors, s.first+int64(len(s.events)))
+	if consumed > s.first {
+		s.events = append([]*StoredEvent{}, s.events[consumed-s.first:]...)
+		s.first = consumed
+	}
+	return nil
+}
+
+func (s *MemoryEventStore) Close() error {
+	return nil
+}
+
+func copyCursor(cursor Cursor) Cursor {
+	res := Cursor{Next: cursor.Next, Topics: make(map[string]int64)}
+	for topic, since := range cursor.Topics {
+		res.Topics[topic] = since
+	}
+	return res
+}
+
+//minCursor returns the offset before which the events have been consumed by all the subscribers
+func minCursor(cursors map[string]Cursor, next int64) int64 {
+	if len(cursors) == 0 {
+		return 0
+	}
+	for _, cursor := range cursors {
+		if cursor.Next < next {
+			next = cursor.Next
+		}
+	}
+	return next
+}
+
+/**
+所有durable topic的事件按emit的顺序写入同一个log，log按大小切分成多个segment:
+<第一个事件的offset>.log 每行是一个json格式的StoredEvent，只会追加，写满SegmentSize后新建下一个segment
+cursors 是subscriber到Cursor的json，commit只更新内存，Flush时整体重写
+Append不会fsync，进程崩溃时已写入的事件不会丢失，机器宕机时最多丢失最近一个flush周期内的事件和cursor，
+cursor回退只会导致重复投递。
+Compact删除所有subscriber都已经处理过的segment，以及超过Retention的segment(未处理的事件也会被删除)
+*/
+
+const defaultSegmentSize = 64 * 1024 * 1024
+
+var errStoreClosed = errors.New("event store is closed")
+
+type FileEventStoreOptions struct {
+	Dir         string
+	SegmentSize int64         //bytes, the default is 64MB
+	Retention   time.Duration //the segment is dropped after it, even though there are subscribers haven't consumed it, 0 means never
+}
+
+type segment struct {
+	first     int64
+	file      *os.File
+	positions []int64
+	size      int64
+	modified  time.Time
+}
+
+func (seg *segment) next() int64 {
+	return seg.first + int64(len(seg.positions))
+}
+
+type FileEventStore struct {
+	options  FileEventStoreOptions
+	mtx      sync.Mutex
+	segments []*segment
+	cursors  map[string]Cursor
+	unsynced bool
+	dirty    bool
+}
+
+func NewFileEventStore(options FileEventStoreOptions) (*FileEventStore, error) {
+	if options.SegmentSize <= 0 {
+		options.SegmentSize = defaultSegmentSize
+	}
+	if err := os.MkdirAll(options.Dir, 0755); nil != err {
+		return nil, err
+	}
+	s := &FileEventStore{options: options, cursors: make(map[string]Cursor)}
+
+	files, err := ioutil.ReadDir(options.Dir)
+	if nil != err {
+		return nil, err
+	}
+	firsts := []int64{}
+	for _, file := range files {
+		var first int64
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".log") {
+			continue
+		}
+		if _, err := fmt.Sscanf(file.Name(), "%d.log", &first); nil != err {
+			continue
+		}
+		firsts = append(firsts, first)
+	}
+	sort.Slice(firsts, func(i, j int) bool { return firsts[i] < firsts[j] })
+	if len(firsts) == 0 {
+		firsts = append(firsts, 0)
+	}
+	for _, first := range firsts {
+		seg, err := s.openSegment(first)
+		if nil != err {
+			s.Close()
+			return nil, err
+		}
+		s.segments = append(s.segments, seg)
+	}
+
+	if data, err := ioutil.ReadFile(s.cursorsFile()); nil == err {
+		if err := json.Unmarshal(data, &s.cursors); nil != err {
+			s.Close()
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileEventStore) segmentFile(first int64) string {
+	return filepath.Join(s.options.Dir, fmt.Sprintf("%020d.log", first))
+}
+
+func (s *FileEventStore) cursorsFile() string {
+	return filepath.Join(s.options.Dir, "cursors")
+}
+
+func (s *FileEventStore) openSegment(first int64) (*segment, error) {
+	file, err := os.OpenFile(s.segmentFile(first), os.O_CREATE|os.O_RDWR, 0644)
+	if nil != err {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if nil != err {
+		file.Close()
+		return nil, err
+	}
+	seg := &segment{first: first, file: file, modified: info.ModTime()}
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			//the last line is broken if process crashed while writing, it should be dropped
+			if len(line) > 0 {
+				if err := file.Truncate(seg.size); nil != err {
+					file.Close()
+					return nil, err
+				}
+			}
+			break
+		} else if nil != err {
+			file.Close()
+			return nil, err
+		}
+		seg.positions = append(seg.positions, seg.size)
+		seg.size += int64(len(line))
+	}
+	return seg, nil
+}
+
+func (s *FileEventStore) Append(event *StoredEvent) (int64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.segments) == 0 {
+		return -1, errStoreClosed
+	}
+
+	seg := s.segments[len(s.segments)-1]
+	if seg.size >= s.options.SegmentSize {
+		if err := seg.file.Sync(); nil != err {
+			return -1, err
+		}
+		next, err := s.openSegment(seg.next())
+		if nil != err {
+			return -1, err
+		}
+		s.segments = append(s.segments, next)
+		seg = next
+	}
+
+	event.Offset = seg.next()
+	data, err := json.Marshal(event)
+	if nil != err {
+		return -1, err
+	}
+	data = append(data, '\n')
+	if _, err := seg.file.WriteAt(data, seg.size); nil != err {
+		return -1, err
+	}
+	seg.positions = append(seg.positions, seg.size)
+	seg.size += int64(len(data))
+	seg.modified = time.Now()
+	s.unsynced = true
+	return event.Offset, nil
+}
+
+func (s *FileEventStore) Read(from int64, limit int) ([]*StoredEvent, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	res := []*StoredEvent{}
+	for _, seg := range s.segments {
+		if from < seg.first {
+			from = seg.first
+		}
+		for ; from < seg.next() && len(res) < limit; from++ {
+			idx := from - seg.first
+			end := seg.size
+			if idx+1 < int64(len(seg.positions)) {
+				end = seg.positions[idx+1]
+			}
+			data := make([]byte, end-seg.positions[idx])
+			if _, err := seg.file.ReadAt(data, seg.positions[idx]); nil != err {
+				return res, err
+			}
+			event := &StoredEvent{}
+			if err := json.Unmarshal(data, event); nil != err {
+				return res, err
+			}
+			res = append(res, event)
+		}
+	}
+	return res, nil
+}
+
+func (s *FileEventStore) NextOffset() (int64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.segments) == 0 {
+		return -1, errStoreClosed
+	}
+
+	return s.segments[len(s.segments)-1].next(), nil
+}
+
+func (s *FileEventStore) Cursor(subscriber string) (Cursor, bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	cursor, exists := s.cursors[subscriber]
+	return copyCursor(cursor), exists, nil
+}
+
+func (s *FileEventStore) Commit(subscriber string, cursor Cursor) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.cursors[subscriber] = copyCursor(cursor)
+	s.dirty = true
+	return nil
+}
+
+func (s *FileEventStore) Flush() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.segments) == 0 {
+		return errStoreClosed
+	}
+	return s.flush()
+}
+
+//flush must be called with mtx held
+func (s *FileEventStore) flush() error {
+	if s.unsynced {
+		if err := s.segments[len(s.segments)-1].file.Sync(); nil != err {
+			return err
+		}
+		s.unsynced = false
+	}
+	if !s.dirty {
+		return nil
+	}
+	data, err := json.Marshal(s.cursors)
+	if nil != err {
+		return err
+	}
+	tmp := s.cursorsFile() + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); nil != err {
+		return err
+	}
+	if err := os.Rename(tmp, s.cursorsFile()); nil != err {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+//Compact never drops the last segment, which is being appended
+func (s *FileEventStore) Compact() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if len(s.segments) == 0 {
+		return errStoreClosed
+	}
+
+	last := s.segments[len(s.segments)-1]
+	consumed := minCursor(s.cursors, last.next())
+	expired := time.Now().Add(-s.options.Retention)
+
+	dropped := 0
+	for _, seg := range s.segments[:len(s.segments)-1] {
+		if seg.next() > consumed && (s.options.Retention <= 0 || seg.modified.After(expired)) {
+			break
+		}
+		dropped++
+	}
+	if dropped == 0 {
+		return nil
+	}
+	//the cursors must be persisted before the events are dropped, they would point to the dropped events after restart otherwise
+	if err := s.flush(); nil != err {
+		return err
+	}
+	for _, seg := range s.segments[:dropped] {
+		if err := os.Remove(s.segmentFile(seg.first)); nil != err {
+			return err
+		}
+		seg.file.Close()
+		s.segments = s.segments[1:]
+	}
+	return nil
+}
+
+func (s *FileEventStore) Close() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var err error
+	if len(s.segments) > 0 {
+		err = s.flush()
+	}
+	for _, seg := range s.segments {
+		seg.file.Close()
+	}
+	s.segments = nil
+	return err
+}
@@ -0,0 +1,350 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package eventemitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/metrics"
+)
+
+/**
+Subscription与Watcher不同，Emit只把事件放入subscription自己的队列，由subscription的goroutine顺序处理，
+处理慢的subscriber不会阻塞Emit，队列满时按照OverflowPolicy处理。
+handler的类型是func(e *T) error或者func(ctx context.Context, e *T) error，类型不匹配的事件不会进入队列。
+使用OverflowBlock时，handler中不要Emit同一个topic，否则队列满时会死锁。
+*/
+
+type OverflowPolicy int
+
+const (
+	OverflowBlock      OverflowPolicy = iota //Emit waits until the queue has space
+	OverflowDropOldest                       //the oldest event in queue is dropped
+	OverflowError                            //the new event is rejected
+)
+
+const DefaultQueueSize = 1024
+
+var ErrQueueFull = errors.New("queue of subscription is full")
+
+type SubscribeOptions struct {
+	Name      string //only used in log
+	QueueSize int    //DefaultQueueSize is used if it isn't positive
+	Overflow  OverflowPolicy
+}
+
+//TopicMetrics is the statistics of subscriptions since the process started
+type TopicMetrics struct {
+	Subscriptions int
+	Queued        int    //events waiting in the queues
+	Emitted       uint64 //events emitted to topic, including the ones handled by Watcher
+	Delivered     uint64 //events handled by subscriptions successfully
+	Failed        uint64 //events handled by subscriptions with error
+	Dropped       uint64 //events dropped by OverflowDropOldest
+	Rejected      uint64 //events rejected by OverflowError or mismatched with the type of handler
+}
+
+type topicCounters struct {
+	emitted   uint64
+	delivered uint64
+	failed    uint64
+	dropped   uint64
+	rejected  uint64
+}
+
+type Subscription struct {
+	topic     string
+	name      string
+	overflow  OverflowPolicy
+	eventType reflect.Type
+	withCtx   bool
+	handler   reflect.Value
+	counters  *topicCounters
+
+	queue   chan EventData
+	pushMtx sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+var (
+	subscriptions   map[string][]*Subscription
+	counters        map[string]*topicCounters
+	subscriptionMtx sync.RWMutex
+
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+//Subscribe handles the events of topic in a new goroutine until ctx is done or Unsubscribe is called
+func Subscribe(ctx context.Context, topic string, handler interface{}, options SubscribeOptions) (*Subscription, error) {
+	handlerValue := reflect.ValueOf(handler)
+	if !handlerValue.IsValid() {
+		return nil, fmt.Errorf("handler of topic:%s can't be nil", topic)
+	}
+	handlerType := handlerValue.Type()
+	if handlerType.Kind() != reflect.Func {
+		return nil, fmt.Errorf("handler of topic:%s must be a func, but it's %s", topic, handlerType.String())
+	}
+	withCtx := handlerType.NumIn() == 2 && handlerType.In(0) == contextType
+	if (handlerType.NumIn() != 1 && !withCtx) || handlerType.NumOut() != 1 || handlerType.Out(0) != errorType {
+		return nil, fmt.Errorf("handler of topic:%s must be func(e T) error or func(ctx context.Context, e T) error, but it's %s", topic, handlerType.String())
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = DefaultQueueSize
+	}
+	if "" == options.Name {
+		options.Name = handlerType.String()
+	}
+
+	sub := &Subscription{
+		topic:     topic,
+		name:      options.Name,
+		overflow:  options.Overflow,
+		eventType: handlerType.In(handlerType.NumIn() - 1),
+		withCtx:   withCtx,
+		handler:   handlerValue,
+		counters:  countersOf(topic),
+		queue:     make(chan EventData, options.QueueSize),
+		done:      make(chan struct{}),
+	}
+	sub.ctx, sub.cancel = context.WithCancel(ctx)
+
+	subscriptionMtx.Lock()
+	subscriptions[topic] = append(subscriptions[topic], sub)
+	subscriptionMtx.Unlock()
+
+	go sub.run()
+	return sub, nil
+}
+
+//Unsubscribe stops receiving events, the events in queue are discarded
+func (sub *Subscription) Unsubscribe() {
+	sub.remove()
+	sub.cancel()
+}
+
+//Done is closed after the goroutine of subscription exits
+func (sub *Subscription) Done() <-chan struct{} {
+	return sub.done
+}
+
+func (sub *Subscription) remove() {
+	subscriptionMtx.Lock()
+	defer subscriptionMtx.Unlock()
+
+	subsTmp := []*Subscription{}
+	for _, s := range subscriptions[sub.topic] {
+		if s != sub {
+			subsTmp = append(subsTmp, s)
+		}
+	}
+	subscriptions[sub.topic] = subsTmp
+}
+
+func (sub *Subscription) run() {
+	defer close(sub.done)
+	defer sub.remove()
+
+	for {
+		select {
+		case <-sub.ctx.Done():
+			return
+		case eventData := <-sub.queue:
+			sub.handle(eventData)
+		}
+	}
+}
+
+func (sub *Subscription) handle(eventData EventData) {
+	var event reflect.Value
+	if nil == eventData {
+		event = reflect.Zero(sub.eventType)
+	} else {
+		event = reflect.ValueOf(eventData)
+	}
+	args := []reflect.Value{event}
+	if sub.withCtx {
+		args = []reflect.Value{reflect.ValueOf(sub.ctx), event}
+	}
+	if res := sub.handler.Call(args)[0]; !res.IsNil() {
+		atomic.AddUint64(&sub.counters.failed, 1)
+		log.Errorf("eventemitter, subscription:%s failed to handle event of topic:%s, err:%s", sub.name, sub.topic, res.Interface().(error).Error())
+	} else {
+		atomic.AddUint64(&sub.counters.delivered, 1)
+	}
+}
+
+func (sub *Subscription) accept(eventData EventData) bool {
+	if nil == eventData {
+		switch sub.eventType.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			return true
+		}
+		return false
+	}
+	return reflect.TypeOf(eventData).AssignableTo(sub.eventType)
+}
+
+func (sub *Subscription) push(eventData EventData) error {
+	if !sub.accept(eventData) {
+		atomic.AddUint64(&sub.counters.rejected, 1)
+		return fmt.Errorf("type of event:%T mismatches the handler:%s", eventData, sub.eventType.String())
+	}
+
+	switch sub.overflow {
+	case OverflowDropOldest:
+		sub.pushMtx.Lock()
+		defer sub.pushMtx.Unlock()
+		for {
+			select {
+			case sub.queue <- eventData:
+				return nil
+			default:
+			}
+			select {
+			case <-sub.queue:
+				atomic.AddUint64(&sub.counters.dropped, 1)
+			default:
+			}
+		}
+	case OverflowError:
+		select {
+		case sub.queue <- eventData:
+			return nil
+		default:
+			atomic.AddUint64(&sub.counters.rejected, 1)
+			return ErrQueueFull
+		}
+	default:
+		select {
+		case sub.queue <- eventData:
+		case <-sub.ctx.Done():
+		}
+		return nil
+	}
+}
+
+func countersOf(topic string) *topicCounters {
+	subscriptionMtx.RLock()
+	c, exists := counters[topic]
+	subscriptionMtx.RUnlock()
+	if exists {
+		return c
+	}
+
+	subscriptionMtx.Lock()
+	defer subscriptionMtx.Unlock()
+	if c, exists = counters[topic]; !exists {
+		c = &topicCounters{}
+		counters[topic] = c
+	}
+	return c
+}
+
+//publish puts the event into the queues of subscriptions
+func publish(topic string, eventData EventData) {
+	atomic.AddUint64(&countersOf(topic).emitted, 1)
+
+	subscriptionMtx.RLock()
+	subs := subscriptions[topic]
+	subscriptionMtx.RUnlock()
+
+	for _, sub := range subs {
+		if err := sub.push(eventData); nil != err {
+			log.Errorf("eventemitter, subscription:%s can't receive event of topic:%s, err:%s", sub.name, topic, err.Error())
+		}
+	}
+}
+
+func Metrics(topic string) TopicMetrics {
+	subscriptionMtx.RLock()
+	defer subscriptionMtx.RUnlock()
+
+	return metricsOf(topic)
+}
+
+//AllMetrics returns the metrics of topics which have been emitted or subscribed
+func AllMetrics() map[string]TopicMetrics {
+	subscriptionMtx.RLock()
+	defer subscriptionMtx.RUnlock()
+
+	res := make(map[string]TopicMetrics)
+	for topic := range counters {
+		res[topic] = metricsOf(topic)
+	}
+	return res
+}
+
+//metricsOf must be called with subscriptionMtx held
+func metricsOf(topic string) TopicMetrics {
+	metrics := TopicMetrics{Subscriptions: len(subscriptions[topic])}
+	for _, sub := range subscriptions[topic] {
+		metrics.Queued += len(sub.queue)
+	}
+	if c, exists := counters[topic]; exists {
+		metrics.Emitted = atomic.LoadUint64(&c.emitted)
+		metrics.Delivered = atomic.LoadUint64(&c.delivered)
+		metrics.Failed = atomic.LoadUint64(&c.failed)
+		metrics.Dropped = atomic.LoadUint64(&c.dropped)
+		metrics.Rejected = atomic.LoadUint64(&c.rejected)
+	}
+	return metrics
+}
+
+func collectMetrics(sample func(topic string, m TopicMetrics) []metrics.Sample) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		res := []metrics.Sample{}
+		for topic, m := range AllMetrics() {
+			res = append(res, sample(topic, m)...)
+		}
+		return res
+	}
+}
+
+func init() {
+	subscriptions = make(map[string][]*Subscription)
+	counters = make(map[string]*topicCounters)
+
+	metrics.NewGaugeFunc("relay_eventemitter_queued_events", "Events waiting in the queues of subscriptions.", []string{"topic"},
+		collectMetrics(func(topic string, m TopicMetrics) []metrics.Sample {
+			return []metrics.Sample{{LabelValues: []string{topic}, Value: float64(m.Queued)}}
+		}))
+	metrics.NewGaugeFunc("relay_eventemitter_subscriptions", "Subscriptions of topic.", []string{"topic"},
+		collectMetrics(func(topic string, m TopicMetrics) []metrics.Sample {
+			return []metrics.Sample{{LabelValues: []string{topic}, Value: float64(m.Subscriptions)}}
+		}))
+	metrics.NewCounterFunc("relay_eventemitter_events_total", "Events of topic, result is emitted, delivered, failed, dropped or rejected.", []string{"topic", "result"},
+		collectMetrics(func(topic string, m TopicMetrics) []metrics.Sample {
+			return []metrics.Sample{
+				{LabelValues: []string{topic, "emitted"}, Value: float64(m.Emitted)},
+				{LabelValues: []string{topic, "delivered"}, Value: float64(m.Delivered)},
+				{LabelValues: []string{topic, "failed"}, Value: float64(m.Failed)},
+				{LabelValues: []string{topic, "dropped"}, Value: float64(m.Dropped)},
+				{LabelValues: []string{topic, "rejected"}, Value: float64(m.Rejected)},
+			}
+		}))
+}
@@ -0,0 +1,124 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package eventemitter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/eventemiter"
+	"github.com/Loopring/relay/log"
+	"go.uber.org/zap"
+)
+
+type SubscribedEvent struct {
+	Seq int
+}
+
+func TestSubscribe_Typed(t *testing.T) {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+	topic := "SubscribeTyped"
+
+	if _, err := eventemitter.Subscribe(context.Background(), topic, func(e *SubscribedEvent) {}, eventemitter.SubscribeOptions{}); nil == err {
+		t.Fatalf("handler without error result should be rejected")
+	}
+
+	received := make(chan int, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := eventemitter.Subscribe(ctx, topic, func(ctx context.Context, e *SubscribedEvent) error {
+		received <- e.Seq
+		return nil
+	}, eventemitter.SubscribeOptions{Name: "typed"})
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+
+	eventemitter.Emit(topic, &SubscribedEvent{Seq: 1})
+	eventemitter.Emit(topic, SubscribedEvent{Seq: 2})
+	eventemitter.Emit(topic, &SubscribedEvent{Seq: 3})
+	for _, expected := range []int{1, 3} {
+		select {
+		case seq := <-received:
+			if seq != expected {
+				t.Fatalf("expected event:%d, but got:%d", expected, seq)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event:%d isn't delivered", expected)
+		}
+	}
+
+	cancel()
+	select {
+	case <-sub.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("subscription should exit after ctx is canceled")
+	}
+	eventemitter.Emit(topic, &SubscribedEvent{Seq: 4})
+
+	metrics := eventemitter.Metrics(topic)
+	if metrics.Subscriptions != 0 || metrics.Emitted != 4 || metrics.Delivered != 2 || metrics.Rejected != 1 {
+		t.Fatalf("unexpected metrics:%+v", metrics)
+	}
+}
+
+func TestSubscribe_Overflow(t *testing.T) {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+	topic := "SubscribeOverflow"
+
+	block := make(chan struct{})
+	received := make(chan int, 10)
+	handler := func(e *SubscribedEvent) error {
+		<-block
+		received <- e.Seq
+		return nil
+	}
+	dropSub, _ := eventemitter.Subscribe(context.Background(), topic, handler, eventemitter.SubscribeOptions{QueueSize: 2, Overflow: eventemitter.OverflowDropOldest})
+	defer dropSub.Unsubscribe()
+	errSub, _ := eventemitter.Subscribe(context.Background(), topic, handler, eventemitter.SubscribeOptions{QueueSize: 2, Overflow: eventemitter.OverflowError})
+	defer errSub.Unsubscribe()
+
+	//the first event is taken by the handler of every subscription, the others are queued
+	eventemitter.Emit(topic, &SubscribedEvent{Seq: 0})
+	time.Sleep(50 * time.Millisecond)
+	for seq := 1; seq <= 4; seq++ {
+		eventemitter.Emit(topic, &SubscribedEvent{Seq: seq})
+	}
+
+	metrics := eventemitter.Metrics(topic)
+	if metrics.Queued != 4 || metrics.Dropped != 2 || metrics.Rejected != 2 {
+		t.Fatalf("unexpected metrics:%+v", metrics)
+	}
+
+	close(block)
+	counts := make(map[int]int)
+	for i := 0; i < 6; i++ {
+		select {
+		case seq := <-received:
+			counts[seq]++
+		case <-time.After(time.Second):
+			t.Fatalf("only received %d events", i)
+		}
+	}
+	//the drop oldest one handles 0,3,4 and the error one handles 0,1,2
+	if counts[0] != 2 || counts[1] != 1 || counts[2] != 1 || counts[3] != 1 || counts[4] != 1 {
+		t.Fatalf("unexpected events:%v", counts)
+	}
+}
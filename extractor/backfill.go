@@ -0,0 +1,149 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package extractor
+
+import (
+	"fmt"
+	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/types"
+	"math/big"
+)
+
+/**
+追赶同步(backfill)，只用于 block 模式：
+1、落后的块数超过 BackfillWindow 时，BackfillWorkers 个 goroutine 并发获取块、交易和 receipt 并解析事件，
+   请求按块号通过 MutilClient 随机分散到各个节点
+2、已获取但还未提交的块不超过 BackfillWindow 个
+3、仍然严格按照块的顺序提交：Block_New -> 事件 -> Block_End
+4、每提交 BackfillWindow 个块，将已提交的块号记录在 dao.CheckPoint 中
+5、追上之后使用 BlockIterator 逐块处理
+*/
+
+const (
+	defaultBackfillWindowRatio = 4
+	backfillStepRatio          = 16 // blocks of one step is BackfillWindow*backfillStepRatio, stop is checked between steps
+)
+
+type backfillBlock struct {
+	number    *big.Int
+	block     *ethaccessor.BlockWithTxAndReceipt
+	emissions []emission
+	err       error
+}
+
+//fetchOrdered fetches the blocks in [from, to] with workers concurrently, and commits them in block order.
+//it stops at the first error of fetch or commit, the blocks after it aren't committed
+func fetchOrdered(from, to *big.Int, workers, window int, fetch func(number *big.Int) *backfillBlock, commit func(block *backfillBlock) error) error {
+	futures := make(chan chan *backfillBlock, window)
+	workerSem := make(chan bool, workers)
+	quit := make(chan bool)
+	defer close(quit)
+
+	go func() {
+		defer close(futures)
+		for number := new(big.Int).Set(from); number.Cmp(to) <= 0; number = new(big.Int).Add(number, big.NewInt(1)) {
+			future := make(chan *backfillBlock, 1)
+			select {
+			case futures <- future:
+			case <-quit:
+				return
+			}
+			select {
+			case workerSem <- true:
+			case <-quit:
+				return
+			}
+			go func(number *big.Int) {
+				defer func() { <-workerSem }()
+				future <- fetch(number)
+			}(number)
+		}
+	}()
+
+	for future := range futures {
+		block := <-future
+		if block.err != nil {
+			return block.err
+		}
+		if err := commit(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//processBlocks backfills until caught up, then processes blocks one by one
+func (l *ExtractorServiceImpl) processBlocks() error {
+	if nil == l.iterator {
+		caughtUp, err := l.Backfill()
+		if err != nil || !caughtUp {
+			return err
+		}
+		log.Infof("extractor,backfill caught up at block:%s", l.cursor.String())
+		l.iterator = ethaccessor.NewBlockIterator(l.cursor, l.endBlockNumber, true, l.options.ConfirmBlockNumber)
+	}
+	return l.ProcessBlock()
+}
+
+//Backfill processes one step of confirmed blocks concurrently, caughtUp is true if the lag is less than BackfillWindow
+func (l *ExtractorServiceImpl) Backfill() (caughtUp bool, err error) {
+	var bestBlock types.Big
+	if err := ethaccessor.BlockNumber(&bestBlock); err != nil {
+		return false, fmt.Errorf("extractor,get ethereum node current block number error:%s", err.Error())
+	}
+	window := l.options.BackfillWindow
+	from, to, ok := logRange(l.cursor, bestBlock.BigInt(), l.endBlockNumber, l.options.ConfirmBlockNumber, window*backfillStepRatio)
+	if !ok || new(big.Int).Sub(to, from).Cmp(big.NewInt(int64(window))) < 0 {
+		return true, nil
+	}
+
+	log.Infof("extractor,backfill blocks:%s->%s", from.String(), to.String())
+	committed := 0
+	err = fetchOrdered(from, to, l.options.BackfillWorkers, window, l.fetchBackfillBlock, func(b *backfillBlock) error {
+		if err := l.commitBlock(&b.block.Block, b.emissions, bestBlock.BigInt()); err != nil {
+			return err
+		}
+
+		l.cursor = new(big.Int).Add(b.number, big.NewInt(1))
+		if committed++; committed%window == 0 || b.number.Cmp(to) == 0 {
+			if err := l.dao.SaveCheckPoint(dao.ExtractorBackfillType, b.number.Int64()); err != nil {
+				log.Errorf("extractor,save backfill check point:%s error:%s", b.number.String(), err.Error())
+			}
+		}
+		return nil
+	})
+	return false, err
+}
+
+//fetchBackfillBlock gets the block with txs and receipts, and decodes the events and methods
+func (l *ExtractorServiceImpl) fetchBackfillBlock(number *big.Int) *backfillBlock {
+	b := &backfillBlock{number: number}
+	inter, err := ethaccessor.GetFullBlock(number, true)
+	if err != nil {
+		b.err = fmt.Errorf("extractor,backfill get block:%s error:%s", number.String(), err.Error())
+		return b
+	}
+	b.block = inter.(*ethaccessor.BlockWithTxAndReceipt)
+	for idx := range b.block.Transactions {
+		b.emissions = append(b.emissions, l.decodeMinedTransaction(&b.block.Transactions[idx], &b.block.Receipts[idx], b.block.Timestamp.BigInt())...)
+	}
+	return b
+}
@@ -0,0 +1,66 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package extractor
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestFetchOrdered(t *testing.T) {
+	fetch := func(number *big.Int) *backfillBlock {
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		return &backfillBlock{number: number}
+	}
+
+	committed := []int64{}
+	commit := func(b *backfillBlock) error {
+		committed = append(committed, b.number.Int64())
+		return nil
+	}
+	if err := fetchOrdered(big.NewInt(10), big.NewInt(109), 8, 16, fetch, commit); err != nil {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if len(committed) != 100 {
+		t.Fatalf("all blocks should be committed, but got:%d", len(committed))
+	}
+	for idx, number := range committed {
+		if number != int64(10+idx) {
+			t.Fatalf("blocks should be committed in order, but got:%d at %d", number, idx)
+		}
+	}
+
+	committed = []int64{}
+	failedFetch := func(number *big.Int) *backfillBlock {
+		b := fetch(number)
+		if number.Int64() == 50 {
+			b.err = errors.New("can't get block")
+		}
+		return b
+	}
+	if err := fetchOrdered(big.NewInt(10), big.NewInt(109), 8, 16, failedFetch, commit); err == nil {
+		t.Fatalf("error of fetch should be returned")
+	}
+	if len(committed) != 40 || committed[len(committed)-1] != 49 {
+		t.Fatalf("blocks should be committed until the failed one, but got:%v", committed)
+	}
+}
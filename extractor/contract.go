@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"math/big"
+	"reflect"
 )
 
 type EventData struct {
@@ -160,6 +161,10 @@ func (processor *AbiProcessor) GetEvent(evtLog ethaccessor.Log) (EventData, bool
 	}
 
 	event, ok = processor.events[id]
+	if ok && nil != event.Event {
+		//every event data has its own value to be unpacked into, so that events can be decoded in parallel
+		event.Event = reflect.New(reflect.TypeOf(event.Event).Elem()).Interface()
+	}
 	return event, ok
 }
 
@@ -954,6 +959,12 @@ func (processor *AbiProcessor) handleWethWithdrawalEvent(input eventemitter.Even
 }
 
 func (processor *AbiProcessor) handleEthTransfer(tx *ethaccessor.Transaction, receipt *ethaccessor.TransactionReceipt, time *big.Int) error {
+	eventemitter.Emit(eventemitter.EthTransferEvent, processor.ethTransferEvent(tx, receipt, time))
+
+	return nil
+}
+
+func (processor *AbiProcessor) ethTransferEvent(tx *ethaccessor.Transaction, receipt *ethaccessor.TransactionReceipt, time *big.Int) *types.TransferEvent {
 	var dst types.TransferEvent
 
 	dst.From = common.HexToAddress(tx.From)
@@ -975,9 +986,7 @@ func (processor *AbiProcessor) handleEthTransfer(tx *ethaccessor.Transaction, re
 
 	log.Debugf("extractor,tx:%s handleEthTransfer from:%s, to:%s, value:%s, gasUsed:%s, status:%d", tx.Hash, tx.From, tx.To, tx.Value.BigInt().String(), dst.GasUsed.String(), dst.Status)
 
-	eventemitter.Emit(eventemitter.EthTransferEvent, &dst)
-
-	return nil
+	return &dst
 }
 
 func (processor *AbiProcessor) getGasAndStatus(tx *ethaccessor.Transaction, receipt *ethaccessor.TransactionReceipt) (*big.Int, types.TxStatus) {
@@ -25,6 +25,7 @@ import (
 	"github.com/Loopring/relay/ethaccessor"
 	"github.com/Loopring/relay/eventemiter"
 	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/metrics"
 	"github.com/Loopring/relay/types"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -41,12 +42,22 @@ import (
 const (
 	defaultEndBlockNumber  = 1000000000
 	defaultForkWaitingTime = 10
+	defaultFinalityDepth   = 64
+)
+
+var (
+	processedBlockNumber = metrics.NewGaugeVec("relay_extractor_block_number", "Number of the latest block processed by extractor.")
+	headLag              = metrics.NewGaugeVec("relay_extractor_head_lag_blocks", "Blocks between the best block of eth nodes and the latest processed block.")
+	forkRollbacks        = metrics.NewCounterVec("relay_extractor_fork_rollbacks_total", "Chain forks detected by extractor.")
+	forkRollbackBlocks   = metrics.NewCounterVec("relay_extractor_fork_rollback_blocks_total", "Blocks rolled back by the detected chain forks.")
+	finalBlockNumber     = metrics.NewGaugeVec("relay_extractor_final_block_number", "Number of the latest processed block which won't be rolled back.")
 )
 
 type ExtractorService interface {
 	Start()
 	Stop()
 	ForkProcess(block *types.Block) error
+	IsSyncComplete() bool
 }
 
 // TODO(fukun):不同的channel，应当交给orderbook统一进行后续处理，可以将channel作为函数返回值、全局变量、参数等方式
@@ -60,6 +71,8 @@ type ExtractorServiceImpl struct {
 	startBlockNumber *big.Int
 	endBlockNumber   *big.Int
 	iterator         *ethaccessor.BlockIterator
+	cursor           *big.Int // next block of logs mode and backfill
+	finalBlock       *big.Int // the latest block emitted by Block_Final
 	pendingTxWatcher *eventemitter.Watcher
 	syncComplete     bool
 	forkComplete     bool
@@ -71,11 +84,23 @@ func NewExtractorService(options config.ExtractorOptions, db dao.RdsService) *Ex
 	if options.ForkWaitingTime <= 0 {
 		options.ForkWaitingTime = defaultForkWaitingTime
 	}
+	if options.Mode != ExtractMode_Logs {
+		options.Mode = ExtractMode_Block
+	}
+	if options.LogBlockRange <= 0 {
+		options.LogBlockRange = defaultLogBlockRange
+	}
+	if options.BackfillWindow < options.BackfillWorkers {
+		options.BackfillWindow = options.BackfillWorkers * defaultBackfillWindowRatio
+	}
+	if options.FinalityDepth == 0 {
+		options.FinalityDepth = defaultFinalityDepth
+	}
 
 	l.options = options
 	l.dao = db
 	l.processor = newAbiProcessor(db, &options)
-	l.detector = newForkDetector(db, l.options.StartBlockNumber)
+	l.detector = newForkDetector(db, l.options.StartBlockNumber, l.options.FinalityDepth)
 	l.stop = make(chan bool, 1)
 	l.setBlockNumberRange()
 
@@ -90,17 +115,28 @@ func (l *ExtractorServiceImpl) Start() {
 		return
 	}
 
-	log.Infof("extractor start from block:%s...", l.startBlockNumber.String())
+	log.Infof("extractor start from block:%s in %s mode...", l.startBlockNumber.String(), l.options.Mode)
 	l.syncComplete = false
 
-	l.iterator = ethaccessor.NewBlockIterator(l.startBlockNumber, l.endBlockNumber, true, l.options.ConfirmBlockNumber)
+	process := l.ProcessBlock
+	if l.options.Mode == ExtractMode_Logs {
+		l.cursor = new(big.Int).Set(l.startBlockNumber)
+		process = l.ProcessLogRange
+	} else if l.options.BackfillWorkers > 1 {
+		//the iterator is created after backfill caught up
+		l.cursor = new(big.Int).Set(l.startBlockNumber)
+		l.iterator = nil
+		process = l.processBlocks
+	} else {
+		l.iterator = ethaccessor.NewBlockIterator(l.startBlockNumber, l.endBlockNumber, true, l.options.ConfirmBlockNumber)
+	}
 	go func() {
 		for {
 			select {
 			case <-l.stop:
 				return
 			default:
-				if err := l.ProcessBlock(); nil != err {
+				if err := process(); nil != err {
 					log.Error(err.Error())
 					time.Sleep(1 * time.Second)
 				}
@@ -130,6 +166,8 @@ func (l *ExtractorServiceImpl) ForkProcess(currentBlock *types.Block) error {
 	}
 
 	log.Debugf("extractor,detected chain fork, from :%d to %d", forkEvent.ForkBlock.Int64(), forkEvent.DetectedBlock.Int64())
+	forkRollbacks.Inc()
+	forkRollbackBlocks.Add(float64(forkEvent.DetectedBlock.Int64() - forkEvent.ForkBlock.Int64()))
 
 	l.Stop()
 
@@ -162,6 +200,11 @@ func (l *ExtractorServiceImpl) Sync(blockNumber *big.Int) {
 	}
 }
 
+//IsSyncComplete returns whether the extractor has caught up with the eth nodes
+func (l *ExtractorServiceImpl) IsSyncComplete() bool {
+	return !l.options.Open || l.syncComplete
+}
+
 // Warning 当发生严重错误时需关停extractor，并通知其他模块
 func (l *ExtractorServiceImpl) Warning(err error) {
 	l.Stop()
@@ -185,44 +228,98 @@ func (l *ExtractorServiceImpl) ProcessBlock() error {
 	block := inter.(*ethaccessor.BlockWithTxAndReceipt)
 	log.Infof("extractor,get block:%s->%s, transaction number:%d", block.Number.BigInt().String(), block.Hash.Hex(), len(block.Transactions))
 
+	return l.processFullBlock(block)
+}
+
+//processFullBlock decodes the txs with receipts and commits the block, the best block is got once for each block in this mode
+func (l *ExtractorServiceImpl) processFullBlock(block *ethaccessor.BlockWithTxAndReceipt) error {
+	emissions := []emission{}
+	for idx := range block.Transactions {
+		l.debug("extractor,tx:%s", block.Transactions[idx].Hash)
+		emissions = append(emissions, l.decodeMinedTransaction(&block.Transactions[idx], &block.Receipts[idx], block.Timestamp.BigInt())...)
+	}
+
+	return l.commitBlock(&block.Block, emissions, l.bestBlockNumber(block.Number.BigInt()))
+}
+
+//commitBlock emits the block and the events decoded from its txs, the events are dropped if chain fork is detected.
+//bestBlock is the latest block of eth nodes got by the caller once for each step
+func (l *ExtractorServiceImpl) commitBlock(block *ethaccessor.Block, emissions []emission, bestBlock *big.Int) error {
+	blockEvent, err := l.processBlockHeader(block, bestBlock)
+	if err != nil {
+		return err
+	}
+
+	emitAll(withFinality(emissions, blockEvent.Finality))
+	eventemitter.Emit(eventemitter.Block_End, blockEvent)
+	l.emitFinalBlock()
+	return nil
+}
+
+//processBlockHeader detects chain fork, saves the block and emits Block_New
+func (l *ExtractorServiceImpl) processBlockHeader(block *ethaccessor.Block, bestBlock *big.Int) (*types.BlockEvent, error) {
 	currentBlock := &types.Block{}
 	currentBlock.BlockNumber = block.Number.BigInt()
 	currentBlock.ParentHash = block.ParentHash
 	currentBlock.BlockHash = block.Hash
 	currentBlock.CreateTime = block.Timestamp.Int64()
 
-	// convert and save block
-	var entity dao.Block
-	entity.ConvertDown(currentBlock)
-	l.dao.SaveBlock(&entity)
-
 	// sync block on chain
 	if l.syncComplete == false {
 		l.Sync(block.Number.BigInt())
 	}
 
-	// detect chain fork
+	//detect chain fork, the block is saved after detecting so that it won't be marked as forked when it is processed again
 	if err := l.ForkProcess(currentBlock); err != nil {
-		return err
+		return nil, err
 	}
 
+	// convert and save block
+	var entity dao.Block
+	entity.ConvertDown(currentBlock)
+	l.dao.SaveBlock(&entity)
+
+	l.observeHeadLag(block.Number.BigInt(), bestBlock)
+	l.detector.advance(bestBlock)
+
 	// emit new block
 	blockEvent := &types.BlockEvent{}
 	blockEvent.BlockNumber = block.Number.BigInt()
 	blockEvent.BlockHash = block.Hash
 	blockEvent.BlockTime = block.Timestamp.Int64()
+	blockEvent.Finality = l.detector.finality(blockEvent.BlockNumber)
 	eventemitter.Emit(eventemitter.Block_New, blockEvent)
 
-	if len(block.Transactions) > 0 {
-		for idx, transaction := range block.Transactions {
-			receipt := block.Receipts[idx]
-			l.debug("extractor,tx:%s", transaction.Hash)
-			l.ProcessMinedTransaction(&transaction, &receipt, block.Timestamp.BigInt())
-		}
+	return blockEvent, nil
+}
+
+//bestBlockNumber returns the best block of eth nodes, blockNumber is returned if it can't be got
+func (l *ExtractorServiceImpl) bestBlockNumber(blockNumber *big.Int) *big.Int {
+	var bestBlock types.Big
+	if err := ethaccessor.BlockNumber(&bestBlock); nil != err {
+		return blockNumber
 	}
+	return bestBlock.BigInt()
+}
 
-	eventemitter.Emit(eventemitter.Block_End, blockEvent)
-	return nil
+func (l *ExtractorServiceImpl) observeHeadLag(blockNumber, bestBlock *big.Int) {
+	processedBlockNumber.Set(float64(blockNumber.Int64()))
+	headLag.Set(float64(bestBlock.Int64() - blockNumber.Int64()))
+}
+
+//emitFinalBlock emits Block_Final when more processed blocks become final
+func (l *ExtractorServiceImpl) emitFinalBlock() {
+	final := l.detector.processedFinalBlock()
+	if nil != l.finalBlock && final.Cmp(l.finalBlock) <= 0 {
+		return
+	}
+	l.finalBlock = final
+	finalBlockNumber.Set(float64(final.Int64()))
+
+	blockEvent := &types.BlockEvent{}
+	blockEvent.BlockNumber = new(big.Int).Set(final)
+	blockEvent.Finality = types.BLOCK_FINAL
+	eventemitter.Emit(eventemitter.Block_Final, blockEvent)
 }
 
 func (l *ExtractorServiceImpl) ProcessPendingTransaction(tx *ethaccessor.Transaction) error {
@@ -240,18 +337,62 @@ func (l *ExtractorServiceImpl) ProcessPendingTransaction(tx *ethaccessor.Transac
 func (l *ExtractorServiceImpl) ProcessMinedTransaction(tx *ethaccessor.Transaction, receipt *ethaccessor.TransactionReceipt, blockTime *big.Int) error {
 	l.debug("extractor,process mined transaction,tx:%s status :%s,logs:%d", tx.Hash, receipt.Status.BigInt().String(), len(receipt.Logs))
 
+	emitAll(l.decodeMinedTransaction(tx, receipt, blockTime))
+	return nil
+}
+
+func (l *ExtractorServiceImpl) ProcessMethod(tx *ethaccessor.Transaction, receipt *ethaccessor.TransactionReceipt, blockTime *big.Int) error {
+	emitAll(l.decodeMethod(tx, receipt, blockTime))
+	return nil
+}
+
+func (l *ExtractorServiceImpl) ProcessEvent(tx *ethaccessor.Transaction, receipt *ethaccessor.TransactionReceipt, blockTime *big.Int) error {
+	emitAll(l.decodeEvents(tx, receipt, blockTime))
+	return nil
+}
+
+//emission is the event or method decoded from tx, decoding can be done in parallel but emissions must be emitted in block order
+type emission struct {
+	topic string
+	data  eventemitter.EventData
+}
+
+func emitAll(emissions []emission) {
+	for _, e := range emissions {
+		eventemitter.Emit(e.topic, e.data)
+	}
+}
+
+//withFinality sets the finality of decoded events, the handlers of AbiProcessor copy TxInfo into the events emitted by them
+func withFinality(emissions []emission, finality types.BlockFinality) []emission {
+	for idx, e := range emissions {
+		switch data := e.data.(type) {
+		case EventData:
+			data.Finality = finality
+			emissions[idx].data = data
+		case MethodData:
+			data.Finality = finality
+			emissions[idx].data = data
+		case *types.TransferEvent:
+			data.Finality = finality
+		}
+	}
+	return emissions
+}
+
+func (l *ExtractorServiceImpl) decodeMinedTransaction(tx *ethaccessor.Transaction, receipt *ethaccessor.TransactionReceipt, blockTime *big.Int) []emission {
 	if l.processor.SupportedEvents(receipt) {
-		return l.ProcessEvent(tx, receipt, blockTime)
+		return l.decodeEvents(tx, receipt, blockTime)
 	}
 
 	if l.processor.SupportedMethod(tx) {
-		return l.ProcessMethod(tx, receipt, blockTime)
+		return l.decodeMethod(tx, receipt, blockTime)
 	}
 
-	return l.processor.handleEthTransfer(tx, receipt, blockTime)
+	return []emission{{topic: eventemitter.EthTransferEvent, data: l.processor.ethTransferEvent(tx, receipt, blockTime)}}
 }
 
-func (l *ExtractorServiceImpl) ProcessMethod(tx *ethaccessor.Transaction, receipt *ethaccessor.TransactionReceipt, blockTime *big.Int) error {
+func (l *ExtractorServiceImpl) decodeMethod(tx *ethaccessor.Transaction, receipt *ethaccessor.TransactionReceipt, blockTime *big.Int) []emission {
 	method, ok := l.processor.GetMethod(tx)
 	if !ok {
 		l.debug("extractor,process method,tx:%s,unsupported contract method", tx.Hash)
@@ -260,12 +401,11 @@ func (l *ExtractorServiceImpl) ProcessMethod(tx *ethaccessor.Transaction, receip
 
 	gas, status := l.processor.getGasAndStatus(tx, receipt)
 	method.FullFilled(tx, gas, blockTime, status, method.Name)
-	eventemitter.Emit(method.Id, method)
 
-	return nil
+	return []emission{{topic: method.Id, data: method}}
 }
 
-func (l *ExtractorServiceImpl) ProcessEvent(tx *ethaccessor.Transaction, receipt *ethaccessor.TransactionReceipt, blockTime *big.Int) error {
+func (l *ExtractorServiceImpl) decodeEvents(tx *ethaccessor.Transaction, receipt *ethaccessor.TransactionReceipt, blockTime *big.Int) []emission {
 	methodName := l.processor.GetMethodName(tx)
 
 	// 如果是submitRing的相关事件，必须保证fill在前，transfer在后
@@ -282,6 +422,7 @@ func (l *ExtractorServiceImpl) ProcessEvent(tx *ethaccessor.Transaction, receipt
 		})
 	}
 
+	emissions := []emission{}
 	for _, evtLog := range receipt.Logs {
 		event, ok := l.processor.GetEvent(evtLog)
 		if !ok {
@@ -298,10 +439,10 @@ func (l *ExtractorServiceImpl) ProcessEvent(tx *ethaccessor.Transaction, receipt
 		}
 
 		event.FullFilled(tx, &evtLog, receipt.GasUsed.BigInt(), blockTime, methodName)
-		eventemitter.Emit(event.Id.Hex(), event)
+		emissions = append(emissions, emission{topic: event.Id.Hex(), data: event})
 	}
 
-	return nil
+	return emissions
 }
 
 func (l *ExtractorServiceImpl) setBlockNumberRange() {
@@ -321,6 +462,11 @@ func (l *ExtractorServiceImpl) setBlockNumberRange() {
 	latestBlock.ConvertUp(&ret)
 	l.startBlockNumber = ret.BlockNumber
 
+	//the latest block has been committed by backfill
+	if point, err := l.dao.QueryCheckPointByType(dao.ExtractorBackfillType); err == nil && point.CheckPoint == ret.BlockNumber.Int64() {
+		l.startBlockNumber = new(big.Int).Add(ret.BlockNumber, big.NewInt(1))
+	}
+
 	log.Debugf("extractor,configStartBlockNumber:%s latestBlockNumber:%s", l.options.StartBlockNumber.String(), l.startBlockNumber.String())
 }
 
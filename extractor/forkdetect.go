@@ -27,18 +27,29 @@ import (
 	"math/big"
 )
 
+/**
+分叉检测只回滚最终块之后的块:
+1、最终块是最佳块(eth节点的最新块与已处理的最新块中较大者)之前 FinalityDepth 个块，只增不减
+2、分叉点在最终块之前时不再回滚，extractor 发出 warning 并停止，需要人工处理
+3、查找分叉点时向前回溯父块，最多回溯到最终块
+*/
+
 type forkDetector struct {
 	db          dao.RdsService
 	latestBlock *types.Block
+	depth       uint64
+	finalBlock  *big.Int // blocks not greater than it won't be rolled back
 }
 
-func newForkDetector(db dao.RdsService, startBlockConfig *big.Int) *forkDetector {
+func newForkDetector(db dao.RdsService, startBlockConfig *big.Int, depth uint64) *forkDetector {
 	detector := &forkDetector{}
 	detector.db = db
+	detector.depth = depth
 	detector.latestBlock = &types.Block{}
 
 	if entity, err := detector.db.FindLatestBlock(); err == nil {
 		entity.ConvertUp(detector.latestBlock)
+		detector.finalBlock = detector.finalOf(detector.latestBlock.BlockNumber)
 		return detector
 	}
 
@@ -51,6 +62,7 @@ func newForkDetector(db dao.RdsService, startBlockConfig *big.Int) *forkDetector
 	detector.latestBlock.BlockHash = block.Hash
 	detector.latestBlock.CreateTime = block.Timestamp.BigInt().Int64()
 	detector.latestBlock.ParentHash = block.ParentHash
+	detector.finalBlock = detector.finalOf(detector.latestBlock.BlockNumber)
 
 	model := &dao.Block{}
 	model.ConvertDown(detector.latestBlock)
@@ -59,6 +71,35 @@ func newForkDetector(db dao.RdsService, startBlockConfig *big.Int) *forkDetector
 	return detector
 }
 
+func (detector *forkDetector) finalOf(bestBlock *big.Int) *big.Int {
+	return new(big.Int).Sub(bestBlock, new(big.Int).SetUint64(detector.depth))
+}
+
+//advance moves the final block forward, bestBlock is the latest block of eth nodes
+func (detector *forkDetector) advance(bestBlock *big.Int) {
+	if nil != detector.latestBlock.BlockNumber && detector.latestBlock.BlockNumber.Cmp(bestBlock) > 0 {
+		bestBlock = detector.latestBlock.BlockNumber
+	}
+	if final := detector.finalOf(bestBlock); final.Cmp(detector.finalBlock) > 0 {
+		detector.finalBlock = final
+	}
+}
+
+func (detector *forkDetector) finality(blockNumber *big.Int) types.BlockFinality {
+	if blockNumber.Cmp(detector.finalBlock) <= 0 {
+		return types.BLOCK_FINAL
+	}
+	return types.BLOCK_UNCONFIRMED
+}
+
+//processedFinalBlock returns the latest final block which has been processed
+func (detector *forkDetector) processedFinalBlock() *big.Int {
+	if detector.latestBlock.BlockNumber.Cmp(detector.finalBlock) < 0 {
+		return new(big.Int).Set(detector.latestBlock.BlockNumber)
+	}
+	return new(big.Int).Set(detector.finalBlock)
+}
+
 func (detector *forkDetector) Detect(currentBlock *types.Block) (*types.ForkedEvent, error) {
 	// filter invalid block
 	if types.IsZeroHash(currentBlock.ParentHash) || types.IsZeroHash(currentBlock.BlockHash) {
@@ -95,27 +136,33 @@ func (detector *forkDetector) Detect(currentBlock *types.Block) (*types.ForkedEv
 	return &forkEvent, nil
 }
 
+//getForkedBlock walks back the parents until one of them is found in database, the final block is the bound
 func (detector *forkDetector) getForkedBlock(block *types.Block) (*types.Block, error) {
-	var (
-		ethBlock    ethaccessor.Block
-		parentBlock types.Block
-	)
-
-	// find parent block in database
-	if parentBlockModel, err := detector.db.FindBlockByHash(block.ParentHash); err == nil {
-		parentBlockModel.ConvertUp(&parentBlock)
-		return &parentBlock, nil
+	for {
+		parentNumber := new(big.Int).Sub(block.BlockNumber, big.NewInt(1))
+		if parentNumber.Cmp(detector.finalBlock) < 0 {
+			return nil, fmt.Errorf("chain fork is deeper than final block:%s, finality depth:%d", detector.finalBlock.String(), detector.depth)
+		}
+
+		// find parent block in database
+		if parentBlockModel, err := detector.db.FindBlockByHash(block.ParentHash); err == nil {
+			var parentBlock types.Block
+			parentBlockModel.ConvertUp(&parentBlock)
+			return &parentBlock, nil
+		}
+
+		// find parent block on chain
+		var ethBlock ethaccessor.Block
+		if err := ethaccessor.GetBlockByHash(&ethBlock, block.ParentHash.Hex(), false); err != nil {
+			return nil, err
+		}
+		if ethBlock.Number.BigInt().Cmp(parentNumber) != 0 {
+			return nil, fmt.Errorf("parent of block:%s is %s", block.BlockNumber.String(), ethBlock.Number.BigInt().String())
+		}
+
+		block = &types.Block{}
+		block.BlockNumber = ethBlock.Number.BigInt()
+		block.BlockHash = ethBlock.Hash
+		block.ParentHash = ethBlock.ParentHash
 	}
-
-	// find parent block on chain
-	if err := ethaccessor.GetBlockByHash(&ethBlock, block.ParentHash.Hex(), false); err != nil {
-		return nil, err
-	}
-
-	preBlock := &types.Block{}
-	preBlock.BlockNumber = ethBlock.Number.BigInt()
-	preBlock.BlockHash = ethBlock.Hash
-	preBlock.ParentHash = ethBlock.ParentHash
-
-	return detector.getForkedBlock(preBlock)
 }
@@ -0,0 +1,148 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"time"
+)
+
+/**
+logs 模式，用于从 StartBlockNumber 快速同步：
+1、按 LogBlockRange 分段，通过 eth_getLogs 获取 AbiProcessor 中已加载合约(地址、事件 topic)的事件
+2、块只获取交易体，不获取 receipt，只有产生了事件的交易以及调用了已支持方法的交易(方法的处理需要 calldata，如失败的 submitRing)才获取 receipt
+3、每个块仍然保存、检测分叉并发送 Block_New/Block_End
+4、与 block 模式不同：普通的 eth 转账以及未加载的 erc20 合约的事件不会处理
+*/
+
+const (
+	ExtractMode_Block = "block"
+	ExtractMode_Logs  = "logs"
+
+	defaultLogBlockRange = 1000
+	logWaitingTime       = 5 * time.Second
+)
+
+//LogFilter filter of eth_getLogs with the addresses and events have ever been load
+func (processor *AbiProcessor) LogFilter(fromBlock, toBlock *big.Int) *ethaccessor.LogFilter {
+	filter := &ethaccessor.LogFilter{
+		FromBlock: fmt.Sprintf("%#x", fromBlock),
+		ToBlock:   fmt.Sprintf("%#x", toBlock),
+	}
+	for protocol := range processor.protocols {
+		filter.Address = append(filter.Address, protocol)
+	}
+	ids := []common.Hash{}
+	for id := range processor.events {
+		ids = append(ids, id)
+	}
+	filter.Topics = [][]common.Hash{ids}
+	return filter
+}
+
+//logRange returns the next range of blocks which have been confirmed, ok is false if there isn't a confirmed block
+func logRange(cursor, bestBlock, endBlock *big.Int, confirms uint64, size int) (from, to *big.Int, ok bool) {
+	confirmed := new(big.Int).Sub(bestBlock, new(big.Int).SetUint64(confirms))
+	to = new(big.Int).Add(cursor, big.NewInt(int64(size-1)))
+	if to.Cmp(confirmed) > 0 {
+		to.Set(confirmed)
+	}
+	if nil != endBlock && endBlock.Sign() > 0 && to.Cmp(endBlock) > 0 {
+		to.Set(endBlock)
+	}
+	if to.Cmp(cursor) < 0 {
+		return nil, nil, false
+	}
+	return new(big.Int).Set(cursor), to, true
+}
+
+//logTransactions returns the txs in block which emit the filtered logs or call the supported methods
+func (processor *AbiProcessor) logTransactions(txs []ethaccessor.Transaction, logTxs map[common.Hash]bool) []ethaccessor.Transaction {
+	selected := []ethaccessor.Transaction{}
+	for _, tx := range txs {
+		if logTxs[common.HexToHash(tx.Hash)] || processor.SupportedMethod(&tx) {
+			selected = append(selected, tx)
+		}
+	}
+	return selected
+}
+
+func (l *ExtractorServiceImpl) ProcessLogRange() error {
+	if nil != l.endBlockNumber && l.endBlockNumber.Sign() > 0 && l.cursor.Cmp(l.endBlockNumber) > 0 {
+		return errors.New("extractor,logs mode finished")
+	}
+
+	var bestBlock types.Big
+	if err := ethaccessor.BlockNumber(&bestBlock); err != nil {
+		return fmt.Errorf("extractor,get ethereum node current block number error:%s", err.Error())
+	}
+	from, to, ok := logRange(l.cursor, bestBlock.BigInt(), l.endBlockNumber, l.options.ConfirmBlockNumber, l.options.LogBlockRange)
+	if !ok {
+		time.Sleep(logWaitingTime)
+		return nil
+	}
+
+	var logs []ethaccessor.Log
+	if err := ethaccessor.GetLogs(&logs, l.processor.LogFilter(from, to)); err != nil {
+		return fmt.Errorf("extractor,get logs from:%s to:%s error:%s", from.String(), to.String(), err.Error())
+	}
+	logTxs := make(map[common.Hash]bool)
+	for _, evtLog := range logs {
+		if !evtLog.Removed {
+			logTxs[common.HexToHash(evtLog.TransactionHash)] = true
+		}
+	}
+
+	blocks, err := ethaccessor.BatchBlocksWithTxObject(from, to)
+	if err != nil {
+		return fmt.Errorf("extractor,get blocks from:%s to:%s error:%s", from.String(), to.String(), err.Error())
+	}
+	log.Infof("extractor,get blocks:%s->%s, log number:%d", from.String(), to.String(), len(logs))
+
+	for _, block := range blocks {
+		txs := l.processor.logTransactions(block.Transactions, logTxs)
+		receipts := make([]*ethaccessor.BatchTransactionRecipientReq, len(txs))
+		for idx, tx := range txs {
+			receipts[idx] = &ethaccessor.BatchTransactionRecipientReq{TxHash: tx.Hash}
+		}
+		if len(receipts) > 0 {
+			if err := ethaccessor.BatchTransactionRecipients(receipts, block.Number.BigInt().String()); err != nil {
+				return fmt.Errorf("extractor,get receipts of block:%s error:%s", block.Number.BigInt().String(), err.Error())
+			}
+		}
+
+		emissions := []emission{}
+		for idx := range txs {
+			l.debug("extractor,tx:%s", txs[idx].Hash)
+			emissions = append(emissions, l.decodeMinedTransaction(&txs[idx], &receipts[idx].TxContent, block.Timestamp.BigInt())...)
+		}
+		if err := l.commitBlock(&block.Block, emissions, bestBlock.BigInt()); err != nil {
+			return err
+		}
+		l.cursor = new(big.Int).Add(block.Number.BigInt(), big.NewInt(1))
+	}
+
+	return nil
+}
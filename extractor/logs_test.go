@@ -0,0 +1,67 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package extractor
+
+import (
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"testing"
+)
+
+func TestLogRange(t *testing.T) {
+	from, to, ok := logRange(big.NewInt(100), big.NewInt(10000), big.NewInt(0), 5, 1000)
+	if !ok || from.Int64() != 100 || to.Int64() != 1099 {
+		t.Fatalf("unexpected range:%v, %v", from, to)
+	}
+
+	from, to, ok = logRange(big.NewInt(100), big.NewInt(300), big.NewInt(0), 5, 1000)
+	if !ok || from.Int64() != 100 || to.Int64() != 295 {
+		t.Fatalf("range should end at the confirmed block, but got:%v, %v", from, to)
+	}
+
+	from, to, ok = logRange(big.NewInt(100), big.NewInt(10000), big.NewInt(150), 5, 1000)
+	if !ok || to.Int64() != 150 {
+		t.Fatalf("range should end at the end block, but got:%v, %v", from, to)
+	}
+
+	if _, _, ok = logRange(big.NewInt(100), big.NewInt(104), big.NewInt(0), 5, 1000); ok {
+		t.Fatalf("there isn't a confirmed block")
+	}
+}
+
+func TestLogTransactions(t *testing.T) {
+	protocol := common.HexToAddress("0x750aD4351bB728ceC7d639A9511F9D6488f1E259")
+	processor := &AbiProcessor{
+		protocols: map[common.Address]string{protocol: "loopring"},
+		methods:   map[string]MethodData{"0xe78aadb2": {Name: ethaccessor.METHOD_SUBMIT_RING}},
+	}
+
+	logTx := common.HexToHash("0x01")
+	txs := []ethaccessor.Transaction{
+		{Hash: logTx.Hex(), To: common.HexToAddress("0x02").Hex(), Input: "0x"},
+		{Hash: common.HexToHash("0x02").Hex(), To: protocol.Hex(), Input: "0xe78aadb20000"},
+		{Hash: common.HexToHash("0x03").Hex(), To: protocol.Hex(), Input: "0x12345678"},
+		{Hash: common.HexToHash("0x04").Hex(), To: common.HexToAddress("0x03").Hex(), Input: "0xe78aadb20000"},
+	}
+	selected := processor.logTransactions(txs, map[common.Hash]bool{logTx: true})
+	if len(selected) != 2 || selected[0].Hash != txs[0].Hash || selected[1].Hash != txs[1].Hash {
+		t.Fatalf("only the txs emit logs or call supported methods should be selected, but got:%d", len(selected))
+	}
+}
@@ -0,0 +1,480 @@
+//go:build sqlite3
+// +build sqlite3
+
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package extractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Loopring/relay/cache"
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/Loopring/relay/eventemiter"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/market"
+	"github.com/Loopring/relay/marketcap"
+	"github.com/Loopring/relay/ordermanager"
+	"github.com/Loopring/relay/txmanager"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.uber.org/zap"
+)
+
+/**
+分叉测试：fake eth node 提供规范链，合成的分叉链通过 ExtractorServiceImpl 处理，
+ordermanager、txmanager 和 AccountManager 订阅真实的事件，检查回滚后和规范链的状态一致
+*/
+
+const reorgGenesis = 5000000
+
+var (
+	reorgOwner    = common.HexToAddress("0x1b978a1d302335a6f2ebe4b8823b5e17c3c84135")
+	reorgReceiver = common.HexToAddress("0xb1018949b241d76a1ab2094f473e9befeabb5ead")
+	reorgProtocol = common.HexToAddress("0x8d8812b72d1e4ffcec158d25f56748b7d67c1e78")
+)
+
+type reorgRpcReq struct {
+	Id     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+//reorgNode serves the canonical chain, the blocks of all chains can be got by hash like the uncles of eth node
+type reorgNode struct {
+	mtx       sync.Mutex
+	blocks    map[common.Hash]*ethaccessor.BlockWithTxAndReceipt
+	canonical []*ethaccessor.BlockWithTxAndReceipt
+	balances  map[common.Address]*big.Int
+}
+
+func (n *reorgNode) setChain(chain []*ethaccessor.BlockWithTxAndReceipt, balances map[common.Address]*big.Int) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	for _, block := range chain {
+		n.blocks[block.Hash] = block
+	}
+	n.canonical = chain
+	n.balances = balances
+}
+
+func (n *reorgNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	batch := strings.HasPrefix(strings.TrimSpace(string(body)), "[")
+	reqs := []reorgRpcReq{}
+	if batch {
+		json.Unmarshal(body, &reqs)
+	} else {
+		var req reorgRpcReq
+		json.Unmarshal(body, &req)
+		reqs = append(reqs, req)
+	}
+
+	resps := []map[string]interface{}{}
+	for _, req := range reqs {
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.Id}
+		if result, err := n.call(req.Method, req.Params); nil != err {
+			resp["error"] = map[string]interface{}{"code": -32000, "message": err.Error()}
+		} else {
+			resp["result"] = result
+		}
+		resps = append(resps, resp)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		json.NewEncoder(w).Encode(resps)
+	} else {
+		json.NewEncoder(w).Encode(resps[0])
+	}
+}
+
+func (n *reorgNode) call(method string, params []json.RawMessage) (interface{}, error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	switch method {
+	case "eth_blockNumber":
+		return hexutil.EncodeBig(n.canonical[len(n.canonical)-1].Number.BigInt()), nil
+	case "eth_getBlockByNumber":
+		var number string
+		json.Unmarshal(params[0], &number)
+		idx := int(hexutil.MustDecodeBig(number).Int64() - reorgGenesis)
+		if idx < 0 || idx >= len(n.canonical) {
+			return nil, nil
+		}
+		return reorgBlockJson(n.canonical[idx]), nil
+	case "eth_getBlockByHash":
+		var hash common.Hash
+		json.Unmarshal(params[0], &hash)
+		if block, ok := n.blocks[hash]; ok {
+			return reorgBlockJson(block), nil
+		}
+		return nil, nil
+	case "eth_getBalance":
+		var owner common.Address
+		json.Unmarshal(params[0], &owner)
+		if balance, ok := n.balances[owner]; ok {
+			return hexutil.EncodeBig(balance), nil
+		}
+		return "0x0", nil
+	case "eth_call":
+		return "0x0", nil
+	}
+	return nil, fmt.Errorf("method:%s isn't supported", method)
+}
+
+func reorgBlockJson(block *ethaccessor.BlockWithTxAndReceipt) map[string]interface{} {
+	return map[string]interface{}{
+		"number":     hexutil.EncodeBig(block.Number.BigInt()),
+		"hash":       block.Hash.Hex(),
+		"parentHash": block.ParentHash.Hex(),
+		"timestamp":  hexutil.EncodeBig(block.Timestamp.BigInt()),
+	}
+}
+
+type reorgTx struct {
+	tx      ethaccessor.Transaction
+	receipt ethaccessor.TransactionReceipt
+}
+
+func newReorgTransfer(hash string, from, to common.Address, value int64) reorgTx {
+	var t reorgTx
+	t.tx.Hash = common.HexToHash(hash).Hex()
+	t.tx.From = from.Hex()
+	t.tx.To = to.Hex()
+	t.tx.Value = *types.NewBigPtr(big.NewInt(value))
+	t.tx.Gas = *types.NewBigPtr(big.NewInt(21000))
+	t.tx.GasPrice = *types.NewBigPtr(big.NewInt(1e9))
+	t.tx.Input = "0x"
+	t.receipt.GasUsed = *types.NewBigPtr(big.NewInt(21000))
+	t.receipt.Status = types.NewBigPtr(big.NewInt(1))
+	return t
+}
+
+func newReorgCancel(hash string, orderHash common.Hash, amount int64) reorgTx {
+	t := newReorgTransfer(hash, reorgOwner, reorgProtocol, 0)
+	event := ethaccessor.ProtocolImplAbi().Events[ethaccessor.EVENT_ORDER_CANCELLED]
+	t.receipt.Logs = []ethaccessor.Log{{
+		Address: reorgProtocol.Hex(),
+		Data:    common.ToHex(common.LeftPadBytes(big.NewInt(amount).Bytes(), 32)),
+		Topics:  []string{event.Id().Hex(), orderHash.Hex()},
+	}}
+	return t
+}
+
+//newReorgChain builds blocks on parent, the hash of block is decided by the name of chain and the number
+func newReorgChain(name string, parent *ethaccessor.BlockWithTxAndReceipt, txs map[int64][]reorgTx, length int) []*ethaccessor.BlockWithTxAndReceipt {
+	chain := []*ethaccessor.BlockWithTxAndReceipt{}
+	for i := 0; i < length; i++ {
+		number := big.NewInt(reorgGenesis)
+		block := &ethaccessor.BlockWithTxAndReceipt{}
+		if nil != parent {
+			number.Add(parent.Number.BigInt(), big.NewInt(1))
+			block.ParentHash = parent.Hash
+		} else {
+			block.ParentHash = crypto.Keccak256Hash([]byte("parent of genesis"))
+		}
+		block.Number = *types.NewBigPtr(number)
+		block.Hash = crypto.Keccak256Hash([]byte(fmt.Sprintf("%s-%s", name, number.String())))
+		block.Timestamp = *types.NewBigPtr(big.NewInt(1500000000 + number.Int64()))
+		for idx, t := range txs[number.Int64()-reorgGenesis] {
+			t.tx.BlockHash = block.Hash.Hex()
+			t.tx.BlockNumber = block.Number
+			t.tx.TransactionIndex = *types.NewBigPtr(big.NewInt(int64(idx)))
+			t.receipt.BlockHash = block.Hash.Hex()
+			t.receipt.BlockNumber = block.Number
+			t.receipt.TransactionHash = t.tx.Hash
+			for logIdx := range t.receipt.Logs {
+				t.receipt.Logs[logIdx].TransactionHash = t.tx.Hash
+				t.receipt.Logs[logIdx].BlockHash = block.Hash.Hex()
+				t.receipt.Logs[logIdx].BlockNumber = block.Number
+			}
+			block.Transactions = append(block.Transactions, t.tx)
+			block.Receipts = append(block.Receipts, t.receipt)
+		}
+		chain = append(chain, block)
+		parent = block
+	}
+	return chain
+}
+
+type reorgMarketCap struct {
+	marketcap.MarketCapProvider
+}
+
+func (mc *reorgMarketCap) LegalCurrencyValue(tokenAddress common.Address, amount *big.Rat) (*big.Rat, error) {
+	return new(big.Rat).Set(amount), nil
+}
+
+type reorgHarness struct {
+	node           *reorgNode
+	rds            *dao.RdsServiceImpl
+	extractor      *ExtractorServiceImpl
+	accountManager market.AccountManager
+	orderHash      common.Hash
+
+	mtx      sync.Mutex
+	forks    []*types.ForkedEvent
+	warnings int
+	finals   []int64
+	finality map[string]types.BlockFinality
+}
+
+func newReorgHarness(t *testing.T, genesis *ethaccessor.BlockWithTxAndReceipt) *reorgHarness {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+	cache.NewCache(config.RedisOptions{Engine: "memory"})
+
+	h := &reorgHarness{finality: make(map[string]types.BlockFinality)}
+	h.node = &reorgNode{blocks: make(map[common.Hash]*ethaccessor.BlockWithTxAndReceipt)}
+	h.node.setChain([]*ethaccessor.BlockWithTxAndReceipt{genesis}, nil)
+	server := httptest.NewServer(h.node)
+
+	globalConfig := config.LoadConfig("../config/relay.toml")
+	globalConfig.Common.ProtocolImpl.Address = map[string]string{}
+	if err := ethaccessor.Initialize(config.AccessorOptions{RawUrls: []string{server.URL}}, globalConfig.Common, common.Address{}); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+
+	h.rds = dao.NewRdsService(config.MysqlOptions{Driver: "sqlite3", DbName: ":memory:", TablePrefix: "lpr_"})
+	h.rds.Prepare()
+
+	//the order cancelled by the txs of chains
+	state := &types.OrderState{}
+	state.RawOrder.Owner = reorgOwner
+	state.RawOrder.Protocol = reorgProtocol
+	state.RawOrder.TokenS = common.HexToAddress("0xef68e7c694f40c8202821edf525de3782458639f")
+	state.RawOrder.TokenB = common.HexToAddress("0x2956356cd2a2bf3202f771f50d3d14a367b48070")
+	state.RawOrder.AmountS = big.NewInt(1000)
+	state.RawOrder.AmountB = big.NewInt(100)
+	state.RawOrder.LrcFee = big.NewInt(10)
+	state.RawOrder.Price = big.NewRat(10, 1)
+	state.RawOrder.ValidSince = big.NewInt(1)
+	state.RawOrder.ValidUntil = big.NewInt(time.Now().Unix() + 3600)
+	state.RawOrder.Hash = state.RawOrder.GenerateHash()
+	state.DealtAmountS, state.DealtAmountB = big.NewInt(0), big.NewInt(0)
+	state.SplitAmountS, state.SplitAmountB = big.NewInt(0), big.NewInt(0)
+	state.CancelledAmountS, state.CancelledAmountB = big.NewInt(0), big.NewInt(0)
+	h.orderHash = state.RawOrder.Hash
+	model := &dao.Order{}
+	model.ConvertDown(state)
+	if err := h.rds.Add(model); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+
+	h.accountManager = market.NewAccountManager(config.AccountManagerOptions{})
+	h.accountManager.Start()
+	h.accountManager.UnlockedWallet(reorgOwner.Hex())
+	tm := txmanager.NewTxManager(h.rds, &h.accountManager)
+	tm.Start()
+	om := ordermanager.NewOrderManager(&config.OrderManagerOptions{}, h.rds, nil, &reorgMarketCap{})
+	om.Start()
+
+	eventemitter.On(eventemitter.ChainForkDetected, &eventemitter.Watcher{Concurrent: false, Handle: func(e eventemitter.EventData) error {
+		h.mtx.Lock()
+		defer h.mtx.Unlock()
+		h.forks = append(h.forks, e.(*types.ForkedEvent))
+		return nil
+	}})
+	eventemitter.On(eventemitter.ExtractorWarning, &eventemitter.Watcher{Concurrent: false, Handle: func(e eventemitter.EventData) error {
+		h.mtx.Lock()
+		defer h.mtx.Unlock()
+		h.warnings++
+		return nil
+	}})
+	eventemitter.On(eventemitter.Block_Final, &eventemitter.Watcher{Concurrent: false, Handle: func(e eventemitter.EventData) error {
+		h.mtx.Lock()
+		defer h.mtx.Unlock()
+		h.finals = append(h.finals, e.(*types.BlockEvent).BlockNumber.Int64())
+		return nil
+	}})
+	eventemitter.On(eventemitter.EthTransferEvent, &eventemitter.Watcher{Concurrent: false, Handle: func(e eventemitter.EventData) error {
+		return h.observeFinality(e.(*types.TransferEvent).TxInfo)
+	}})
+	eventemitter.On(eventemitter.CancelOrder, &eventemitter.Watcher{Concurrent: false, Handle: func(e eventemitter.EventData) error {
+		return h.observeFinality(e.(*types.OrderCancelledEvent).TxInfo)
+	}})
+
+	options := config.ExtractorOptions{
+		StartBlockNumber: big.NewInt(reorgGenesis),
+		EndBlockNumber:   big.NewInt(0),
+		FinalityDepth:    4,
+	}
+	h.extractor = NewExtractorService(options, h.rds)
+	h.extractor.options.ForkWaitingTime = 0
+	return h
+}
+
+func (h *reorgHarness) observeFinality(info types.TxInfo) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.finality[info.TxHash.Hex()] = info.Finality
+	return nil
+}
+
+//process commits the blocks like the iterator of extractor, the blocks after the detected fork are processed again by the caller
+func (h *reorgHarness) process(chain []*ethaccessor.BlockWithTxAndReceipt) error {
+	for _, block := range chain {
+		if err := h.extractor.processFullBlock(block); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *reorgHarness) cancelledAmount(t *testing.T) int64 {
+	model, err := h.rds.GetOrderByHash(h.orderHash)
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	amount, _ := new(big.Int).SetString(model.CancelledAmountS, 0)
+	return amount.Int64()
+}
+
+func (h *reorgHarness) ethBalance(t *testing.T, owner common.Address) int64 {
+	balances, err := h.accountManager.GetBalanceWithSymbolResult(owner)
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	return balances["ETH"].Int64()
+}
+
+func (h *reorgHarness) txMined(hash string, logIndex int64) bool {
+	_, err := h.rds.FindTxEntity(common.HexToHash(hash).Hex(), logIndex)
+	return nil == err
+}
+
+func TestReorgConverge(t *testing.T) {
+	genesis := newReorgChain("a", nil, nil, 1)[0]
+	h := newReorgHarness(t, genesis)
+
+	//chain a: the cancel of a2 is final, the cancel and the transfer of a5 are orphaned by chain b
+	chainA := newReorgChain("a", genesis, map[int64][]reorgTx{
+		2: {newReorgCancel("0xc1", h.orderHash, 10)},
+		5: {newReorgCancel("0xc2", h.orderHash, 30), newReorgTransfer("0xe1", reorgOwner, reorgReceiver, 7)},
+	}, 6)
+	h.node.setChain(append([]*ethaccessor.BlockWithTxAndReceipt{genesis}, chainA...), map[common.Address]*big.Int{reorgOwner: big.NewInt(93)})
+
+	//the balance is cached, so that AccountManager keeps it updated
+	if balance := h.ethBalance(t, reorgOwner); balance != 93 {
+		t.Fatalf("balance should be 93, but got:%d", balance)
+	}
+	for i := 0; i < 100; i++ {
+		if exists, _ := cache.Exists(market.BalancePrefix + strings.ToLower(reorgOwner.Hex())); exists {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := h.process(chainA); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if amount := h.cancelledAmount(t); amount != 40 {
+		t.Fatalf("cancelled amount should be 40, but got:%d", amount)
+	}
+	if !h.txMined("0xe1", 0) {
+		t.Fatalf("transfer tx should be saved")
+	}
+	//the best block is 5000006, blocks not greater than 5000002 are final
+	if h.finality[common.HexToHash("0xc1").Hex()] != types.BLOCK_FINAL || h.finality[common.HexToHash("0xc2").Hex()] != types.BLOCK_UNCONFIRMED {
+		t.Fatalf("unexpected finality of events:%v", h.finality)
+	}
+
+	//chain b forks after a3, the cancel of a5 is mined again in b5 and another cancel in b6, the transfer is dropped
+	chainB := newReorgChain("b", chainA[2], map[int64][]reorgTx{
+		5: {newReorgCancel("0xc2", h.orderHash, 30)},
+		6: {newReorgCancel("0xc3", h.orderHash, 50)},
+	}, 4)
+	h.node.setChain(append([]*ethaccessor.BlockWithTxAndReceipt{genesis}, append(chainA[:3:3], chainB...)...), map[common.Address]*big.Int{reorgOwner: big.NewInt(100)})
+
+	//the iterator gets b7 after a6
+	if err := h.process(chainB[3:]); nil == err {
+		t.Fatalf("chain fork should be detected")
+	}
+	if len(h.forks) != 1 || h.forks[0].ForkBlock.Int64() != reorgGenesis+3 || h.forks[0].DetectedBlock.Int64() != reorgGenesis+7 {
+		t.Fatalf("unexpected fork events:%v", h.forks)
+	}
+	if amount := h.cancelledAmount(t); amount != 10 {
+		t.Fatalf("cancelled amount should be rolled back to 10, but got:%d", amount)
+	}
+	if h.txMined("0xe1", 0) || h.txMined("0xc2", 0) || !h.txMined("0xc1", 0) {
+		t.Fatalf("txs after the fork block should be rolled back")
+	}
+	if balance := h.ethBalance(t, reorgOwner); balance != 100 {
+		t.Fatalf("balance should be synced with the canonical chain, but got:%d", balance)
+	}
+	if _, err := h.rds.FindBlockByHash(chainA[4].Hash); nil == err {
+		t.Fatalf("forked block should be marked")
+	}
+
+	//extractor restarts from the block after the fork block
+	if h.extractor.startBlockNumber.Int64() != reorgGenesis+4 {
+		t.Fatalf("extractor should restart from:%d, but got:%s", reorgGenesis+4, h.extractor.startBlockNumber.String())
+	}
+	if err := h.process(chainB); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if amount := h.cancelledAmount(t); amount != 90 {
+		t.Fatalf("cancelled amount should be 90, but got:%d", amount)
+	}
+	if h.txMined("0xe1", 0) || !h.txMined("0xc2", 0) || !h.txMined("0xc3", 0) {
+		t.Fatalf("txs of the canonical chain should be saved")
+	}
+	if _, err := h.rds.FindBlockByHash(chainB[3].Hash); nil != err {
+		t.Fatalf("block detected the fork should be saved after processed again, err:%s", err.Error())
+	}
+	if len(h.forks) != 1 {
+		t.Fatalf("the canonical chain shouldn't fork again")
+	}
+
+	//Block_Final never goes back and reaches best block - finality depth
+	for i := 1; i < len(h.finals); i++ {
+		if h.finals[i] <= h.finals[i-1] {
+			t.Fatalf("final block should increase, %v", h.finals)
+		}
+	}
+	if last := h.finals[len(h.finals)-1]; last != reorgGenesis+3 {
+		t.Fatalf("final block should be %d, but got:%d", reorgGenesis+3, last)
+	}
+
+	//chain c forks after the genesis, it's deeper than the finality depth
+	chainC := newReorgChain("c", genesis, nil, 8)
+	h.node.setChain(append([]*ethaccessor.BlockWithTxAndReceipt{genesis}, chainC...), nil)
+	if err := h.process(chainC[7:]); nil == err || !strings.Contains(err.Error(), "deeper") {
+		t.Fatalf("chain fork deeper than finality depth should be rejected, err:%v", err)
+	}
+	if len(h.forks) != 1 || h.warnings != 1 {
+		t.Fatalf("deep chain fork should emit warning instead of rolling back, forks:%d warnings:%d", len(h.forks), h.warnings)
+	}
+	if amount := h.cancelledAmount(t); amount != 90 {
+		t.Fatalf("cancelled amount shouldn't be rolled back, but got:%d", amount)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"fmt"
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+	gocache "github.com/patrickmn/go-cache"
+	"time"
+)
+
+/**
+订单广播
+1、OrderBroadcaster 负责把本地新订单广播给其他relay，并把其他relay广播过来的订单以 GatewayNewOrder 事件交给 gateway
+2、ipfs: 依赖外部 ipfs daemon 的 pubsub，订阅中断后按退避时间重新订阅，不再退出进程
+3、按订单hash去重，seen_ttl 内见过的订单不会再次触发事件或转发
+*/
+
+const (
+	Broadcaster_Ipfs = "ipfs"
+
+	defaultSeenTtl = 600
+)
+
+type OrderBroadcaster interface {
+	Name() string
+
+	// Start connect to the network and begin receiving orders from other relays
+	Start() error
+
+	Stop()
+
+	// PublishOrder broadcast the order, it does nothing if the order has been seen in the network
+	PublishOrder(order types.Order) error
+}
+
+func NewOrderBroadcaster(options *config.GateWayOptions, ipfsOptions *config.IpfsOptions) (OrderBroadcaster, error) {
+	switch options.Broadcaster {
+	case "", Broadcaster_Ipfs:
+		return NewIPFSBroadcaster(ipfsOptions, options.SeenTtl), nil
+	default:
+		return nil, fmt.Errorf("unsupported broadcaster:%s", options.Broadcaster)
+	}
+}
+
+type seenOrders struct {
+	cache *gocache.Cache
+	ttl   time.Duration
+}
+
+func newSeenOrders(ttl int) *seenOrders {
+	if ttl <= 0 {
+		ttl = defaultSeenTtl
+	}
+	s := &seenOrders{}
+	s.ttl = time.Duration(ttl) * time.Second
+	s.cache = gocache.New(s.ttl, s.ttl)
+	return s
+}
+
+//markSeen returns false if the hash has been seen within ttl
+func (s *seenOrders) markSeen(hash common.Hash) bool {
+	return nil == s.cache.Add(hash.Hex(), true, s.ttl)
+}
+
+func (s *seenOrders) forget(hash common.Hash) {
+	s.cache.Delete(hash.Hex())
+}
+
+type IPFSBroadcaster struct {
+	pub *IPFSPubServiceImpl
+	sub *IPFSSubServiceImpl
+}
+
+func NewIPFSBroadcaster(options *config.IpfsOptions, seenTtl int) *IPFSBroadcaster {
+	b := &IPFSBroadcaster{}
+	b.pub = NewIPFSPubService(options)
+	b.sub = NewIPFSSubService(*options)
+	b.sub.seen = newSeenOrders(seenTtl)
+	return b
+}
+
+func (b *IPFSBroadcaster) Name() string {
+	return Broadcaster_Ipfs
+}
+
+func (b *IPFSBroadcaster) Start() error {
+	b.sub.Start()
+	return nil
+}
+
+func (b *IPFSBroadcaster) Stop() {
+	b.sub.Stop()
+}
+
+func (b *IPFSBroadcaster) PublishOrder(order types.Order) error {
+	//ipfs pubsub delivers our own message back, mark it before publishing
+	if !b.sub.seen.markSeen(order.Hash) {
+		return nil
+	}
+	if err := b.pub.PublishOrder(order); nil != err {
+		b.sub.seen.forget(order.Hash)
+		return err
+	}
+	return nil
+}
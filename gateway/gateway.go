@@ -28,21 +28,25 @@ import (
 	"github.com/Loopring/relay/market"
 	"github.com/Loopring/relay/market/util"
 	"github.com/Loopring/relay/marketcap"
+	"github.com/Loopring/relay/metrics"
 	"github.com/Loopring/relay/ordermanager"
 	"github.com/Loopring/relay/types"
 	"github.com/ethereum/go-ethereum/common"
 	"math/big"
 	"qiniupkg.com/x/errors.v7"
+	"reflect"
 	"time"
 )
 
+var filteredOrders = metrics.NewCounterVec("relay_gateway_filtered_orders_total", "Orders accepted or rejected by the filters of gateway.", "filter", "result")
+
 type Gateway struct {
 	filters          []Filter
 	om               ordermanager.OrderManager
 	am               market.AccountManager
 	isBroadcast      bool
 	maxBroadcastTime int
-	ipfsPubService   IPFSPubService
+	broadcaster      OrderBroadcaster
 	marketCap        marketcap.MarketCapProvider
 }
 
@@ -52,15 +56,19 @@ type Filter interface {
 	filter(o *types.Order) (bool, error)
 }
 
+func filterName(f Filter) string {
+	return reflect.Indirect(reflect.ValueOf(f)).Type().Name()
+}
+
 func Initialize(filterOptions *config.GatewayFiltersOptions,
-	options *config.GateWayOptions, ipfsOptions *config.IpfsOptions,
+	options *config.GateWayOptions, broadcaster OrderBroadcaster,
 		om ordermanager.OrderManager, marketCap marketcap.MarketCapProvider, am market.AccountManager) {
 	// add gateway watcher
 	gatewayWatcher := &eventemitter.Watcher{Concurrent: false, Handle: HandleOrder}
 	eventemitter.On(eventemitter.GatewayNewOrder, gatewayWatcher)
 
 	gateway = Gateway{filters: make([]Filter, 0), om: om, isBroadcast: options.IsBroadcast, maxBroadcastTime: options.MaxBroadcastTime, am: am}
-	//gateway.ipfsPubService = NewIPFSPubService(ipfsOptions)
+	gateway.broadcaster = broadcaster
 
 	gateway.marketCap = marketCap
 
@@ -111,8 +119,6 @@ func HandleInputOrder(input eventemitter.EventData) (orderHash string, err error
 	order.Hash = order.GenerateHash()
 	orderHash = order.Hash.Hex()
 
-	//var broadcastTime int
-
 	//TODO(xiaolu) 这里需要测试一下，超时error和查询数据为空的error，处理方式不应该一样
 	if state, err = gateway.om.GetOrderByHash(order.Hash); err != nil && err.Error() == "record not found" {
 		// lgh: 如果该订单本地数据库没有记录，那么进入这里，触发新订单事件，否则触发订单已经存在的错误
@@ -125,33 +131,29 @@ func HandleInputOrder(input eventemitter.EventData) (orderHash string, err error
 		for _, v := range gateway.filters {
 			valid, err := v.filter(order)
 			if !valid {
+				filteredOrders.Inc(filterName(v), "rejected")
 				log.Errorf(err.Error())
 				return orderHash, err
 			}
+			filteredOrders.Inc(filterName(v), "accepted")
 		}
 		state = &types.OrderState{}
 		state.RawOrder = *order
-		//broadcastTime = 0
 		eventemitter.Emit(eventemitter.NewOrder, state)
 	} else {
-		//broadcastTime = state.BroadcastTime
 		log.Infof("gateway,order %s exist,will not insert again", order.Hash.Hex())
 		return orderHash, errors.New("order existed, please not submit again")
 	}
 
-	//if gateway.isBroadcast && broadcastTime < gateway.maxBroadcastTime {
-	//	//broadcast
-	//	log.Infof(">>>>>>> broad to ipfs order : " + state.RawOrder.Hash.Hex())
-	//	pubErr := gateway.ipfsPubService.PublishOrder(state.RawOrder)
-	//	if pubErr != nil {
-	//		log.Errorf("gateway,publish order %s failed", state.RawOrder.Hash.String())
-	//	} else {
-	//		if err = gateway.om.UpdateBroadcastTimeByHash(state.RawOrder.Hash, state.BroadcastTime+1); nil != err {
-	//			return err
-	//		}
-	//	}
-	//}
-	return orderHash, err
+	if gateway.isBroadcast && nil != gateway.broadcaster && state.BroadcastTime < gateway.maxBroadcastTime {
+		//orders from other relays have been seen by the broadcaster and will not be sent again
+		if pubErr := gateway.broadcaster.PublishOrder(state.RawOrder); nil != pubErr {
+			log.Errorf("gateway,publish order %s by %s failed:%s", orderHash, gateway.broadcaster.Name(), pubErr.Error())
+		} else if err = gateway.om.UpdateBroadcastTimeByHash(state.RawOrder.Hash, state.BroadcastTime+1); nil != err {
+			log.Errorf("gateway,update broadcast time of order %s failed:%s", orderHash, err.Error())
+		}
+	}
+	return orderHash, nil
 }
 
 func HandleOrder(input eventemitter.EventData) error {
@@ -286,7 +288,7 @@ func (f *BaseFilter) filter(o *types.Order) (bool, error) {
 	}
 	tokenSFloatPrice, _ := tokenSPrice.Float64()
 	if tokenSFloatPrice <= 0 {
-		return false, fmt.Errorf("get zero token s price. symbol : " + tokenS.Symbol)
+		return false, fmt.Errorf("get zero token s price. symbol : %s", tokenS.Symbol)
 	}
 
 	amountDivDecimal, _ := new(big.Rat).SetFrac(o.AmountS, tokenS.Decimals).Float64()
@@ -27,6 +27,7 @@ import (
 	"github.com/ipfs/go-ipfs-api"
 	pb "github.com/libp2p/go-floodsub/pb"
 	peer "github.com/libp2p/go-libp2p-peer"
+	"io"
 	"net/http"
 )
 
@@ -56,6 +57,7 @@ func (r Record) TopicIDs() []string {
 
 type PubSubSubscription struct {
 	reader *chunkedReader
+	output io.ReadCloser
 }
 
 func (s *PubSubSubscription) Next() (*Record, error) {
@@ -70,6 +72,11 @@ func (s *PubSubSubscription) Next() (*Record, error) {
 	return record, nil
 }
 
+//Close releases the connection to ipfs, a blocked Next will return with error
+func (s *PubSubSubscription) Close() error {
+	return s.output.Close()
+}
+
 func PubSubSubscribe(url, topic string) (*PubSubSubscription, error) {
 	req := shell.NewRequest(context.Background(), url, "pubsub/sub", topic)
 	client := &http.Client{Transport: &http.Transport{
@@ -85,6 +92,6 @@ func PubSubSubscribe(url, topic string) (*PubSubSubscription, error) {
 			return nil, err
 		}
 		reader := NewChunkedReader(response.Output)
-		return &PubSubSubscription{reader: reader}, nil
+		return &PubSubSubscription{reader: reader, output: response.Output}, nil
 	}
 }
@@ -27,6 +27,7 @@ import (
 
 	"github.com/Loopring/relay/log"
 	"sync"
+	"time"
 )
 
 type IPFSSubService interface {
@@ -53,6 +54,7 @@ type IPFSSubServiceImpl struct {
 	stop    chan struct{}
 	mtx     sync.Mutex
 	url     string
+	seen    *seenOrders
 }
 
 func NewIPFSSubService(options config.IpfsOptions) *IPFSSubServiceImpl {
@@ -60,15 +62,12 @@ func NewIPFSSubService(options config.IpfsOptions) *IPFSSubServiceImpl {
 	l.url = options.Url()
 	l.options = options
 	l.subs = make(map[string]*subProxy)
+	l.seen = newSeenOrders(defaultSeenTtl)
 
 	// TODO: get topics from mysql and combine with toml config
 
 	for _, topic := range l.options.ListenTopics {
-		proxy, err := l.newSubProxy(topic)
-		if err != nil {
-			log.Fatalf("ipfs impl create sub scribe error:%s", err.Error())
-		}
-		l.subs[topic] = proxy
+		l.subs[topic] = l.newSubProxy(topic)
 	}
 
 	return l
@@ -78,20 +77,11 @@ func (l *IPFSSubServiceImpl) Register(topic string) error {
 	l.mtx.Lock()
 	defer l.mtx.Unlock()
 
-	var (
-		proxy *subProxy
-		err   error
-		ok    bool
-	)
-
-	if proxy, ok = l.subs[topic]; ok {
+	if _, ok := l.subs[topic]; ok {
 		return fmt.Errorf("ipfs sub,topic %s already exist", topic)
 	}
 
-	if proxy, err = l.newSubProxy(topic); err != nil {
-		return fmt.Errorf("ipfs sub,register new topic %s error %s", topic, err.Error())
-	}
-
+	proxy := l.newSubProxy(topic)
 	proxy.listen()
 	l.subs[topic] = proxy
 
@@ -138,47 +128,116 @@ func (l *IPFSSubServiceImpl) Restart() {
 	}
 }
 
+const (
+	subRetryInterval    = time.Second
+	subMaxRetryInterval = time.Minute
+)
+
 type subProxy struct {
 	topic    string
+	url      string
+	seen     *seenOrders
 	iterator *ipfs.PubSubSubscription
 	stop     chan struct{}
+	mtx      sync.Mutex
 }
 
-func (l *IPFSSubServiceImpl) newSubProxy(topic string) (*subProxy, error) {
+func (l *IPFSSubServiceImpl) newSubProxy(topic string) *subProxy {
 	s := &subProxy{}
 	s.topic = topic
-	scribe, err := ipfs.PubSubSubscribe(l.url, topic)
-	if err != nil {
-		return nil, err
-	}
-	s.iterator = scribe
-
-	return s, nil
+	s.url = l.url
+	s.seen = l.seen
+	return s
 }
 
+//listen subscribes the topic and keeps resubscribing with backoff when ipfs goes away, until quit
 func (p *subProxy) listen() {
-	p.stop = make(chan struct{})
+	stop := make(chan struct{})
+	p.mtx.Lock()
+	p.stop = stop
+	p.mtx.Unlock()
 
 	go func() {
+		interval := subRetryInterval
 		for {
-			record, err := p.iterator.Next()
-			if err != nil {
-				log.Fatalf("ipfs sub,ipfs occurs err:%s shut down!", err.Error())
+			iterator, err := p.subscribe(stop)
+			if nil != err {
+				log.Errorf("ipfs sub,subscribe topic %s error:%s, retry in %s", p.topic, err.Error(), interval.String())
+				select {
+				case <-stop:
+					return
+				case <-time.After(interval):
+				}
+				if interval *= 2; interval > subMaxRetryInterval {
+					interval = subMaxRetryInterval
+				}
+				continue
 			}
-			//record.data() have to contain two char: '{' and '}'
-			if len(record.Data()) > 2 {
-				ord := &types.Order{}
-				if err := ord.UnmarshalJSON(record.Data()); err != nil {
-					log.Errorf("ipfs sub,failed to accept data %s", err.Error())
-					continue
+			interval = subRetryInterval
+
+			for {
+				record, err := iterator.Next()
+				if nil != err {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					log.Errorf("ipfs sub,topic %s occurs err:%s, resubscribe", p.topic, err.Error())
+					iterator.Close()
+					break
 				}
-				log.Debugf("ipfs sub,accept data from topic %s and data is %s", p.topic, string(record.Data()))
-				eventemitter.Emit(eventemitter.GatewayNewOrder, ord)
+				p.handleRecord(record)
 			}
 		}
 	}()
 }
 
+func (p *subProxy) subscribe(stop chan struct{}) (*ipfs.PubSubSubscription, error) {
+	iterator, err := ipfs.PubSubSubscribe(p.url, p.topic)
+	if nil != err {
+		return nil, err
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	select {
+	case <-stop:
+		iterator.Close()
+		return nil, fmt.Errorf("ipfs sub,topic %s has been quit", p.topic)
+	default:
+	}
+	p.iterator = iterator
+	return iterator, nil
+}
+
+func (p *subProxy) handleRecord(record *ipfs.Record) {
+	//record.data() have to contain two char: '{' and '}'
+	if len(record.Data()) <= 2 {
+		return
+	}
+	ord := &types.Order{}
+	if err := ord.UnmarshalJSON(record.Data()); err != nil {
+		log.Errorf("ipfs sub,failed to accept data %s", err.Error())
+		return
+	}
+	if !p.seen.markSeen(ord.GenerateHash()) {
+		return
+	}
+	log.Debugf("ipfs sub,accept data from topic %s and data is %s", p.topic, string(record.Data()))
+	eventemitter.Emit(eventemitter.GatewayNewOrder, ord)
+}
+
 func (p *subProxy) quit() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if nil == p.stop {
+		return
+	}
 	close(p.stop)
+	p.stop = nil
+	if nil != p.iterator {
+		p.iterator.Close()
+		p.iterator = nil
+	}
 }
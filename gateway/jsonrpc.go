@@ -19,8 +19,10 @@
 package gateway
 
 import (
+	"errors"
 	"fmt"
 	"github.com/Loopring/relay/log"
+	txtyp "github.com/Loopring/relay/txmanager/types"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/cors"
 	"net"
@@ -40,19 +42,21 @@ type JsonrpcService interface {
 type JsonrpcServiceImpl struct {
 	port          string
 	walletService *WalletServiceImpl
+	rateLimiter   *RateLimiter
 }
 
-func NewJsonrpcService(port string, walletService *WalletServiceImpl) *JsonrpcServiceImpl {
+func NewJsonrpcService(port string, walletService *WalletServiceImpl, rateLimiter *RateLimiter) *JsonrpcServiceImpl {
 	l := &JsonrpcServiceImpl{}
 	l.port = port
 	l.walletService = walletService
+	l.rateLimiter = rateLimiter
 	return l
 }
 
 func (j *JsonrpcServiceImpl) Start() {
 
 	handler := rpc.NewServer()
-	if err := handler.RegisterName("loopring", j.walletService); err != nil {
+	if err := handler.RegisterName("loopring", OwnerAuthService{j.walletService}); err != nil {
 		fmt.Println(err)
 		return
 	}
@@ -66,15 +70,15 @@ func (j *JsonrpcServiceImpl) Start() {
 		return
 	}
 	//httpServer := rpc.NewHTTPServer([]string{"*"}, handler)
-	httpServer := &http.Server{Handler: newCorsHandler(handler, []string{"*"})}
+	httpServer := &http.Server{Handler: newCorsHandler(newRateLimitHandler(j.rateLimiter, handler), []string{"*"})}
 	//httpServer.Handler = newCorsHandler(handler, []string{"*"})
 	go httpServer.Serve(listener)
-	log.Info(fmt.Sprintf("HTTP endpoint opened on " + j.port))
+	log.Info(fmt.Sprintf("HTTP endpoint opened on %s", j.port))
 
 	return
 }
 
-func newCorsHandler(srv *rpc.Server, allowedOrigins []string) http.Handler {
+func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
 		return srv
@@ -85,7 +89,69 @@ func newCorsHandler(srv *rpc.Server, allowedOrigins []string) http.Handler {
 		AllowedMethods:   []string{"POST", "GET"},
 		MaxAge:           600,
 		AllowedHeaders:   []string{"*"},
+		ExposedHeaders:   []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 	})
 	return c.Handler(srv)
 }
+
+//OwnerAuthService requires the session token for the methods scoped to owner, the others are served by WalletServiceImpl directly
+type OwnerAuthService struct {
+	*WalletServiceImpl
+}
+
+func (s OwnerAuthService) authorize(owner, token string) error {
+	if nil == s.sessions {
+		return errors.New("session is not supported")
+	}
+	return s.sessions.Authorize(owner, token)
+}
+
+func (s OwnerAuthService) UnlockWallet(owner SingleOwner) (result string, err error) {
+	if err = s.authorize(owner.Owner, owner.Token); nil != err {
+		return "", err
+	}
+	return s.WalletServiceImpl.UnlockWallet(owner)
+}
+
+func (s OwnerAuthService) NotifyTransactionSubmitted(txNotify TxNotify) (result string, err error) {
+	if err = s.authorize(txNotify.From, txNotify.Token); nil != err {
+		return "", err
+	}
+	return s.WalletServiceImpl.NotifyTransactionSubmitted(txNotify)
+}
+
+func (s OwnerAuthService) GetPortfolio(query SingleOwner) (res []Portfolio, err error) {
+	if err = s.authorize(query.Owner, query.Token); nil != err {
+		return nil, err
+	}
+	return s.WalletServiceImpl.GetPortfolio(query)
+}
+
+func (s OwnerAuthService) GetBalance(query CommonTokenRequest) (res AccountJson, err error) {
+	if err = s.authorize(query.Owner, query.Token); nil != err {
+		return res, err
+	}
+	return s.WalletServiceImpl.GetBalance(query)
+}
+
+func (s OwnerAuthService) GetTransactions(query TransactionQuery) (res PageResult, err error) {
+	if err = s.authorize(query.Owner, query.Token); nil != err {
+		return res, err
+	}
+	return s.WalletServiceImpl.GetTransactions(query)
+}
+
+func (s OwnerAuthService) GetPendingTransactions(query SingleOwner) (result []txtyp.TransactionJsonResult, err error) {
+	if err = s.authorize(query.Owner, query.Token); nil != err {
+		return nil, err
+	}
+	return s.WalletServiceImpl.GetPendingTransactions(query)
+}
+
+func (s OwnerAuthService) GetTransactionsByHash(query TransactionQuery) (result []txtyp.TransactionJsonResult, err error) {
+	if err = s.authorize(query.Owner, query.Token); nil != err {
+		return nil, err
+	}
+	return s.WalletServiceImpl.GetTransactionsByHash(query)
+}
@@ -1,3 +1,6 @@
+//go:build integration
+// +build integration
+
 /*
 
   Copyright 2017 Loopring Project Ltd (Loopring Foundation).
@@ -16,7 +19,7 @@
 
 */
 
-package gateway
+package gateway_test
 
 import (
 	"fmt"
@@ -0,0 +1,214 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"encoding"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const openAPIVersion = "3.0.0"
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	bigIntType        = reflect.TypeOf(big.Int{})
+)
+
+//newOpenAPIDocument generates the document of routes, the schemas are reflected from the samples of body and result
+func newOpenAPIDocument(routes []restRoute) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	schemas["RestError"] = schemaOf(reflect.TypeOf(RestError{}), schemas)
+	errorResponse := map[string]interface{}{
+		"description": "error, the first three digits of code is the http status",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"error": map[string]interface{}{"$ref": "#/components/schemas/RestError"}},
+				},
+			},
+		},
+	}
+
+	paths := make(map[string]interface{})
+	for _, route := range routes {
+		operation := map[string]interface{}{
+			"operationId": route.WalletMethod,
+			"summary":     route.Summary,
+		}
+		params := []interface{}{}
+		for _, param := range route.Params {
+			p := map[string]interface{}{
+				"name":     param.Name,
+				"in":       param.In,
+				"required": param.Required || param.In == "path",
+				"schema":   map[string]interface{}{"type": param.Type},
+			}
+			if "" != param.Description {
+				p["description"] = param.Description
+			}
+			params = append(params, p)
+		}
+		operation["parameters"] = params
+		if nil != route.Body {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(route.Body), schemas)},
+				},
+			}
+		}
+		if route.Auth {
+			operation["security"] = []interface{}{map[string]interface{}{"sessionToken": []string{}}}
+		}
+		operation["responses"] = map[string]interface{}{
+			strconv.Itoa(route.Status): map[string]interface{}{
+				"description": "success",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{
+							"type":       "object",
+							"properties": map[string]interface{}{"data": schemaOf(reflect.TypeOf(route.Result), schemas)},
+						},
+					},
+				},
+			},
+			"default": errorResponse,
+		}
+
+		path := restPathPrefix + route.Path
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   "Loopring Relay REST API",
+			"version": "2.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"sessionToken": map[string]interface{}{"type": "http", "scheme": "bearer", "description": "token of loopring_createSession"},
+				"apiKey":       map[string]interface{}{"type": "apiKey", "in": "header", "name": apiKeyHeader},
+			},
+		},
+	}
+}
+
+//schemaOf returns the json schema of typ, the named structs are added to schemas and referenced
+func schemaOf(typ reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == bigIntType {
+		return map[string]interface{}{"type": "string", "description": "big integer in hex"}
+	}
+	if typ.Implements(textMarshalerType) || reflect.PtrTo(typ).Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaOf(typ.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(typ.Elem(), schemas)}
+	case reflect.Struct:
+		name := typ.Name()
+		if _, exists := schemas[name]; exists && "" != name {
+			return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+		}
+		if "" != name {
+			//placeholder for the recursive types
+			schemas[name] = nil
+		}
+		properties, required := inlineProperties(typ, schemas)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		if "" == name {
+			return schema
+		}
+		schemas[name] = schema
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+//inlineProperties returns the properties of the exported fields, the embedded structs are flattened like encoding/json.
+//the fields tagged with gencodec:"required" are required
+func inlineProperties(typ reflect.Type, schemas map[string]interface{}) (map[string]interface{}, []string) {
+	properties := make(map[string]interface{})
+	required := []string{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if "" != field.PkgPath && !field.Anonymous {
+			continue
+		}
+		jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+		if "-" == jsonName {
+			continue
+		}
+		if field.Anonymous && "" == jsonName {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				embeddedProperties, embeddedRequired := inlineProperties(embedded, schemas)
+				for k, v := range embeddedProperties {
+					properties[k] = v
+				}
+				required = append(required, embeddedRequired...)
+				continue
+			}
+		}
+		if "" == jsonName {
+			jsonName = field.Name
+		}
+		properties[jsonName] = schemaOf(field.Type, schemas)
+		if "required" == field.Tag.Get("gencodec") {
+			required = append(required, jsonName)
+		}
+	}
+	return properties, required
+}
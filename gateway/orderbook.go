@@ -0,0 +1,667 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/Loopring/relay/eventemiter"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/market/util"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+增量订单簿
+1、OrderBook 在内存中按 delegate+market 维护深度，启动时从数据库加载，之后只由事件驱动更新:
+   NewOrder 新增订单；OrderFilled、CancelOrder 累加成交和取消数量，订单完成后移除；Cutoff、CutoffPair 移除被截止的订单
+2、BalanceUpdated 只标记用户，余额在 Block_End 时才同步到缓存，所以在下一个 Block_New 时重新计算该用户的订单
+3、每次变化后市场的 sequence 加一，并发出 DepthDelta 事件，内容是变化了的价格档位，数量为0表示该档位被移除
+4、客户端先通过 GetDepth 取得带 sequence 的快照，再依次应用 sequence 更大的增量，发现 sequence 不连续时重新取快照
+5、定时刷新处理订单的生效和过期，分叉后重新从数据库加载，加载前后的差异同样以增量发出
+6、加载时按 id 分页读取全部未过期的订单，包括 valid_since 在未来的订单，这些订单在生效后由定时刷新加入深度
+*/
+
+const (
+	orderBookLoadPageSize    = 1000
+	orderBookRefreshInterval = 10 * time.Second
+
+	depthSideBuy  = 0
+	depthSideSell = 1
+)
+
+type orderBookSource interface {
+	GetActiveOrderBook(protocol, tokenS, tokenB common.Address, fromId, limit int) ([]types.OrderState, int, error)
+	IsOrderFullFinished(state *types.OrderState) bool
+}
+
+type balanceSource interface {
+	GetBalanceAndAllowance(owner, token, spender common.Address) (balance, allowance *big.Int, err error)
+}
+
+type OrderBook struct {
+	source   orderBookSource
+	balances balanceSource
+	pageSize int
+
+	books       map[string]*marketBook
+	orders      map[common.Hash]*bookOrder
+	owners      map[common.Address]map[common.Hash]*bookOrder
+	dirtyOwners map[common.Address]bool
+	forked      bool
+
+	watchers map[string]*eventemitter.Watcher
+	stop     chan struct{}
+	mtx      sync.Mutex
+}
+
+type marketBook struct {
+	delegate common.Address
+	market   string
+	sequence uint64
+	levels   [2]map[string]*depthLevel
+	touched  [2]map[string]bool
+}
+
+type depthLevel struct {
+	amount *big.Rat
+	size   *big.Rat
+	orders int
+}
+
+type bookOrder struct {
+	state  types.OrderState
+	book   *marketBook
+	side   int
+	level  string
+	amount *big.Rat
+	size   *big.Rat
+	events map[string]bool
+}
+
+func NewOrderBook(source orderBookSource, balances balanceSource) *OrderBook {
+	ob := &OrderBook{}
+	ob.source = source
+	ob.balances = balances
+	ob.pageSize = orderBookLoadPageSize
+	ob.books = make(map[string]*marketBook)
+	ob.orders = make(map[common.Hash]*bookOrder)
+	ob.owners = make(map[common.Address]map[common.Hash]*bookOrder)
+	ob.dirtyOwners = make(map[common.Address]bool)
+	ob.watchers = make(map[string]*eventemitter.Watcher)
+	return ob
+}
+
+func (ob *OrderBook) Start() {
+	delegates := []common.Address{}
+	for delegate := range ethaccessor.DelegateAddresses() {
+		delegates = append(delegates, delegate)
+	}
+	ob.mtx.Lock()
+	ob.loadBooks(delegates)
+	ob.emitDeltas()
+	ob.mtx.Unlock()
+
+	ob.watchers[eventemitter.NewOrder] = &eventemitter.Watcher{Concurrent: false, Handle: ob.handleNewOrder}
+	ob.watchers[eventemitter.OrderFilled] = &eventemitter.Watcher{Concurrent: false, Handle: ob.handleOrderFilled}
+	ob.watchers[eventemitter.CancelOrder] = &eventemitter.Watcher{Concurrent: false, Handle: ob.handleOrderCancelled}
+	ob.watchers[eventemitter.CutoffAll] = &eventemitter.Watcher{Concurrent: false, Handle: ob.handleCutoff}
+	ob.watchers[eventemitter.CutoffPair] = &eventemitter.Watcher{Concurrent: false, Handle: ob.handleCutoffPair}
+	ob.watchers[eventemitter.BalanceUpdated] = &eventemitter.Watcher{Concurrent: false, Handle: ob.handleBalanceUpdated}
+	ob.watchers[eventemitter.Block_New] = &eventemitter.Watcher{Concurrent: false, Handle: ob.handleBlockNew}
+	ob.watchers[eventemitter.ChainForkDetected] = &eventemitter.Watcher{Concurrent: false, Handle: ob.handleFork}
+	for topic, watcher := range ob.watchers {
+		eventemitter.On(topic, watcher)
+	}
+
+	ob.stop = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(orderBookRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ob.stop:
+				return
+			case <-ticker.C:
+				ob.refresh(time.Now().Unix())
+			}
+		}
+	}()
+}
+
+func (ob *OrderBook) Stop() {
+	for topic, watcher := range ob.watchers {
+		eventemitter.Un(topic, watcher)
+	}
+	if nil != ob.stop {
+		close(ob.stop)
+	}
+}
+
+//Depth returns the snapshot of the market with its sequence, it returns false if the book is not maintained
+func (ob *OrderBook) Depth(delegate common.Address, market string, length int) (Depth, bool) {
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+
+	book, ok := ob.books[depthKey(delegate, market)]
+	if !ok {
+		return Depth{}, false
+	}
+	depth := Depth{DelegateAddress: delegate.Hex(), Market: book.market, Sequence: book.sequence}
+	depth.Depth.Buy = book.snapshot(depthSideBuy, length)
+	depth.Depth.Sell = book.snapshot(depthSideSell, length)
+	return depth, true
+}
+
+func depthKey(delegate common.Address, market string) string {
+	return strings.ToLower(delegate.Hex()) + "_" + strings.ToLower(market)
+}
+
+func (ob *OrderBook) loadBooks(delegates []common.Address) {
+	for _, delegate := range delegates {
+		for _, mkt := range util.AllMarkets {
+			a, b := util.UnWrap(mkt)
+			tokenA, okA := util.AllTokens[a]
+			tokenB, okB := util.AllTokens[b]
+			if !okA || !okB {
+				continue
+			}
+			asks, err := ob.loadOrders(delegate, tokenA.Protocol, tokenB.Protocol)
+			if nil != err {
+				log.Errorf("order book,load asks of %s error:%s", mkt, err.Error())
+				continue
+			}
+			bids, err := ob.loadOrders(delegate, tokenB.Protocol, tokenA.Protocol)
+			if nil != err {
+				log.Errorf("order book,load bids of %s error:%s", mkt, err.Error())
+				continue
+			}
+
+			key := depthKey(delegate, mkt)
+			book, ok := ob.books[key]
+			if !ok {
+				book = newMarketBook(delegate, mkt)
+				ob.books[key] = book
+			}
+			for hash, o := range ob.orders {
+				if o.book == book {
+					ob.removeOrder(hash)
+				}
+			}
+			now := time.Now().Unix()
+			for i := range asks {
+				ob.addOrder(&asks[i], now)
+			}
+			for i := range bids {
+				ob.addOrder(&bids[i], now)
+			}
+			log.Debugf("order book,loaded %s of %s, asks:%d, bids:%d", mkt, delegate.Hex(), len(asks), len(bids))
+		}
+	}
+}
+
+func (ob *OrderBook) loadOrders(delegate, tokenS, tokenB common.Address) ([]types.OrderState, error) {
+	orders := []types.OrderState{}
+	fromId := 0
+	for {
+		page, lastId, err := ob.source.GetActiveOrderBook(delegate, tokenS, tokenB, fromId, ob.pageSize)
+		if nil != err {
+			return orders, err
+		}
+		orders = append(orders, page...)
+		if lastId <= fromId {
+			return orders, nil
+		}
+		fromId = lastId
+	}
+}
+
+func newMarketBook(delegate common.Address, market string) *marketBook {
+	book := &marketBook{delegate: delegate, market: market}
+	for side := range book.levels {
+		book.levels[side] = make(map[string]*depthLevel)
+		book.touched[side] = make(map[string]bool)
+	}
+	return book
+}
+
+func (ob *OrderBook) addOrder(state *types.OrderState, now int64) {
+	ord := state.RawOrder
+	if ord.OrderType != types.ORDER_TYPE_MARKET || nil == ord.Price {
+		return
+	}
+	if _, exists := ob.orders[ord.Hash]; exists {
+		return
+	}
+	mkt, err := util.WrapMarketByAddress(ord.TokenS.Hex(), ord.TokenB.Hex())
+	if nil != err {
+		return
+	}
+	book, ok := ob.books[depthKey(ord.DelegateAddress, mkt)]
+	if !ok {
+		return
+	}
+
+	o := &bookOrder{state: *state, book: book, events: make(map[string]bool)}
+	for _, amount := range []**big.Int{&o.state.DealtAmountS, &o.state.DealtAmountB, &o.state.SplitAmountS, &o.state.SplitAmountB, &o.state.CancelledAmountS, &o.state.CancelledAmountB} {
+		if nil == *amount {
+			*amount = big.NewInt(0)
+		}
+	}
+	if a, _ := util.UnWrap(mkt); util.AllTokens[a].Protocol == ord.TokenS {
+		o.side = depthSideSell
+	} else {
+		o.side = depthSideBuy
+	}
+
+	ob.orders[ord.Hash] = o
+	if _, ok := ob.owners[ord.Owner]; !ok {
+		ob.owners[ord.Owner] = make(map[common.Hash]*bookOrder)
+	}
+	ob.owners[ord.Owner][ord.Hash] = o
+	ob.updateOrder(o, now)
+}
+
+func (ob *OrderBook) removeOrder(hash common.Hash) {
+	o, ok := ob.orders[hash]
+	if !ok {
+		return
+	}
+	o.book.withdraw(o)
+	delete(ob.orders, hash)
+	if orders, ok := ob.owners[o.state.RawOrder.Owner]; ok {
+		delete(orders, hash)
+		if 0 == len(orders) {
+			delete(ob.owners, o.state.RawOrder.Owner)
+		}
+	}
+}
+
+//updateOrder recalculates what the order contributes to its price level
+func (ob *OrderBook) updateOrder(o *bookOrder, now int64) {
+	o.book.withdraw(o)
+
+	ord := o.state.RawOrder
+	if ord.ValidSince.Int64() >= now || ord.ValidUntil.Int64() < now {
+		return
+	}
+	tokenS, err := util.AddressToToken(ord.TokenS)
+	if nil != err {
+		return
+	}
+	tokenB, err := util.AddressToToken(ord.TokenB)
+	if nil != err {
+		return
+	}
+	price, amount, size, err := orderDepth(ob.balances, &o.state, o.side == depthSideSell, tokenS.Decimals, tokenB.Decimals)
+	if nil != err {
+		return
+	}
+	o.level, o.amount, o.size = price, amount, size
+	o.book.deposit(o)
+}
+
+func (b *marketBook) withdraw(o *bookOrder) {
+	if "" == o.level {
+		return
+	}
+	if level, ok := b.levels[o.side][o.level]; ok {
+		level.amount.Sub(level.amount, o.amount)
+		level.size.Sub(level.size, o.size)
+		if level.orders--; level.orders <= 0 {
+			delete(b.levels[o.side], o.level)
+		}
+	}
+	b.touched[o.side][o.level] = true
+	o.level, o.amount, o.size = "", nil, nil
+}
+
+func (b *marketBook) deposit(o *bookOrder) {
+	level, ok := b.levels[o.side][o.level]
+	if !ok {
+		level = &depthLevel{amount: new(big.Rat), size: new(big.Rat)}
+		b.levels[o.side][o.level] = level
+	}
+	level.amount.Add(level.amount, o.amount)
+	level.size.Add(level.size, o.size)
+	level.orders++
+	b.touched[o.side][o.level] = true
+}
+
+//flush returns the changed levels since last flush with a new sequence
+func (b *marketBook) flush() (*types.DepthDeltaEvent, bool) {
+	if 0 == len(b.touched[depthSideBuy]) && 0 == len(b.touched[depthSideSell]) {
+		return nil, false
+	}
+	b.sequence++
+	evt := &types.DepthDeltaEvent{DelegateAddress: b.delegate.Hex(), Market: b.market, Sequence: b.sequence}
+	evt.Buy = b.changes(depthSideBuy)
+	evt.Sell = b.changes(depthSideSell)
+	return evt, true
+}
+
+func (b *marketBook) changes(side int) [][]string {
+	changes := [][]string{}
+	for price := range b.touched[side] {
+		if level, ok := b.levels[side][price]; ok {
+			changes = append(changes, level.format(price))
+		} else {
+			changes = append(changes, []string{price, strconv.FormatFloat(0, 'f', 10, 64), strconv.FormatFloat(0, 'f', 10, 64)})
+		}
+	}
+	b.touched[side] = make(map[string]bool)
+	sortDepth(changes)
+	return changes
+}
+
+func (b *marketBook) snapshot(side int, length int) [][]string {
+	depth := [][]string{}
+	for price, level := range b.levels[side] {
+		depth = append(depth, level.format(price))
+	}
+	sortDepth(depth)
+	if length < len(depth) {
+		if side == depthSideSell {
+			return depth[len(depth)-length:]
+		} else {
+			return depth[:length]
+		}
+	}
+	return depth
+}
+
+func (l *depthLevel) format(price string) []string {
+	amount, _ := l.amount.Float64()
+	size, _ := l.size.Float64()
+	return []string{price, strconv.FormatFloat(amount, 'f', 10, 64), strconv.FormatFloat(size, 'f', 10, 64)}
+}
+
+func sortDepth(depth [][]string) {
+	sort.Slice(depth, func(i, j int) bool {
+		cmpA, _ := strconv.ParseFloat(depth[i][0], 64)
+		cmpB, _ := strconv.ParseFloat(depth[j][0], 64)
+		return cmpA > cmpB
+	})
+}
+
+//emitDeltas emits the changes of all books, it is called with mtx held to keep the order of sequence
+func (ob *OrderBook) emitDeltas() {
+	for _, book := range ob.books {
+		if evt, ok := book.flush(); ok {
+			eventemitter.Emit(eventemitter.DepthDelta, evt)
+		}
+	}
+}
+
+func (ob *OrderBook) handleNewOrder(input eventemitter.EventData) error {
+	state := input.(*types.OrderState)
+
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+
+	ob.addOrder(state, time.Now().Unix())
+	ob.emitDeltas()
+	return nil
+}
+
+func (ob *OrderBook) handleOrderFilled(input eventemitter.EventData) error {
+	evt := input.(*types.OrderFilledEvent)
+	if evt.Status != types.TX_STATUS_SUCCESS {
+		return nil
+	}
+
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+
+	o, ok := ob.orders[evt.OrderHash]
+	if !ok {
+		return nil
+	}
+	eventKey := evt.TxHash.Hex() + "_" + evt.FillIndex.String()
+	if o.events[eventKey] {
+		return nil
+	}
+	o.events[eventKey] = true
+
+	o.state.DealtAmountS = new(big.Int).Add(o.state.DealtAmountS, evt.AmountS)
+	o.state.DealtAmountB = new(big.Int).Add(o.state.DealtAmountB, evt.AmountB)
+	o.state.SplitAmountS = new(big.Int).Add(o.state.SplitAmountS, evt.SplitS)
+	o.state.SplitAmountB = new(big.Int).Add(o.state.SplitAmountB, evt.SplitB)
+	ob.settleOrder(o)
+	ob.emitDeltas()
+	return nil
+}
+
+func (ob *OrderBook) handleOrderCancelled(input eventemitter.EventData) error {
+	evt := input.(*types.OrderCancelledEvent)
+	if evt.Status != types.TX_STATUS_SUCCESS {
+		return nil
+	}
+
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+
+	o, ok := ob.orders[evt.OrderHash]
+	if !ok {
+		return nil
+	}
+	eventKey := evt.TxHash.Hex()
+	if o.events[eventKey] {
+		return nil
+	}
+	o.events[eventKey] = true
+
+	if o.state.RawOrder.BuyNoMoreThanAmountB {
+		o.state.CancelledAmountB = new(big.Int).Add(o.state.CancelledAmountB, evt.AmountCancelled)
+	} else {
+		o.state.CancelledAmountS = new(big.Int).Add(o.state.CancelledAmountS, evt.AmountCancelled)
+	}
+	ob.settleOrder(o)
+	ob.emitDeltas()
+	return nil
+}
+
+func (ob *OrderBook) settleOrder(o *bookOrder) {
+	if ob.source.IsOrderFullFinished(&o.state) {
+		ob.removeOrder(o.state.RawOrder.Hash)
+	} else {
+		ob.updateOrder(o, time.Now().Unix())
+	}
+}
+
+//handleCutoff removes the orders like ordermanager.GetCutoffOrders
+func (ob *OrderBook) handleCutoff(input eventemitter.EventData) error {
+	evt := input.(*types.CutoffEvent)
+	if evt.Status != types.TX_STATUS_SUCCESS {
+		return nil
+	}
+
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+
+	for hash, o := range ob.owners[evt.Owner] {
+		if o.state.RawOrder.ValidSince.Cmp(evt.Cutoff) < 0 {
+			ob.removeOrder(hash)
+		}
+	}
+	ob.emitDeltas()
+	return nil
+}
+
+func (ob *OrderBook) handleCutoffPair(input eventemitter.EventData) error {
+	evt := input.(*types.CutoffPairEvent)
+	if evt.Status != types.TX_STATUS_SUCCESS {
+		return nil
+	}
+
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+
+	for hash, o := range ob.owners[evt.Owner] {
+		ord := o.state.RawOrder
+		if ord.ValidSince.Cmp(evt.Cutoff) < 0 &&
+			(ord.TokenS == evt.Token1 && ord.TokenB == evt.Token2 || ord.TokenS == evt.Token2 && ord.TokenB == evt.Token1) {
+			ob.removeOrder(hash)
+		}
+	}
+	ob.emitDeltas()
+	return nil
+}
+
+func (ob *OrderBook) handleBalanceUpdated(input eventemitter.EventData) error {
+	evt := input.(types.BalanceUpdateEvent)
+
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+
+	ob.dirtyOwners[common.HexToAddress(evt.Owner)] = true
+	return nil
+}
+
+//handleBlockNew recalculates the orders of owners whose balance changed in previous block,
+//the balances have been synced into cache when the Block_End emitting returned
+func (ob *OrderBook) handleBlockNew(input eventemitter.EventData) error {
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+
+	now := time.Now().Unix()
+	for owner := range ob.dirtyOwners {
+		for _, o := range ob.owners[owner] {
+			ob.updateOrder(o, now)
+		}
+	}
+	ob.dirtyOwners = make(map[common.Address]bool)
+	ob.emitDeltas()
+	return nil
+}
+
+func (ob *OrderBook) handleFork(input eventemitter.EventData) error {
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+
+	ob.forked = true
+	return nil
+}
+
+//refresh removes expired orders and brings in orders becoming valid, the books are reloaded after chain forked
+func (ob *OrderBook) refresh(now int64) {
+	ob.mtx.Lock()
+	defer ob.mtx.Unlock()
+
+	if ob.forked {
+		ob.forked = false
+		delegates := []common.Address{}
+		seen := make(map[common.Address]bool)
+		for _, book := range ob.books {
+			if !seen[book.delegate] {
+				seen[book.delegate] = true
+				delegates = append(delegates, book.delegate)
+			}
+		}
+		ob.loadBooks(delegates)
+	} else {
+		for hash, o := range ob.orders {
+			ord := o.state.RawOrder
+			if ord.ValidUntil.Int64() < now {
+				ob.removeOrder(hash)
+			} else if "" == o.level && ord.ValidSince.Int64() < now {
+				ob.updateOrder(o, now)
+			}
+		}
+	}
+	ob.emitDeltas()
+}
+
+//orderDepth returns the price level and the amounts that the order contributes to depth,
+//the amount is limited by the balance and allowance of owner
+func orderDepth(balances balanceSource, s *types.OrderState, isAsk bool, tokenSDecimal, tokenBDecimal *big.Int) (string, *big.Rat, *big.Rat, error) {
+	amountS, amountB := s.RemainedAmount()
+	amountS = amountS.Quo(amountS, new(big.Rat).SetFrac(tokenSDecimal, big.NewInt(1)))
+	amountB = amountB.Quo(amountB, new(big.Rat).SetFrac(tokenBDecimal, big.NewInt(1)))
+
+	if amountS.Cmp(new(big.Rat).SetFloat64(0)) == 0 {
+		return "", nil, nil, errors.New("amount s is zero, skipped")
+	}
+
+	if amountB.Cmp(new(big.Rat).SetFloat64(0)) == 0 {
+		return "", nil, nil, errors.New("amount b is zero, skipped")
+	}
+
+	minAmountB := amountB
+	minAmountS, err := availableMinAmount(balances, amountS, s.RawOrder.Owner, s.RawOrder.TokenS, s.RawOrder.DelegateAddress, tokenSDecimal)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	sellPrice := new(big.Rat).SetFrac(s.RawOrder.AmountS, s.RawOrder.AmountB)
+	buyPrice := new(big.Rat).SetFrac(s.RawOrder.AmountB, s.RawOrder.AmountS)
+	if s.RawOrder.BuyNoMoreThanAmountB {
+		limitedAmountS := new(big.Rat).Mul(minAmountB, sellPrice)
+		if limitedAmountS.Cmp(minAmountS) < 0 {
+			minAmountS = limitedAmountS
+		}
+		minAmountB = new(big.Rat).Mul(minAmountS, buyPrice)
+	} else {
+		limitedAmountB := new(big.Rat).Mul(minAmountS, buyPrice)
+		if limitedAmountB.Cmp(minAmountB) < 0 {
+			minAmountB = limitedAmountB
+		}
+		minAmountS = new(big.Rat).Mul(minAmountB, sellPrice)
+	}
+
+	price := new(big.Rat).Set(s.RawOrder.Price)
+	if isAsk {
+		return price.Inv(price).FloatString(10), minAmountS, minAmountB, nil
+	}
+	return price.FloatString(10), minAmountB, minAmountS, nil
+}
+
+func availableMinAmount(balances balanceSource, depthAmount *big.Rat, owner, token, spender common.Address, decimal *big.Int) (amount *big.Rat, err error) {
+
+	amount = depthAmount
+
+	balance, allowance, err := balances.GetBalanceAndAllowance(owner, token, spender)
+	if err != nil {
+		return
+	}
+
+	balanceRat := new(big.Rat).SetFrac(balance, decimal)
+	allowanceRat := new(big.Rat).SetFrac(allowance, decimal)
+
+	if amount.Cmp(balanceRat) > 0 {
+		amount = balanceRat
+	}
+
+	if amount.Cmp(allowanceRat) > 0 {
+		amount = allowanceRat
+	}
+
+	if amount.Cmp(new(big.Rat).SetFloat64(1e-8)) < 0 {
+		return nil, errors.New("amount is zero, skipped")
+	}
+
+	return
+}
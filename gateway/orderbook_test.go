@@ -0,0 +1,281 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/eventemiter"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/market/util"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeOrderBookSource struct {
+	orders []types.OrderState
+}
+
+//GetActiveOrderBook takes the index of order plus one as its id
+func (s *fakeOrderBookSource) GetActiveOrderBook(protocol, tokenS, tokenB common.Address, fromId, limit int) ([]types.OrderState, int, error) {
+	list := []types.OrderState{}
+	lastId := fromId
+	for i := fromId; i < len(s.orders) && len(list) < limit; i++ {
+		state := s.orders[i]
+		lastId = i + 1
+		if state.RawOrder.TokenS == tokenS && state.RawOrder.TokenB == tokenB && state.RawOrder.ValidUntil.Int64() >= time.Now().Unix() {
+			list = append(list, state)
+		}
+	}
+	return list, lastId, nil
+}
+
+func (s *fakeOrderBookSource) IsOrderFullFinished(state *types.OrderState) bool {
+	amountS, _ := state.RemainedAmount()
+	return amountS.Sign() <= 0
+}
+
+type fakeBalances struct {
+	balances map[common.Address]*big.Int
+}
+
+func (b *fakeBalances) GetBalanceAndAllowance(owner, token, spender common.Address) (*big.Int, *big.Int, error) {
+	if balance, ok := b.balances[owner]; ok {
+		return balance, balance, nil
+	}
+	return ether(1000000), ether(1000000), nil
+}
+
+func ether(amount float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(amount), big.NewFloat(1e18)).Int(nil)
+	return wei
+}
+
+var (
+	bookDelegate = common.HexToAddress("0x17233e07c67d086464fd408148c3abb56245fa64")
+	bookLrc      = common.HexToAddress("0xEF68e7C694F40c8202821eDF525dE3782458639f")
+	bookWeth     = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	bookOwner1   = common.HexToAddress("0xb1018949b241d76a1ab2094f473e9befeabb5ead")
+	bookOwner2   = common.HexToAddress("0x1b978a1d302335a6f2ebe4b8823b5e17c3c84135")
+)
+
+func bookOrderState(hash string, owner, tokenS, tokenB common.Address, amountS, amountB float64) *types.OrderState {
+	state := &types.OrderState{}
+	state.RawOrder.Hash = common.HexToHash(hash)
+	state.RawOrder.DelegateAddress = bookDelegate
+	state.RawOrder.Owner = owner
+	state.RawOrder.TokenS = tokenS
+	state.RawOrder.TokenB = tokenB
+	state.RawOrder.AmountS = ether(amountS)
+	state.RawOrder.AmountB = ether(amountB)
+	state.RawOrder.Price = new(big.Rat).SetFrac(state.RawOrder.AmountS, state.RawOrder.AmountB)
+	state.RawOrder.ValidSince = big.NewInt(time.Now().Unix() - 100)
+	state.RawOrder.ValidUntil = big.NewInt(time.Now().Unix() + 3600)
+	state.RawOrder.OrderType = types.ORDER_TYPE_MARKET
+	return state
+}
+
+type deltaCollector struct {
+	deltas []*types.DepthDeltaEvent
+	mtx    sync.Mutex
+}
+
+func (c *deltaCollector) handle(input eventemitter.EventData) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.deltas = append(c.deltas, input.(*types.DepthDeltaEvent))
+	return nil
+}
+
+func (c *deltaCollector) take() []*types.DepthDeltaEvent {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	deltas := c.deltas
+	c.deltas = nil
+	return deltas
+}
+
+//applyDeltas replays deltas on the snapshot as clients do
+func applyDeltas(t *testing.T, snapshot Depth, deltas []*types.DepthDeltaEvent) Depth {
+	levels := [2]map[string][]string{make(map[string][]string), make(map[string][]string)}
+	for side, list := range [][][]string{snapshot.Depth.Buy, snapshot.Depth.Sell} {
+		for _, level := range list {
+			levels[side][level[0]] = level
+		}
+	}
+	sequence := snapshot.Sequence
+	for _, delta := range deltas {
+		if delta.Sequence != sequence+1 {
+			t.Fatalf("sequence:%d, expect:%d", delta.Sequence, sequence+1)
+		}
+		sequence = delta.Sequence
+		for side, list := range [][][]string{delta.Buy, delta.Sell} {
+			for _, level := range list {
+				if amount, _ := new(big.Rat).SetString(level[1]); 0 == amount.Sign() {
+					delete(levels[side], level[0])
+				} else {
+					levels[side][level[0]] = level
+				}
+			}
+		}
+	}
+
+	depth := Depth{DelegateAddress: snapshot.DelegateAddress, Market: snapshot.Market, Sequence: sequence}
+	depth.Depth.Buy = [][]string{}
+	depth.Depth.Sell = [][]string{}
+	for _, level := range levels[depthSideBuy] {
+		depth.Depth.Buy = append(depth.Depth.Buy, level)
+	}
+	for _, level := range levels[depthSideSell] {
+		depth.Depth.Sell = append(depth.Depth.Sell, level)
+	}
+	sortDepth(depth.Depth.Buy)
+	sortDepth(depth.Depth.Sell)
+	return depth
+}
+
+func TestOrderBook(t *testing.T) {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+	util.Initialize(config.MarketOptions{TokenFile: "../config/tokens.json"})
+	mkt, err := util.WrapMarket("LRC", "WETH")
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+
+	collector := &deltaCollector{}
+	watcher := &eventemitter.Watcher{Concurrent: false, Handle: collector.handle}
+	eventemitter.On(eventemitter.DepthDelta, watcher)
+	defer eventemitter.Un(eventemitter.DepthDelta, watcher)
+
+	source := &fakeOrderBookSource{}
+	source.orders = append(source.orders, *bookOrderState("0x01", bookOwner1, bookLrc, bookWeth, 1000, 1))
+	balances := &fakeBalances{balances: make(map[common.Address]*big.Int)}
+	ob := NewOrderBook(source, balances)
+	ob.mtx.Lock()
+	ob.loadBooks([]common.Address{bookDelegate})
+	ob.emitDeltas()
+	ob.mtx.Unlock()
+	collector.take()
+
+	snapshot, ok := ob.Depth(bookDelegate, mkt, 50)
+	if !ok {
+		t.Fatalf("book of %s not loaded", mkt)
+	}
+	expect := [][]string{{"0.0010000000", "1000.0000000000", "1.0000000000"}}
+	if snapshot.Sequence != 1 || !reflect.DeepEqual(snapshot.Depth.Sell, expect) || 0 != len(snapshot.Depth.Buy) {
+		t.Fatalf("snapshot:%+v", snapshot)
+	}
+
+	//new bid and another ask at the same price
+	ob.handleNewOrder(bookOrderState("0x02", bookOwner2, bookWeth, bookLrc, 0.5, 1000))
+	ob.handleNewOrder(bookOrderState("0x03", bookOwner2, bookLrc, bookWeth, 500, 0.5))
+	depth, _ := ob.Depth(bookDelegate, mkt, 50)
+	if !reflect.DeepEqual(depth.Depth.Sell, [][]string{{"0.0010000000", "1500.0000000000", "1.5000000000"}}) ||
+		!reflect.DeepEqual(depth.Depth.Buy, [][]string{{"0.0005000000", "1000.0000000000", "0.5000000000"}}) {
+		t.Fatalf("depth:%+v", depth)
+	}
+
+	//fill half of 0x01, the same fill is applied once
+	fill := &types.OrderFilledEvent{OrderHash: common.HexToHash("0x01"), AmountS: ether(500), AmountB: ether(0.5), SplitS: big.NewInt(0), SplitB: big.NewInt(0), FillIndex: big.NewInt(0)}
+	fill.Status = types.TX_STATUS_SUCCESS
+	fill.TxHash = common.HexToHash("0xf1")
+	ob.handleOrderFilled(fill)
+	ob.handleOrderFilled(fill)
+
+	//cancel all of 0x03
+	cancel := &types.OrderCancelledEvent{OrderHash: common.HexToHash("0x03"), AmountCancelled: ether(500)}
+	cancel.Status = types.TX_STATUS_SUCCESS
+	cancel.TxHash = common.HexToHash("0xc1")
+	ob.handleOrderCancelled(cancel)
+	depth, _ = ob.Depth(bookDelegate, mkt, 50)
+	if !reflect.DeepEqual(depth.Depth.Sell, [][]string{{"0.0010000000", "500.0000000000", "0.5000000000"}}) {
+		t.Fatalf("depth:%+v", depth)
+	}
+	if _, exists := ob.orders[common.HexToHash("0x03")]; exists {
+		t.Fatalf("finished order should be removed")
+	}
+
+	//balance changes take effect in next block
+	balances.balances[bookOwner1] = ether(100)
+	ob.handleBalanceUpdated(types.BalanceUpdateEvent{Owner: bookOwner1.Hex()})
+	if depth, _ = ob.Depth(bookDelegate, mkt, 50); depth.Depth.Sell[0][1] != "500.0000000000" {
+		t.Fatalf("depth:%+v", depth)
+	}
+	ob.handleBlockNew(&types.BlockEvent{})
+	if depth, _ = ob.Depth(bookDelegate, mkt, 50); depth.Depth.Sell[0][1] != "100.0000000000" {
+		t.Fatalf("depth:%+v", depth)
+	}
+
+	//cutoff removes the bid
+	cutoff := &types.CutoffEvent{Owner: bookOwner2, Cutoff: big.NewInt(time.Now().Unix())}
+	cutoff.Status = types.TX_STATUS_SUCCESS
+	ob.handleCutoff(cutoff)
+
+	final, _ := ob.Depth(bookDelegate, mkt, 50)
+	if 0 != len(final.Depth.Buy) || 1 != len(final.Depth.Sell) {
+		t.Fatalf("depth:%+v", final)
+	}
+	replayed := applyDeltas(t, snapshot, collector.take())
+	if !reflect.DeepEqual(replayed, final) {
+		t.Fatalf("replayed:%+v, expect:%+v", replayed, final)
+	}
+}
+
+func TestOrderBook_LoadBooks(t *testing.T) {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+	util.Initialize(config.MarketOptions{TokenFile: "../config/tokens.json"})
+	mkt, err := util.WrapMarket("LRC", "WETH")
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+
+	source := &fakeOrderBookSource{}
+	source.orders = append(source.orders, *bookOrderState("0x01", bookOwner1, bookLrc, bookWeth, 1000, 1))
+	source.orders = append(source.orders, *bookOrderState("0x02", bookOwner1, bookLrc, bookWeth, 1000, 2))
+	source.orders = append(source.orders, *bookOrderState("0x03", bookOwner2, bookWeth, bookLrc, 0.5, 1000))
+	future := bookOrderState("0x04", bookOwner2, bookLrc, bookWeth, 1000, 4)
+	future.RawOrder.ValidSince = big.NewInt(time.Now().Unix() + 60)
+	source.orders = append(source.orders, *future)
+
+	ob := NewOrderBook(source, &fakeBalances{balances: make(map[common.Address]*big.Int)})
+	ob.pageSize = 1
+	ob.mtx.Lock()
+	ob.loadBooks([]common.Address{bookDelegate})
+	ob.mtx.Unlock()
+
+	if 4 != len(ob.orders) {
+		t.Fatalf("loaded %d orders, expect:4", len(ob.orders))
+	}
+	depth, _ := ob.Depth(bookDelegate, mkt, 50)
+	if 2 != len(depth.Depth.Sell) || 1 != len(depth.Depth.Buy) {
+		t.Fatalf("depth:%+v", depth)
+	}
+
+	//the order becomes valid in refresh
+	ob.refresh(time.Now().Unix() + 61)
+	depth, _ = ob.Depth(bookDelegate, mkt, 50)
+	if 3 != len(depth.Depth.Sell) || depth.Depth.Sell[0][0] != "0.0040000000" {
+		t.Fatalf("depth:%+v", depth)
+	}
+}
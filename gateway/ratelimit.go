@@ -0,0 +1,233 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/Loopring/relay/cache"
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/log"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+限流
+1、每个客户端一个令牌桶，带有已配置的api key(请求头 X-Api-Key 或 socketio 连接参数 apiKey)时按key计算，否则按ip计算，
+   未配置的api key按ip计算；桶的容量和每秒补充的令牌数分别配置，容量为0表示不限制
+2、每次调用消耗的令牌数由 method_costs 按 WalletServiceImpl 的方法名配置，例如 GetDepth，未配置的为1；
+   jsonrpc 的 loopring_getDepth 对应 GetDepth，socketio 的 depth_req 对应 EventTypeRoute 中的 GetDepth，批量请求消耗各方法之和
+3、桶保存在cache中，由 cache.HMUpdate 原子地读写(redis 使用 watch/multi)，共用redis的relay共享同一份额度，不同客户端之间不会互相等待；
+   cache出错时不限流
+4、trust_proxy 时客户端ip取 X-Forwarded-For 从右数第 proxy_hops 个地址，即最外层可信代理追加的地址，左边的地址由客户端填写，不可信
+5、jsonrpc 和 rest 的应答带有 X-RateLimit-Limit、X-RateLimit-Remaining、X-RateLimit-Reset(桶恢复满的秒数)，
+   超出限额时返回 RateLimitErrorCode 的jsonrpc错误(rest 为 RestCodeRateLimited)和 Retry-After；socketio 在对应的 _res 事件中返回错误
+*/
+
+const (
+	rateLimitPreKey = "RATE_LIMIT_"
+
+	RateLimitErrorCode    = -32005
+	RateLimitErrorMessage = "rate limit exceeded"
+
+	apiKeyHeader       = "X-Api-Key"
+	apiKeyQuery        = "apiKey"
+	maxJsonrpcBodySize = 1024 * 1024
+)
+
+type RateLimiter struct {
+	options config.RateLimitOptions
+	apiKeys map[string]bool
+	now     func() time.Time
+}
+
+type Quota struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	Reset      int64 //seconds until the bucket is full
+	RetryAfter int64 //seconds until the cost is available
+}
+
+func NewRateLimiter(options config.RateLimitOptions) *RateLimiter {
+	l := &RateLimiter{options: options, now: time.Now}
+	l.apiKeys = make(map[string]bool)
+	for _, key := range options.ApiKeys {
+		l.apiKeys[key] = true
+	}
+	return l
+}
+
+//Cost returns the tokens consumed by the method of WalletServiceImpl
+func (l *RateLimiter) Cost(method string) int64 {
+	if cost, ok := l.options.MethodCosts[method]; ok {
+		return cost
+	}
+	return 1
+}
+
+//Take consumes cost tokens from the bucket of the client
+func (l *RateLimiter) Take(apiKey, ip string, cost int64) Quota {
+	key := rateLimitPreKey + "IP_" + ip
+	capacity, rate := l.options.IpCapacity, l.options.IpRefillRate
+	if l.apiKeys[apiKey] {
+		key = rateLimitPreKey + "KEY_" + apiKey
+		capacity, rate = l.options.ApiKeyCapacity, l.options.ApiKeyRefillRate
+	}
+	if capacity <= 0 || rate <= 0 {
+		return Quota{Allowed: true}
+	}
+
+	//the bucket is full after ttl, it is the same as a new one
+	ttl := int64(math.Ceil(float64(capacity)/rate)) + 1
+	var quota Quota
+	err := cache.HMUpdate(key, ttl, [][]byte{[]byte("tokens"), []byte("updatedAt")}, func(values [][]byte) [][]byte {
+		now := float64(l.now().UnixNano()) / 1e9
+		tokens := float64(capacity)
+		if len(values) == 2 && len(values[0]) > 0 && len(values[1]) > 0 {
+			lastTokens, _ := strconv.ParseFloat(string(values[0]), 64)
+			updatedAt, _ := strconv.ParseFloat(string(values[1]), 64)
+			tokens = math.Min(float64(capacity), lastTokens+math.Max(0, now-updatedAt)*rate)
+		}
+
+		quota = Quota{Limit: capacity}
+		if tokens >= float64(cost) {
+			tokens -= float64(cost)
+			quota.Allowed = true
+		} else {
+			quota.RetryAfter = int64(math.Ceil((float64(cost) - tokens) / rate))
+		}
+		quota.Remaining = int64(math.Floor(tokens))
+		quota.Reset = int64(math.Ceil((float64(capacity) - tokens) / rate))
+		return [][]byte{[]byte("tokens"), []byte(strconv.FormatFloat(tokens, 'f', -1, 64)), []byte("updatedAt"), []byte(strconv.FormatFloat(now, 'f', -1, 64))}
+	})
+	if nil != err {
+		log.Errorf("rate limit update bucket:%s error:%s", key, err.Error())
+		return Quota{Allowed: true}
+	}
+	return quota
+}
+
+//clientIp returns the address appended by the outermost trusted proxy to X-Forwarded-For,
+//the addresses on the left of it are sent by client and can not be trusted
+func (l *RateLimiter) clientIp(remoteAddr string, header http.Header) string {
+	if l.options.TrustProxy {
+		if forwarded := header.Get("X-Forwarded-For"); "" != forwarded {
+			hops := l.options.ProxyHops
+			if hops <= 0 {
+				hops = 1
+			}
+			addrs := strings.Split(forwarded, ",")
+			if len(addrs) >= hops {
+				return strings.TrimSpace(addrs[len(addrs)-hops])
+			}
+		}
+	}
+	if host, _, err := net.SplitHostPort(remoteAddr); nil == err {
+		return host
+	}
+	return remoteAddr
+}
+
+type jsonrpcMessage struct {
+	Version string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Method  string          `json:"method,omitempty"`
+	Error   *jsonrpcError   `json:"error,omitempty"`
+}
+
+type jsonrpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+//walletMethodName converts loopring_getDepth to GetDepth
+func walletMethodName(jsonrpcMethod string) string {
+	name := strings.TrimPrefix(jsonrpcMethod, "loopring_")
+	if "" == name {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+//newRateLimitHandler charges the cost of methods in the request before it is served by next
+func newRateLimitHandler(limiter *RateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if nil == limiter || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxJsonrpcBodySize))
+		if nil != err {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		//the malformed requests are left to the rpc server
+		var msgs []jsonrpcMessage
+		isBatch := len(bytes.TrimSpace(body)) > 0 && bytes.TrimSpace(body)[0] == '['
+		if isBatch {
+			json.Unmarshal(body, &msgs)
+		} else {
+			msg := jsonrpcMessage{}
+			json.Unmarshal(body, &msg)
+			msgs = append(msgs, msg)
+		}
+		cost := int64(0)
+		for _, msg := range msgs {
+			cost += limiter.Cost(walletMethodName(msg.Method))
+		}
+
+		quota := limiter.Take(r.Header.Get(apiKeyHeader), limiter.clientIp(r.RemoteAddr, r.Header), cost)
+		setQuotaHeaders(w, quota)
+		if quota.Allowed {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		resps := make([]jsonrpcMessage, 0, len(msgs))
+		for _, msg := range msgs {
+			resps = append(resps, jsonrpcMessage{Version: "2.0", Id: msg.Id, Error: &jsonrpcError{Code: RateLimitErrorCode, Message: RateLimitErrorMessage}})
+		}
+		if isBatch {
+			json.NewEncoder(w).Encode(resps)
+		} else {
+			json.NewEncoder(w).Encode(resps[0])
+		}
+	})
+}
+
+func setQuotaHeaders(w http.ResponseWriter, quota Quota) {
+	if quota.Limit > 0 {
+		w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(quota.Limit, 10))
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(quota.Remaining, 10))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(quota.Reset, 10))
+	}
+	if !quota.Allowed {
+		w.Header().Set("Retry-After", strconv.FormatInt(quota.RetryAfter, 10))
+	}
+}
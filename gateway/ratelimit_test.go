@@ -0,0 +1,185 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"encoding/json"
+	"github.com/Loopring/relay/cache"
+	"github.com/Loopring/relay/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+	mtx sync.Mutex
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestRateLimiter() (*RateLimiter, *fakeClock) {
+	cache.NewCache(config.RedisOptions{Engine: "memory"})
+	limiter := NewRateLimiter(config.RateLimitOptions{
+		IpCapacity:       10,
+		IpRefillRate:     1,
+		ApiKeyCapacity:   100,
+		ApiKeyRefillRate: 10,
+		ApiKeys:          []string{"key1"},
+		MethodCosts:      map[string]int64{"GetDepth": 5},
+	})
+	clock := &fakeClock{now: time.Now()}
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+func TestRateLimiter_Take(t *testing.T) {
+	limiter, clock := newTestRateLimiter()
+
+	if cost := limiter.Cost(walletMethodName("loopring_getDepth")); cost != 5 {
+		t.Fatalf("cost of GetDepth should be 5, got:%d", cost)
+	}
+	if cost := limiter.Cost(walletMethodName("loopring_getTicker")); cost != 1 {
+		t.Fatalf("cost of GetTicker should be 1, got:%d", cost)
+	}
+
+	if quota := limiter.Take("", "1.1.1.1", 5); !quota.Allowed || quota.Remaining != 5 || quota.Limit != 10 {
+		t.Fatalf("the first request should be allowed, got:%+v", quota)
+	}
+	if quota := limiter.Take("", "1.1.1.1", 5); !quota.Allowed || quota.Remaining != 0 || quota.Reset != 10 {
+		t.Fatalf("the second request should be allowed, got:%+v", quota)
+	}
+	if quota := limiter.Take("", "1.1.1.1", 1); quota.Allowed || quota.RetryAfter != 1 {
+		t.Fatalf("the bucket should be empty, got:%+v", quota)
+	}
+	if quota := limiter.Take("", "2.2.2.2", 1); !quota.Allowed {
+		t.Fatalf("other ip should not be limited")
+	}
+	if quota := limiter.Take("key1", "1.1.1.1", 50); !quota.Allowed || quota.Limit != 100 {
+		t.Fatalf("api key should have its own bucket, got:%+v", quota)
+	}
+	if quota := limiter.Take("unknown", "1.1.1.1", 1); quota.Allowed {
+		t.Fatalf("unknown api key should be limited by ip")
+	}
+
+	clock.Add(3 * time.Second)
+	if quota := limiter.Take("", "1.1.1.1", 3); !quota.Allowed || quota.Remaining != 0 {
+		t.Fatalf("3 tokens should be refilled, got:%+v", quota)
+	}
+	clock.Add(time.Hour)
+	if quota := limiter.Take("", "1.1.1.1", 1); !quota.Allowed || quota.Remaining != 9 {
+		t.Fatalf("bucket should not be refilled over capacity, got:%+v", quota)
+	}
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	limiter, _ := newTestRateLimiter()
+	served := 0
+	server := httptest.NewServer(newRateLimitHandler(limiter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"ok"}`))
+	})))
+	defer server.Close()
+
+	post := func(body string) *http.Response {
+		resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+		if nil != err {
+			t.Fatalf("err:%s", err.Error())
+		}
+		return resp
+	}
+
+	resp := post(`[{"jsonrpc":"2.0","id":1,"method":"loopring_getDepth","params":[]},{"jsonrpc":"2.0","id":2,"method":"loopring_getTicker","params":[]}]`)
+	resp.Body.Close()
+	if served != 1 || resp.Header.Get("X-RateLimit-Limit") != "10" || resp.Header.Get("X-RateLimit-Remaining") != "4" {
+		t.Fatalf("batch should cost 6, got remaining:%s", resp.Header.Get("X-RateLimit-Remaining"))
+	}
+
+	resp = post(`{"jsonrpc":"2.0","id":3,"method":"loopring_getDepth","params":[]}`)
+	defer resp.Body.Close()
+	if served != 1 || resp.Header.Get("Retry-After") != "1" {
+		t.Fatalf("request should be throttled, served:%d, retry after:%s", served, resp.Header.Get("Retry-After"))
+	}
+	msg := jsonrpcMessage{}
+	if err := json.NewDecoder(resp.Body).Decode(&msg); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if nil == msg.Error || msg.Error.Code != RateLimitErrorCode || string(msg.Id) != "3" {
+		t.Fatalf("throttled request should get jsonrpc error with its id, got:%+v", msg)
+	}
+}
+
+func TestRateLimiter_ClientIp(t *testing.T) {
+	limiter := NewRateLimiter(config.RateLimitOptions{})
+	header := http.Header{}
+	header.Set("X-Forwarded-For", "9.9.9.9, 1.1.1.1, 2.2.2.2")
+	if ip := limiter.clientIp("3.3.3.3:1234", header); ip != "3.3.3.3" {
+		t.Fatalf("X-Forwarded-For should be ignored without trusted proxy, got:%s", ip)
+	}
+
+	//the left addresses are sent by client
+	limiter = NewRateLimiter(config.RateLimitOptions{TrustProxy: true})
+	if ip := limiter.clientIp("3.3.3.3:1234", header); ip != "2.2.2.2" {
+		t.Fatalf("ip should be appended by the proxy, got:%s", ip)
+	}
+	limiter = NewRateLimiter(config.RateLimitOptions{TrustProxy: true, ProxyHops: 2})
+	if ip := limiter.clientIp("3.3.3.3:1234", header); ip != "1.1.1.1" {
+		t.Fatalf("ip should be appended by the outermost proxy, got:%s", ip)
+	}
+	limiter = NewRateLimiter(config.RateLimitOptions{TrustProxy: true, ProxyHops: 4})
+	if ip := limiter.clientIp("3.3.3.3:1234", header); ip != "3.3.3.3" {
+		t.Fatalf("request not passed all proxies should use remote address, got:%s", ip)
+	}
+}
+
+func TestRateLimiter_TakeConcurrently(t *testing.T) {
+	limiter, _ := newTestRateLimiter()
+
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if quota := limiter.Take("", "4.4.4.4", 1); quota.Allowed {
+				mtx.Lock()
+				allowed++
+				mtx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 10 {
+		t.Fatalf("only the capacity should be allowed, got:%d", allowed)
+	}
+}
@@ -0,0 +1,381 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"encoding/json"
+	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/market"
+	"github.com/Loopring/relay/market/util"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/**
+REST 接口，与jsonrpc使用同样的 WalletServiceImpl 方法
+1、GET  /v2/markets/{market}/depth?delegateAddress=            GetDepth
+   GET  /v2/orders/{hash}                                       GetOrderByHash
+   POST /v2/orders                                              SubmitOrder，body与 loopring_submitOrder 的参数相同
+   GET  /v2/owners/{owner}/balances?delegateAddress=            GetBalance，需要请求头 Authorization: Bearer {token}，token由 CreateSession 取得
+   GET  /v2/fills?delegateAddress=&market=&owner=&...           GetFills
+   GET  /v2/trends?market=&interval=                            GetTrend
+   GET  /v2/openapi.json                                        由 restRoutes 生成的 OpenAPI 文档
+2、成功时返回 {"data": ...}，失败时返回 {"error": {"code": "40001", "message": "..."}}，code的前三位是http状态码
+3、与jsonrpc共用限流，每次请求消耗的令牌数按对应的 WalletServiceImpl 方法名计算
+*/
+
+const (
+	RestCodeInvalidParams    = "40001"
+	RestCodeUnauthorized     = "40101"
+	RestCodeNotFound         = "40401"
+	RestCodeMethodNotAllowed = "40501"
+	RestCodeRateLimited      = "42901"
+	RestCodeInternal         = "50001"
+
+	restPathPrefix = "/v2"
+)
+
+var restCodeStatus = map[string]int{
+	RestCodeInvalidParams:    http.StatusBadRequest,
+	RestCodeUnauthorized:     http.StatusUnauthorized,
+	RestCodeNotFound:         http.StatusNotFound,
+	RestCodeMethodNotAllowed: http.StatusMethodNotAllowed,
+	RestCodeRateLimited:      http.StatusTooManyRequests,
+	RestCodeInternal:         http.StatusInternalServerError,
+}
+
+type RestError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type RestResponse struct {
+	Data  interface{} `json:"data,omitempty"`
+	Error *RestError  `json:"error,omitempty"`
+}
+
+func newRestError(code string, message string) *RestError {
+	return &RestError{Code: code, Message: message}
+}
+
+//restBackend is implemented by WalletServiceImpl
+type restBackend interface {
+	GetDepth(query DepthQuery) (Depth, error)
+	GetOrderByHash(query OrderQuery) (OrderJsonResult, error)
+	SubmitOrder(order *types.OrderJsonRequest) (string, error)
+	GetBalance(query CommonTokenRequest) (AccountJson, error)
+	GetFills(query FillQuery) (dao.PageResult, error)
+	GetTrend(query TrendQuery) ([]market.Trend, error)
+}
+
+type restParam struct {
+	Name        string
+	In          string //path or query
+	Type        string //string or integer
+	Required    bool
+	Description string
+}
+
+type restRoute struct {
+	Method       string
+	Path         string //relative to restPathPrefix, the segment in braces is a path param
+	WalletMethod string
+	Summary      string
+	Params       []restParam
+	Body         interface{} //sample of request body
+	Result       interface{} //sample of data in response
+	Status       int
+	Auth         bool //requires session token of the owner in path
+	handle       func(rs *RestServiceImpl, r *http.Request, params map[string]string) (interface{}, *RestError)
+}
+
+var delegateAddressParam = restParam{Name: "delegateAddress", In: "query", Type: "string", Required: true, Description: "address of delegate contract"}
+
+var restRoutes = []restRoute{
+	{
+		Method: http.MethodGet, Path: "/markets/{market}/depth", WalletMethod: "GetDepth", Summary: "depth of market",
+		Params: []restParam{{Name: "market", In: "path", Type: "string", Required: true, Description: "such as LRC-WETH"}, delegateAddressParam},
+		Result: Depth{}, Status: http.StatusOK, handle: (*RestServiceImpl).getDepth,
+	},
+	{
+		Method: http.MethodGet, Path: "/orders/{hash}", WalletMethod: "GetOrderByHash", Summary: "order by hash",
+		Params: []restParam{{Name: "hash", In: "path", Type: "string", Required: true, Description: "hash of order"}},
+		Result: OrderJsonResult{}, Status: http.StatusOK, handle: (*RestServiceImpl).getOrder,
+	},
+	{
+		Method: http.MethodPost, Path: "/orders", WalletMethod: "SubmitOrder", Summary: "submit a signed order, the hash of order is returned",
+		Body: types.OrderJsonRequest{}, Result: "", Status: http.StatusCreated, handle: (*RestServiceImpl).submitOrder,
+	},
+	{
+		Method: http.MethodGet, Path: "/owners/{owner}/balances", WalletMethod: "GetBalance", Summary: "balances and allowances of owner",
+		Params: []restParam{{Name: "owner", In: "path", Type: "string", Required: true, Description: "address of owner"}, delegateAddressParam},
+		Result: AccountJson{}, Status: http.StatusOK, Auth: true, handle: (*RestServiceImpl).getBalances,
+	},
+	{
+		Method: http.MethodGet, Path: "/fills", WalletMethod: "GetFills", Summary: "fills in pages",
+		Params: []restParam{
+			{Name: "delegateAddress", In: "query", Type: "string"},
+			{Name: "market", In: "query", Type: "string"},
+			{Name: "owner", In: "query", Type: "string"},
+			{Name: "orderHash", In: "query", Type: "string"},
+			{Name: "ringHash", In: "query", Type: "string"},
+			{Name: "side", In: "query", Type: "string", Description: "buy or sell"},
+			{Name: "orderType", In: "query", Type: "string"},
+			{Name: "pageIndex", In: "query", Type: "integer"},
+			{Name: "pageSize", In: "query", Type: "integer"},
+		},
+		Result: dao.PageResult{}, Status: http.StatusOK, handle: (*RestServiceImpl).getFills,
+	},
+	{
+		Method: http.MethodGet, Path: "/trends", WalletMethod: "GetTrend", Summary: "trends of market",
+		Params: []restParam{
+			{Name: "market", In: "query", Type: "string", Required: true, Description: "such as LRC-WETH"},
+			{Name: "interval", In: "query", Type: "string", Description: "such as 1Hr"},
+		},
+		Result: []market.Trend{}, Status: http.StatusOK, handle: (*RestServiceImpl).getTrends,
+	},
+}
+
+type RestServiceImpl struct {
+	port        string
+	backend     restBackend
+	sessions    *SessionManager
+	rateLimiter *RateLimiter
+	server      *http.Server
+	openAPI     []byte
+}
+
+func NewRestService(port string, walletService *WalletServiceImpl, rateLimiter *RateLimiter) *RestServiceImpl {
+	rs := &RestServiceImpl{}
+	rs.port = port
+	rs.backend = walletService
+	rs.sessions = walletService.sessions
+	rs.rateLimiter = rateLimiter
+	rs.server = &http.Server{Addr: ":" + port, Handler: newCorsHandler(rs, []string{"*"})}
+	rs.openAPI, _ = json.MarshalIndent(newOpenAPIDocument(restRoutes), "", "  ")
+	return rs
+}
+
+func (rs *RestServiceImpl) Start() {
+	if "" == rs.port {
+		return
+	}
+	log.Infof("rest serving at port:%s", rs.port)
+	if err := rs.server.ListenAndServe(); nil != err && err != http.ErrServerClosed {
+		log.Fatalf("rest listen error:%s", err.Error())
+	}
+}
+
+func (rs *RestServiceImpl) Stop() {
+	rs.server.Close()
+}
+
+func (rs *RestServiceImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && r.URL.Path == restPathPrefix+"/openapi.json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(rs.openAPI)
+		return
+	}
+
+	route, params, restErr := matchRestRoute(r.Method, r.URL.Path)
+	if nil != restErr {
+		writeRestResponse(w, 0, nil, restErr)
+		return
+	}
+	if nil != rs.rateLimiter {
+		quota := rs.rateLimiter.Take(r.Header.Get(apiKeyHeader), rs.rateLimiter.clientIp(r.RemoteAddr, r.Header), rs.rateLimiter.Cost(route.WalletMethod))
+		setQuotaHeaders(w, quota)
+		if !quota.Allowed {
+			writeRestResponse(w, 0, nil, newRestError(RestCodeRateLimited, RateLimitErrorMessage))
+			return
+		}
+	}
+	if route.Auth {
+		if restErr := rs.authorize(r, params["owner"]); nil != restErr {
+			writeRestResponse(w, 0, nil, restErr)
+			return
+		}
+	}
+	data, restErr := route.handle(rs, r, params)
+	writeRestResponse(w, route.Status, data, restErr)
+}
+
+func matchRestRoute(method, path string) (*restRoute, map[string]string, *RestError) {
+	if !strings.HasPrefix(path, restPathPrefix+"/") {
+		return nil, nil, newRestError(RestCodeNotFound, "path not found")
+	}
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(path, restPathPrefix), "/"), "/")
+	pathMatched := false
+	for i := range restRoutes {
+		route := &restRoutes[i]
+		params, ok := matchRestPath(route.Path, segments)
+		if !ok {
+			continue
+		}
+		pathMatched = true
+		if route.Method == method {
+			return route, params, nil
+		}
+	}
+	if pathMatched {
+		return nil, nil, newRestError(RestCodeMethodNotAllowed, "method not allowed")
+	}
+	return nil, nil, newRestError(RestCodeNotFound, "path not found")
+}
+
+func matchRestPath(template string, segments []string) (map[string]string, bool) {
+	templateSegments := strings.Split(strings.Trim(template, "/"), "/")
+	if len(templateSegments) != len(segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, s := range templateSegments {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			params[strings.Trim(s, "{}")] = segments[i]
+		} else if s != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func writeRestResponse(w http.ResponseWriter, status int, data interface{}, restErr *RestError) {
+	resp := RestResponse{Data: data}
+	if nil != restErr {
+		resp = RestResponse{Error: restErr}
+		status = restCodeStatus[restErr.Code]
+	}
+	if 0 == status {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (rs *RestServiceImpl) authorize(r *http.Request, owner string) *RestError {
+	if nil == rs.sessions {
+		return newRestError(RestCodeUnauthorized, "session is not supported")
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer"))
+	if err := rs.sessions.Authorize(owner, token); nil != err {
+		return newRestError(RestCodeUnauthorized, err.Error())
+	}
+	return nil
+}
+
+func (rs *RestServiceImpl) getDepth(r *http.Request, params map[string]string) (interface{}, *RestError) {
+	delegateAddress := r.URL.Query().Get("delegateAddress")
+	if !common.IsHexAddress(delegateAddress) {
+		return nil, newRestError(RestCodeInvalidParams, "invalid delegateAddress")
+	}
+	if _, err := util.WrapMarket(util.UnWrap(params["market"])); nil != err {
+		return nil, newRestError(RestCodeInvalidParams, "unsupported market")
+	}
+	depth, err := rs.backend.GetDepth(DepthQuery{DelegateAddress: delegateAddress, Market: params["market"]})
+	if nil != err {
+		return nil, newRestError(RestCodeInternal, err.Error())
+	}
+	return depth, nil
+}
+
+func (rs *RestServiceImpl) getOrder(r *http.Request, params map[string]string) (interface{}, *RestError) {
+	hash := params["hash"]
+	if !strings.HasPrefix(hash, "0x") || len(common.FromHex(hash)) != common.HashLength {
+		return nil, newRestError(RestCodeInvalidParams, "invalid hash")
+	}
+	order, err := rs.backend.GetOrderByHash(OrderQuery{OrderHash: hash})
+	if nil != err {
+		return nil, newRestError(RestCodeNotFound, err.Error())
+	}
+	return order, nil
+}
+
+func (rs *RestServiceImpl) submitOrder(r *http.Request, params map[string]string) (interface{}, *RestError) {
+	order := &types.OrderJsonRequest{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxJsonrpcBodySize)).Decode(order); nil != err {
+		return nil, newRestError(RestCodeInvalidParams, err.Error())
+	}
+	hash, err := rs.backend.SubmitOrder(order)
+	if nil != err {
+		return nil, newRestError(RestCodeInvalidParams, err.Error())
+	}
+	return hash, nil
+}
+
+func (rs *RestServiceImpl) getBalances(r *http.Request, params map[string]string) (interface{}, *RestError) {
+	delegateAddress := r.URL.Query().Get("delegateAddress")
+	if !common.IsHexAddress(params["owner"]) || !common.IsHexAddress(delegateAddress) {
+		return nil, newRestError(RestCodeInvalidParams, "invalid owner or delegateAddress")
+	}
+	balances, err := rs.backend.GetBalance(CommonTokenRequest{Owner: params["owner"], DelegateAddress: delegateAddress})
+	if nil != err {
+		return nil, newRestError(RestCodeInternal, err.Error())
+	}
+	return balances, nil
+}
+
+func (rs *RestServiceImpl) getFills(r *http.Request, params map[string]string) (interface{}, *RestError) {
+	values := r.URL.Query()
+	query := FillQuery{
+		DelegateAddress: values.Get("delegateAddress"),
+		Market:          values.Get("market"),
+		Owner:           values.Get("owner"),
+		OrderHash:       values.Get("orderHash"),
+		RingHash:        values.Get("ringHash"),
+		Side:            values.Get("side"),
+		OrderType:       values.Get("orderType"),
+	}
+	var err error
+	if query.PageIndex, err = restIntParam(values.Get("pageIndex")); nil != err {
+		return nil, newRestError(RestCodeInvalidParams, "invalid pageIndex")
+	}
+	if query.PageSize, err = restIntParam(values.Get("pageSize")); nil != err {
+		return nil, newRestError(RestCodeInvalidParams, "invalid pageSize")
+	}
+	fills, err := rs.backend.GetFills(query)
+	if nil != err {
+		return nil, newRestError(RestCodeInternal, err.Error())
+	}
+	return fills, nil
+}
+
+func (rs *RestServiceImpl) getTrends(r *http.Request, params map[string]string) (interface{}, *RestError) {
+	values := r.URL.Query()
+	if "" == values.Get("market") {
+		return nil, newRestError(RestCodeInvalidParams, "market must be applied")
+	}
+	trends, err := rs.backend.GetTrend(TrendQuery{Market: values.Get("market"), Interval: values.Get("interval")})
+	if nil != err {
+		return nil, newRestError(RestCodeInternal, err.Error())
+	}
+	return trends, nil
+}
+
+func restIntParam(value string) (int, error) {
+	if "" == value {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,228 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Loopring/relay/cache"
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/crypto"
+	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/market"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testOrderHash = "0x2794f8e4d2940a2695c7ecc68e10e4f479b809601fa1d07f5b4ce03feec289d5"
+
+type fakeRestBackend struct {
+	submitted *types.OrderJsonRequest
+}
+
+func (b *fakeRestBackend) GetDepth(query DepthQuery) (Depth, error) {
+	return Depth{DelegateAddress: query.DelegateAddress, Market: query.Market}, nil
+}
+
+func (b *fakeRestBackend) GetOrderByHash(query OrderQuery) (OrderJsonResult, error) {
+	if query.OrderHash != testOrderHash {
+		return OrderJsonResult{}, errors.New("order not found")
+	}
+	return OrderJsonResult{Status: "ORDER_OPENED"}, nil
+}
+
+func (b *fakeRestBackend) SubmitOrder(order *types.OrderJsonRequest) (string, error) {
+	if order.Protocol == (common.Address{}) {
+		return "", errors.New("protocol must be applied")
+	}
+	b.submitted = order
+	return testOrderHash, nil
+}
+
+func (b *fakeRestBackend) GetBalance(query CommonTokenRequest) (AccountJson, error) {
+	return AccountJson{DelegateAddress: query.DelegateAddress, Address: query.Owner}, nil
+}
+
+func (b *fakeRestBackend) GetFills(query FillQuery) (dao.PageResult, error) {
+	return dao.PageResult{PageIndex: query.PageIndex, PageSize: query.PageSize}, nil
+}
+
+func (b *fakeRestBackend) GetTrend(query TrendQuery) ([]market.Trend, error) {
+	return []market.Trend{{Market: query.Market, Intervals: query.Interval}}, nil
+}
+
+func testOrderBody(protocol string) string {
+	return `{"protocol":"` + protocol + `","delegateAddress":"0x17233e07c67d086464fD408148c3ABB56245FA64",` +
+		`"tokenS":"0xef68e7c694f40c8202821edf525de3782458639f","tokenB":"0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2",` +
+		`"authAddr":"0x47fe1648b80fa04584241781488ce4c0aaca23e4","walletAddress":"0xb94065482ad64d4c2b9252358d746b39e820a582",` +
+		`"amountS":"0x1","amountB":"0x1","validSince":"0x0","validUntil":"0x1","lrcFee":"0x0",` +
+		`"buyNoMoreThanAmountB":false,"marginSplitPercentage":50,"v":27,` +
+		`"r":"0x` + strings.Repeat("1", 64) + `","s":"0x` + strings.Repeat("2", 64) + `"}`
+}
+
+func newTestRestService(rateLimiter *RateLimiter) (*RestServiceImpl, *fakeRestBackend) {
+	backend := &fakeRestBackend{}
+	rs := &RestServiceImpl{
+		backend:     backend,
+		sessions:    NewSessionManager(config.SessionOptions{ChallengeTtl: 60, TokenTtl: 60}),
+		rateLimiter: rateLimiter,
+	}
+	rs.openAPI, _ = json.Marshal(newOpenAPIDocument(restRoutes))
+	return rs, backend
+}
+
+func doRestRequest(rs *RestServiceImpl, method, target, body string, header map[string]string) (*httptest.ResponseRecorder, RestResponse) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	rs.ServeHTTP(w, req)
+	resp := RestResponse{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp
+}
+
+func TestRestService_Routes(t *testing.T) {
+	rs, backend := newTestRestService(nil)
+
+	cases := []struct {
+		method string
+		target string
+		body   string
+		status int
+		code   string
+	}{
+		{http.MethodGet, "/v2/orders/" + testOrderHash, "", http.StatusOK, ""},
+		{http.MethodGet, "/v2/orders/0x1234", "", http.StatusBadRequest, RestCodeInvalidParams},
+		{http.MethodGet, "/v2/orders/0x" + strings.Repeat("0", 64), "", http.StatusNotFound, RestCodeNotFound},
+		{http.MethodDelete, "/v2/orders/" + testOrderHash, "", http.StatusMethodNotAllowed, RestCodeMethodNotAllowed},
+		{http.MethodGet, "/v2/unknown", "", http.StatusNotFound, RestCodeNotFound},
+		{http.MethodGet, "/v1/orders/" + testOrderHash, "", http.StatusNotFound, RestCodeNotFound},
+		{http.MethodGet, "/v2/markets/LRC-WETH/depth?delegateAddress=0x123", "", http.StatusBadRequest, RestCodeInvalidParams},
+		{http.MethodGet, "/v2/fills?pageIndex=2&pageSize=20", "", http.StatusOK, ""},
+		{http.MethodGet, "/v2/fills?pageIndex=a", "", http.StatusBadRequest, RestCodeInvalidParams},
+		{http.MethodGet, "/v2/trends?market=LRC-WETH&interval=1Hr", "", http.StatusOK, ""},
+		{http.MethodGet, "/v2/trends", "", http.StatusBadRequest, RestCodeInvalidParams},
+		{http.MethodPost, "/v2/orders", testOrderBody("0x8d8812b72d1e4ffCeC158D25f56748b7d67c1e78"), http.StatusCreated, ""},
+		{http.MethodPost, "/v2/orders", `{"protocol":`, http.StatusBadRequest, RestCodeInvalidParams},
+		{http.MethodPost, "/v2/orders", `{}`, http.StatusBadRequest, RestCodeInvalidParams},
+		{http.MethodPost, "/v2/orders", testOrderBody("0x0000000000000000000000000000000000000000"), http.StatusBadRequest, RestCodeInvalidParams},
+	}
+	for _, c := range cases {
+		w, resp := doRestRequest(rs, c.method, c.target, c.body, nil)
+		if w.Code != c.status {
+			t.Fatalf("%s %s status should be %d, got:%d, body:%s", c.method, c.target, c.status, w.Code, w.Body.String())
+		}
+		if "" == c.code && (nil != resp.Error || nil == resp.Data) {
+			t.Fatalf("%s %s should return data, got:%s", c.method, c.target, w.Body.String())
+		}
+		if "" != c.code && (nil == resp.Error || resp.Error.Code != c.code) {
+			t.Fatalf("%s %s error code should be %s, got:%s", c.method, c.target, c.code, w.Body.String())
+		}
+	}
+	if nil == backend.submitted {
+		t.Fatalf("order should be submitted to backend")
+	}
+}
+
+func TestRestService_Auth(t *testing.T) {
+	cache.NewCache(config.RedisOptions{Engine: "memory"})
+	crypto.Initialize(crypto.NewKSCrypto(true, nil))
+	rs, _ := newTestRestService(nil)
+	owner := newSessionSigner(t, "0x4c5496d2745fe9cc2e0aa3e1aad2b66cc792a716decf707ddb7f92bd2fd5f1d0")
+	target := "/v2/owners/" + owner.Address().Hex() + "/balances?delegateAddress=0x17233e07c67d086464fD408148c3ABB56245FA64"
+
+	if w, resp := doRestRequest(rs, http.MethodGet, target, "", nil); w.Code != http.StatusUnauthorized || resp.Error.Code != RestCodeUnauthorized {
+		t.Fatalf("request without token should be unauthorized, got:%s", w.Body.String())
+	}
+
+	challenge, err := rs.sessions.NewChallenge(owner.Address().Hex())
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	session, err := rs.sessions.CreateSession(SessionRequest{Owner: owner.Address().Hex(), Challenge: challenge.Challenge, Sig: signChallenge(t, owner, challenge.Challenge)})
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	header := map[string]string{"Authorization": "Bearer " + session.Token}
+	if w, resp := doRestRequest(rs, http.MethodGet, target, "", header); w.Code != http.StatusOK || nil != resp.Error {
+		t.Fatalf("request with token should be allowed, got:%s", w.Body.String())
+	}
+
+	otherTarget := "/v2/owners/0x17233e07c67d086464fD408148c3ABB56245FA64/balances?delegateAddress=0x17233e07c67d086464fD408148c3ABB56245FA64"
+	if w, _ := doRestRequest(rs, http.MethodGet, otherTarget, "", header); w.Code != http.StatusUnauthorized {
+		t.Fatalf("token should not be used for other owner, got:%s", w.Body.String())
+	}
+}
+
+func TestRestService_RateLimit(t *testing.T) {
+	limiter, _ := newTestRateLimiter()
+	rs, _ := newTestRestService(limiter)
+
+	header := map[string]string{}
+	for i := 0; i < 10; i++ {
+		if w, _ := doRestRequest(rs, http.MethodGet, "/v2/orders/"+testOrderHash, "", header); w.Code != http.StatusOK {
+			t.Fatalf("request %d should be allowed, got:%s", i, w.Body.String())
+		}
+	}
+	w, resp := doRestRequest(rs, http.MethodGet, "/v2/orders/"+testOrderHash, "", header)
+	if w.Code != http.StatusTooManyRequests || resp.Error.Code != RestCodeRateLimited {
+		t.Fatalf("request should be limited, got:%d %s", w.Code, w.Body.String())
+	}
+	if "" == w.Header().Get("Retry-After") || "0" != w.Header().Get("X-RateLimit-Remaining") {
+		t.Fatalf("quota headers should be set, got:%v", w.Header())
+	}
+
+	header[apiKeyHeader] = "key1"
+	if w, _ := doRestRequest(rs, http.MethodGet, "/v2/orders/"+testOrderHash, "", header); w.Code != http.StatusOK {
+		t.Fatalf("request with api key should be allowed, got:%s", w.Body.String())
+	}
+}
+
+func TestRestService_OpenAPI(t *testing.T) {
+	rs, _ := newTestRestService(nil)
+	w, _ := doRestRequest(rs, http.MethodGet, "/v2/openapi.json", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status should be 200, got:%d", w.Code)
+	}
+	doc := struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	for _, route := range restRoutes {
+		if _, exists := doc.Paths[restPathPrefix+route.Path][strings.ToLower(route.Method)]; !exists {
+			t.Fatalf("%s %s is missing in openapi document", route.Method, route.Path)
+		}
+	}
+	for _, name := range []string{"RestError", "Depth", "OrderJsonResult", "OrderJsonRequest", "AccountJson", "PageResult", "Trend"} {
+		if schema, exists := doc.Components.Schemas[name]; !exists || "null" == string(schema) {
+			t.Fatalf("schema %s is missing in openapi document", name)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"github.com/Loopring/relay/cache"
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/crypto"
+	"github.com/ethereum/go-ethereum/common"
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+	"strings"
+	"time"
+)
+
+/**
+owner 会话
+1、客户端调用 GetSessionChallenge 取得随机的challenge，challenge在 challenge_ttl 内有效，只能使用一次
+2、客户端用owner的私钥对challenge(32字节)做 eth_sign，调用 CreateSession 换取token，token在 token_ttl 内有效；
+   验证的是加了 "\x19Ethereum Signed Message:\n32" 前缀后的hash，不接受对challenge本身的签名，避免challenge被用来骗取交易签名
+3、属于owner的数据需要token: jsonrpc 的 UnlockWallet、NotifyTransactionSubmitted、GetBalance、GetPortfolio、GetTransactions、GetTransactionsByHash、
+   GetPendingTransactions，   以及socketio的 balance、portfolio、transaction、pendingTx，参数中带上token，并且token的owner必须与参数中的owner相同
+4、challenge和token保存在cache中，共用redis的relay之间可以互相识别
+*/
+
+const (
+	sessionChallengePreKey = "SESSION_CHALLENGE_"
+	sessionTokenPreKey     = "SESSION_TOKEN_"
+
+	defaultChallengeTtl = 300
+	defaultTokenTtl     = 3600
+)
+
+type SessionManager struct {
+	challengeTtl int64
+	tokenTtl     int64
+}
+
+type SessionChallenge struct {
+	Owner     string `json:"owner"`
+	Challenge string `json:"challenge"`
+	ExpireAt  int64  `json:"expireAt"`
+}
+
+type SessionRequest struct {
+	Owner     string `json:"owner"`
+	Challenge string `json:"challenge"`
+	Sig       string `json:"sig"`
+}
+
+type Session struct {
+	Owner    string `json:"owner"`
+	Token    string `json:"token"`
+	ExpireAt int64  `json:"expireAt"`
+}
+
+//OwnerToken is the part of the queries that are scoped to owner
+type OwnerToken struct {
+	Owner string `json:"owner"`
+	Token string `json:"token"`
+}
+
+func NewSessionManager(options config.SessionOptions) *SessionManager {
+	s := &SessionManager{challengeTtl: options.ChallengeTtl, tokenTtl: options.TokenTtl}
+	if s.challengeTtl <= 0 {
+		s.challengeTtl = defaultChallengeTtl
+	}
+	if s.tokenTtl <= 0 {
+		s.tokenTtl = defaultTokenTtl
+	}
+	return s
+}
+
+func (s *SessionManager) NewChallenge(owner string) (SessionChallenge, error) {
+	if !common.IsHexAddress(owner) {
+		return SessionChallenge{}, errors.New("invalid owner")
+	}
+	challenge, err := randomHex()
+	if nil != err {
+		return SessionChallenge{}, err
+	}
+	owner = strings.ToLower(owner)
+	if err := cache.Set(sessionChallengePreKey+challenge, []byte(owner), s.challengeTtl); nil != err {
+		return SessionChallenge{}, err
+	}
+	return SessionChallenge{Owner: owner, Challenge: challenge, ExpireAt: time.Now().Unix() + s.challengeTtl}, nil
+}
+
+func (s *SessionManager) CreateSession(req SessionRequest) (Session, error) {
+	challenge := strings.ToLower(req.Challenge)
+	owner, err := cache.Get(sessionChallengePreKey + challenge)
+	if nil != err || !strings.EqualFold(string(owner), req.Owner) {
+		return Session{}, errors.New("challenge not found or expired")
+	}
+	cache.Del(sessionChallengePreKey + challenge)
+
+	sig := common.FromHex(req.Sig)
+	if len(sig) != 65 {
+		return Session{}, errors.New("invalid sig")
+	}
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pubKey, err := ethCrypto.SigToPub(challengeHash(common.FromHex(challenge)), sig)
+	if nil != err {
+		return Session{}, err
+	}
+	if ethCrypto.PubkeyToAddress(*pubKey) != common.HexToAddress(req.Owner) {
+		return Session{}, errors.New("sig not match owner")
+	}
+
+	token, err := randomHex()
+	if nil != err {
+		return Session{}, err
+	}
+	if err := cache.Set(sessionTokenPreKey+token, owner, s.tokenTtl); nil != err {
+		return Session{}, err
+	}
+	return Session{Owner: string(owner), Token: token, ExpireAt: time.Now().Unix() + s.tokenTtl}, nil
+}
+
+//Owner returns the owner of the token in lower case
+func (s *SessionManager) Owner(token string) (string, error) {
+	if "" == token {
+		return "", errors.New("token must be applied")
+	}
+	owner, err := cache.Get(sessionTokenPreKey + strings.ToLower(token))
+	if nil != err {
+		return "", errors.New("token not found or expired")
+	}
+	return string(owner), nil
+}
+
+func (s *SessionManager) Authorize(owner, token string) error {
+	tokenOwner, err := s.Owner(token)
+	if nil != err {
+		return err
+	}
+	if !strings.EqualFold(tokenOwner, owner) {
+		return errors.New("token not match owner")
+	}
+	return nil
+}
+
+//AuthorizeQuery authorizes the query in json, which contains owner and token
+func (s *SessionManager) AuthorizeQuery(query []byte) error {
+	ownerToken := &OwnerToken{}
+	if err := json.Unmarshal(query, ownerToken); nil != err {
+		return err
+	}
+	return s.Authorize(ownerToken.Owner, ownerToken.Token)
+}
+
+//challengeHash returns the hash signed by eth_sign, wallets always add the prefix before signing
+func challengeHash(challenge []byte) []byte {
+	return crypto.GenerateHash([]byte("\x19Ethereum Signed Message:\n32"), challenge)
+}
+
+func randomHex() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); nil != err {
+		return "", err
+	}
+	return common.ToHex(b), nil
+}
@@ -0,0 +1,113 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"github.com/Loopring/relay/cache"
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/crypto"
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSessionSigner(t *testing.T, privateKey string) crypto.EthPrivateKeyCrypto {
+	signer, err := crypto.NewPrivateKeyCrypto(false, privateKey)
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	return signer
+}
+
+func signChallenge(t *testing.T, signer crypto.EthPrivateKeyCrypto, challenge string) string {
+	sig, err := signer.SignHash(challengeHash(common.FromHex(challenge)), signer.Address())
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	sig[64] += 27
+	return common.ToHex(sig)
+}
+
+func TestSessionManager(t *testing.T) {
+	cache.NewCache(config.RedisOptions{Engine: "memory"})
+	crypto.Initialize(crypto.NewKSCrypto(true, nil))
+
+	sessions := NewSessionManager(config.SessionOptions{ChallengeTtl: 60, TokenTtl: 1})
+	owner := newSessionSigner(t, "0x4c5496d2745fe9cc2e0aa3e1aad2b66cc792a716decf707ddb7f92bd2fd5f1d0")
+	other := newSessionSigner(t, "0x2b9a8b4b8f8d2b7b6a6b7f3c9e5d2b1a0f9e8d7c6b5a49382716a5b4c3d2e1f0")
+
+	if _, err := sessions.NewChallenge("0x123"); nil == err {
+		t.Fatalf("invalid owner should be rejected")
+	}
+
+	challenge, err := sessions.NewChallenge(owner.Address().Hex())
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if _, err := sessions.CreateSession(SessionRequest{Owner: owner.Address().Hex(), Challenge: challenge.Challenge, Sig: signChallenge(t, other, challenge.Challenge)}); nil == err {
+		t.Fatalf("sig of other address should be rejected")
+	}
+	if _, err := sessions.CreateSession(SessionRequest{Owner: owner.Address().Hex(), Challenge: challenge.Challenge, Sig: signChallenge(t, owner, challenge.Challenge)}); nil == err {
+		t.Fatalf("challenge can only be used once")
+	}
+
+	challenge, _ = sessions.NewChallenge(owner.Address().Hex())
+	if _, err := sessions.CreateSession(SessionRequest{Owner: other.Address().Hex(), Challenge: challenge.Challenge, Sig: signChallenge(t, other, challenge.Challenge)}); nil == err {
+		t.Fatalf("challenge issued to other owner should be rejected")
+	}
+
+	//the raw signature of challenge could be a signature of transaction, it must be rejected
+	challenge, _ = sessions.NewChallenge(owner.Address().Hex())
+	rawSig, err := owner.SignHash(common.FromHex(challenge.Challenge), owner.Address())
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	rawSig[64] += 27
+	if _, err := sessions.CreateSession(SessionRequest{Owner: owner.Address().Hex(), Challenge: challenge.Challenge, Sig: common.ToHex(rawSig)}); nil == err {
+		t.Fatalf("sig without prefix should be rejected")
+	}
+
+	challenge, _ = sessions.NewChallenge(owner.Address().Hex())
+	session, err := sessions.CreateSession(SessionRequest{Owner: owner.Address().Hex(), Challenge: challenge.Challenge, Sig: signChallenge(t, owner, challenge.Challenge)})
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if session.Owner != strings.ToLower(owner.Address().Hex()) {
+		t.Fatalf("owner of session should be %s, got:%s", owner.Address().Hex(), session.Owner)
+	}
+
+	if err := sessions.Authorize(strings.ToUpper(owner.Address().Hex()), session.Token); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if err := sessions.Authorize(other.Address().Hex(), session.Token); nil == err {
+		t.Fatalf("token should not be used by other owner")
+	}
+	if err := sessions.AuthorizeQuery([]byte(`{"owner":"` + owner.Address().Hex() + `","token":"` + session.Token + `"}`)); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if err := sessions.AuthorizeQuery([]byte(`{"owner":"` + owner.Address().Hex() + `"}`)); nil == err {
+		t.Fatalf("query without token should be rejected")
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if err := sessions.Authorize(owner.Address().Hex(), session.Token); nil == err {
+		t.Fatalf("token should be expired")
+	}
+}
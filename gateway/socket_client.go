@@ -20,11 +20,10 @@ package gateway
 
 import (
 	"encoding/json"
-	"fmt"
+	"github.com/Loopring/relay/log"
 	"github.com/gorilla/websocket"
-	"log"
-	"qiniupkg.com/x/errors.v7"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -32,7 +31,7 @@ const (
 	writeWait      = 10 * time.Second
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
+	maxMessageSize = 4096
 )
 
 type SocketClient struct {
@@ -40,112 +39,138 @@ type SocketClient struct {
 
 	conn *websocket.Conn
 
-	send chan interface{}
+	send chan *WebsocketResponse
+	done chan struct{}
+
+	handle func(client *SocketClient, req *WebsocketRequest) *WebsocketResponse
+
+	owner         string
+	subscriptions map[string]json.RawMessage
+	depthKey      string
+	mtx           sync.RWMutex
+	closeOnce     sync.Once
+}
+
+func newSocketClient(node *SocketNode, conn *websocket.Conn, handle func(client *SocketClient, req *WebsocketRequest) *WebsocketResponse) *SocketClient {
+	return &SocketClient{
+		node:          node,
+		conn:          conn,
+		send:          make(chan *WebsocketResponse, wsSendBufferSize),
+		done:          make(chan struct{}),
+		handle:        handle,
+		subscriptions: make(map[string]json.RawMessage),
+	}
 }
 
 func (c *SocketClient) read() {
 	defer func() {
-		c.node.unregister <- c
-		c.conn.Close()
+		c.node.unregister(c)
+		c.close()
 	}()
-	fmt.Print("read ------->1")
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
-	fmt.Print("read ------->2")
 	for {
-		fmt.Print("read ------->33")
-		req := &WebsocketRequest{}
-		err := c.conn.ReadJSON(&req)
-		fmt.Print(req)
-		fmt.Print(err)
-		fmt.Print("read ------->3333")
-		if err != nil {
+		_, message, err := c.conn.ReadMessage()
+		if nil != err {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error: %v", err)
+				log.Debugf("websocket read error:%s", err.Error())
 			}
-			break
+			return
 		}
-
-		fmt.Print("read ------->4")
-		resp, err := c.handler(*req)
-		if err == nil {
-			c.send <- resp
-		}
-	}
-}
-
-func (c *SocketClient) handler(req WebsocketRequest) (interface{}, error) {
-	walletService := WalletServiceImpl{}
-	fmt.Print("read ------->5")
-	fmt.Println(req)
-	fmt.Print("read ------->6")
-
-	if method, ok := req["method"]; ok {
-		switch method {
-		case "portfolio":
-			if v, ok := req["owner"]; ok {
-				return walletService.GetPortfolio(SingleOwner{v})
-			} else {
-				return nil, errors.New("owner must be applied")
-			}
-		case "balance":
-			//balanceQuery := CommonTokenRequest{ContractVersion: req["contractVersion"], Owner: req["owner"]}
-			//return walletService.GetBalance(balanceQuery)
-		case "tickers":
-			return walletService.GetTickers(SingleMarket{req["market"]})
-		case "transactions":
-			pageIndex, _ := strconv.Atoi(req["pageIndex"])
-			pageSize, _ := strconv.Atoi(req["pageSize"])
-			txnQuery := TransactionQuery{ThxHash: req["thxHash"], Owner: req["owner"], PageIndex: pageIndex, PageSize: pageSize}
-			return walletService.GetTransactions(txnQuery)
-		case "marketcap":
-			return walletService.GetPriceQuote(PriceQuoteQuery{req["currency"]})
-		case "depth":
-			//length, _ := strconv.Atoi(req["length"])
-			//depthQuery := DepthQuery{Length: length, Market: req["market"], ContractVersion: req["contractVersion"]}
-			//return walletService.GetDepth(depthQuery)
-		case "trends":
-			query := TrendQuery{Market: req["market"]}
-			return walletService.GetTrend(query)
+		c.conn.SetReadDeadline(time.Now().Add(pongWait))
+		req := &WebsocketRequest{}
+		if err := json.Unmarshal(message, req); nil != err {
+			c.push(&WebsocketResponse{Error: err.Error()})
+			continue
 		}
-
+		c.push(c.handle(c, req))
 	}
-
-	return nil, errors.New("method must be applied")
 }
 
 func (c *SocketClient) write() {
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
 		ticker.Stop()
-		c.conn.Close()
+		c.close()
 	}()
 	for {
-		fmt.Print("read ------->7")
 		select {
-		case message, ok := <-c.send:
-			fmt.Println("read ------->8")
-			fmt.Println(message)
-			fmt.Println(ok)
-			fmt.Println(json.Marshal(message))
+		case <-c.done:
+			return
+		case resp := <-c.send:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			if err := c.conn.WriteJSON(resp); nil != err {
+				log.Debugf("websocket write error:%s", err.Error())
 				return
 			}
-			err := c.conn.WriteJSON(&message)
-			if err != nil {
-				fmt.Println("write error occurs")
-				fmt.Println(err)
-				return
-			}
-
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); nil != err {
 				return
 			}
 		}
 	}
 }
+
+//push never blocks, the client is closed when its send buffer is full
+func (c *SocketClient) push(resp *WebsocketResponse) {
+	select {
+	case <-c.done:
+	case c.send <- resp:
+	default:
+		log.Infof("websocket client:%s is too slow, close it", c.conn.RemoteAddr().String())
+		c.close()
+	}
+}
+
+//close interrupts the blocked writer of slow client, the reader will unregister the client after that
+func (c *SocketClient) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *SocketClient) setOwner(owner string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.owner = strings.ToLower(owner)
+}
+
+func (c *SocketClient) isOwner(owner string) bool {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return "" != c.owner && c.owner == strings.ToLower(owner)
+}
+
+func (c *SocketClient) subscribe(topic string, params json.RawMessage, depthKey string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.subscriptions[topic] = params
+	if eventKeyDepth == topic {
+		c.depthKey = depthKey
+	}
+}
+
+func (c *SocketClient) unsubscribe(topic string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	delete(c.subscriptions, topic)
+	if eventKeyDepth == topic {
+		c.depthKey = ""
+	}
+}
+
+func (c *SocketClient) subscription(topic string) (json.RawMessage, bool) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	params, ok := c.subscriptions[topic]
+	return params, ok
+}
+
+func (c *SocketClient) depthSubscribed(key string) bool {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return "" != c.depthKey && c.depthKey == key
+}
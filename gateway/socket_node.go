@@ -18,41 +18,54 @@
 
 package gateway
 
+import (
+	"sync"
+)
+
 type SocketNode struct {
-	clients    map[*SocketClient]bool
-	broadcast  chan []byte
-	register   chan *SocketClient
-	unregister chan *SocketClient
+	clients map[*SocketClient]bool
+	mtx     sync.RWMutex
 }
 
 func newSocketNode() *SocketNode {
-	return &SocketNode{
-		broadcast:  make(chan []byte),
-		register:   make(chan *SocketClient),
-		unregister: make(chan *SocketClient),
-		clients:    make(map[*SocketClient]bool),
+	return &SocketNode{clients: make(map[*SocketClient]bool)}
+}
+
+func (h *SocketNode) register(client *SocketClient) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	h.clients[client] = true
+}
+
+func (h *SocketNode) unregister(client *SocketClient) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	delete(h.clients, client)
+}
+
+//each should not block, the clients push messages to their own send buffer
+func (h *SocketNode) each(fn func(client *SocketClient)) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	for client := range h.clients {
+		fn(client)
 	}
 }
 
-func (h *SocketNode) run() {
-	for {
-		select {
-		case client := <-h.register:
-			h.clients[client] = true
-		case client := <-h.unregister:
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-			}
-		case message := <-h.broadcast:
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
-			}
-		}
+func (h *SocketNode) count() int {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return len(h.clients)
+}
+
+func (h *SocketNode) closeAll() {
+	h.mtx.RLock()
+	clients := make([]*SocketClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mtx.RUnlock()
+	for _, client := range clients {
+		client.close()
 	}
 }
@@ -16,6 +16,7 @@ import (
 	"gopkg.in/googollee/go-engine.io.v1"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -86,6 +87,7 @@ const (
 	eventKeyTransaction     = "transaction"
 	eventKeyPendingTx       = "pendingTx"
 	eventKeyDepth           = "depth"
+	eventKeyDepthDelta      = "depthDelta"
 	eventKeyTrades          = "trades"
 )
 
@@ -127,12 +129,14 @@ type SocketIOServiceImpl struct {
 	connIdMap          *sync.Map
 	connBusinessKeyMap map[string]socketio.Conn
 	cron               *cron.Cron
+	rateLimiter        *RateLimiter
 }
 
-func NewSocketIOService(port string, walletService WalletServiceImpl) *SocketIOServiceImpl {
+func NewSocketIOService(port string, walletService WalletServiceImpl, rateLimiter *RateLimiter) *SocketIOServiceImpl {
 	so := &SocketIOServiceImpl{}
 	so.port = port
 	so.walletService = walletService
+	so.rateLimiter = rateLimiter
 	so.connBusinessKeyMap = make(map[string]socketio.Conn)
 	so.connIdMap = &sync.Map{}
 	so.cron = cron.New()
@@ -148,6 +152,8 @@ func NewSocketIOService(port string, walletService WalletServiceImpl) *SocketIOS
 	//eventemitter.On(eventemitter.BalanceUpdated, balanceWatcher)
 	//depthWatcher := &eventemitter.Watcher{Concurrent: false, Handle: so.broadcastDepth}
 	//eventemitter.On(eventemitter.DepthUpdated, depthWatcher)
+	depthDeltaWatcher := &eventemitter.Watcher{Concurrent: false, Handle: so.broadcastDepthDelta}
+	eventemitter.On(eventemitter.DepthDelta, depthDeltaWatcher)
 	//transactionWatcher := &eventemitter.Watcher{Concurrent: false, Handle: so.handleTransactionUpdate}
 	//eventemitter.On(eventemitter.TransactionEvent, transactionWatcher)
 	//pendingTxWatcher := &eventemitter.Watcher{Concurrent: false, Handle: so.handlePendingTransaction}
@@ -179,6 +185,16 @@ func (so *SocketIOServiceImpl) Start() {
 
 		server.OnEvent("/", aliasOfV+EventPostfixReq, func(s socketio.Conn, msg string) {
 			fmt.Println("input emit msg is ....." + msg)
+			if !so.take(s, aliasOfV) {
+				errJson, _ := json.Marshal(SocketIOJsonResp{Error: RateLimitErrorMessage, Code: strconv.Itoa(RateLimitErrorCode)})
+				s.Emit(aliasOfV+EventPostfixRes, string(errJson[:]))
+				return
+			}
+			if err := so.authorize(aliasOfV, msg); nil != err {
+				errJson, _ := json.Marshal(SocketIOJsonResp{Error: err.Error()})
+				s.Emit(aliasOfV+EventPostfixRes, string(errJson[:]))
+				return
+			}
 			context := make(map[string]string)
 			if s != nil && s.Context() != nil {
 				context = s.Context().(map[string]string)
@@ -219,6 +235,10 @@ func (so *SocketIOServiceImpl) Start() {
 				so.broadcastLoopringTicker(nil)
 			})
 		case eventKeyDepth:
+			//the subscribers get the snapshot at first and the deltas after that
+			if nil != so.walletService.orderBook {
+				continue
+			}
 			so.cron.AddFunc(spec, func() {
 				//log.Info("start depth broadcast")
 				so.broadcastDepth(nil)
@@ -237,6 +257,14 @@ func (so *SocketIOServiceImpl) Start() {
 						businesses := v.Context().(map[string]string)
 						eventContext, ok := businesses[copyOfK]
 						if ok {
+							//the subscription is removed after the token expired
+							if err := so.authorize(copyOfK, eventContext); nil != err {
+								errJson, _ := json.Marshal(SocketIOJsonResp{Error: err.Error()})
+								v.Emit(copyOfK+EventPostfixRes, string(errJson[:]))
+								delete(businesses, copyOfK)
+								v.SetContext(businesses)
+								return true
+							}
 							//log.Infof("[SOCKETIO-EMIT]cron emit by key : %s, connId : %s", copyOfK, v.ID())
 							so.EmitNowByEventType(copyOfK, v, eventContext)
 						}
@@ -295,44 +323,63 @@ func (so *SocketIOServiceImpl) EmitNowByEventType(bk string, v socketio.Conn, bv
 	}
 }
 
-func (so *SocketIOServiceImpl) handleWith(eventType string, query interface{}, methodName string, ctx string) string {
-
-	results := make([]reflect.Value, 0)
-	var err error
-
-	if query == nil {
-		results = reflect.ValueOf(&so.walletService).MethodByName(methodName).Call(nil)
-	} else {
-		queryType := reflect.TypeOf(query)
-		queryClone := reflect.New(queryType)
-		err = json.Unmarshal([]byte(ctx), queryClone.Interface())
-		if err != nil {
-			log.Info("unmarshal error " + err.Error())
-			errJson, _ := json.Marshal(SocketIOJsonResp{Error: err.Error()})
-			return string(errJson[:])
-
-		}
-		params := make([]reflect.Value, 1)
-		params[0] = queryClone.Elem()
-		results = reflect.ValueOf(&so.walletService).MethodByName(methodName).Call(params)
+//take charges the request of event, the pushes by cron or event are free
+func (so *SocketIOServiceImpl) take(s socketio.Conn, eventType string) bool {
+	if nil == so.rateLimiter {
+		return true
+	}
+	apiKey := s.RemoteHeader().Get(apiKeyHeader)
+	if "" == apiKey {
+		u := s.URL()
+		apiKey = u.Query().Get(apiKeyQuery)
 	}
+	ip := so.rateLimiter.clientIp(s.RemoteAddr().String(), s.RemoteHeader())
+	return so.rateLimiter.Take(apiKey, ip, so.rateLimiter.Cost(EventTypeRoute[eventType].MethodName)).Allowed
+}
 
-	res := results[0]
-	if results[1].Interface() == nil {
-		err = nil
-	} else {
-		err = results[1].Interface().(error)
+//authorize checks the session token in ctx for the events scoped to owner
+func (so *SocketIOServiceImpl) authorize(eventType string, ctx string) error {
+	if invokeInfo, ok := EventTypeRoute[eventType]; !ok || invokeInfo.isBroadcast {
+		return nil
+	}
+	if nil == so.walletService.sessions {
+		return errors.New("session is not supported")
 	}
+	return so.walletService.sessions.AuthorizeQuery([]byte(ctx))
+}
+
+func (so *SocketIOServiceImpl) handleWith(eventType string, query interface{}, methodName string, ctx string) string {
+	res, err := invokeWalletService(&so.walletService, query, methodName, []byte(ctx))
 	if err != nil {
 		errJson, _ := json.Marshal(SocketIOJsonResp{Error: err.Error()})
 		return string(errJson[:])
 	} else {
-		rst := SocketIOJsonResp{Data: res.Interface()}
+		rst := SocketIOJsonResp{Data: res}
 		b, _ := json.Marshal(rst)
 		return string(b[:])
 	}
 }
 
+//invokeWalletService calls the method of walletService with the query unmarshalled from params, it is shared by socketio and websocket
+func invokeWalletService(walletService *WalletServiceImpl, query interface{}, methodName string, params []byte) (interface{}, error) {
+	results := make([]reflect.Value, 0)
+	if query == nil {
+		results = reflect.ValueOf(walletService).MethodByName(methodName).Call(nil)
+	} else {
+		queryClone := reflect.New(reflect.TypeOf(query))
+		if err := json.Unmarshal(params, queryClone.Interface()); err != nil {
+			log.Info("unmarshal error " + err.Error())
+			return nil, err
+		}
+		results = reflect.ValueOf(walletService).MethodByName(methodName).Call([]reflect.Value{queryClone.Elem()})
+	}
+
+	if err, ok := results[1].Interface().(error); ok && err != nil {
+		return nil, err
+	}
+	return results[0].Interface(), nil
+}
+
 func (so *SocketIOServiceImpl) handleAfterEmit(eventType string, query interface{}, methodName string, conn socketio.Conn, ctx string) {
 	result := so.handleWith(eventType, query, methodName, ctx)
 	conn.Emit(eventType+EventPostfixRes, result)
@@ -450,6 +497,30 @@ func (so *SocketIOServiceImpl) broadcastDepth(input eventemitter.EventData) (err
 	return nil
 }
 
+//broadcastDepthDelta pushes the delta to the subscribers of depth, clients should request depth again when the sequence is not continuous
+func (so *SocketIOServiceImpl) broadcastDepthDelta(input eventemitter.EventData) (err error) {
+	evt := input.(*types.DepthDeltaEvent)
+	respJson, _ := json.Marshal(SocketIOJsonResp{Data: evt})
+	deltaKey := strings.ToLower(evt.DelegateAddress) + "_" + strings.ToLower(evt.Market)
+
+	so.connIdMap.Range(func(key, value interface{}) bool {
+		v := value.(socketio.Conn)
+		if v.Context() != nil {
+			businesses := v.Context().(map[string]string)
+			ctx, ok := businesses[eventKeyDepth]
+			if ok {
+				dQuery := &DepthQuery{}
+				err := json.Unmarshal([]byte(ctx), dQuery)
+				if err == nil && strings.ToLower(dQuery.DelegateAddress)+"_"+strings.ToLower(dQuery.Market) == deltaKey {
+					v.Emit(eventKeyDepthDelta+EventPostfixRes, string(respJson[:]))
+				}
+			}
+		}
+		return true
+	})
+	return nil
+}
+
 func (so *SocketIOServiceImpl) broadcastTrades(input eventemitter.EventData) (err error) {
 
 	//log.Infof("[SOCKETIO-RECEIVE-EVENT] loopring depth input. %s", input)
@@ -599,7 +670,7 @@ func (so *SocketIOServiceImpl) handleBalanceUpdate(input eventemitter.EventData)
 }
 
 func (so *SocketIOServiceImpl) notifyBalanceUpdateByDelegateAddress(owner, delegateAddress string) (err error) {
-	req := CommonTokenRequest{owner, delegateAddress}
+	req := CommonTokenRequest{Owner: owner, DelegateAddress: delegateAddress}
 	resp := SocketIOJsonResp{}
 	balance, err := so.walletService.GetBalance(req)
 
@@ -733,7 +804,7 @@ func (so *SocketIOServiceImpl) handlePendingTransaction(input eventemitter.Event
 					log.Error("tx query unmarshal error, " + err.Error())
 				} else if strings.ToUpper(owner) == strings.ToUpper(txQuery.Owner) {
 					log.Info("emit tx pending " + ctx)
-					txs, err := so.walletService.GetPendingTransactions(SingleOwner{owner})
+					txs, err := so.walletService.GetPendingTransactions(SingleOwner{Owner: owner})
 					resp := SocketIOJsonResp{}
 
 					if err != nil {
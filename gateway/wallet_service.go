@@ -70,6 +70,7 @@ type PageResult struct {
 type Depth struct {
 	DelegateAddress string `json:"delegateAddress"`
 	Market          string `json:"market"`
+	Sequence        uint64 `json:"sequence"` //the DepthDelta with greater sequence can be applied to this snapshot
 	Depth           AskBid `json:"depth"`
 }
 
@@ -87,6 +88,7 @@ type DepthElement struct {
 type CommonTokenRequest struct {
 	DelegateAddress string `json:"delegateAddress"`
 	Owner           string `json:"owner"`
+	Token           string `json:"token"` //session token of owner
 }
 
 type SingleDelegateAddress struct {
@@ -104,6 +106,7 @@ type TrendQuery struct {
 
 type SingleOwner struct {
 	Owner string `json:"owner"`
+	Token string `json:"token"` //session token of owner, only required by the methods scoped to owner
 }
 
 type TxNotify struct {
@@ -118,6 +121,7 @@ type TxNotify struct {
 	R        string `json:"r"`
 	S        string `json:"s"`
 	V        string `json:"v"`
+	Token    string `json:"token"` //session token of from
 }
 
 type PriceQuoteQuery struct {
@@ -145,6 +149,7 @@ type TransactionQuery struct {
 	TrxHashes []string `json:"trxHashes"`
 	PageIndex int      `json:"pageIndex"`
 	PageSize  int      `json:"pageSize"`
+	Token     string   `json:"token"` //session token of owner
 }
 
 type OrderQuery struct {
@@ -290,10 +295,12 @@ type WalletServiceImpl struct {
 	tickerCollector market.CollectorImpl
 	rds             dao.RdsService
 	oldWethAddress  string
+	orderBook       *OrderBook
+	sessions        *SessionManager
 }
 
 func NewWalletService(trendManager market.TrendManager, orderManager ordermanager.OrderManager, accountManager market.AccountManager,
-	capProvider marketcap.MarketCapProvider, collector market.CollectorImpl, rds dao.RdsService, oldWethAddress string) *WalletServiceImpl {
+	capProvider marketcap.MarketCapProvider, collector market.CollectorImpl, rds dao.RdsService, oldWethAddress string, orderBook *OrderBook, sessions *SessionManager) *WalletServiceImpl {
 	w := &WalletServiceImpl{}
 	w.trendManager = trendManager
 	w.orderManager = orderManager
@@ -302,6 +309,8 @@ func NewWalletService(trendManager market.TrendManager, orderManager ordermanage
 	w.tickerCollector = collector
 	w.rds = rds
 	w.oldWethAddress = oldWethAddress
+	w.orderBook = orderBook
+	w.sessions = sessions
 	return w
 }
 func (w *WalletServiceImpl) TestPing(input int) (resp []byte, err error) {
@@ -411,6 +420,20 @@ func (w *WalletServiceImpl) GetTickers(mkt SingleMarket) (result map[string]mark
 	return result, nil
 }
 
+func (w *WalletServiceImpl) GetSessionChallenge(owner SingleOwner) (SessionChallenge, error) {
+	if nil == w.sessions {
+		return SessionChallenge{}, errors.New("session is not supported")
+	}
+	return w.sessions.NewChallenge(owner.Owner)
+}
+
+func (w *WalletServiceImpl) CreateSession(req SessionRequest) (Session, error) {
+	if nil == w.sessions {
+		return Session{}, errors.New("session is not supported")
+	}
+	return w.sessions.CreateSession(req)
+}
+
 func (w *WalletServiceImpl) UnlockWallet(owner SingleOwner) (result string, err error) {
 	if len(owner.Owner) == 0 {
 		return "", errors.New("owner can't be null string")
@@ -460,6 +483,8 @@ func (w *WalletServiceImpl) NotifyTransactionSubmitted(txNotify TxNotify) (resul
 
 	log.Debug("emit Pending tx >>>>>>>>>>>>>>>> " + tx.Hash)
 	eventemitter.Emit(eventemitter.PendingTransaction, tx)
+	//the token should not be returned by GetPendingRawTxByHash
+	txNotify.Token = ""
 	txByte, err := json.Marshal(txNotify)
 	if err == nil {
 		err = cache.Set(PendingTxPreKey+strings.ToUpper(txNotify.Hash), txByte, 3600*24*7)
@@ -584,6 +609,12 @@ func (w *WalletServiceImpl) GetDepth(query DepthQuery) (res Depth, err error) {
 		return
 	}
 
+	if nil != w.orderBook {
+		if depth, ok := w.orderBook.Depth(common.HexToAddress(delegateAddress), mkt, defaultDepthLength); ok {
+			return depth, nil
+		}
+	}
+
 	empty := make([][]string, 0)
 
 	for i := range empty {
@@ -991,84 +1022,17 @@ func (w *WalletServiceImpl) calculateDepth(states []types.OrderState, length int
 
 	depthMap := make(map[string]DepthElement)
 
-	for _, s := range states {
-
-		//log.Infof("handle order ....... %s", s.RawOrder.Hash.Hex())
-
-		price := *s.RawOrder.Price
-		amountS, amountB := s.RemainedAmount()
-		amountS = amountS.Quo(amountS, new(big.Rat).SetFrac(tokenSDecimal, big.NewInt(1)))
-		amountB = amountB.Quo(amountB, new(big.Rat).SetFrac(tokenBDecimal, big.NewInt(1)))
-
-		if amountS.Cmp(new(big.Rat).SetFloat64(0)) == 0 {
-			log.Debug("amount s is zero, skipped")
-			continue
-		}
-
-		if amountB.Cmp(new(big.Rat).SetFloat64(0)) == 0 {
-			log.Debug("amount b is zero, skipped")
-			continue
-		}
-
-		minAmountB := amountB
-		minAmountS := amountS
-		var err error
-
-		minAmountS, err = w.getAvailableMinAmount(amountS, s.RawOrder.Owner, s.RawOrder.TokenS, s.RawOrder.DelegateAddress, tokenSDecimal)
+	for i := range states {
+		priceFloatStr, amount, size, err := orderDepth(&w.accountManager, &states[i], isAsk, tokenSDecimal, tokenBDecimal)
 		if err != nil {
 			//log.Debug(err.Error())
 			continue
 		}
 
-		sellPrice := new(big.Rat).SetFrac(s.RawOrder.AmountS, s.RawOrder.AmountB)
-		buyPrice := new(big.Rat).SetFrac(s.RawOrder.AmountB, s.RawOrder.AmountS)
-		if s.RawOrder.BuyNoMoreThanAmountB {
-			//log.Info("order BuyNoMoreThanAmountB is true")
-			//log.Infof("amount s is %s", minAmountS)
-			//log.Infof("amount b is %s", minAmountB)
-			//log.Infof("sellprice is %s", sellPrice.String())
-			limitedAmountS := new(big.Rat).Mul(minAmountB, sellPrice)
-			//log.Infof("limit amount s is %s", limitedAmountS)
-			if limitedAmountS.Cmp(minAmountS) < 0 {
-				minAmountS = limitedAmountS
-			}
-
-			minAmountB = minAmountB.Mul(minAmountS, buyPrice)
-		} else {
-			//log.Infof("amount s is %s", minAmountS)
-			//log.Infof("amount b is %s", minAmountB)
-			//log.Infof("buyprice is %s", buyPrice.String())
-			limitedAmountB := new(big.Rat).Mul(minAmountS, buyPrice)
-			//log.Infof("limit amount b is %s", limitedAmountB)
-			if limitedAmountB.Cmp(minAmountB) < 0 {
-				minAmountB = limitedAmountB
-			}
-			minAmountS = minAmountS.Mul(minAmountB, sellPrice)
-		}
-
-		if isAsk {
-			price = *price.Inv(&price)
-			priceFloatStr := price.FloatString(10)
-			if v, ok := depthMap[priceFloatStr]; ok {
-				amount := v.Amount
-				size := v.Size
-				amount = amount.Add(amount, minAmountS)
-				size = size.Add(size, minAmountB)
-				depthMap[priceFloatStr] = DepthElement{Price: v.Price, Amount: amount, Size: size}
-			} else {
-				depthMap[priceFloatStr] = DepthElement{Price: priceFloatStr, Amount: minAmountS, Size: minAmountB}
-			}
+		if v, ok := depthMap[priceFloatStr]; ok {
+			depthMap[priceFloatStr] = DepthElement{Price: v.Price, Amount: v.Amount.Add(v.Amount, amount), Size: v.Size.Add(v.Size, size)}
 		} else {
-			priceFloatStr := price.FloatString(10)
-			if v, ok := depthMap[priceFloatStr]; ok {
-				amount := v.Amount
-				size := v.Size
-				amount = amount.Add(amount, minAmountB)
-				size = size.Add(size, minAmountS)
-				depthMap[priceFloatStr] = DepthElement{Price: v.Price, Amount: amount, Size: size}
-			} else {
-				depthMap[priceFloatStr] = DepthElement{Price: priceFloatStr, Amount: minAmountB, Size: minAmountS}
-			}
+			depthMap[priceFloatStr] = DepthElement{Price: priceFloatStr, Amount: amount, Size: size}
 		}
 	}
 
@@ -1092,7 +1056,7 @@ func (w *WalletServiceImpl) calculateDepth(states []types.OrderState, length int
 
 	if length < len(depth) {
 		if isAsk {
-			return depth[len(depth)-length-1:]
+			return depth[len(depth)-length:]
 		} else {
 			return depth[:length]
 		}
@@ -1100,39 +1064,6 @@ func (w *WalletServiceImpl) calculateDepth(states []types.OrderState, length int
 	return depth
 }
 
-func (w *WalletServiceImpl) getAvailableMinAmount(depthAmount *big.Rat, owner, token, spender common.Address, decimal *big.Int) (amount *big.Rat, err error) {
-
-	amount = depthAmount
-
-	balance, allowance, err := w.accountManager.GetBalanceAndAllowance(owner, token, spender)
-	if err != nil {
-		return
-	}
-
-	balanceRat := new(big.Rat).SetFrac(balance, decimal)
-	allowanceRat := new(big.Rat).SetFrac(allowance, decimal)
-
-	//log.Info(amount.String())
-	//log.Info(balanceRat.String())
-	//log.Info(allowanceRat.String())
-
-	if amount.Cmp(balanceRat) > 0 {
-		amount = balanceRat
-	}
-
-	if amount.Cmp(allowanceRat) > 0 {
-		amount = allowanceRat
-	}
-
-	if amount.Cmp(new(big.Rat).SetFloat64(1e-8)) < 0 {
-		return nil, errors.New("amount is zero, skipped")
-	}
-
-	//log.Infof("get reuslt amount is  %s", amount)
-
-	return
-}
-
 func fillQueryToMap(q FillQuery) (map[string]interface{}, int, int) {
 	rst := make(map[string]interface{})
 	var pi, ps int
@@ -19,62 +19,251 @@
 package gateway
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/Loopring/relay/eventemiter"
 	"github.com/Loopring/relay/log"
-	"github.com/Loopring/relay/market"
-	"github.com/Loopring/relay/marketcap"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/gorilla/websocket"
+	"github.com/robfig/cron"
 	"net/http"
+	"strings"
+)
+
+/**
+plain websocket 协议，供不使用socketio的客户端(bot、移动端)使用，地址为 ws://host:ws_port/ws
+1、请求: {"id":1, "op":"subscribe", "topic":"depth", "params":{"delegateAddress":"0x...", "market":"LRC-WETH"}}
+   subscribe: 订阅topic，topic和params与 EventTypeRoute 中的相同，同一连接的同一topic只保留最后一次的params，应答中带有当前数据
+   unsubscribe: 取消订阅topic
+   auth: params为 {"token":"0x..."}，token是jsonrpc CreateSession 得到的会话token，不接受可以被重放的签名
+   ping: 应答的op为pong
+2、应答: {"id":1, "op":"subscribe", "topic":"depth", "error":"", "data":{...}}，error不为空时表示请求失败
+3、推送: {"op":"push", "topic":"depth", "data":{...}}，按 EventTypeRoute 中各topic的cron推送
+   开启订单簿时depth不再定时推送，而是推送topic为depthDelta的增量，客户端缓存收到快照前的增量，
+   丢弃sequence不大于快照的增量，发现sequence不连续时重新订阅depth
+4、balance、portfolio、transaction、pendingTx 属于owner，需要先auth，并且params中的owner必须是auth的owner
+5、服务器每隔 pingPeriod 发送websocket ping，pongWait 内没有收到任何消息则断开；
+   每个连接的发送队列长度为 wsSendBufferSize，队列满时认为客户端处理不过来，直接断开
+*/
+
+const (
+	wsOpSubscribe   = "subscribe"
+	wsOpUnsubscribe = "unsubscribe"
+	wsOpAuth        = "auth"
+	wsOpPing        = "ping"
+	wsOpPong        = "pong"
+	wsOpPush        = "push"
+
+	wsSendBufferSize = 256
 )
 
 type WebsocketService interface {
-	Start(port string)
+	Start()
 	Stop()
 }
 
 type WebsocketServiceImpl struct {
-	port     string
-	upgrader websocket.Upgrader
+	port          string
+	upgrader      websocket.Upgrader
+	walletService *WalletServiceImpl
+	node          *SocketNode
+	cron          *cron.Cron
+	server        *http.Server
+	watcher       *eventemitter.Watcher
+
+	//invoke returns the data of topic, it calls walletService by EventTypeRoute
+	invoke func(topic string, params json.RawMessage) (interface{}, error)
 }
 
-type WebsocketRequest map[string]string
+type WebsocketRequest struct {
+	Id     int64           `json:"id"`
+	Op     string          `json:"op"`
+	Topic  string          `json:"topic"`
+	Params json.RawMessage `json:"params"`
+}
+
+type WebsocketResponse struct {
+	Id    int64       `json:"id,omitempty"`
+	Op    string      `json:"op"`
+	Topic string      `json:"topic,omitempty"`
+	Error string      `json:"error,omitempty"`
+	Data  interface{} `json:"data,omitempty"`
+}
+
+type WebsocketAuth struct {
+	Token string `json:"token"` //session token created by CreateSession
+}
 
-func NewWebsocketService(port string, trendManager market.TrendManager, accountManager market.AccountManager, capProvider marketcap.MarketCapProvider) *WebsocketServiceImpl {
-	l := &WebsocketServiceImpl{}
-	l.port = port
-	l.upgrader = websocket.Upgrader{
+func NewWebsocketService(port string, walletService *WalletServiceImpl) *WebsocketServiceImpl {
+	ws := &WebsocketServiceImpl{}
+	ws.port = port
+	ws.walletService = walletService
+	ws.node = newSocketNode()
+	ws.cron = cron.New()
+	ws.upgrader = websocket.Upgrader{
 		CheckOrigin:     func(r *http.Request) bool { return true },
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
 	}
-	return l
+	ws.invoke = ws.invokeByRoute
+	ws.watcher = &eventemitter.Watcher{Concurrent: false, Handle: ws.pushDepthDelta}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", ws.serve)
+	ws.server = &http.Server{Addr: ":" + port, Handler: mux}
+	return ws
 }
 
 func (ws *WebsocketServiceImpl) Start() {
+	if "" == ws.port {
+		return
+	}
+	eventemitter.On(eventemitter.DepthDelta, ws.watcher)
 
-	node := newSocketNode()
-	go node.run()
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		ws.serve(node, w, r)
-	})
+	for topic, invokeInfo := range EventTypeRoute {
+		copyOfTopic := topic
+		if eventKeyDepth == topic && ws.orderBookEnabled() {
+			continue
+		}
+		ws.cron.AddFunc(invokeInfo.spec, func() {
+			ws.pushTopic(copyOfTopic)
+		})
+	}
+	ws.cron.Start()
 
-	err := http.ListenAndServe(":"+ws.port, nil)
-	if err != nil {
-		log.Fatal("ListenAndServe Websocket Error : " + err.Error())
+	log.Infof("websocket serving at port:%s", ws.port)
+	if err := ws.server.ListenAndServe(); nil != err && err != http.ErrServerClosed {
+		log.Fatalf("websocket listen error:%s", err.Error())
 	}
+}
 
-	return
+func (ws *WebsocketServiceImpl) Stop() {
+	eventemitter.Un(eventemitter.DepthDelta, ws.watcher)
+	ws.cron.Stop()
+	ws.server.Close()
+	ws.node.closeAll()
 }
 
-func (ws *WebsocketServiceImpl) serve(node *SocketNode, w http.ResponseWriter, r *http.Request) {
+func (ws *WebsocketServiceImpl) serve(w http.ResponseWriter, r *http.Request) {
 	conn, err := ws.upgrader.Upgrade(w, r, nil)
-	fmt.Print(conn.LocalAddr())
-	if err != nil {
-		log.Error("get ws connection error , " + err.Error())
+	if nil != err {
+		log.Errorf("websocket upgrade error:%s", err.Error())
 		return
 	}
-	client := &SocketClient{node: node, conn: conn}
-	client.node.register <- client
+	client := newSocketClient(ws.node, conn, ws.handle)
+	ws.node.register(client)
 	go client.write()
 	go client.read()
 }
+
+func (ws *WebsocketServiceImpl) orderBookEnabled() bool {
+	return nil != ws.walletService && nil != ws.walletService.orderBook
+}
+
+func (ws *WebsocketServiceImpl) handle(client *SocketClient, req *WebsocketRequest) *WebsocketResponse {
+	resp := &WebsocketResponse{Id: req.Id, Op: req.Op, Topic: req.Topic}
+	var err error
+	switch req.Op {
+	case wsOpPing:
+		resp.Op = wsOpPong
+	case wsOpAuth:
+		var owner string
+		if owner, err = ws.authenticate(req.Params); nil == err {
+			client.setOwner(owner)
+			resp.Data = owner
+		}
+	case wsOpSubscribe:
+		resp.Data, err = ws.subscribe(client, req.Topic, req.Params)
+	case wsOpUnsubscribe:
+		client.unsubscribe(req.Topic)
+	default:
+		err = fmt.Errorf("unsupported op:%s", req.Op)
+	}
+	if nil != err {
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
+func (ws *WebsocketServiceImpl) subscribe(client *SocketClient, topic string, params json.RawMessage) (interface{}, error) {
+	invokeInfo, ok := EventTypeRoute[topic]
+	if !ok {
+		return nil, fmt.Errorf("unsupported topic:%s", topic)
+	}
+	if len(params) == 0 {
+		params = json.RawMessage("{}")
+	}
+	if !invokeInfo.isBroadcast {
+		query := &SingleOwner{}
+		if err := json.Unmarshal(params, query); nil != err {
+			return nil, err
+		}
+		if !client.isOwner(query.Owner) {
+			return nil, errors.New("owner must be authenticated")
+		}
+	}
+
+	//subscribe before taking the snapshot of depth, the deltas not greater than its sequence should be ignored by client
+	key := ""
+	if eventKeyDepth == topic {
+		query := &DepthQuery{}
+		if err := json.Unmarshal(params, query); nil != err {
+			return nil, err
+		}
+		key = depthKey(common.HexToAddress(query.DelegateAddress), query.Market)
+	}
+	client.subscribe(topic, params, key)
+	return ws.invoke(topic, params)
+}
+
+func (ws *WebsocketServiceImpl) invokeByRoute(topic string, params json.RawMessage) (interface{}, error) {
+	invokeInfo := EventTypeRoute[topic]
+	return invokeWalletService(ws.walletService, invokeInfo.Query, invokeInfo.MethodName, params)
+}
+
+//pushTopic invokes once for each distinct params of the topic
+func (ws *WebsocketServiceImpl) pushTopic(topic string) {
+	results := make(map[string]*WebsocketResponse)
+	ws.node.each(func(client *SocketClient) {
+		params, ok := client.subscription(topic)
+		if !ok {
+			return
+		}
+		resp, exists := results[string(params)]
+		if !exists {
+			resp = &WebsocketResponse{Op: wsOpPush, Topic: topic}
+			if data, err := ws.invoke(topic, params); nil != err {
+				resp.Error = err.Error()
+			} else {
+				resp.Data = data
+			}
+			results[string(params)] = resp
+		}
+		client.push(resp)
+	})
+}
+
+func (ws *WebsocketServiceImpl) pushDepthDelta(input eventemitter.EventData) error {
+	evt := input.(*types.DepthDeltaEvent)
+	key := strings.ToLower(evt.DelegateAddress) + "_" + strings.ToLower(evt.Market)
+	resp := &WebsocketResponse{Op: wsOpPush, Topic: eventKeyDepthDelta, Data: evt}
+	ws.node.each(func(client *SocketClient) {
+		if client.depthSubscribed(key) {
+			client.push(resp)
+		}
+	})
+	return nil
+}
+
+func (ws *WebsocketServiceImpl) authenticate(params json.RawMessage) (string, error) {
+	auth := &WebsocketAuth{}
+	if err := json.Unmarshal(params, auth); nil != err {
+		return "", err
+	}
+	if nil == ws.walletService || nil == ws.walletService.sessions {
+		return "", errors.New("session is not supported")
+	}
+	return ws.walletService.sessions.Owner(auth.Token)
+}
@@ -0,0 +1,217 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"encoding/json"
+	"github.com/Loopring/relay/cache"
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/crypto"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/types"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	wsTestDelegate = "0x17233e07c67d086464fD408148c3ABB56245FA64"
+	wsTestMarket   = "LRC-WETH"
+)
+
+var wsTestOnce sync.Once
+
+type wsTestClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func newWsTestService() (*WebsocketServiceImpl, *httptest.Server) {
+	wsTestOnce.Do(func() {
+		log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+		crypto.Initialize(crypto.NewKSCrypto(true, nil))
+	})
+
+	ws := NewWebsocketService("", nil)
+	ws.invoke = func(topic string, params json.RawMessage) (interface{}, error) {
+		return topic + ":" + string(params), nil
+	}
+	return ws, httptest.NewServer(http.HandlerFunc(ws.serve))
+}
+
+func dialWs(t *testing.T, server *httptest.Server) *wsTestClient {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	return &wsTestClient{t: t, conn: conn}
+}
+
+func (c *wsTestClient) request(req WebsocketRequest) WebsocketResponse {
+	if err := c.conn.WriteJSON(req); nil != err {
+		c.t.Fatalf("err:%s", err.Error())
+	}
+	return c.next()
+}
+
+func (c *wsTestClient) next() WebsocketResponse {
+	resp := WebsocketResponse{}
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := c.conn.ReadJSON(&resp); nil != err {
+		c.t.Fatalf("err:%s", err.Error())
+	}
+	return resp
+}
+
+func wsAuthParams(token string) json.RawMessage {
+	params, _ := json.Marshal(WebsocketAuth{Token: token})
+	return params
+}
+
+func TestWebsocketService_Subscribe(t *testing.T) {
+	ws, server := newWsTestService()
+	defer server.Close()
+	client := dialWs(t, server)
+	defer client.conn.Close()
+
+	if resp := client.request(WebsocketRequest{Id: 1, Op: wsOpPing}); resp.Id != 1 || resp.Op != wsOpPong {
+		t.Fatalf("ping should be answered with pong, got:%+v", resp)
+	}
+	if resp := client.request(WebsocketRequest{Id: 2, Op: wsOpSubscribe, Topic: "unknown"}); "" == resp.Error {
+		t.Fatalf("unknown topic should be rejected")
+	}
+
+	params := json.RawMessage(`{"market":"LRC-WETH"}`)
+	resp := client.request(WebsocketRequest{Id: 3, Op: wsOpSubscribe, Topic: eventKeyTickers, Params: params})
+	if "" != resp.Error || resp.Data != eventKeyTickers+":"+string(params) {
+		t.Fatalf("subscribe should return the current data, got:%+v", resp)
+	}
+
+	ws.pushTopic(eventKeyTickers)
+	ws.pushTopic(eventKeyTrends)
+	if resp := client.next(); resp.Op != wsOpPush || resp.Topic != eventKeyTickers {
+		t.Fatalf("only the subscribed topic should be pushed, got:%+v", resp)
+	}
+
+	client.request(WebsocketRequest{Id: 4, Op: wsOpUnsubscribe, Topic: eventKeyTickers})
+	ws.pushTopic(eventKeyTickers)
+	if resp := client.request(WebsocketRequest{Id: 5, Op: wsOpPing}); resp.Id != 5 {
+		t.Fatalf("nothing should be pushed after unsubscribe, got:%+v", resp)
+	}
+}
+
+func TestWebsocketService_Auth(t *testing.T) {
+	ws, server := newWsTestService()
+	defer server.Close()
+	client := dialWs(t, server)
+	defer client.conn.Close()
+
+	if resp := client.request(WebsocketRequest{Id: 1, Op: wsOpAuth, Params: wsAuthParams("0x01")}); "" == resp.Error {
+		t.Fatalf("auth should fail without sessions")
+	}
+
+	cache.NewCache(config.RedisOptions{Engine: "memory"})
+	sessions := NewSessionManager(config.SessionOptions{ChallengeTtl: 60, TokenTtl: 60})
+	ws.walletService = &WalletServiceImpl{sessions: sessions}
+	signer := newSessionSigner(t, "0x4c5496d2745fe9cc2e0aa3e1aad2b66cc792a716decf707ddb7f92bd2fd5f1d0")
+	challenge, err := sessions.NewChallenge(signer.Address().Hex())
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	session, err := sessions.CreateSession(SessionRequest{Owner: signer.Address().Hex(), Challenge: challenge.Challenge, Sig: signChallenge(t, signer, challenge.Challenge)})
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	balanceParams := json.RawMessage(`{"owner":"` + session.Owner + `"}`)
+
+	if resp := client.request(WebsocketRequest{Id: 2, Op: wsOpSubscribe, Topic: eventKeyBalance, Params: balanceParams}); "" == resp.Error {
+		t.Fatalf("balance should not be subscribed before auth")
+	}
+	//the signature of owner is not accepted any more, only the session token
+	if resp := client.request(WebsocketRequest{Id: 3, Op: wsOpAuth, Params: json.RawMessage(`{"owner":"` + session.Owner + `","timestamp":1520000000,"sig":"0x01"}`)}); "" == resp.Error {
+		t.Fatalf("auth without token should be rejected")
+	}
+	if resp := client.request(WebsocketRequest{Id: 4, Op: wsOpAuth, Params: wsAuthParams("0x01")}); "" == resp.Error {
+		t.Fatalf("unknown token should be rejected")
+	}
+
+	if resp := client.request(WebsocketRequest{Id: 5, Op: wsOpAuth, Params: wsAuthParams(session.Token)}); "" != resp.Error || resp.Data != session.Owner {
+		t.Fatalf("auth failed, got:%+v", resp)
+	}
+	otherParams := json.RawMessage(`{"owner":"0xb1018949b241d76a1ab2094f473e9befeabb5ead"}`)
+	if resp := client.request(WebsocketRequest{Id: 6, Op: wsOpSubscribe, Topic: eventKeyPortfolio, Params: otherParams}); "" == resp.Error {
+		t.Fatalf("topic of other owner should be rejected")
+	}
+	if resp := client.request(WebsocketRequest{Id: 7, Op: wsOpSubscribe, Topic: eventKeyBalance, Params: balanceParams}); "" != resp.Error {
+		t.Fatalf("err:%s", resp.Error)
+	}
+}
+
+func TestWebsocketService_DepthDelta(t *testing.T) {
+	ws, server := newWsTestService()
+	defer server.Close()
+	subscriber := dialWs(t, server)
+	defer subscriber.conn.Close()
+	other := dialWs(t, server)
+	defer other.conn.Close()
+
+	subscriber.request(WebsocketRequest{Id: 1, Op: wsOpSubscribe, Topic: eventKeyDepth, Params: json.RawMessage(`{"delegateAddress":"` + wsTestDelegate + `","market":"` + wsTestMarket + `"}`)})
+	other.request(WebsocketRequest{Id: 1, Op: wsOpSubscribe, Topic: eventKeyDepth, Params: json.RawMessage(`{"delegateAddress":"` + wsTestDelegate + `","market":"RDN-WETH"}`)})
+
+	ws.pushDepthDelta(&types.DepthDeltaEvent{DelegateAddress: wsTestDelegate, Market: wsTestMarket, Sequence: 7})
+	resp := subscriber.next()
+	if resp.Op != wsOpPush || resp.Topic != eventKeyDepthDelta {
+		t.Fatalf("delta should be pushed to the subscriber of the market, got:%+v", resp)
+	}
+	if delta := resp.Data.(map[string]interface{}); delta["sequence"].(float64) != 7 {
+		t.Fatalf("sequence should be 7, got:%v", delta["sequence"])
+	}
+	if resp := other.request(WebsocketRequest{Id: 2, Op: wsOpPing}); resp.Id != 2 {
+		t.Fatalf("delta of other market should not be pushed, got:%+v", resp)
+	}
+}
+
+func TestWebsocketService_SlowClient(t *testing.T) {
+	ws, server := newWsTestService()
+	defer server.Close()
+	client := dialWs(t, server)
+	defer client.conn.Close()
+
+	client.request(WebsocketRequest{Id: 1, Op: wsOpSubscribe, Topic: eventKeyTickers, Params: json.RawMessage(`{"market":"LRC-WETH"}`)})
+	payload := strings.Repeat("0", 64*1024)
+	ws.invoke = func(topic string, params json.RawMessage) (interface{}, error) {
+		return payload, nil
+	}
+	//the client does not read, the pushes fill its send buffer and it should be closed
+	for i := 0; i < 100*wsSendBufferSize && ws.node.count() > 0; i++ {
+		ws.pushTopic(eventKeyTickers)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for ws.node.count() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ws.node.count() > 0 {
+		t.Fatalf("slow client should be closed")
+	}
+}
@@ -583,14 +583,14 @@ func (accountManager *AccountManager) Start() {
 	blockEndWatcher := &eventemitter.Watcher{Concurrent: false, Handle: accountManager.handleBlockEnd}
 	blockNewWatcher := &eventemitter.Watcher{Concurrent: false, Handle: accountManager.handleBlockNew}
 	ethTransferWatcher := &eventemitter.Watcher{Concurrent: false, Handle: accountManager.handleEthTransfer}
-	eventemitter.On(eventemitter.Transfer, transferWatcher)
-	eventemitter.On(eventemitter.Approve, approveWatcher)
-	eventemitter.On(eventemitter.EthTransferEvent, ethTransferWatcher)
-	eventemitter.On(eventemitter.Block_End, blockEndWatcher)
-	eventemitter.On(eventemitter.Block_New, blockNewWatcher)
-	eventemitter.On(eventemitter.WethDeposit, wethDepositWatcher)
-	eventemitter.On(eventemitter.WethWithdrawal, wethWithdrawalWatcher)
-	eventemitter.On(eventemitter.ChainForkDetected, blockForkWatcher)
+	eventemitter.OnDurable(eventemitter.Transfer, "accountmanager", transferWatcher)
+	eventemitter.OnDurable(eventemitter.Approve, "accountmanager", approveWatcher)
+	eventemitter.OnDurable(eventemitter.EthTransferEvent, "accountmanager", ethTransferWatcher)
+	eventemitter.OnDurable(eventemitter.Block_End, "accountmanager", blockEndWatcher)
+	eventemitter.OnDurable(eventemitter.Block_New, "accountmanager", blockNewWatcher)
+	eventemitter.OnDurable(eventemitter.WethDeposit, "accountmanager", wethDepositWatcher)
+	eventemitter.OnDurable(eventemitter.WethWithdrawal, "accountmanager", wethWithdrawalWatcher)
+	eventemitter.OnDurable(eventemitter.ChainForkDetected, "accountmanager", blockForkWatcher)
 
 }
 
@@ -0,0 +1,206 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/market/util"
+)
+
+/**
+交易所 ticker 的适配器：
+1、每个交易所实现 ExchangeAdapter，并在 init 中通过 RegisterExchangeAdapter 注册
+2、配置文件 market.exchanges 选择启用的交易所，及其 url、同步间隔、限流和重试次数
+3、代币在交易所的符号由 token 文件的 ExchangeSymbols 配置，如 WETH 在交易所中是 ETH
+增加交易所只需要实现适配器并增加配置
+*/
+
+const (
+	defaultExchangeInterval   = 20 // seconds
+	defaultExchangeRateLimit  = 5  // requests per second
+	defaultExchangeRetryCount = 2
+	defaultExchangeTimeout    = 10 // seconds
+	defaultExchangeSymbolKey  = "default"
+)
+
+//ExchangeAdapter fetches tickers from one exchange, the markets of relay are in the form of LRC-WETH
+type ExchangeAdapter interface {
+	Name() string
+	//FetchTickers returns the tickers of markets listed by exchange, the others are ignored
+	FetchTickers(markets []string) ([]Ticker, error)
+}
+
+//ExchangeAdapterCreator creates adapter with the client of configured url, rate limit and retry
+type ExchangeAdapterCreator func(client *ExchangeClient) ExchangeAdapter
+
+type exchangeRegistration struct {
+	defaultUrl string
+	creator    ExchangeAdapterCreator
+}
+
+var (
+	exchangeRegistryMtx sync.RWMutex
+	exchangeRegistry    = make(map[string]exchangeRegistration)
+)
+
+//RegisterExchangeAdapter registers the adapter of exchange, defaultUrl is used if base url isn't configured
+func RegisterExchangeAdapter(name, defaultUrl string, creator ExchangeAdapterCreator) {
+	exchangeRegistryMtx.Lock()
+	defer exchangeRegistryMtx.Unlock()
+	exchangeRegistry[name] = exchangeRegistration{defaultUrl: defaultUrl, creator: creator}
+}
+
+func NewExchangeAdapter(options config.ExchangeOptions) (ExchangeAdapter, error) {
+	exchangeRegistryMtx.RLock()
+	registration, exists := exchangeRegistry[options.Name]
+	exchangeRegistryMtx.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("exchange:%s isn't registered", options.Name)
+	}
+	if "" == options.BaseUrl {
+		options.BaseUrl = registration.defaultUrl
+	}
+	return registration.creator(NewExchangeClient(options)), nil
+}
+
+//defaultExchanges is used if there isn't any exchange in config
+func defaultExchanges() []config.ExchangeOptions {
+	return []config.ExchangeOptions{
+		{Name: binance, Interval: 20},
+		{Name: okex, Interval: 5},
+		{Name: huobi, Interval: 5},
+	}
+}
+
+//ExchangeClient requests the api of exchange with rate limit and retry
+type ExchangeClient struct {
+	name       string
+	baseUrl    string
+	retryCount int
+	httpClient *http.Client
+
+	mtx         sync.Mutex
+	minInterval time.Duration
+	lastRequest time.Time
+}
+
+func NewExchangeClient(options config.ExchangeOptions) *ExchangeClient {
+	client := &ExchangeClient{name: options.Name, baseUrl: strings.TrimRight(options.BaseUrl, "/")}
+	client.retryCount = options.RetryCount
+	if client.retryCount <= 0 {
+		client.retryCount = defaultExchangeRetryCount
+	}
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = defaultExchangeTimeout
+	}
+	client.httpClient = &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	rateLimit := options.RateLimit
+	if rateLimit <= 0 {
+		rateLimit = defaultExchangeRateLimit
+	}
+	client.minInterval = time.Duration(float64(time.Second) / rateLimit)
+	return client
+}
+
+//Symbol returns the symbol of token listed by exchange
+func (c *ExchangeClient) Symbol(symbol string) string {
+	symbol = strings.ToUpper(symbol)
+	if token, exists := util.AllTokens[symbol]; exists {
+		if s, ok := token.ExchangeSymbols[c.name]; ok {
+			return s
+		}
+		if s, ok := token.ExchangeSymbols[defaultExchangeSymbolKey]; ok {
+			return s
+		}
+	}
+	return symbol
+}
+
+//Pairs maps the symbol of markets on exchange to markets, pair builds the symbol with the symbols of tokens
+func (c *ExchangeClient) Pairs(markets []string, pair func(s, b string) string) map[string]string {
+	pairs := make(map[string]string)
+	for _, mkt := range markets {
+		s, b := util.UnWrap(mkt)
+		if "" == s || "" == b {
+			continue
+		}
+		pairs[pair(c.Symbol(s), c.Symbol(b))] = mkt
+	}
+	return pairs
+}
+
+//wait blocks until the next request is allowed by rate limit
+func (c *ExchangeClient) wait() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if next := c.lastRequest.Add(c.minInterval); time.Now().Before(next) {
+		time.Sleep(time.Until(next))
+	}
+	c.lastRequest = time.Now()
+}
+
+//Get requests baseUrl + path and decodes the json response into result, it retries when the request fails
+func (c *ExchangeClient) Get(path string, result interface{}) error {
+	var err error
+	for i := 0; i <= c.retryCount; i++ {
+		if i > 0 {
+			log.Debugf("exchange:%s, retry request:%s, err:%s", c.name, path, err.Error())
+		}
+		if err = c.get(path, result); nil == err {
+			return nil
+		}
+	}
+	return err
+}
+
+func (c *ExchangeClient) get(path string, result interface{}) error {
+	c.wait()
+	resp, err := c.httpClient.Get(c.baseUrl + path)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("exchange:%s, status:%d, body:%s", c.name, resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, result)
+}
+
+//formatChange formats the change of price in percent
+func formatChange(change float64) string {
+	if change > 0 {
+		return fmt.Sprintf("+%.2f%%", change)
+	}
+	return fmt.Sprintf("%.2f%%", change)
+}
@@ -0,0 +1,92 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package market
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const binanceUrl = "https://api.binance.com"
+
+func init() {
+	RegisterExchangeAdapter(binance, binanceUrl, func(client *ExchangeClient) ExchangeAdapter {
+		return &BinanceAdapter{client: client}
+	})
+}
+
+type BinanceTicker struct {
+	Symbol    string `json:"symbol"`
+	Change    string `json:"priceChangePercent"`
+	Close     string `json:"prevClosePrice"`
+	Open      string `json:"openPrice"`
+	High      string `json:"highPrice"`
+	Low       string `json:"lowPrice"`
+	LastPrice string `json:"lastPrice"`
+	Amount    string `json:"volume"`
+	Vol       string `json:"quoteVolume"`
+	Ask       string `json:"askPrice"`
+	Bid       string `json:"bidPrice"`
+}
+
+//BinanceAdapter gets the tickers of all markets by one request
+type BinanceAdapter struct {
+	client *ExchangeClient
+}
+
+func (a *BinanceAdapter) Name() string {
+	return binance
+}
+
+func (a *BinanceAdapter) FetchTickers(markets []string) ([]Ticker, error) {
+	pairs := a.client.Pairs(markets, func(s, b string) string {
+		return strings.ToUpper(s + b)
+	})
+
+	var binanceTickers []BinanceTicker
+	if err := a.client.Get("/api/v1/ticker/24hr", &binanceTickers); nil != err {
+		return nil, err
+	}
+	if len(binanceTickers) == 0 {
+		return nil, errors.New("fetch ticker from binance failed")
+	}
+
+	tickers := make([]Ticker, 0)
+	for _, binanceTicker := range binanceTickers {
+		mkt, exists := pairs[binanceTicker.Symbol]
+		if !exists {
+			continue
+		}
+		ticker := Ticker{}
+		ticker.Market = mkt
+		ticker.Amount, _ = strconv.ParseFloat(binanceTicker.Amount, 64)
+		ticker.Open, _ = strconv.ParseFloat(binanceTicker.Open, 64)
+		ticker.Close, _ = strconv.ParseFloat(binanceTicker.Close, 64)
+		ticker.Last, _ = strconv.ParseFloat(binanceTicker.LastPrice, 64)
+		change, _ := strconv.ParseFloat(binanceTicker.Change, 64)
+		ticker.Change = formatChange(change)
+		ticker.Exchange = binance
+		ticker.Vol, _ = strconv.ParseFloat(binanceTicker.Vol, 64)
+		ticker.High, _ = strconv.ParseFloat(binanceTicker.High, 64)
+		ticker.Low, _ = strconv.ParseFloat(binanceTicker.Low, 64)
+		tickers = append(tickers, ticker)
+	}
+	return tickers, nil
+}
@@ -0,0 +1,110 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package market
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Loopring/relay/log"
+)
+
+const huobiUrl = "https://api.huobi.pro"
+
+func init() {
+	RegisterExchangeAdapter(huobi, huobiUrl, func(client *ExchangeClient) ExchangeAdapter {
+		return &HuobiAdapter{client: client}
+	})
+}
+
+type HuobiTicker struct {
+	Timestamp int64            `json:"ts"`
+	ErrorCode string           `json:"err-code"`
+	Status    string           `json:"status"`
+	Tick      HuobiInnerTicker `json:"tick"`
+}
+
+type HuobiInnerTicker struct {
+	Close  float64   `json:"close"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Amount float64   `json:"amount"`
+	Count  int       `json:"count"`
+	Vol    float64   `json:"vol"`
+	Ask    []float64 `json:"ask"`
+	Bid    []float64 `json:"bid"`
+}
+
+//HuobiAdapter requests the ticker of each market, the requests are limited by the rate limit of client
+type HuobiAdapter struct {
+	client *ExchangeClient
+}
+
+func (a *HuobiAdapter) Name() string {
+	return huobi
+}
+
+func (a *HuobiAdapter) FetchTickers(markets []string) ([]Ticker, error) {
+	pairs := a.client.Pairs(markets, func(s, b string) string {
+		return strings.ToLower(s + b)
+	})
+
+	var err error
+	tickers := make([]Ticker, 0)
+	for pair, mkt := range pairs {
+		var ticker Ticker
+		if ticker, err = a.fetchTicker(pair, mkt); nil != err {
+			log.Debugf("exchange:huobi, can't get ticker of market:%s, err:%s", mkt, err.Error())
+		} else {
+			tickers = append(tickers, ticker)
+		}
+	}
+	if len(tickers) == 0 && nil != err {
+		return nil, err
+	}
+	return tickers, nil
+}
+
+func (a *HuobiAdapter) fetchTicker(pair, mkt string) (ticker Ticker, err error) {
+	var huobiTicker HuobiTicker
+	if err := a.client.Get("/market/detail/merged?symbol="+pair, &huobiTicker); nil != err {
+		return ticker, err
+	}
+	if huobiTicker.Status == "error" {
+		return ticker, errors.New("get ticker from huobi error" + huobiTicker.ErrorCode)
+	}
+
+	innerTicker := huobiTicker.Tick
+	ticker.Market = mkt
+	ticker.Amount = innerTicker.Amount
+	ticker.Open = innerTicker.Open
+	ticker.Close = innerTicker.Close
+	if len(innerTicker.Bid) > 0 {
+		ticker.Last = innerTicker.Bid[0]
+	}
+	if ticker.Open > 0 {
+		ticker.Change = formatChange(100 * (ticker.Last - ticker.Open) / ticker.Open)
+	}
+	ticker.Exchange = huobi
+	ticker.Vol = innerTicker.Vol
+	ticker.High = innerTicker.High
+	ticker.Low = innerTicker.Low
+	return ticker, nil
+}
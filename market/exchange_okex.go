@@ -0,0 +1,91 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package market
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const okexUrl = "https://www.okex.com"
+
+func init() {
+	RegisterExchangeAdapter(okex, okexUrl, func(client *ExchangeClient) ExchangeAdapter {
+		return &OkexAdapter{client: client}
+	})
+}
+
+type OkexFullTicker struct {
+	Code int              `json:"code"`
+	Data []OkexTickerElem `json:"data"`
+	Msg  string           `json:"msg"`
+}
+
+type OkexTickerElem struct {
+	Buy    string `json:"buy"`
+	Last   string `json:"last"`
+	Vol    string `json:"volume"`
+	Symbol string `json:"symbol"`
+	High   string `json:"high"`
+	Low    string `json:"low"`
+	Change string `json:"changePercentage"`
+}
+
+//OkexAdapter gets the tickers of all markets by one request
+type OkexAdapter struct {
+	client *ExchangeClient
+}
+
+func (a *OkexAdapter) Name() string {
+	return okex
+}
+
+func (a *OkexAdapter) FetchTickers(markets []string) ([]Ticker, error) {
+	pairs := a.client.Pairs(markets, func(s, b string) string {
+		return strings.ToLower(s + "_" + b)
+	})
+
+	var okexTickers OkexFullTicker
+	if err := a.client.Get("/v2/markets/tickers", &okexTickers); nil != err {
+		return nil, err
+	}
+	if okexTickers.Code != 0 {
+		return nil, fmt.Errorf("fetch ticker from okex failed, code:%d, msg:%s", okexTickers.Code, okexTickers.Msg)
+	}
+
+	tickers := make([]Ticker, 0)
+	for _, v := range okexTickers.Data {
+		mkt, exists := pairs[strings.ToLower(v.Symbol)]
+		if !exists {
+			continue
+		}
+		ticker := Ticker{}
+		ticker.Market = mkt
+		ticker.Last, _ = strconv.ParseFloat(v.Last, 64)
+		ticker.Change = v.Change
+		ticker.Exchange = okex
+		ticker.Amount, _ = strconv.ParseFloat(v.Vol, 64)
+		ticker.Vol = ticker.Amount * ticker.Last
+		ticker.High, _ = strconv.ParseFloat(v.High, 64)
+		ticker.Low, _ = strconv.ParseFloat(v.Low, 64)
+		tickers = append(tickers, ticker)
+	}
+	return tickers, nil
+}
@@ -0,0 +1,57 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package market
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/market/util"
+	gocache "github.com/patrickmn/go-cache"
+)
+
+//LatestFillPrices reads the latest fills saved by extractor, it doesn't subscribe any event or start any job like TrendManager,
+//so it can be used by the node without relay, the fills are saved only when a relay node shares the database with it
+type LatestFillPrices struct {
+	rds        dao.RdsService
+	localCache *gocache.Cache
+}
+
+func NewLatestFillPrices(rds dao.RdsService) *LatestFillPrices {
+	return &LatestFillPrices{rds: rds, localCache: gocache.New(5*time.Second, 5*time.Minute)}
+}
+
+//LatestFillPrice returns the price of the latest fill in market and the time it's filled
+func (p *LatestFillPrices) LatestFillPrice(mkt string) (price float64, createTime int64, err error) {
+	if fill, ok := p.localCache.Get(mkt); ok {
+		latest := fill.(dao.FillEvent)
+		return util.CalculatePrice(latest.AmountS, latest.AmountB, latest.TokenS, latest.TokenB), latest.CreateTime, nil
+	}
+
+	fills, err := p.rds.GetLatestFills(map[string]interface{}{"market": mkt}, 1)
+	if nil != err {
+		return 0, 0, err
+	}
+	if len(fills) == 0 {
+		return 0, 0, fmt.Errorf("no fill found in market:%s", mkt)
+	}
+	p.localCache.Set(mkt, fills[0], gocache.DefaultExpiration)
+	return util.CalculatePrice(fills[0].AmountS, fills[0].AmountB, fills[0].TokenS, fills[0].TokenB), fills[0].CreateTime, nil
+}
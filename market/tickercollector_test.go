@@ -1,13 +1,183 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
 package market_test
 
 import (
-	"fmt"
-	"github.com/Loopring/relay/market"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/Loopring/relay/cache"
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/market"
+	"github.com/Loopring/relay/market/util"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
 )
 
+//exchangeFixtures serves the responses of exchanges in testdata, the first request of binance fails to test retry
+type exchangeFixtures struct {
+	mtx      sync.Mutex
+	requests map[string]int
+	symbols  []string
+}
+
+func (f *exchangeFixtures) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	f.requests[r.URL.Path]++
+	count := f.requests[r.URL.Path]
+	f.mtx.Unlock()
+
+	file := ""
+	switch r.URL.Path {
+	case "/api/v1/ticker/24hr":
+		if count == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		file = "testdata/binance_tickers.json"
+	case "/v2/markets/tickers":
+		file = "testdata/okex_tickers.json"
+	case "/market/detail/merged":
+		symbol := r.URL.Query().Get("symbol")
+		f.mtx.Lock()
+		f.symbols = append(f.symbols, symbol)
+		f.mtx.Unlock()
+		if "lrceth" == symbol || "rdnxeth" == symbol {
+			file = "testdata/huobi_ticker.json"
+		} else {
+			file = "testdata/huobi_error.json"
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	data, _ := ioutil.ReadFile(file)
+	w.Write(data)
+}
+
+func prepareExchanges() (*exchangeFixtures, *httptest.Server) {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+	cache.NewCache(config.RedisOptions{Engine: "memory"})
+	decimals := big.NewInt(1e18)
+	util.AllTokens = map[string]types.Token{
+		"WETH": {Protocol: common.HexToAddress("0x2956356cd2a2bf3202f771f50d3d14a367b48070"), Symbol: "WETH", Decimals: decimals, IsMarket: true, ExchangeSymbols: map[string]string{"default": "ETH"}},
+		"LRC":  {Protocol: common.HexToAddress("0xef68e7c694f40c8202821edf525de3782458639f"), Symbol: "LRC", Decimals: decimals},
+		"RDN":  {Protocol: common.HexToAddress("0x255aa6df07540cb5d3d297f0d0d4d84cb52bc8e6"), Symbol: "RDN", Decimals: decimals, ExchangeSymbols: map[string]string{"huobi": "RDNX"}},
+	}
+	util.AllMarkets = []string{"LRC-WETH", "RDN-WETH"}
+
+	fixtures := &exchangeFixtures{requests: make(map[string]int)}
+	return fixtures, httptest.NewServer(fixtures)
+}
+
+func exchangeOptions(url string) []config.ExchangeOptions {
+	return []config.ExchangeOptions{
+		{Name: "binance", BaseUrl: url, Interval: 3600, RateLimit: 100, RetryCount: 1},
+		{Name: "okex", BaseUrl: url, Interval: 3600, RateLimit: 100},
+		{Name: "huobi", BaseUrl: url, Interval: 3600, RateLimit: 100},
+	}
+}
+
+func TestExchangeAdapters(t *testing.T) {
+	fixtures, server := prepareExchanges()
+	defer server.Close()
+
+	expected := map[string]map[string]float64{
+		"binance": {"LRC-WETH": 0.00102, "RDN-WETH": 0.00301},
+		"okex":    {"LRC-WETH": 0.00103},
+		"huobi":   {"LRC-WETH": 0.00104, "RDN-WETH": 0.00104},
+	}
+	for _, options := range exchangeOptions(server.URL) {
+		adapter, err := market.NewExchangeAdapter(options)
+		if nil != err {
+			t.Fatalf("err:%s", err.Error())
+		}
+		tickers, err := adapter.FetchTickers(util.AllMarkets)
+		if nil != err {
+			t.Fatalf("exchange:%s, err:%s", options.Name, err.Error())
+		}
+		if len(tickers) != len(expected[options.Name]) {
+			t.Fatalf("exchange:%s, tickers:%v", options.Name, tickers)
+		}
+		for _, ticker := range tickers {
+			if ticker.Exchange != options.Name || ticker.Last != expected[options.Name][ticker.Market] {
+				t.Fatalf("exchange:%s, unexpected ticker:%v", options.Name, ticker)
+			}
+		}
+	}
+
+	// the failed request of binance is retried, huobi uses the symbol configured in token file
+	if fixtures.requests["/api/v1/ticker/24hr"] != 2 {
+		t.Fatalf("request of binance should be retried, requests:%d", fixtures.requests["/api/v1/ticker/24hr"])
+	}
+	sort.Strings(fixtures.symbols)
+	if len(fixtures.symbols) != 2 || fixtures.symbols[0] != "lrceth" || fixtures.symbols[1] != "rdnxeth" {
+		t.Fatalf("unexpected symbols requested from huobi:%v", fixtures.symbols)
+	}
+
+	if _, err := market.NewExchangeAdapter(config.ExchangeOptions{Name: "unknown"}); nil == err {
+		t.Fatalf("exchange not registered should be rejected")
+	}
+}
+
+func TestExchangeRateLimit(t *testing.T) {
+	_, server := prepareExchanges()
+	defer server.Close()
+
+	client := market.NewExchangeClient(config.ExchangeOptions{Name: "okex", BaseUrl: server.URL, RateLimit: 20})
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		var result market.OkexFullTicker
+		if err := client.Get("/v2/markets/tickers", &result); nil != err {
+			t.Fatalf("err:%s", err.Error())
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("5 requests should take at least 200ms at 20 requests per second, but took:%s", elapsed.String())
+	}
+}
+
 func TestCollectorImpl_GetTickers(t *testing.T) {
+	_, server := prepareExchanges()
+	defer server.Close()
 
-	fmt.Println(market.GetAllTickerFromBinance())
+	collector := market.NewCollector(true, exchangeOptions(server.URL)...)
+	collector.Start()
 
+	tickers, err := collector.GetTickers("LRC-WETH")
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if len(tickers) != 3 {
+		t.Fatalf("tickers of all exchanges should be returned, tickers:%v", tickers)
+	}
+	for _, ticker := range tickers {
+		if ticker.UpdatedAt <= 0 {
+			t.Fatalf("ticker of exchange:%s should have updated time", ticker.Exchange)
+		}
+	}
 }
@@ -19,6 +19,7 @@
 package market
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -73,6 +74,7 @@ type Ticker struct {
 	Buy       float64 `json:"buy"`
 	Sell      float64 `json:"sell"`
 	Change    string  `json:"change"`
+	UpdatedAt int64   `json:"updatedAt"`
 }
 
 type Cache struct {
@@ -118,8 +120,13 @@ func NewTrendManager(dao dao.RdsService, cronJobLock bool) TrendManager {
 		if cronJobLock {
 			trendManager.startScheduleUpdate()
 		}
-		fillOrderWatcher := &eventemitter.Watcher{Concurrent: false, Handle: trendManager.HandleOrderFilled}
-		eventemitter.On(eventemitter.OrderFilled, fillOrderWatcher)
+		//the fills dropped when the queue is full will be corrected by ProofRead
+		if _, err := eventemitter.Subscribe(context.Background(), eventemitter.OrderFilled, trendManager.HandleOrderFilled, eventemitter.SubscribeOptions{
+			Name:     "trendmanager",
+			Overflow: eventemitter.OverflowDropOldest,
+		}); nil != err {
+			log.Fatalf("trend manager can't subscribe fill event, err:%s", err.Error())
+		}
 
 	})
 
@@ -1035,13 +1042,12 @@ func ConvertUp(src dao.Trend) Trend {
 	}
 }
 
-func (t *TrendManager) HandleOrderFilled(input eventemitter.EventData) (err error) {
+func (t *TrendManager) HandleOrderFilled(event *types.OrderFilledEvent) (err error) {
 
 	log.Info("HandleOrderFilled invoked")
 
 	if t.cacheReady {
 
-		event := input.(*types.OrderFilledEvent)
 		if event.Status != types.TX_STATUS_SUCCESS {
 			return
 		}
@@ -75,13 +75,14 @@ func StartRefreshCron(option config.MarketOptions) {
 }
 
 type token struct {
-	Protocol string `json:"Protocol"`
-	Symbol   string `json:"Symbol"`
-	Source   string `json:"Source"`
-	Deny     bool   `json:"Deny"`
-	Decimals int    `json:"Decimals"`
-	IsMarket bool   `json:"IsMarket"`
-	IcoPrice string `json:"IcoPrice"`
+	Protocol        string            `json:"Protocol"`
+	Symbol          string            `json:"Symbol"`
+	Source          string            `json:"Source"`
+	Deny            bool              `json:"Deny"`
+	Decimals        int               `json:"Decimals"`
+	IsMarket        bool              `json:"IsMarket"`
+	IcoPrice        string            `json:"IcoPrice"`
+	ExchangeSymbols map[string]string `json:"ExchangeSymbols"`
 }
 
 func (t *token) convert() types.Token {
@@ -94,6 +95,7 @@ func (t *token) convert() types.Token {
 	dst.Decimals = new(big.Int)
 	dst.Decimals.SetString("1"+strings.Repeat("0", t.Decimals), 0)
 	dst.IsMarket = t.IsMarket
+	dst.ExchangeSymbols = t.ExchangeSymbols
 	if "" != t.IcoPrice {
 		dst.IcoPrice = new(big.Rat)
 		dst.IcoPrice.SetString(t.IcoPrice)
@@ -110,26 +112,54 @@ func getTokenAndMarketFromDB(tokenfile string) (
 	allTokenPairs []TokenPair,
 	symbolTokenMap map[common.Address]string) {
 
-	supportTokens = make(map[string]types.Token)
-	allTokens = make(map[string]types.Token)
-	supportMarkets = make(map[string]types.Token)
-	allMarkets = make([]string, 0)
-	allTokenPairs = make([]TokenPair, 0)
-	symbolTokenMap = make(map[common.Address]string)
+	list, err := readTokenFile(tokenfile)
+	if err != nil {
+		log.Fatalf("market util load tokens failed:%s", err.Error())
+	}
+	return buildTokenAndMarket(list)
+}
 
+//ReloadTokens reads the token file again, the current tokens are kept if it fails
+func ReloadTokens(options config.MarketOptions) error {
+	list, err := readTokenFile(options.TokenFile)
+	if err != nil {
+		return err
+	}
+	SupportTokens, SupportMarkets, AllTokens, AllMarkets, AllTokenPairs, SymbolTokenMap = buildTokenAndMarket(list)
+	log.Infof("market util,reloaded tokens:%d, markets:%d", len(AllTokens), len(AllMarkets))
+	return nil
+}
+
+func readTokenFile(tokenfile string) ([]token, error) {
 	var list []token
 	tokenfile = lgh_util.FindConfigFile(tokenfile)
 	fn, err := os.Open(tokenfile)
 	if err != nil {
-		log.Fatalf("market util load tokens failed:%s", err.Error())
+		return list, err
 	}
+	defer fn.Close()
 	bs, err := ioutil.ReadAll(fn)
 	if err != nil {
-		log.Fatalf("market util read tokens json file failed:%s", err.Error())
-	}
-	if err := json.Unmarshal(bs, &list); err != nil {
-		log.Fatalf("market util unmarshal tokens failed:%s", err.Error())
+		return list, err
 	}
+	err = json.Unmarshal(bs, &list)
+	return list, err
+}
+
+func buildTokenAndMarket(list []token) (
+	supportTokens map[string]types.Token,
+	supportMarkets map[string]types.Token,
+	allTokens map[string]types.Token,
+	allMarkets []string,
+	allTokenPairs []TokenPair,
+	symbolTokenMap map[common.Address]string) {
+
+	supportTokens = make(map[string]types.Token)
+	allTokens = make(map[string]types.Token)
+	supportMarkets = make(map[string]types.Token)
+	allMarkets = make([]string, 0)
+	allTokenPairs = make([]TokenPair, 0)
+	symbolTokenMap = make(map[common.Address]string)
 
 	for _, v := range list {
 		if v.Deny == false {
@@ -0,0 +1,250 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package marketcap
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/market/util"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/**
+CapProvider_Aggregate 汇总多个 PriceFeed 的价格：
+1、每个 feed 的价格超过 MaxAge 未更新视为过期，不参与计算
+2、以 WETH 计价的价格(交易所 ticker、链上成交)通过 WETH 的法币价格换算
+3、有新鲜价格的 feed 数少于 MinFeeds 时返回错误，不再使用默认价格，
+   WETH 只能由法币计价的 feed 提供，同样需要 MinFeeds 个 feed，但不超过配置的法币计价 feed 的数量
+4、median 取所有价格的中位数，weighted 按 feed 的 Weight 加权平均
+*/
+
+const (
+	Provider_CoinMarketCap = "coinmarketcap"
+	Provider_Aggregate     = "aggregate"
+
+	Aggregation_Median   = "median"
+	Aggregation_Weighted = "weighted"
+)
+
+type aggregatedFeed struct {
+	feed   PriceFeed
+	weight *big.Rat
+	maxAge int64
+}
+
+type weightedPrice struct {
+	price  *big.Rat
+	weight *big.Rat
+}
+
+type CapProvider_Aggregate struct {
+	currency    string
+	aggregation string
+	minFeeds    int
+	ethMinFeeds int
+	feeds       []*aggregatedFeed
+}
+
+//NewAggregateCapProvider uses the weight and max age of options.Feeds[i] for feeds[i]
+func NewAggregateCapProvider(options config.MarketCapOptions, feeds []PriceFeed) *CapProvider_Aggregate {
+	provider := &CapProvider_Aggregate{}
+	provider.currency = options.Currency
+	provider.aggregation = options.Aggregation
+	if Aggregation_Weighted != provider.aggregation {
+		provider.aggregation = Aggregation_Median
+	}
+	provider.minFeeds = options.MinFeeds
+	if provider.minFeeds <= 0 {
+		provider.minFeeds = 1
+	}
+	for idx, feed := range feeds {
+		f := &aggregatedFeed{feed: feed, weight: big.NewRat(1, 1)}
+		if idx < len(options.Feeds) {
+			if options.Feeds[idx].Weight > 0 {
+				f.weight.SetFloat64(options.Feeds[idx].Weight)
+			}
+			f.maxAge = options.Feeds[idx].MaxAge
+		}
+		provider.feeds = append(provider.feeds, f)
+	}
+
+	fiatFeeds := 0
+	for _, feed := range feeds {
+		if isQuotedByCurrency(feed) {
+			fiatFeeds++
+		}
+	}
+	provider.ethMinFeeds = provider.minFeeds
+	if fiatFeeds < provider.ethMinFeeds {
+		provider.ethMinFeeds = fiatFeeds
+	}
+	if provider.ethMinFeeds <= 0 {
+		provider.ethMinFeeds = 1
+	}
+	return provider
+}
+
+//isQuotedByCurrency returns whether the feed provides the price of WETH, the others quote the tokens by WETH
+func isQuotedByCurrency(feed PriceFeed) bool {
+	switch feed.Name() {
+	case PriceFeed_CoinMarketCap, PriceFeed_File:
+		return true
+	}
+	return false
+}
+
+func (p *CapProvider_Aggregate) Start() {
+	for _, f := range p.feeds {
+		f.feed.Start()
+	}
+}
+
+func (p *CapProvider_Aggregate) Stop() {
+	for _, f := range p.feeds {
+		f.feed.Stop()
+	}
+}
+
+func (p *CapProvider_Aggregate) LegalCurrencyValue(tokenAddress common.Address, amount *big.Rat) (*big.Rat, error) {
+	return p.LegalCurrencyValueByCurrency(tokenAddress, amount, p.currency)
+}
+
+func (p *CapProvider_Aggregate) LegalCurrencyValueOfEth(amount *big.Rat) (*big.Rat, error) {
+	return p.LegalCurrencyValueByCurrency(util.WethTokenAddress(), amount, p.currency)
+}
+
+func (p *CapProvider_Aggregate) LegalCurrencyValueByCurrency(tokenAddress common.Address, amount *big.Rat, currencyStr string) (*big.Rat, error) {
+	token, err := util.AddressToToken(tokenAddress)
+	if nil != err {
+		return nil, err
+	}
+	price, err := p.GetMarketCapByCurrency(tokenAddress, currencyStr)
+	if nil != err {
+		return nil, err
+	}
+	v := new(big.Rat).SetInt(token.Decimals)
+	v.Quo(amount, v)
+	return v.Mul(price, v), nil
+}
+
+func (p *CapProvider_Aggregate) GetMarketCap(tokenAddress common.Address) (*big.Rat, error) {
+	return p.GetMarketCapByCurrency(tokenAddress, p.currency)
+}
+
+func (p *CapProvider_Aggregate) GetEthCap() (*big.Rat, error) {
+	return p.GetMarketCapByCurrency(util.WethTokenAddress(), p.currency)
+}
+
+func (p *CapProvider_Aggregate) GetMarketCapByCurrency(tokenAddress common.Address, currencyStr string) (*big.Rat, error) {
+	currency := strings.ToUpper(currencyStr)
+	prices, pricesByWeth := p.freshPrices(tokenAddress, currency)
+
+	if len(pricesByWeth) > 0 {
+		ethPrices, _ := p.freshPrices(util.WethTokenAddress(), currency)
+		if ethPrice, err := p.aggregatePrices(util.WethTokenAddress(), currency, ethPrices, p.ethMinFeeds); nil != err {
+			log.Debugf("marketcap, can't convert the prices of token:%s quoted by WETH, err:%s", tokenAddress.Hex(), err.Error())
+		} else {
+			for feed, feedPrices := range pricesByWeth {
+				for _, price := range feedPrices {
+					price.price.Mul(price.price, ethPrice)
+				}
+				prices[feed] = append(prices[feed], feedPrices...)
+			}
+		}
+	}
+
+	minFeeds := p.minFeeds
+	if tokenAddress == util.WethTokenAddress() {
+		minFeeds = p.ethMinFeeds
+	}
+	return p.aggregatePrices(tokenAddress, currency, prices, minFeeds)
+}
+
+//freshPrices returns the prices quoted by currency and the prices quoted by WETH, they are grouped by feed
+func (p *CapProvider_Aggregate) freshPrices(tokenAddress common.Address, currency string) (map[PriceFeed][]*weightedPrice, map[PriceFeed][]*weightedPrice) {
+	now := time.Now().Unix()
+	prices := make(map[PriceFeed][]*weightedPrice)
+	pricesByWeth := make(map[PriceFeed][]*weightedPrice)
+	for _, f := range p.feeds {
+		feedPrices, err := f.feed.Prices(tokenAddress, currency)
+		if nil != err {
+			log.Debugf("marketcap, feed:%s has no price of token:%s, err:%s", f.feed.Name(), tokenAddress.Hex(), err.Error())
+			continue
+		}
+		for _, feedPrice := range feedPrices {
+			if f.maxAge > 0 && feedPrice.UpdatedAt+f.maxAge < now {
+				log.Debugf("marketcap, the price of token:%s from feed:%s is stale, updated at:%d", tokenAddress.Hex(), f.feed.Name(), feedPrice.UpdatedAt)
+				continue
+			}
+			if nil == feedPrice.Price || feedPrice.Price.Sign() <= 0 {
+				continue
+			}
+			price := &weightedPrice{price: new(big.Rat).Set(feedPrice.Price), weight: f.weight}
+			switch strings.ToUpper(feedPrice.Quote) {
+			case currency:
+				prices[f.feed] = append(prices[f.feed], price)
+			case QuoteWeth:
+				pricesByWeth[f.feed] = append(pricesByWeth[f.feed], price)
+			}
+		}
+	}
+	return prices, pricesByWeth
+}
+
+func (p *CapProvider_Aggregate) aggregatePrices(tokenAddress common.Address, currency string, prices map[PriceFeed][]*weightedPrice, minFeeds int) (*big.Rat, error) {
+	if len(prices) < minFeeds {
+		return nil, fmt.Errorf("only %d feeds have fresh price of token:%s in %s, at least %d are required", len(prices), tokenAddress.Hex(), currency, minFeeds)
+	}
+	all := []*weightedPrice{}
+	for _, feedPrices := range prices {
+		all = append(all, feedPrices...)
+	}
+	if Aggregation_Weighted == p.aggregation {
+		return weightedMean(all), nil
+	}
+	return median(all), nil
+}
+
+func median(prices []*weightedPrice) *big.Rat {
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].price.Cmp(prices[j].price) < 0
+	})
+	mid := len(prices) / 2
+	if len(prices)%2 == 1 {
+		return new(big.Rat).Set(prices[mid].price)
+	}
+	v := new(big.Rat).Add(prices[mid-1].price, prices[mid].price)
+	return v.Quo(v, big.NewRat(2, 1))
+}
+
+func weightedMean(prices []*weightedPrice) *big.Rat {
+	sum := new(big.Rat)
+	weights := new(big.Rat)
+	for _, price := range prices {
+		sum.Add(sum, new(big.Rat).Mul(price.price, price.weight))
+		weights.Add(weights, price.weight)
+	}
+	return sum.Quo(sum, weights)
+}
@@ -0,0 +1,204 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package marketcap_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/market"
+	"github.com/Loopring/relay/market/util"
+	"github.com/Loopring/relay/marketcap"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+var (
+	wethAddress = common.HexToAddress("0x2956356cd2a2bf3202f771f50d3d14a367b48070")
+	lrcAddress  = common.HexToAddress("0xef68e7c694f40c8202821edf525de3782458639f")
+)
+
+type tickerSource map[string][]market.Ticker
+
+func (s tickerSource) GetTickers(mkt string) ([]market.Ticker, error) {
+	return s[mkt], nil
+}
+
+type fillSource struct {
+	price      float64
+	createTime int64
+}
+
+func (s *fillSource) LatestFillPrice(mkt string) (float64, int64, error) {
+	if "LRC-WETH" != mkt {
+		return 0, 0, fmt.Errorf("no fill found in market:%s", mkt)
+	}
+	return s.price, s.createTime, nil
+}
+
+func prepareAggregate(t *testing.T, prices string) (*marketcap.FileFeed, string) {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+	util.AllTokens = map[string]types.Token{
+		"WETH": {Protocol: wethAddress, Symbol: "WETH", Decimals: big.NewInt(1e18)},
+		"LRC":  {Protocol: lrcAddress, Symbol: "LRC", Decimals: big.NewInt(1e18)},
+	}
+
+	file, err := ioutil.TempFile("", "prices")
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	defer file.Close()
+	file.WriteString(prices)
+	feed, err := marketcap.NewFileFeed(file.Name())
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	return feed, file.Name()
+}
+
+func equalPrice(price *big.Rat, expected float64) bool {
+	v, _ := price.Float64()
+	return math.Abs(v-expected) < 1e-9
+}
+
+func TestAggregateCapProvider(t *testing.T) {
+	now := time.Now().Unix()
+	fileFeed, file := prepareAggregate(t, fmt.Sprintf(`[{"symbol":"WETH","price_usd":"500","last_updated":"%d"},{"symbol":"LRC","price_usd":"0.6"}]`, now))
+	defer os.Remove(file)
+	tickers := tickerSource{"LRC-WETH": {
+		{Market: "LRC-WETH", Exchange: "binance", Last: 0.001, UpdatedAt: now},
+		{Market: "LRC-WETH", Exchange: "okex", Last: 0.0012, UpdatedAt: now - 10},
+		{Market: "LRC-WETH", Exchange: "huobi", Last: 0.01, UpdatedAt: now - 3600},
+	}}
+	fills := &fillSource{price: 0.0014, createTime: now - 100}
+
+	options := config.MarketCapOptions{
+		Currency:    "USD",
+		Aggregation: marketcap.Aggregation_Median,
+		MinFeeds:    3,
+		Feeds: []config.PriceFeedOptions{
+			{Type: marketcap.PriceFeed_File, Weight: 2, MaxAge: 600},
+			{Type: marketcap.PriceFeed_Exchange, Weight: 1, MaxAge: 600},
+			{Type: marketcap.PriceFeed_Fill, Weight: 2, MaxAge: 600},
+		},
+	}
+	feeds := []marketcap.PriceFeed{fileFeed, marketcap.NewExchangeFeed(tickers), marketcap.NewFillFeed(fills)}
+
+	//the stale ticker of huobi is ignored, median of 0.6, 0.5, 0.6 and 0.7
+	provider := marketcap.NewAggregateCapProvider(options, feeds)
+	if price, err := provider.GetMarketCap(lrcAddress); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	} else if !equalPrice(price, 0.6) {
+		t.Fatalf("median price should be 0.6, but got:%s", price.FloatString(6))
+	}
+	if value, err := provider.LegalCurrencyValue(lrcAddress, new(big.Rat).SetInt64(2e18)); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	} else if !equalPrice(value, 1.2) {
+		t.Fatalf("legal value should be 1.2, but got:%s", value.FloatString(6))
+	}
+	if price, err := provider.GetEthCap(); nil != err || !equalPrice(price, 500) {
+		t.Fatalf("eth price should be 500, price:%v, err:%v", price, err)
+	}
+
+	//(0.6*2 + 0.5 + 0.6 + 0.7*2) / 6
+	options.Aggregation = marketcap.Aggregation_Weighted
+	provider = marketcap.NewAggregateCapProvider(options, feeds)
+	if price, err := provider.GetMarketCap(lrcAddress); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	} else if !equalPrice(price, 3.7/6) {
+		t.Fatalf("weighted price should be %f, but got:%s", 3.7/6, price.FloatString(6))
+	}
+
+	//the prices are rejected instead of a default price
+	options.MinFeeds = 4
+	provider = marketcap.NewAggregateCapProvider(options, feeds)
+	if price, err := provider.GetMarketCap(lrcAddress); nil == err {
+		t.Fatalf("price should be rejected when fresh feeds are less than min feeds, but got:%s", price.FloatString(6))
+	}
+	if _, err := provider.GetMarketCap(common.HexToAddress("0x1")); nil == err {
+		t.Fatalf("price of unknown token should be rejected")
+	}
+	if _, err := provider.GetMarketCapByCurrency(lrcAddress, "CNY"); nil == err {
+		t.Fatalf("price of currency not in feeds should be rejected")
+	}
+}
+
+func TestAggregateCapProviderWithoutEthPrice(t *testing.T) {
+	now := time.Now().Unix()
+	fileFeed, file := prepareAggregate(t, fmt.Sprintf(`[{"symbol":"WETH","price_usd":"500","last_updated":"%d"}]`, now-3600))
+	defer os.Remove(file)
+	tickers := tickerSource{"LRC-WETH": {{Market: "LRC-WETH", Exchange: "binance", Last: 0.001, UpdatedAt: now}}}
+
+	options := config.MarketCapOptions{
+		Currency: "USD",
+		Feeds: []config.PriceFeedOptions{
+			{Type: marketcap.PriceFeed_File, MaxAge: 600},
+			{Type: marketcap.PriceFeed_Exchange, MaxAge: 600},
+		},
+	}
+	provider := marketcap.NewAggregateCapProvider(options, []marketcap.PriceFeed{fileFeed, marketcap.NewExchangeFeed(tickers)})
+
+	//the price of WETH is stale, the tickers can't be converted
+	if _, err := provider.GetEthCap(); nil == err {
+		t.Fatalf("stale price of eth should be rejected")
+	}
+	if _, err := provider.LegalCurrencyValue(lrcAddress, big.NewRat(1, 1)); nil == err {
+		t.Fatalf("price quoted by WETH can't be used without the price of WETH")
+	}
+}
+
+func TestAggregateCapProviderEthMinFeeds(t *testing.T) {
+	now := time.Now().Unix()
+	freshFeed, freshFile := prepareAggregate(t, fmt.Sprintf(`[{"symbol":"WETH","price_usd":"500","last_updated":"%d"}]`, now))
+	defer os.Remove(freshFile)
+	staleFeed, staleFile := prepareAggregate(t, fmt.Sprintf(`[{"symbol":"WETH","price_usd":"400","last_updated":"%d"}]`, now-3600))
+	defer os.Remove(staleFile)
+	tickers := tickerSource{"LRC-WETH": {{Market: "LRC-WETH", Exchange: "binance", Last: 0.001, UpdatedAt: now}}}
+
+	options := config.MarketCapOptions{
+		Currency: "USD",
+		MinFeeds: 3,
+		Feeds: []config.PriceFeedOptions{
+			{Type: marketcap.PriceFeed_File, MaxAge: 600},
+			{Type: marketcap.PriceFeed_File, MaxAge: 600},
+			{Type: marketcap.PriceFeed_Exchange, MaxAge: 600},
+		},
+	}
+
+	//two feeds quoted by currency are configured, so WETH requires both of them
+	provider := marketcap.NewAggregateCapProvider(options, []marketcap.PriceFeed{freshFeed, staleFeed, marketcap.NewExchangeFeed(tickers)})
+	if price, err := provider.GetEthCap(); nil == err {
+		t.Fatalf("price of eth should be rejected when fresh feeds are less than min feeds, but got:%s", price.FloatString(6))
+	}
+
+	//only one feed quoted by currency is configured
+	options.Feeds = options.Feeds[1:]
+	provider = marketcap.NewAggregateCapProvider(options, []marketcap.PriceFeed{freshFeed, marketcap.NewExchangeFeed(tickers)})
+	if price, err := provider.GetEthCap(); nil != err || !equalPrice(price, 500) {
+		t.Fatalf("eth price should be 500, price:%v, err:%v", price, err)
+	}
+}
@@ -219,7 +219,10 @@ func (p *CapProvider_CoinMarketCap) LegalCurrencyValueByCurrency(tokenAddress co
 
 		// lgh: 下面的函数是去获取 tokenAddress 代币相对于 currencyStr = USD 的汇率。
 		// lgh: currencyStr 在配置文件中设置，默认是 USD。结果返回的就是，‘一个代币 = 多少USD’
-		price, _ := p.GetMarketCapByCurrency(tokenAddress, currencyStr)
+		price, err := p.GetMarketCapByCurrency(tokenAddress, currencyStr)
+		if nil != err {
+			return nil, err
+		}
 
 		v.Mul(price, v) // lgh: 数量乘上汇率，得出真实的价格，单位基于 currencyStr 即是 USD
 		return v, nil
@@ -250,22 +253,33 @@ func (p *CapProvider_CoinMarketCap) GetMarketCapByCurrency(tokenAddress common.A
 		}
 		if "VITE" == c.Symbol || "ARP" == c.Symbol {
 			// VITE 或 ARP 的就转为 WETH
-			wethCap, _ := p.GetMarketCapByCurrency(util.AllTokens["WETH"].Protocol, currencyStr)
+			wethCap, err := p.GetMarketCapByCurrency(util.AllTokens["WETH"].Protocol, currencyStr)
+			if nil != err {
+				return nil, err
+			}
 			v = wethCap.Mul(wethCap, util.AllTokens[c.Symbol].IcoPrice) // 又进行了一次稀释，乘上 IcoPrice
 		}
-		if v == nil {
-			return nil, errors.New("tokenCap is nil")
+		//the price missing in response is unmarshaled as zero
+		if v == nil || v.Sign() <= 0 {
+			return nil, fmt.Errorf("tokenCap of token:%s in %s is nil", c.Symbol, currencyStr)
 		} else {
 			return new(big.Rat).Set(v), nil // lgh: 返回汇率，例如一个 BTC = 7621.63 USD
 		}
 	} else {
-		err := errors.New("not found tokenCap:" + tokenAddress.Hex())
-		res := new(big.Rat).SetInt64(int64(1))
-		if nil != err {
-			log.Errorf("get MarketCap of token:%s, occurs error:%s. the value will be default value:%s", tokenAddress.Hex(), err.Error(), res.String())
-		}
-		return res, err
+		return nil, errors.New("not found tokenCap:" + tokenAddress.Hex())
+	}
+}
+
+//lastUpdated returns the time of price synced, VITE and ARP are priced by WETH
+func (p *CapProvider_CoinMarketCap) lastUpdated(tokenAddress common.Address) int64 {
+	c, exists := p.tokenMarketCaps[tokenAddress]
+	if !exists {
+		return 0
 	}
+	if "VITE" == c.Symbol || "ARP" == c.Symbol {
+		return p.lastUpdated(util.AllTokens["WETH"].Protocol)
+	}
+	return c.LastUpdated
 }
 
 func (p *CapProvider_CoinMarketCap) Stop() {
@@ -345,7 +359,20 @@ func (p *CapProvider_CoinMarketCap) syncMarketCap() error {
 }
 
 func NewMarketCapProvider(options config.MarketCapOptions) *CapProvider_CoinMarketCap {
+	provider := newCoinMarketCap(options)
+
+	// lgh: 这里进入货币价格，市值等数据的获取，会覆盖更新 之前从文件中的部分代币市值信息
+	if err := provider.syncMarketCap(); nil != err {
+		log.Fatalf("can't sync marketcap with error:%s", err.Error())
+	}
+
+	return provider
+}
+
+//newCoinMarketCap doesn't sync prices, they are synced by Start
+func newCoinMarketCap(options config.MarketCapOptions) *CapProvider_CoinMarketCap {
 	provider := &CapProvider_CoinMarketCap{}
+	provider.stopChan = make(chan bool)
 	provider.baseUrl = options.BaseUrl
 	provider.currency = options.Currency
 	provider.tokenMarketCaps = make(map[common.Address]*types.CurrencyMarketCap)
@@ -376,11 +403,76 @@ func NewMarketCapProvider(options config.MarketCapOptions) *CapProvider_CoinMark
 			provider.idToAddress[strings.ToUpper(c.Id)] = c.Address
 		}
 	}
+	return provider
+}
 
-	// lgh: 这里进入货币价格，市值等数据的获取，会覆盖更新 之前从文件中的部分代币市值信息
-	if err := provider.syncMarketCap(); nil != err {
-		log.Fatalf("can't sync marketcap with error:%s", err.Error())
-	}
+//CapProvider_Fixed never syncs prices, it is used to simulate rings with history orders
+type CapProvider_Fixed struct {
+	currency        string
+	ethAddress      common.Address
+	tokenMarketCaps map[common.Address]*types.CurrencyMarketCap
+}
 
+//NewFixedCapProvider uses PriceUsd, PriceCny and PriceBtc of caps, the cap of ethAddress is used as eth
+func NewFixedCapProvider(currency string, ethAddress common.Address, caps []*types.CurrencyMarketCap) *CapProvider_Fixed {
+	provider := &CapProvider_Fixed{}
+	provider.currency = currency
+	provider.ethAddress = ethAddress
+	provider.tokenMarketCaps = make(map[common.Address]*types.CurrencyMarketCap)
+	for _, c := range caps {
+		provider.tokenMarketCaps[c.Address] = c
+	}
 	return provider
 }
+
+func (p *CapProvider_Fixed) Start() {}
+
+func (p *CapProvider_Fixed) Stop() {}
+
+func (p *CapProvider_Fixed) LegalCurrencyValue(tokenAddress common.Address, amount *big.Rat) (*big.Rat, error) {
+	return p.LegalCurrencyValueByCurrency(tokenAddress, amount, p.currency)
+}
+
+func (p *CapProvider_Fixed) LegalCurrencyValueOfEth(amount *big.Rat) (*big.Rat, error) {
+	return p.LegalCurrencyValueByCurrency(p.ethAddress, amount, p.currency)
+}
+
+func (p *CapProvider_Fixed) LegalCurrencyValueByCurrency(tokenAddress common.Address, amount *big.Rat, currencyStr string) (*big.Rat, error) {
+	if c, exists := p.tokenMarketCaps[tokenAddress]; !exists {
+		return nil, errors.New("not found tokenCap:" + tokenAddress.Hex())
+	} else if price, err := p.GetMarketCapByCurrency(tokenAddress, currencyStr); nil != err {
+		return nil, err
+	} else {
+		v := new(big.Rat).SetInt(c.Decimals)
+		v.Quo(amount, v)
+		return v.Mul(price, v), nil
+	}
+}
+
+func (p *CapProvider_Fixed) GetMarketCap(tokenAddress common.Address) (*big.Rat, error) {
+	return p.GetMarketCapByCurrency(tokenAddress, p.currency)
+}
+
+func (p *CapProvider_Fixed) GetEthCap() (*big.Rat, error) {
+	return p.GetMarketCapByCurrency(p.ethAddress, p.currency)
+}
+
+func (p *CapProvider_Fixed) GetMarketCapByCurrency(tokenAddress common.Address, currencyStr string) (*big.Rat, error) {
+	if c, exists := p.tokenMarketCaps[tokenAddress]; !exists {
+		return nil, errors.New("not found tokenCap:" + tokenAddress.Hex())
+	} else {
+		var v *big.Rat
+		switch StringToLegalCurrency(currencyStr) {
+		case CNY:
+			v = c.PriceCny
+		case USD:
+			v = c.PriceUsd
+		case BTC:
+			v = c.PriceBtc
+		}
+		if nil == v {
+			return nil, errors.New("tokenCap is nil")
+		}
+		return new(big.Rat).Set(v), nil
+	}
+}
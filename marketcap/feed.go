@@ -0,0 +1,301 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package marketcap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/market"
+	"github.com/Loopring/relay/market/util"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	PriceFeed_CoinMarketCap = "coinmarketcap"
+	PriceFeed_Exchange      = "exchange"
+	PriceFeed_Fill          = "fill"
+	PriceFeed_File          = "file"
+
+	//the prices of exchange and fill feeds are quoted by WETH
+	QuoteWeth = "WETH"
+)
+
+//FeedPrice is the price of one token quoted by Quote, that is the legal currency or WETH
+type FeedPrice struct {
+	Price     *big.Rat
+	Quote     string
+	UpdatedAt int64
+}
+
+type PriceFeed interface {
+	Name() string
+	Start()
+	Stop()
+	//Prices returns the prices of token, they may be quoted by WETH instead of currency
+	Prices(tokenAddress common.Address, currency string) ([]*FeedPrice, error)
+}
+
+//TickerSource is the tickers of exchanges, it's implemented by market.CollectorImpl
+type TickerSource interface {
+	GetTickers(market string) ([]market.Ticker, error)
+}
+
+//FillPriceSource is the price of the latest fill, it's implemented by market.LatestFillPrices
+type FillPriceSource interface {
+	LatestFillPrice(market string) (price float64, createTime int64, err error)
+}
+
+//NewPriceFeeds creates the feeds of options.Feeds in order, tickers and fills are only used by exchange and fill feeds
+func NewPriceFeeds(options config.MarketCapOptions, tickers TickerSource, fills FillPriceSource) []PriceFeed {
+	feeds := []PriceFeed{}
+	for _, feedOptions := range options.Feeds {
+		switch feedOptions.Type {
+		case PriceFeed_CoinMarketCap:
+			feeds = append(feeds, NewCoinMarketCapFeed(options))
+		case PriceFeed_Exchange:
+			feeds = append(feeds, NewExchangeFeed(tickers))
+		case PriceFeed_Fill:
+			feeds = append(feeds, NewFillFeed(fills))
+		case PriceFeed_File:
+			if feed, err := NewFileFeed(feedOptions.File); nil != err {
+				log.Fatalf("can't load price file:%s, err:%s", feedOptions.File, err.Error())
+			} else {
+				feeds = append(feeds, feed)
+			}
+		default:
+			log.Fatalf("unsupported price feed:%s", feedOptions.Type)
+		}
+	}
+	return feeds
+}
+
+//wethMarket returns the market of token quoted by WETH
+func wethMarket(tokenAddress common.Address) (string, error) {
+	token, err := util.AddressToToken(tokenAddress)
+	if nil != err {
+		return "", err
+	}
+	if QuoteWeth == token.Symbol {
+		return "", errors.New("WETH isn't quoted by itself")
+	}
+	return token.Symbol + "-" + QuoteWeth, nil
+}
+
+//CoinMarketCapFeed syncs prices from coinmarketcap, the provider won't exit when it can't sync at the beginning
+type CoinMarketCapFeed struct {
+	provider *CapProvider_CoinMarketCap
+}
+
+func NewCoinMarketCapFeed(options config.MarketCapOptions) *CoinMarketCapFeed {
+	return &CoinMarketCapFeed{provider: newCoinMarketCap(options)}
+}
+
+func (feed *CoinMarketCapFeed) Name() string {
+	return PriceFeed_CoinMarketCap
+}
+
+func (feed *CoinMarketCapFeed) Start() {
+	if err := feed.provider.syncMarketCap(); nil != err {
+		log.Errorf("can't sync marketcap, err:%s", err.Error())
+	}
+	feed.provider.Start()
+}
+
+func (feed *CoinMarketCapFeed) Stop() {
+	feed.provider.Stop()
+}
+
+func (feed *CoinMarketCapFeed) Prices(tokenAddress common.Address, currency string) ([]*FeedPrice, error) {
+	price, err := feed.provider.GetMarketCapByCurrency(tokenAddress, currency)
+	if nil != err {
+		return nil, err
+	}
+	return []*FeedPrice{{Price: price, Quote: currency, UpdatedAt: feed.provider.lastUpdated(tokenAddress)}}, nil
+}
+
+//ExchangeFeed uses the last prices of the exchanges synced by ticker collector
+type ExchangeFeed struct {
+	tickers TickerSource
+}
+
+func NewExchangeFeed(tickers TickerSource) *ExchangeFeed {
+	return &ExchangeFeed{tickers: tickers}
+}
+
+func (feed *ExchangeFeed) Name() string {
+	return PriceFeed_Exchange
+}
+
+func (feed *ExchangeFeed) Start() {}
+
+func (feed *ExchangeFeed) Stop() {}
+
+func (feed *ExchangeFeed) Prices(tokenAddress common.Address, currency string) ([]*FeedPrice, error) {
+	mkt, err := wethMarket(tokenAddress)
+	if nil != err {
+		return nil, err
+	}
+	tickers, err := feed.tickers.GetTickers(mkt)
+	if nil != err {
+		return nil, err
+	}
+	prices := []*FeedPrice{}
+	for _, ticker := range tickers {
+		if ticker.Last <= 0 {
+			continue
+		}
+		prices = append(prices, &FeedPrice{Price: new(big.Rat).SetFloat64(ticker.Last), Quote: QuoteWeth, UpdatedAt: ticker.UpdatedAt})
+	}
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("no ticker of market:%s", mkt)
+	}
+	return prices, nil
+}
+
+//FillFeed uses the price of the latest fill on chain
+type FillFeed struct {
+	fills FillPriceSource
+}
+
+func NewFillFeed(fills FillPriceSource) *FillFeed {
+	return &FillFeed{fills: fills}
+}
+
+func (feed *FillFeed) Name() string {
+	return PriceFeed_Fill
+}
+
+func (feed *FillFeed) Start() {}
+
+func (feed *FillFeed) Stop() {}
+
+func (feed *FillFeed) Prices(tokenAddress common.Address, currency string) ([]*FeedPrice, error) {
+	mkt, err := wethMarket(tokenAddress)
+	if nil != err {
+		return nil, err
+	}
+	price, createTime, err := feed.fills.LatestFillPrice(mkt)
+	if nil != err {
+		return nil, err
+	}
+	if price <= 0 {
+		return nil, fmt.Errorf("invalid price of the latest fill in market:%s", mkt)
+	}
+	return []*FeedPrice{{Price: new(big.Rat).SetFloat64(price), Quote: QuoteWeth, UpdatedAt: createTime}}, nil
+}
+
+//FileFeed reads prices from a file of the format of coinmarketcap, the file is reloaded when it's modified,
+//the modified time of file is used as the updated time of prices without last_updated
+type FileFeed struct {
+	file    string
+	mtx     sync.RWMutex
+	modTime int64
+	caps    map[string]*types.CurrencyMarketCap
+}
+
+func NewFileFeed(file string) (*FileFeed, error) {
+	feed := &FileFeed{file: file}
+	if err := feed.reload(); nil != err {
+		return nil, err
+	}
+	return feed, nil
+}
+
+func (feed *FileFeed) Name() string {
+	return PriceFeed_File
+}
+
+func (feed *FileFeed) Start() {}
+
+func (feed *FileFeed) Stop() {}
+
+func (feed *FileFeed) reload() error {
+	info, err := os.Stat(feed.file)
+	if nil != err {
+		return err
+	}
+	feed.mtx.RLock()
+	modified := info.ModTime().Unix() != feed.modTime
+	feed.mtx.RUnlock()
+	if !modified {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(feed.file)
+	if nil != err {
+		return err
+	}
+	var list []*types.CurrencyMarketCap
+	if err := json.Unmarshal(data, &list); nil != err {
+		return err
+	}
+	caps := make(map[string]*types.CurrencyMarketCap)
+	for _, c := range list {
+		if c.LastUpdated <= 0 {
+			c.LastUpdated = info.ModTime().Unix()
+		}
+		caps[strings.ToUpper(c.Symbol)] = c
+	}
+
+	feed.mtx.Lock()
+	defer feed.mtx.Unlock()
+	feed.caps = caps
+	feed.modTime = info.ModTime().Unix()
+	return nil
+}
+
+func (feed *FileFeed) Prices(tokenAddress common.Address, currency string) ([]*FeedPrice, error) {
+	if err := feed.reload(); nil != err {
+		log.Errorf("can't reload price file:%s, err:%s", feed.file, err.Error())
+	}
+	token, err := util.AddressToToken(tokenAddress)
+	if nil != err {
+		return nil, err
+	}
+
+	feed.mtx.RLock()
+	defer feed.mtx.RUnlock()
+	c, exists := feed.caps[strings.ToUpper(token.Symbol)]
+	if !exists {
+		return nil, fmt.Errorf("price of token:%s isn't in file", token.Symbol)
+	}
+	var price *big.Rat
+	switch StringToLegalCurrency(currency) {
+	case CNY:
+		price = c.PriceCny
+	case USD:
+		price = c.PriceUsd
+	case BTC:
+		price = c.PriceBtc
+	}
+	if nil == price || price.Sign() <= 0 {
+		return nil, fmt.Errorf("price of token:%s in %s isn't in file", token.Symbol, currency)
+	}
+	return []*FeedPrice{{Price: new(big.Rat).Set(price), Quote: currency, UpdatedAt: c.LastUpdated}}, nil
+}
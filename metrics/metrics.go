@@ -0,0 +1,250 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+按照prometheus的text format(0.0.4)输出指标，只实现了relay用到的counter、gauge和不带分位数的summary，
+指标在各模块的包变量中定义，名称以relay_开头，重复的名称会在init时panic。
+label的值应该是有限的集合，例如market、地址、节点，不要使用订单hash等无限增长的值。
+*/
+
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+	typeSummary = "summary"
+)
+
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+type value struct {
+	labelValues []string
+	value       float64
+	sum         float64
+	count       uint64
+}
+
+type metric struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	collect func() []Sample
+
+	mtx    sync.Mutex
+	values map[string]*value
+}
+
+var (
+	registry    map[string]*metric
+	registryMtx sync.RWMutex
+)
+
+func register(name, help, typ string, labels []string, collect func() []Sample) *metric {
+	registryMtx.Lock()
+	defer registryMtx.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("metric:%s has been registered", name))
+	}
+	m := &metric{name: name, help: help, typ: typ, labels: labels, collect: collect, values: make(map[string]*value)}
+	registry[name] = m
+	return m
+}
+
+//valueOf must be called with mtx held
+func (m *metric) valueOf(labelValues []string) *value {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metric:%s requires %d label values, but got %d", m.name, len(m.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v, exists := m.values[key]
+	if !exists {
+		v = &value{labelValues: append([]string{}, labelValues...)}
+		m.values[key] = v
+	}
+	return v
+}
+
+type CounterVec struct {
+	m *metric
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{m: register(name, help, typeCounter, labels, nil)}
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+//Add ignores the negative delta, counter never decreases
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		return
+	}
+	c.m.mtx.Lock()
+	defer c.m.mtx.Unlock()
+	c.m.valueOf(labelValues).value += delta
+}
+
+type GaugeVec struct {
+	m *metric
+}
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{m: register(name, help, typeGauge, labels, nil)}
+}
+
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.m.mtx.Lock()
+	defer g.m.mtx.Unlock()
+	g.m.valueOf(labelValues).value = v
+}
+
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.m.mtx.Lock()
+	defer g.m.mtx.Unlock()
+	g.m.valueOf(labelValues).value += delta
+}
+
+//SummaryVec only exports the sum and count of observations
+type SummaryVec struct {
+	m *metric
+}
+
+func NewSummaryVec(name, help string, labels ...string) *SummaryVec {
+	return &SummaryVec{m: register(name, help, typeSummary, labels, nil)}
+}
+
+func (s *SummaryVec) Observe(v float64, labelValues ...string) {
+	s.m.mtx.Lock()
+	defer s.m.mtx.Unlock()
+	value := s.m.valueOf(labelValues)
+	value.sum += v
+	value.count++
+}
+
+//NewGaugeFunc registers a gauge whose samples are collected when it's scraped
+func NewGaugeFunc(name, help string, labels []string, collect func() []Sample) {
+	register(name, help, typeGauge, labels, collect)
+}
+
+//NewCounterFunc registers a counter whose samples are collected when it's scraped
+func NewCounterFunc(name, help string, labels []string, collect func() []Sample) {
+	register(name, help, typeCounter, labels, collect)
+}
+
+func (m *metric) samples() []*value {
+	res := []*value{}
+	if nil != m.collect {
+		for _, sample := range m.collect() {
+			if len(sample.LabelValues) == len(m.labels) {
+				res = append(res, &value{labelValues: sample.LabelValues, value: sample.Value})
+			}
+		}
+	} else {
+		m.mtx.Lock()
+		for _, v := range m.values {
+			copied := *v
+			res = append(res, &copied)
+		}
+		m.mtx.Unlock()
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return strings.Join(res[i].labelValues, "\xff") < strings.Join(res[j].labelValues, "\xff")
+	})
+	return res
+}
+
+//WriteTo writes all the registered metrics in text format
+func WriteTo(w io.Writer) error {
+	registryMtx.RLock()
+	metrics := []*metric{}
+	for _, m := range registry {
+		metrics = append(metrics, m)
+	}
+	registryMtx.RUnlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name < metrics[j].name })
+
+	writer := bufio.NewWriter(w)
+	for _, m := range metrics {
+		fmt.Fprintf(writer, "# HELP %s %s\n", m.name, escape(m.help, false))
+		fmt.Fprintf(writer, "# TYPE %s %s\n", m.name, m.typ)
+		for _, v := range m.samples() {
+			labels := m.formatLabels(v.labelValues)
+			if typeSummary == m.typ {
+				fmt.Fprintf(writer, "%s_sum%s %s\n", m.name, labels, formatFloat(v.sum))
+				fmt.Fprintf(writer, "%s_count%s %d\n", m.name, labels, v.count)
+			} else {
+				fmt.Fprintf(writer, "%s%s %s\n", m.name, labels, formatFloat(v.value))
+			}
+		}
+	}
+	return writer.Flush()
+}
+
+func (m *metric) formatLabels(labelValues []string) string {
+	if len(m.labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(m.labels))
+	for i, label := range m.labels {
+		pairs[i] = label + `="` + escape(labelValues[i], true) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(s string, quote bool) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	if quote {
+		s = strings.Replace(s, `"`, `\"`, -1)
+	}
+	return s
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func init() {
+	registry = make(map[string]*metric)
+}
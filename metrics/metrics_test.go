@@ -0,0 +1,67 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package metrics_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Loopring/relay/metrics"
+)
+
+func TestWriteTo(t *testing.T) {
+	counter := metrics.NewCounterVec("test_requests_total", "Requests of node.", "node")
+	counter.Inc(`http://127.0.0.1:8545`)
+	counter.Add(2, `http://127.0.0.1:8545`)
+	counter.Add(-1, `http://127.0.0.1:8545`)
+	counter.Inc(`a"b`)
+
+	gauge := metrics.NewGaugeVec("test_lag_blocks", "Lag of\nextractor.")
+	gauge.Set(3)
+
+	summary := metrics.NewSummaryVec("test_duration_seconds", "Duration.", "method")
+	summary.Observe(0.5, "eth_call")
+	summary.Observe(1, "eth_call")
+
+	metrics.NewGaugeFunc("test_queued", "Queued events.", []string{"topic"}, func() []metrics.Sample {
+		return []metrics.Sample{{LabelValues: []string{"OrderFilled"}, Value: 7}}
+	})
+
+	var buf bytes.Buffer
+	if err := metrics.WriteTo(&buf); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	output := buf.String()
+	for _, expected := range []string{
+		"# TYPE test_requests_total counter\n",
+		`test_requests_total{node="http://127.0.0.1:8545"} 3` + "\n",
+		`test_requests_total{node="a\"b"} 1` + "\n",
+		"# HELP test_lag_blocks Lag of\\nextractor.\n",
+		"test_lag_blocks 3\n",
+		"# TYPE test_duration_seconds summary\n",
+		`test_duration_seconds_sum{method="eth_call"} 1.5` + "\n",
+		`test_duration_seconds_count{method="eth_call"} 2` + "\n",
+		`test_queued{topic="OrderFilled"} 7` + "\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("output doesn't contain %q:\n%s", expected, output)
+		}
+	}
+}
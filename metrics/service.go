@@ -0,0 +1,68 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package metrics
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/log"
+)
+
+type MetricsService struct {
+	options config.MetricsOptions
+	mux     *http.ServeMux
+	server  *http.Server
+}
+
+func NewMetricsService(options config.MetricsOptions) *MetricsService {
+	s := &MetricsService{options: options}
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/metrics", Handle)
+	return s
+}
+
+//Handle serves the scrape request of prometheus
+func Handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := WriteTo(w); nil != err {
+		log.Errorf("metrics, write response err:%s", err.Error())
+	}
+}
+
+func (s *MetricsService) Start() {
+	if "" == s.options.Port {
+		return
+	}
+	listener, err := net.Listen("tcp", ":"+s.options.Port)
+	if nil != err {
+		log.Errorf("metrics, listen on port:%s err:%s", s.options.Port, err.Error())
+		return
+	}
+	s.server = &http.Server{Handler: s.mux}
+	go s.server.Serve(listener)
+	log.Infof("metrics endpoint opened on %s", s.options.Port)
+}
+
+func (s *MetricsService) Stop() {
+	if nil != s.server {
+		s.server.Close()
+	}
+}
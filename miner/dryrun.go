@@ -0,0 +1,132 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package miner
+
+import (
+	"math/big"
+
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/**
+提交前使用 eth_call 在 pending 块上预执行环，失败的环不再提交，避免浪费油费：
+1、revert 的原因记录在 RingSubmitInfo 的 err 中
+2、检查环中每个订单的 tokenS 余额、授权以及 cutoff，不满足的订单作为 OffendingOrders 通知 matcher，
+   matcher 只惩罚这些订单；找不到原因时，和执行失败一样惩罚环中的所有订单
+*/
+
+type dryRunError struct {
+	reason          string
+	offendingOrders []common.Hash
+}
+
+func (e *dryRunError) Error() string {
+	return "ring reverted in dry run:" + e.reason
+}
+
+//dryRun returns dryRunError if the ring will revert, the ring is submitted if the eth node can't be called
+func (submitter *RingSubmitter) dryRun(ringSubmitInfo *types.RingSubmitInfo) error {
+	reverted, reason, err := ethaccessor.DryRunTransaction(
+		ringSubmitInfo.Miner,
+		ringSubmitInfo.ProtocolAddress,
+		ringSubmitInfo.ProtocolGas,
+		ringSubmitInfo.ProtocolGasPrice,
+		ringSubmitInfo.ProtocolData,
+		"pending")
+	if nil != err {
+		log.Errorf("submitter, dry run ring:%s err:%s", ringSubmitInfo.Ringhash.Hex(), err.Error())
+		return nil
+	}
+	if !reverted {
+		return nil
+	}
+	e := &dryRunError{reason: reason, offendingOrders: submitter.offendingOrders(ringSubmitInfo)}
+	log.Infof("submitter, ring:%s reverted in dry run, reason:%s, offending orders:%d", ringSubmitInfo.Ringhash.Hex(), reason, len(e.offendingOrders))
+	return e
+}
+
+//offendingOrders finds the orders whose owner can't afford the fill or have been cut off
+func (submitter *RingSubmitter) offendingOrders(ringSubmitInfo *types.RingSubmitInfo) []common.Hash {
+	spender, err := ethaccessor.GetSpenderAddress(ringSubmitInfo.ProtocolAddress)
+	if nil != err {
+		log.Errorf("submitter, err:%s", err.Error())
+		return nil
+	}
+	orders := ringSubmitInfo.RawRing.Orders
+	reqs := []*ethaccessor.BatchErc20Req{}
+	for _, filledOrder := range orders {
+		order := filledOrder.OrderState.RawOrder
+		reqs = append(reqs, &ethaccessor.BatchErc20Req{Owner: order.Owner, Token: order.TokenS, Spender: spender, BlockParameter: "pending"})
+	}
+	if err := ethaccessor.BatchErc20BalanceAndAllowance("pending", reqs); nil != err {
+		log.Errorf("submitter, get balance of orders err:%s", err.Error())
+		return nil
+	}
+
+	offending := []common.Hash{}
+	for idx, filledOrder := range orders {
+		order := filledOrder.OrderState.RawOrder
+		req := reqs[idx]
+		cutoff, err := ethaccessor.GetCutoff(ringSubmitInfo.ProtocolAddress, order.Owner, "pending")
+		if nil != err {
+			cutoff = nil
+		}
+		var balance, allowance *big.Int
+		if nil == req.BalanceErr {
+			balance = req.Balance.BigInt()
+		}
+		if nil == req.AllowanceErr {
+			allowance = req.Allowance.BigInt()
+		}
+		if reason := offendingReason(filledOrder, balance, allowance, cutoff); "" != reason {
+			log.Debugf("submitter, order:%s is offending, %s", order.Hash.Hex(), reason)
+			offending = append(offending, order.Hash)
+		}
+	}
+	return offending
+}
+
+//offendingReason returns why the order makes the ring revert, the unknown values are ignored
+func offendingReason(filledOrder *types.FilledOrder, balance, allowance, cutoff *big.Int) string {
+	order := filledOrder.OrderState.RawOrder
+	if nil != cutoff && cutoff.Sign() > 0 && nil != order.ValidSince && order.ValidSince.Cmp(cutoff) <= 0 {
+		return "order has been cut off"
+	}
+	if nil == filledOrder.FillAmountS {
+		return ""
+	}
+	fillAmountS := new(big.Int).Quo(filledOrder.FillAmountS.Num(), filledOrder.FillAmountS.Denom())
+	if nil != balance && balance.Cmp(fillAmountS) < 0 {
+		return "balance of tokenS isn't enough"
+	}
+	if nil != allowance && allowance.Cmp(fillAmountS) < 0 {
+		return "allowance of tokenS isn't enough"
+	}
+	return ""
+}
+
+func dryRunOffendingOrders(err error) []common.Hash {
+	if e, ok := err.(*dryRunError); ok {
+		return e.offendingOrders
+	}
+	return nil
+}
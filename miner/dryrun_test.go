@@ -0,0 +1,60 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package miner
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestOffendingReason(t *testing.T) {
+	filledOrder := &types.FilledOrder{FillAmountS: big.NewRat(100, 1)}
+	filledOrder.OrderState.RawOrder.ValidSince = big.NewInt(1000)
+
+	if reason := offendingReason(filledOrder, big.NewInt(100), big.NewInt(100), big.NewInt(0)); "" != reason {
+		t.Fatalf("order isn't offending, but got:%s", reason)
+	}
+	if reason := offendingReason(filledOrder, nil, nil, nil); "" != reason {
+		t.Fatalf("unknown values should be ignored, but got:%s", reason)
+	}
+	if reason := offendingReason(filledOrder, big.NewInt(100), big.NewInt(100), big.NewInt(1000)); "order has been cut off" != reason {
+		t.Fatalf("order should be cut off, but got:%s", reason)
+	}
+	if reason := offendingReason(filledOrder, big.NewInt(99), big.NewInt(100), nil); "balance of tokenS isn't enough" != reason {
+		t.Fatalf("balance should be insufficient, but got:%s", reason)
+	}
+	if reason := offendingReason(filledOrder, big.NewInt(100), big.NewInt(99), nil); "allowance of tokenS isn't enough" != reason {
+		t.Fatalf("allowance should be insufficient, but got:%s", reason)
+	}
+}
+
+func TestDryRunOffendingOrders(t *testing.T) {
+	orderhash := common.HexToHash("0x01")
+	err := &dryRunError{reason: "execution reverted", offendingOrders: []common.Hash{orderhash}}
+	if orders := dryRunOffendingOrders(err); len(orders) != 1 || orders[0] != orderhash {
+		t.Fatalf("offending orders should be returned, but got:%v", orders)
+	}
+	if orders := dryRunOffendingOrders(errors.New("send failed")); nil != orders {
+		t.Fatalf("other errors have no offending orders")
+	}
+}
@@ -415,8 +415,7 @@ func (e *Evaluator) computeFeeOfRingAndOrder(ringState *types.Ring) error {
 			// 这里给用户的是 0 ， 就不需要从矿工账户扣
 		}
 	}
-	e.evaluateReceived(ringState)
-	return nil
+	return e.evaluateReceived(ringState)
 }
 
 //成环之后才可计算能否成交，否则不需计算，判断是否能够成交，不能使用除法计算
@@ -427,6 +426,24 @@ func PriceValid(a2BOrder *types.OrderState, b2AOrder *types.OrderState) bool {
 	return amountS.Cmp(amountB) >= 0
 }
 
+//RingPriceValid is PriceValid for a ring of any length, the orders must be in ring order
+func RingPriceValid(orders ...*types.OrderState) bool {
+	if len(orders) <= 1 {
+		return false
+	}
+	amountS := big.NewInt(int64(1))
+	amountB := big.NewInt(int64(1))
+	for idx, order := range orders {
+		next := orders[(idx+1)%len(orders)]
+		if order.RawOrder.TokenB != next.RawOrder.TokenS {
+			return false
+		}
+		amountS.Mul(amountS, order.RawOrder.AmountS)
+		amountB.Mul(amountB, order.RawOrder.AmountB)
+	}
+	return amountS.Cmp(amountB) >= 0
+}
+
 func PriceRateCVSquare(ringState *types.Ring) (*big.Int, error) {
 	rateRatios := []*big.Int{}
 	scale, _ := new(big.Int).SetString("10000", 0)
@@ -477,13 +494,26 @@ func CVSquare(rateRatios []*big.Int, scale *big.Int) *big.Int {
 	return cvs.Mul(cvs, scale).Div(cvs, avg).Mul(cvs, scale).Div(cvs, avg).Div(cvs, length1)
 }
 
+//LegalVolume is the legal currency value of all the tokens sold in the ring, ComputeRing must be called before it
+func (e *Evaluator) LegalVolume(ringState *types.Ring) (*big.Rat, error) {
+	volume := big.NewRat(int64(0), int64(1))
+	for _, filledOrder := range ringState.Orders {
+		if legalAmount, err := e.getLegalCurrency(filledOrder.OrderState.RawOrder.TokenS, filledOrder.FillAmountS); nil != err {
+			return nil, err
+		} else {
+			volume.Add(volume, legalAmount)
+		}
+	}
+	return volume, nil
+}
+
 func (e *Evaluator) getLegalCurrency(tokenAddress common.Address, amount *big.Rat) (*big.Rat, error) {
 	return e.marketCapProvider.LegalCurrencyValue(tokenAddress, amount)
 }
 
 // lgh: 貌似主要是计算给以太坊矿工的gas的多少
 // lgh: 从这里可以看出的算法基础是: 1,根据 ring 的环数; 2,gas 计算算法决定的 gas 最终的实际价格。此外而和其它无关
-func (e *Evaluator) evaluateReceived(ringState *types.Ring) {
+func (e *Evaluator) evaluateReceived(ringState *types.Ring) error {
 	ringState.Received = big.NewRat(int64(0), int64(1)) // 0/1 = 0
 	// lgh: 计算油费标准
 	ringState.GasPrice = ethaccessor.EstimateGasPrice(e.minGasPrice, e.maxGasPrice)
@@ -503,7 +533,11 @@ func (e *Evaluator) evaluateReceived(ringState *types.Ring) {
 	// ==> 5*10^14 < 5*10^5 * GasPrice < 5*10^16
 	// ==> costEth 目前基于默认的配置文件最大是 0.05 个ETH，最小是 5/10^4 ETH
 	// 下面获取 costEth 基于 eth 的情况下，价值多少 USD
-	ringState.LegalCost, _ = e.marketCapProvider.LegalCurrencyValueOfEth(costEth) // 当前环 gas 油费的实际价格
+	legalCost, err := e.marketCapProvider.LegalCurrencyValueOfEth(costEth) // 当前环 gas 油费的实际价格
+	if nil != err {
+		return err
+	}
+	ringState.LegalCost = legalCost
 	// 虽然 LegalCost 代表的是油费的价格，但它在加减乘除后的值不能代表是以太坊矿工最后的油费收益。
 	// LegalCost 在改变之前是 == 以太坊油费收益的
 
@@ -519,7 +553,7 @@ func (e *Evaluator) evaluateReceived(ringState *types.Ring) {
 
 	// walletSplit 由配置文件决定，walletSplit = 0.8，相当于 0.2 给了 wallet 钱包
 	ringState.Received.Mul(ringState.Received, e.walletSplit)
-	return
+	return nil
 }
 
 // lgh: 计算费用的实例
@@ -26,5 +26,11 @@ import (
 type Matcher interface {
 	Start()
 	Stop()
+
+	//Pause skips the matching rounds until Resume is called
+	Pause()
+	Resume()
+	IsPaused() bool
+
 	GetAccountAvailableAmount(address, tokenAddress, spender common.Address) (*big.Rat, error)
 }
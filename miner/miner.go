@@ -39,6 +39,18 @@ func (minerInstance *Miner) Stop() {
 	minerInstance.submitter.stop()
 }
 
+func (minerInstance *Miner) PauseMatcher() {
+	minerInstance.matcher.Pause()
+}
+
+func (minerInstance *Miner) ResumeMatcher() {
+	minerInstance.matcher.Resume()
+}
+
+func (minerInstance *Miner) IsMatcherPaused() bool {
+	return minerInstance.matcher.IsPaused()
+}
+
 func NewMiner(submitter *RingSubmitter, matcher Matcher, evaluator *Evaluator, marketCapProvider marketcap.MarketCapProvider) *Miner {
 	return &Miner{
 		marketCapProvider: marketCapProvider,
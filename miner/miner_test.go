@@ -1,3 +1,6 @@
+//go:build integration
+// +build integration
+
 /*
 
   Copyright 2017 Loopring Project Ltd (Loopring Foundation).
@@ -99,7 +102,7 @@ func TestMatch(t *testing.T) {
 	submitter, _ := miner.NewSubmitter(cfg.Miner, rdsService, marketCapProvider)
 	evaluator := miner.NewEvaluator(marketCapProvider, cfg.Miner)
 	rds := test.GenerateDaoService()
-	matcher := timing_matcher.NewTimingMatcher(cfg.Miner.TimingMatcher, submitter, evaluator, om, &accountManager, rds)
+	matcher := timing_matcher.NewTimingMatcher(cfg.Miner, submitter, evaluator, om, &accountManager, rds)
 	evaluator.SetMatcher(matcher)
 
 	m := miner.NewMiner(submitter, matcher, evaluator, marketCapProvider)
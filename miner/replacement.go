@@ -0,0 +1,332 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package miner
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/**
+卡住的交易的替换：
+1、提交的每个环交易按 sender 和 nonce 记录，收到 Block_New 时，latest nonce 已经超过的交易被认为已经打包
+2、超过 MaxPendingTtl 个块仍然 pending 的交易，使用相同的 nonce 提高 gasPrice 重新提交，type-2 交易的 tip 和 fee cap 分别提高，
+   提交时的 fee cap 只记录在 pendingTx 中，tip 在第一次替换时从节点查询 pending 的交易得到
+3、提高后的 gasPrice（type-2 交易为 fee cap）超过 GasPriceLimit 时，改为发送给自己的 0 ETH 交易取消该环，取消交易的 gasPrice 最多为 GasPriceLimit 的两倍
+4、每次替换都会在 RingSubmitInfo 中新增一条记录，replaced_tx_hash 指向被替换的交易，被替换的记录的 replaced_by 指向新交易
+5、取消交易被打包后，环的提交结果为失败；重启后从 RingSubmitInfo 中恢复 pending 的交易
+*/
+
+const (
+	ReplaceType_Resubmit = "resubmit"
+	ReplaceType_Cancel   = "cancel"
+
+	//geth rejects the replacement whose gas price isn't 10% higher than the pending one
+	gasPriceBumpPercent = 12
+	cancelGas           = 21000
+)
+
+var errRingCancelled = errors.New("ring tx has been cancelled because of pending too long")
+
+type pendingTx struct {
+	info      dao.RingSubmitInfo
+	sender    common.Address
+	nonce     uint64
+	gasPrice  *big.Int
+	tipCap    *big.Int //nil if it's unknown or the tx is legacy
+	feeCap    *big.Int //nil if it's unknown, it's the same as gasPrice if the tx is legacy
+	sentBlock *big.Int
+	cancelled bool
+}
+
+//txTracker tracks the pending ring txs by sender and nonce
+type txTracker struct {
+	mtx sync.Mutex
+	txs map[common.Address]map[uint64]*pendingTx
+}
+
+func newTxTracker() *txTracker {
+	return &txTracker{txs: make(map[common.Address]map[uint64]*pendingTx)}
+}
+
+func (tracker *txTracker) track(tx *pendingTx) {
+	tracker.mtx.Lock()
+	defer tracker.mtx.Unlock()
+
+	if _, exists := tracker.txs[tx.sender]; !exists {
+		tracker.txs[tx.sender] = make(map[uint64]*pendingTx)
+	}
+	tracker.txs[tx.sender][tx.nonce] = tx
+}
+
+func (tracker *txTracker) senders() []common.Address {
+	tracker.mtx.Lock()
+	defer tracker.mtx.Unlock()
+
+	senders := []common.Address{}
+	for sender := range tracker.txs {
+		senders = append(senders, sender)
+	}
+	return senders
+}
+
+//confirm removes and returns the txs whose nonce is lower than the latest nonce of sender
+func (tracker *txTracker) confirm(sender common.Address, latestNonce uint64) []*pendingTx {
+	tracker.mtx.Lock()
+	defer tracker.mtx.Unlock()
+
+	confirmed := []*pendingTx{}
+	for nonce, tx := range tracker.txs[sender] {
+		if nonce < latestNonce {
+			confirmed = append(confirmed, tx)
+			delete(tracker.txs[sender], nonce)
+		}
+	}
+	if len(tracker.txs[sender]) == 0 {
+		delete(tracker.txs, sender)
+	}
+	return confirmed
+}
+
+//stuck returns the txs of sender which have been pending for ttl blocks, ordered by nonce
+func (tracker *txTracker) stuck(sender common.Address, blockNumber *big.Int, ttl int) []*pendingTx {
+	tracker.mtx.Lock()
+	defer tracker.mtx.Unlock()
+
+	res := []*pendingTx{}
+	for _, tx := range tracker.txs[sender] {
+		//the new tracked tx starts from the next block
+		if nil == tx.sentBlock {
+			tx.sentBlock = new(big.Int).Set(blockNumber)
+			continue
+		}
+		if new(big.Int).Sub(blockNumber, tx.sentBlock).Cmp(big.NewInt(int64(ttl))) >= 0 {
+			res = append(res, tx)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].nonce < res[j].nonce })
+	return res
+}
+
+//replacementGasPrice returns the bumped gas price and whether the tx should be cancelled,
+//nil is returned if the tx can't be replaced any more
+func replacementGasPrice(gasPrice, limit *big.Int, cancelled bool) (*big.Int, bool) {
+	bumped := bumpGasPrice(gasPrice)
+	if nil == limit || limit.Sign() <= 0 {
+		return bumped, cancelled
+	}
+	if !cancelled && bumped.Cmp(limit) <= 0 {
+		return bumped, false
+	}
+	if bumped.Cmp(new(big.Int).Mul(limit, big.NewInt(2))) > 0 {
+		return nil, true
+	}
+	return bumped, true
+}
+
+func bumpGasPrice(gasPrice *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(gasPrice, big.NewInt(100+gasPriceBumpPercent))
+	bumped.Div(bumped, big.NewInt(100))
+	return bumped.Add(bumped, big.NewInt(1))
+}
+
+//replacementFee bumps the tip and the fee cap of type-2 tx separately, the tip isn't bumped if it's unknown
+func replacementFee(tipCap, feeCap *big.Int) (*big.Int, *big.Int) {
+	if nil == feeCap {
+		return nil, nil
+	}
+	bumpedFeeCap := bumpGasPrice(feeCap)
+	if nil == tipCap {
+		return nil, bumpedFeeCap
+	}
+	bumpedTipCap := bumpGasPrice(tipCap)
+	if bumpedTipCap.Cmp(bumpedFeeCap) > 0 {
+		bumpedTipCap.Set(bumpedFeeCap)
+	}
+	return bumpedTipCap, bumpedFeeCap
+}
+
+func (submitter *RingSubmitter) normalMiner(sender common.Address) *NormalSenderAddress {
+	for _, miner := range submitter.normalMinerAddresses {
+		if miner.Address == sender {
+			return miner
+		}
+	}
+	return nil
+}
+
+//trackTx tracks the sent tx, feeCap is nil if it's unknown
+func (submitter *RingSubmitter) trackTx(info *dao.RingSubmitInfo, gasPrice, feeCap, nonce *big.Int) {
+	if nil == nonce || nil == gasPrice {
+		return
+	}
+	tx := &pendingTx{
+		info:     *info,
+		sender:   common.HexToAddress(info.Miner),
+		nonce:    nonce.Uint64(),
+		gasPrice: new(big.Int).Set(gasPrice),
+	}
+	if nil != feeCap {
+		tx.feeCap = new(big.Int).Set(feeCap)
+	}
+	submitter.txTracker.track(tx)
+}
+
+//loadPendingTxs restores the pending txs of normal miners after restart
+func (submitter *RingSubmitter) loadPendingTxs() {
+	for _, miner := range submitter.normalMinerAddresses {
+		if miner.MaxPendingTtl <= 0 {
+			continue
+		}
+		infos, err := submitter.dbService.GetPendingRingSubmitInfos(miner.Address)
+		if nil != err {
+			log.Errorf("submitter, load pending txs of %s err:%s", miner.Address.Hex(), err.Error())
+			continue
+		}
+		for _, info := range infos {
+			gasPrice, _ := new(big.Int).SetString(info.ProtocolGasPrice, 0)
+			nonce, _ := new(big.Int).SetString(info.Nonce, 0)
+			submitter.trackTx(info, gasPrice, nil, nonce)
+		}
+	}
+}
+
+//rescueStuckTxs is called on every new block
+func (submitter *RingSubmitter) rescueStuckTxs(blockNumber *big.Int) {
+	for _, sender := range submitter.txTracker.senders() {
+		var latestNonce types.Big
+		if err := ethaccessor.GetTransactionCount(&latestNonce, sender, "latest"); nil != err {
+			log.Errorf("submitter, get nonce of %s err:%s", sender.Hex(), err.Error())
+			continue
+		}
+		for _, tx := range submitter.txTracker.confirm(sender, latestNonce.BigInt().Uint64()) {
+			if tx.cancelled {
+				submitter.checkCancelled(tx)
+			}
+		}
+
+		miner := submitter.normalMiner(sender)
+		if nil == miner || miner.MaxPendingTtl <= 0 {
+			continue
+		}
+		for _, tx := range submitter.txTracker.stuck(sender, blockNumber, miner.MaxPendingTtl) {
+			submitter.replaceTx(tx, miner.GasPriceLimit, blockNumber)
+		}
+	}
+}
+
+//loadFeeCaps gets the tip and the fee cap of the pending tx from eth node, they are kept unchanged if the tx can't be found
+func (submitter *RingSubmitter) loadFeeCaps(tx *pendingTx) {
+	var pending ethaccessor.Transaction
+	if err := ethaccessor.GetTransactionByHash(&pending, tx.info.ProtocolTxHash, "pending"); nil != err {
+		log.Errorf("submitter, get pending tx:%s err:%s", tx.info.ProtocolTxHash, err.Error())
+		return
+	}
+	if pending.MaxFeePerGas.BigInt().Sign() > 0 {
+		tx.tipCap = new(big.Int).Set(pending.MaxPriorityFeePerGas.BigInt())
+		tx.feeCap = new(big.Int).Set(pending.MaxFeePerGas.BigInt())
+	}
+}
+
+//checkCancelled fails the ring if the cancel tx is mined, otherwise the ring tx is mined and handled by extractor
+func (submitter *RingSubmitter) checkCancelled(tx *pendingTx) {
+	var receipt ethaccessor.TransactionReceipt
+	if err := ethaccessor.GetTransactionReceipt(&receipt, tx.info.ProtocolTxHash, "latest"); nil != err {
+		log.Errorf("submitter, get receipt of tx:%s err:%s", tx.info.ProtocolTxHash, err.Error())
+		return
+	}
+	if "" == receipt.TransactionHash {
+		return
+	}
+	submitter.submitResult(
+		common.HexToHash(tx.info.RingHash), common.HexToHash(tx.info.UniqueId), common.HexToHash(tx.info.ProtocolTxHash),
+		types.TX_STATUS_FAILED, big.NewInt(0), receipt.BlockNumber.BigInt(), receipt.GasUsed.BigInt(), errRingCancelled)
+}
+
+func (submitter *RingSubmitter) replaceTx(tx *pendingTx, gasPriceLimit *big.Int, blockNumber *big.Int) {
+	//wait another MaxPendingTtl blocks whatever the replacement succeeds or not
+	tx.sentBlock = new(big.Int).Set(blockNumber)
+
+	if nil == tx.tipCap {
+		submitter.loadFeeCaps(tx)
+	}
+	//the fee cap of type-2 tx is the highest price it may pay
+	price := tx.gasPrice
+	if nil != tx.feeCap {
+		price = tx.feeCap
+	}
+	bumped, cancel := replacementGasPrice(price, gasPriceLimit, tx.cancelled)
+	if nil == bumped {
+		log.Errorf("submitter, tx:%s of %s with nonce:%d is still pending, but the gas price can't be higher", tx.info.ProtocolTxHash, tx.sender.Hex(), tx.nonce)
+		return
+	}
+	gasPrice := bumpGasPrice(tx.gasPrice)
+	tipCap, feeCap := replacementFee(tx.tipCap, tx.feeCap)
+
+	replacement := tx.info
+	replacement.ID = 0
+	replacement.Status = int(types.TX_STATUS_PENDING)
+	replacement.ReplacedTxHash = tx.info.ProtocolTxHash
+	replacement.ReplacedBy = ""
+	replacement.Err = ""
+	replacement.ProtocolGasPrice = gasPrice.String()
+
+	to := common.HexToAddress(tx.info.ProtocolAddress)
+	gas, _ := new(big.Int).SetString(tx.info.ProtocolGas, 0)
+	data := common.FromHex(tx.info.ProtocolData)
+	replacement.ReplaceType = ReplaceType_Resubmit
+	if cancel {
+		to = tx.sender
+		gas = big.NewInt(cancelGas)
+		data = nil
+		replacement.ReplaceType = ReplaceType_Cancel
+		replacement.ProtocolGas = gas.String()
+	}
+
+	txHashStr, err := ethaccessor.ReplaceTransaction(tx.sender, to, gas, gasPrice, tipCap, feeCap, big.NewInt(0), data, new(big.Int).SetUint64(tx.nonce))
+	if nil != err {
+		log.Errorf("submitter, %s tx:%s with nonce:%d err:%s", replacement.ReplaceType, tx.info.ProtocolTxHash, tx.nonce, err.Error())
+		return
+	}
+	txHash := common.HexToHash(txHashStr)
+	replacement.ProtocolTxHash = txHash.Hex()
+	log.Infof("submitter, tx:%s of ring:%s with nonce:%d is replaced by %s, type:%s, gasPrice:%s", tx.info.ProtocolTxHash, tx.info.RingHash, tx.nonce, txHash.Hex(), replacement.ReplaceType, gasPrice.String())
+	submittedTxs.Inc(tx.sender.Hex(), replacement.ReplaceType)
+
+	if err := submitter.dbService.Add(&replacement); nil != err {
+		log.Errorf("submitter, insert replacement of tx:%s err:%s", tx.info.ProtocolTxHash, err.Error())
+	}
+	if err := submitter.dbService.UpdateRingSubmitInfoReplaced(common.HexToHash(tx.info.ProtocolTxHash), txHash); nil != err {
+		log.Errorf("submitter, update replaced tx:%s err:%s", tx.info.ProtocolTxHash, err.Error())
+	}
+
+	tx.info = replacement
+	tx.gasPrice = gasPrice
+	tx.tipCap = tipCap
+	tx.feeCap = feeCap
+	tx.cancelled = cancel
+}
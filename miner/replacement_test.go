@@ -0,0 +1,91 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package miner
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestTxTracker(t *testing.T) {
+	tracker := newTxTracker()
+	sender := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	for nonce := uint64(5); nonce < 8; nonce++ {
+		tracker.track(&pendingTx{sender: sender, nonce: nonce, gasPrice: big.NewInt(10e9)})
+	}
+
+	if stuck := tracker.stuck(sender, big.NewInt(100), 3); len(stuck) != 0 {
+		t.Fatalf("new tracked txs start from the next block, but got %d stuck txs", len(stuck))
+	}
+	if stuck := tracker.stuck(sender, big.NewInt(102), 3); len(stuck) != 0 {
+		t.Fatalf("txs haven't been pending for 3 blocks, but got %d stuck txs", len(stuck))
+	}
+
+	if confirmed := tracker.confirm(sender, 6); len(confirmed) != 1 || confirmed[0].nonce != 5 {
+		t.Fatalf("tx with nonce 5 should be confirmed, got:%d", len(confirmed))
+	}
+	stuck := tracker.stuck(sender, big.NewInt(103), 3)
+	if len(stuck) != 2 || stuck[0].nonce != 6 || stuck[1].nonce != 7 {
+		t.Fatalf("txs with nonce 6 and 7 should be stuck, got:%d", len(stuck))
+	}
+
+	tracker.confirm(sender, 8)
+	if senders := tracker.senders(); len(senders) != 0 {
+		t.Fatalf("all txs have been confirmed, but got %d senders", len(senders))
+	}
+}
+
+func TestReplacementGasPrice(t *testing.T) {
+	limit := big.NewInt(20e9)
+
+	gasPrice, cancel := replacementGasPrice(big.NewInt(10e9), limit, false)
+	if cancel || gasPrice.Cmp(big.NewInt(11200000001)) != 0 {
+		t.Fatalf("tx should be resubmitted with higher gas price, got:%s %t", gasPrice.String(), cancel)
+	}
+	if gasPrice, cancel = replacementGasPrice(big.NewInt(19e9), limit, false); !cancel || gasPrice.Cmp(big.NewInt(19e9)) <= 0 {
+		t.Fatalf("tx should be cancelled when gas price exceeds limit, got:%s %t", gasPrice.String(), cancel)
+	}
+	if gasPrice, cancel = replacementGasPrice(big.NewInt(30e9), limit, true); !cancel || nil == gasPrice {
+		t.Fatalf("cancel tx can be replaced under twice of limit")
+	}
+	if gasPrice, _ = replacementGasPrice(big.NewInt(39e9), limit, true); nil != gasPrice {
+		t.Fatalf("cancel tx can't be replaced over twice of limit, got:%s", gasPrice.String())
+	}
+	if gasPrice, cancel = replacementGasPrice(big.NewInt(100e9), nil, false); cancel || gasPrice.Cmp(big.NewInt(100e9)) <= 0 {
+		t.Fatalf("tx without gas price limit is always resubmitted")
+	}
+}
+
+func TestReplacementFee(t *testing.T) {
+	tipCap, feeCap := replacementFee(big.NewInt(2e9), big.NewInt(30e9))
+	if tipCap.Cmp(big.NewInt(2240000001)) != 0 || feeCap.Cmp(big.NewInt(33600000001)) != 0 {
+		t.Fatalf("both tip and fee cap should be bumped, got:%s %s", tipCap.String(), feeCap.String())
+	}
+	if tipCap, feeCap = replacementFee(big.NewInt(30e9), big.NewInt(30e9)); tipCap.Cmp(feeCap) != 0 || feeCap.Cmp(big.NewInt(33600000001)) != 0 {
+		t.Fatalf("tip can't be higher than fee cap, got:%s %s", tipCap.String(), feeCap.String())
+	}
+	if tipCap, feeCap = replacementFee(nil, big.NewInt(30e9)); nil != tipCap || feeCap.Cmp(big.NewInt(33600000001)) != 0 {
+		t.Fatalf("unknown tip should be suggested by gas oracle")
+	}
+	if tipCap, feeCap = replacementFee(nil, nil); nil != tipCap || nil != feeCap {
+		t.Fatalf("legacy tx has no fee cap")
+	}
+}
@@ -0,0 +1,201 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package miner
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/**
+提交地址的选择：
+1、LegalFee >= StartFee 的环使用 PercentMiners 提交，gasPrice = (FeePercent/100)*(legalFee/eth-price)/gas，
+   但不会低于 evaluator 估算的 gasPrice，保证高收益的环尽快被确认
+2、其它的环使用 NormalMiners 提交，gasPrice 不超过 GasPriceLimit，
+   在余额足够支付油费的地址中选择 pending 数最少的，pending 数相同时选择余额多的
+3、pending 数超过 MaxPendingCount 的地址只有在没有其它可用地址时才会被选择
+*/
+
+var errNoSenderAddress = errors.New("there isn't an available sender address")
+
+//senderState is the state of sender address on chain
+type senderState struct {
+	pendingCount int64
+	balance      *big.Int
+}
+
+func querySenderState(address common.Address) (*senderState, error) {
+	var blockedTxCount, txCount, balance types.Big
+	if err := ethaccessor.GetTransactionCount(&blockedTxCount, address, "latest"); nil != err {
+		return nil, err
+	}
+	if err := ethaccessor.GetTransactionCount(&txCount, address, "pending"); nil != err {
+		return nil, err
+	}
+	if err := ethaccessor.GetBalance(&balance, address, "pending"); nil != err {
+		return nil, err
+	}
+	pendingCount := new(big.Int).Sub(txCount.BigInt(), blockedTxCount.BigInt())
+	return &senderState{pendingCount: pendingCount.Int64(), balance: balance.BigInt()}, nil
+}
+
+//percentGasPrice returns (feePercent/100)*(legalFee/ethPrice)/gas in wei
+func percentGasPrice(legalFee, ethPrice *big.Rat, feePercent float64, gas *big.Int) *big.Int {
+	if nil == legalFee || nil == ethPrice || ethPrice.Sign() <= 0 || nil == gas || gas.Sign() <= 0 || feePercent <= 0 {
+		return nil
+	}
+	percent := new(big.Rat)
+	if nil == percent.SetFloat64(feePercent/100) {
+		return nil
+	}
+	price := new(big.Rat).Quo(legalFee, ethPrice)
+	price.Mul(price, percent)
+	price.Mul(price, new(big.Rat).SetInt(big.NewInt(1e18)))
+	price.Quo(price, new(big.Rat).SetInt(gas))
+	return new(big.Int).Quo(price.Num(), price.Denom())
+}
+
+//selectPercentMiner returns the percent miner whose StartFee is the highest one which doesn't exceed legalFee
+func selectPercentMiner(miners []*SplitMinerAddress, legalFee *big.Rat) *SplitMinerAddress {
+	var selected *SplitMinerAddress
+	if nil == legalFee {
+		return selected
+	}
+	for _, miner := range miners {
+		startFee := new(big.Rat)
+		if nil == startFee.SetFloat64(miner.StartFee) || legalFee.Cmp(startFee) < 0 {
+			continue
+		}
+		if nil == selected || miner.StartFee > selected.StartFee {
+			selected = miner
+		}
+	}
+	return selected
+}
+
+//selectNormalMiner selects the sender which has the least pending txs and can afford the cost
+func selectNormalMiner(miners []*NormalSenderAddress, states map[common.Address]*senderState, gasPrice, gas *big.Int) *NormalSenderAddress {
+	var selected, overloaded *NormalSenderAddress
+	better := func(a, b *senderState) bool {
+		if a.pendingCount != b.pendingCount {
+			return a.pendingCount < b.pendingCount
+		}
+		return a.balance.Cmp(b.balance) > 0
+	}
+	for _, miner := range miners {
+		state, exists := states[miner.Address]
+		if !exists || nil == state.balance {
+			continue
+		}
+		cost := new(big.Int).Mul(normalGasPrice(miner, gasPrice), gas)
+		if state.balance.Cmp(cost) < 0 {
+			continue
+		}
+		if state.pendingCount > miner.MaxPendingCount {
+			if nil == overloaded || better(state, states[overloaded.Address]) {
+				overloaded = miner
+			}
+			continue
+		}
+		if nil == selected || better(state, states[selected.Address]) {
+			selected = miner
+		}
+	}
+	if nil == selected {
+		selected = overloaded
+	}
+	return selected
+}
+
+func (submitter *RingSubmitter) senderStates(addresses []common.Address) map[common.Address]*senderState {
+	states := make(map[common.Address]*senderState)
+	for _, address := range addresses {
+		if state, err := submitter.queryState(address); nil != err {
+			log.Errorf("submitter, query state of sender:%s err:%s", address.Hex(), err.Error())
+		} else {
+			states[address] = state
+		}
+	}
+	return states
+}
+
+//selectSender sets the sender and the gas price of ring tx
+func (submitter *RingSubmitter) selectSender(ringSubmitInfo *types.RingSubmitInfo) error {
+	if miner := selectPercentMiner(submitter.percentMinerAddresses, ringSubmitInfo.RawRing.LegalFee); nil != miner {
+		if err := submitter.usePercentMiner(miner, ringSubmitInfo); nil == err {
+			return nil
+		} else {
+			log.Errorf("submitter, can't use percent miner:%s, err:%s", miner.Address.Hex(), err.Error())
+		}
+	}
+	return submitter.useNormalMiner(ringSubmitInfo)
+}
+
+func (submitter *RingSubmitter) usePercentMiner(miner *SplitMinerAddress, ringSubmitInfo *types.RingSubmitInfo) error {
+	ethPrice, err := submitter.marketCapProvider.GetEthCap()
+	if nil != err {
+		return err
+	}
+	gasPrice := percentGasPrice(ringSubmitInfo.RawRing.LegalFee, ethPrice, miner.FeePercent, ringSubmitInfo.ProtocolGas)
+	if nil == gasPrice {
+		return errors.New("can't calculate the gas price by fee percent")
+	}
+	if nil != ringSubmitInfo.ProtocolGasPrice && gasPrice.Cmp(ringSubmitInfo.ProtocolGasPrice) < 0 {
+		gasPrice.Set(ringSubmitInfo.ProtocolGasPrice)
+	}
+	states := submitter.senderStates([]common.Address{miner.Address})
+	cost := new(big.Int).Mul(gasPrice, ringSubmitInfo.ProtocolGas)
+	if state, exists := states[miner.Address]; !exists || state.balance.Cmp(cost) < 0 {
+		return errors.New("balance isn't enough to pay the gas")
+	}
+	ringSubmitInfo.Miner = miner.Address
+	ringSubmitInfo.ProtocolGasPrice = gasPrice
+	log.Debugf("submitter, ring:%s will be sent by percent miner:%s, legalFee:%s, gasPrice:%s", ringSubmitInfo.Ringhash.Hex(), miner.Address.Hex(), ringSubmitInfo.RawRing.LegalFee.FloatString(2), gasPrice.String())
+	return nil
+}
+
+func (submitter *RingSubmitter) useNormalMiner(ringSubmitInfo *types.RingSubmitInfo) error {
+	addresses := []common.Address{}
+	for _, miner := range submitter.normalMinerAddresses {
+		addresses = append(addresses, miner.Address)
+	}
+	states := submitter.senderStates(addresses)
+	miner := selectNormalMiner(submitter.normalMinerAddresses, states, ringSubmitInfo.ProtocolGasPrice, ringSubmitInfo.ProtocolGas)
+	if nil == miner {
+		return errNoSenderAddress
+	}
+	ringSubmitInfo.Miner = miner.Address
+	ringSubmitInfo.ProtocolGasPrice = normalGasPrice(miner, ringSubmitInfo.ProtocolGasPrice)
+	return nil
+}
+
+func normalGasPrice(miner *NormalSenderAddress, gasPrice *big.Int) *big.Int {
+	if nil == gasPrice {
+		gasPrice = big.NewInt(0)
+	}
+	if nil != miner.GasPriceLimit && miner.GasPriceLimit.Sign() > 0 && gasPrice.Cmp(miner.GasPriceLimit) > 0 {
+		return new(big.Int).Set(miner.GasPriceLimit)
+	}
+	return new(big.Int).Set(gasPrice)
+}
@@ -0,0 +1,137 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package miner
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/marketcap"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+type ethCap struct {
+	marketcap.MarketCapProvider
+	price *big.Rat
+}
+
+func (c *ethCap) GetEthCap() (*big.Rat, error) {
+	return c.price, nil
+}
+
+var (
+	percentMiner = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	normalMiner1 = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	normalMiner2 = common.HexToAddress("0x3333333333333333333333333333333333333333")
+	ether        = big.NewInt(1e18)
+)
+
+func newTestSubmitter(states map[common.Address]*senderState) *RingSubmitter {
+	return &RingSubmitter{
+		normalMinerAddresses: []*NormalSenderAddress{
+			{Address: normalMiner1, GasPriceLimit: big.NewInt(20e9), MaxPendingCount: 2},
+			{Address: normalMiner2, GasPriceLimit: big.NewInt(20e9), MaxPendingCount: 2},
+		},
+		percentMinerAddresses: []*SplitMinerAddress{{Address: percentMiner, FeePercent: 10, StartFee: 100}},
+		marketCapProvider:     &ethCap{price: big.NewRat(500, 1)},
+		queryState: func(address common.Address) (*senderState, error) {
+			if state, exists := states[address]; exists {
+				return state, nil
+			}
+			return nil, errors.New("not found")
+		},
+	}
+}
+
+func newSubmitInfo(legalFee int64) *types.RingSubmitInfo {
+	return &types.RingSubmitInfo{
+		RawRing:          &types.Ring{LegalFee: big.NewRat(legalFee, 1)},
+		ProtocolGas:      big.NewInt(500000),
+		ProtocolGasPrice: big.NewInt(10e9),
+	}
+}
+
+func TestPercentGasPrice(t *testing.T) {
+	//10% of 500 usd is 0.1 eth, so the gas price is 0.1 eth/500000
+	gasPrice := percentGasPrice(big.NewRat(500, 1), big.NewRat(500, 1), 10, big.NewInt(500000))
+	if nil == gasPrice || gasPrice.Cmp(big.NewInt(200e9)) != 0 {
+		t.Fatalf("gasPrice should be 200 gwei, but got:%v", gasPrice)
+	}
+	if nil != percentGasPrice(big.NewRat(500, 1), big.NewRat(0, 1), 10, big.NewInt(500000)) {
+		t.Fatalf("gasPrice can't be calculated without eth price")
+	}
+}
+
+func TestSelectSender(t *testing.T) {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+
+	states := map[common.Address]*senderState{
+		percentMiner: {pendingCount: 0, balance: new(big.Int).Set(ether)},
+		normalMiner1: {pendingCount: 1, balance: new(big.Int).Set(ether)},
+		normalMiner2: {pendingCount: 0, balance: new(big.Int).Set(ether)},
+	}
+	submitter := newTestSubmitter(states)
+
+	info := newSubmitInfo(500)
+	if err := submitter.selectSender(info); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if info.Miner != percentMiner || info.ProtocolGasPrice.Cmp(big.NewInt(200e9)) != 0 {
+		t.Fatalf("high value ring should be sent by percent miner, got:%s %s", info.Miner.Hex(), info.ProtocolGasPrice.String())
+	}
+
+	info = newSubmitInfo(50)
+	if err := submitter.selectSender(info); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if info.Miner != normalMiner2 || info.ProtocolGasPrice.Cmp(big.NewInt(10e9)) != 0 {
+		t.Fatalf("cheap ring should be sent by the normal miner with less pending txs, got:%s %s", info.Miner.Hex(), info.ProtocolGasPrice.String())
+	}
+
+	//percent miner can't afford the gas, and normalMiner2 has too many pending txs
+	states[percentMiner].balance = big.NewInt(1)
+	states[normalMiner2].pendingCount = 3
+	info = newSubmitInfo(500)
+	info.ProtocolGasPrice = big.NewInt(30e9)
+	if err := submitter.selectSender(info); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if info.Miner != normalMiner1 || info.ProtocolGasPrice.Cmp(big.NewInt(20e9)) != 0 {
+		t.Fatalf("ring should fall back to normal miner with limited gas price, got:%s %s", info.Miner.Hex(), info.ProtocolGasPrice.String())
+	}
+
+	states[normalMiner1].balance = big.NewInt(1)
+	info = newSubmitInfo(50)
+	if err := submitter.selectSender(info); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	if info.Miner != normalMiner2 {
+		t.Fatalf("overloaded miner should be used when no other miner is available, got:%s", info.Miner.Hex())
+	}
+
+	states[normalMiner2].balance = big.NewInt(1)
+	if err := submitter.selectSender(newSubmitInfo(50)); err != errNoSenderAddress {
+		t.Fatalf("there should be no available sender, got:%v", err)
+	}
+}
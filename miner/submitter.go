@@ -30,6 +30,7 @@ import (
 	"github.com/Loopring/relay/eventemiter"
 	"github.com/Loopring/relay/log"
 	"github.com/Loopring/relay/marketcap"
+	"github.com/Loopring/relay/metrics"
 	"github.com/Loopring/relay/types"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
@@ -39,6 +40,31 @@ import (
 
 const SubmitRingMethod_LastId = "submitringmethod_lastid"
 
+var (
+	submittedTxs = metrics.NewCounterVec("relay_submitter_txs_total", "Ring transactions of sender, status is dry_run_failed, send_failed, success, failed, resubmit or cancel.", "sender", "status")
+	gasUsed      = metrics.NewCounterVec("relay_submitter_gas_used_total", "Gas used by the mined ring transactions of sender.", "sender")
+	gasFee       = metrics.NewCounterVec("relay_submitter_gas_fee_eth_total", "Fee in ether paid by the mined ring transactions of sender.", "sender")
+)
+
+//observeMinedTx records the result of the ring transaction which has been mined
+func observeMinedTx(sender string, evt *types.RingMinedEvent) {
+	status := "failed"
+	if evt.Status == types.TX_STATUS_SUCCESS {
+		status = "success"
+	}
+	submittedTxs.Inc(sender, status)
+	if nil == evt.GasUsed {
+		return
+	}
+	gasUsed.Add(float64(evt.GasUsed.Int64()), sender)
+	if nil != evt.GasPrice {
+		fee := new(big.Rat).SetInt(new(big.Int).Mul(evt.GasUsed, evt.GasPrice))
+		fee.Quo(fee, new(big.Rat).SetInt(big.NewInt(1e18)))
+		feeFloat, _ := fee.Float64()
+		gasFee.Add(feeFloat, sender)
+	}
+}
+
 //保存ring，并将ring发送到区块链，同样需要分为待完成和已完成
 type RingSubmitter struct {
 	minerAccountForSign accounts.Account
@@ -55,6 +81,8 @@ type RingSubmitter struct {
 	dbService         dao.RdsService
 	marketCapProvider marketcap.MarketCapProvider
 	matcher           Matcher
+	queryState        func(address common.Address) (*senderState, error)
+	txTracker         *txTracker
 
 	stopFuncs []func()
 }
@@ -121,6 +149,8 @@ func NewSubmitter(options config.MinerOptions, dbService dao.RdsService, marketC
 
 	submitter.dbService = dbService
 	submitter.marketCapProvider = marketCapProvider
+	submitter.queryState = querySenderState
+	submitter.txTracker = newTxTracker()
 
 	submitter.stopFuncs = []func(){}
 	return submitter, nil
@@ -131,8 +161,12 @@ func (submitter *RingSubmitter) listenBlockNew() {
 	go func() {
 		for {
 			select {
-			case blockEvent := <-blockEventChan:
+			case blockEvent, ok := <-blockEventChan:
+				if !ok {
+					return
+				}
 				submitter.currentBlockTime = blockEvent.BlockTime
+				submitter.rescueStuckTxs(blockEvent.BlockNumber)
 			}
 		}
 	}()
@@ -191,7 +225,19 @@ func (submitter *RingSubmitter) listenNewRings() {
 			//ringSubmitInfoChan <- e
 			if nil != ringInfos {
 				for _, ringState := range ringInfos {
-					txHash, status, err1 := submitter.submitRing(ringState) // lgh: 提交到以太坊
+					var (
+						txHash       common.Hash
+						maxFeePerGas *big.Int
+						status       types.TxStatus
+						err1         error
+					)
+					//the ring which will revert isn't submitted
+					if err1 = submitter.dryRun(ringState); nil != err1 {
+						txHash, status = types.NilHash, types.TX_STATUS_FAILED
+						submittedTxs.Inc(ringState.Miner.Hex(), "dry_run_failed")
+					} else {
+						txHash, maxFeePerGas, status, err1 = submitter.submitRing(ringState) // lgh: 提交到以太坊
+					}
 					ringState.SubmitTxHash = txHash
 
 					daoInfo := &dao.RingSubmitInfo{}
@@ -199,6 +245,9 @@ func (submitter *RingSubmitter) listenNewRings() {
 					if err := submitter.dbService.Add(daoInfo); nil != err {
 						log.Errorf("Miner submitter,insert new ring err:%s", err.Error())
 					} else {
+						if nil == err1 {
+							submitter.trackTx(daoInfo, ringState.ProtocolGasPrice, maxFeePerGas, ringState.Nonce)
+						}
 						for _, filledOrder := range ringState.RawRing.Orders {
 							daoOrder := &dao.FilledOrder{}
 							daoOrder.ConvertDown(filledOrder, ringState.Ringhash)
@@ -228,28 +277,35 @@ func (submitter *RingSubmitter) canSubmit(ringState *types.RingSubmitInfo) error
 	return errors.New("had been processed")
 }
 
-func (submitter *RingSubmitter) submitRing(ringSubmitInfo *types.RingSubmitInfo) (common.Hash, types.TxStatus, error) {
+//submitRing returns the max fee per gas of the sent tx, the replacement must bump it
+func (submitter *RingSubmitter) submitRing(ringSubmitInfo *types.RingSubmitInfo) (common.Hash, *big.Int, types.TxStatus, error) {
 	status := types.TX_STATUS_PENDING
 	ordersStr, _ := json.Marshal(ringSubmitInfo.RawRing.Orders)
 	log.Debugf("submitring hash:%s, orders:%s", ringSubmitInfo.Ringhash.Hex(), string(ordersStr))
 
 	txHash := types.NilHash
-	var err error
+	var (
+		maxFeePerGas *big.Int
+		err          error
+	)
 
 	if nil == err {
 		txHashStr := "0x"
-		txHashStr, err = ethaccessor.SignAndSendTransaction(
+		//ProtocolGasPrice has been used to check the profit, the max fee per gas may be higher to keep the tx valid while the base fee rises
+		maxFeePerGas = ethaccessor.MaxFeePerGas(ringSubmitInfo.ProtocolGasPrice, submitter.maxGasLimit)
+		txHashStr, ringSubmitInfo.Nonce, err = ethaccessor.SendTransaction(
 			// lgh: all
 			ringSubmitInfo.Miner, // sender 就是矿工的提交地址
 			ringSubmitInfo.ProtocolAddress, // to 是路印协议的地址 LPSC，交易交给协议搞，所以下面的 value = nil
 			ringSubmitInfo.ProtocolGas,
 			ringSubmitInfo.ProtocolGasPrice,
+			maxFeePerGas,
 			nil, // lgh: todo value nil? fix to 见上面
-			ringSubmitInfo.ProtocolData,
-			false)
+			ringSubmitInfo.ProtocolData)
 		if nil != err {
 			log.Errorf("submitring hash:%s, err:%s", ringSubmitInfo.Ringhash.Hex(), err.Error())
 			status = types.TX_STATUS_FAILED
+			submittedTxs.Inc(ringSubmitInfo.Miner.Hex(), "send_failed")
 		}
 		txHash = common.HexToHash(txHashStr)
 	} else {
@@ -257,7 +313,7 @@ func (submitter *RingSubmitter) submitRing(ringSubmitInfo *types.RingSubmitInfo)
 		status = types.TX_STATUS_FAILED
 	}
 
-	return txHash, status, err
+	return txHash, maxFeePerGas, status, err
 }
 
 func (submitter *RingSubmitter) listenSubmitRingMethodEventFromMysql() {
@@ -295,6 +351,9 @@ func (submitter *RingSubmitter) listenSubmitRingMethodEventFromMysql() {
 							err1 = errors.New("")
 						}
 
+						if len(infos) > 0 {
+							observeMinedTx(common.HexToAddress(infos[0].Miner).Hex(), evt)
+						}
 						for _, info := range infos {
 							ringhash := common.HexToHash(info.RingHash)
 							uniqueId := common.HexToHash(info.UniqueId)
@@ -382,6 +441,7 @@ func (submitter *RingSubmitter) submitResult(ringhash, uniqeId, txhash common.Ha
 		BlockNumber:  blockNumber,
 		UsedGas:      usedGas,
 	}
+	resultEvt.OffendingOrders = dryRunOffendingOrders(err)
 	if err := submitter.dbService.UpdateRingSubmitInfoResult(resultEvt); nil != err {
 		log.Errorf("err:%s", err.Error())
 	}
@@ -425,12 +485,6 @@ func (submitter *RingSubmitter) GenerateRingSubmitInfo(ringState *types.Ring) (*
 
 	protocolAbi := ethaccessor.ProtocolImplAbi() // 由 commonOptions.ProtocolImpl.ImplAbi 初始化
 
-	// lgh: 目前 selectSenderAddress 总是直接返回下标是 0 的提交地址
-	if senderAddress, err := submitter.selectSenderAddress(); nil != err {
-		return ringSubmitInfo, err
-	} else {
-		ringSubmitInfo.Miner = senderAddress
-	}
 	//submitter.computeReceivedAndSelectMiner(ringSubmitInfo)
 	// lgh: 生成 protocolAbi 智能合议 的 inputData
 	if protocolData, err :=
@@ -469,6 +523,11 @@ func (submitter *RingSubmitter) GenerateRingSubmitInfo(ringState *types.Ring) (*
 	if submitter.minGasLimit.Sign() > 0 && ringSubmitInfo.ProtocolGas.Cmp(submitter.minGasLimit) < 0 {
 		ringSubmitInfo.ProtocolGas.Set(submitter.minGasLimit)
 	}
+
+	//the sender and gas price are decided by LegalFee of ring, see sender.go
+	if err := submitter.selectSender(ringSubmitInfo); nil != err {
+		return nil, err
+	}
 	return ringSubmitInfo, nil
 }
 
@@ -479,66 +538,13 @@ func (submitter *RingSubmitter) stop() {
 }
 
 func (submitter *RingSubmitter) start() {
+	submitter.loadPendingTxs()
 	submitter.listenNewRings()
 	submitter.listenSubmitRingMethodEventFromMysql()
 	submitter.listenBlockNew()
 	//submitter.listenSubmitRingMethodEvent()
 }
 
-// lgh: 下面这个方法就是所说的 矿工 的环提交地址。具备候选条件
-// lgh: 下面的条件是: 区块等待状态中的数 <= 区块等待状态中的最大数
-func (submitter *RingSubmitter) availableSenderAddresses() []*NormalSenderAddress {
-	senderAddresses := []*NormalSenderAddress{}
-	for _, minerAddress := range submitter.normalMinerAddresses {
-		// 配置文件中 normalMinerAddresses 目前只有一个，可以多个
-		var blockedTxCount, txCount types.Big
-		//todo:change it by event
-
-		// 下面获取当前的提交地址 Address 在以太坊截止目前提交成的最新块的 号码
-		ethaccessor.GetTransactionCount(
-			&blockedTxCount,
-			minerAddress.Address, "latest")
-
-		// 下面获取当前的提交地址 Address 在以太坊截止目前提交了正处于等待被处理的 block 号码
-		// earliest <= latest < pending
-		ethaccessor.GetTransactionCount(
-			&txCount,
-			minerAddress.Address, "pending")
-
-		//submitter.Accessor.Call("latest", &blockedTxCount, "eth_getTransactionCount", minerAddress.Address.Hex(), "latest")
-		//submitter.Accessor.Call("latest", &txCount, "eth_getTransactionCount", minerAddress.Address.Hex(), "pending")
-
-		//todo:check ethbalance
-		pendingCount := big.NewInt(int64(0))
-
-		// 下面 pendingCount = txCount - blockedTxCount，要求 txCount > blockedTxCount
-		// pendingCount 是等待区块的数目
-		pendingCount.Sub(txCount.BigInt(), blockedTxCount.BigInt())
-		if pendingCount.Int64() <= minerAddress.MaxPendingCount {
-			// MaxPendingCount 是区块等待状态中个数的最大数，在 NewSubmitter 的时候初始化一次
-			// pendingCount <= MaxPendingCount，还没超出范围，添加入发送者地址。这里有可能 0<= MaxPendingCount
-			senderAddresses = append(senderAddresses, minerAddress)
-		}
-	}
-	if len(senderAddresses) <= 0 {
-		// lgh todo 是否保持？
-		// lgh: 这里强制设为 第一个，即使它目前提交的 pending block 数 > MaxPendingCount
-		senderAddresses = append(senderAddresses, submitter.normalMinerAddresses[0])
-	}
-	return senderAddresses
-}
-
-func (submitter *RingSubmitter) selectSenderAddress() (common.Address, error) {
-	senderAddresses := submitter.availableSenderAddresses()
-	if len(senderAddresses) <= 0 {
-		// lgh: 下面这里不会进入 availableSenderAddresses 做了强制设置
-		return types.NilAddress, errors.New("there isn't an available sender address")
-	} else {
-		// lgh: todo availableSenderAddresses 内部是批量获取，下面却直接返回下标是 0 的，不需要做个散列算法来选择地址？
-		return senderAddresses[0].Address, nil
-	}
-}
-
 
 
 
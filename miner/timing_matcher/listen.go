@@ -123,7 +123,7 @@ func (matcher *TimingMatcher) listenTimingRound() {
 	stopChan := make(chan bool)
 
 	matchFunc := func() {
-		if !matcher.isOrdersReady {
+		if !matcher.isOrdersReady || matcher.IsPaused() {
 			return
 		}
 		//if ethaccessor.Synced() {
@@ -141,6 +141,7 @@ func (matcher *TimingMatcher) listenTimingRound() {
 			}(market)
 		}
 		wg.Wait()
+		matcher.matchMultiHop()
 		//}
 	}
 	go func() {
@@ -182,6 +183,9 @@ func (matcher *TimingMatcher) listenSubmitEvent() {
 						if minedEvent.Status == types.TX_STATUS_FAILED {
 							log.Debugf("AddFailedRingCache:%s", minedEvent.RingHash.Hex())
 							//if strings.Contains(minedEvent.Err.Error(), "failed to execute ring:") {
+							if len(minedEvent.OffendingOrders) > 0 {
+								orderhashes = minedEvent.OffendingOrders
+							}
 							AddFailedRingCache(minedEvent.RingUniqueId, minedEvent.TxHash, orderhashes)
 							//}
 						}
@@ -23,12 +23,20 @@ import (
 	"github.com/Loopring/relay/ethaccessor"
 	"github.com/Loopring/relay/eventemiter"
 	"github.com/Loopring/relay/log"
+	marketUtilLib "github.com/Loopring/relay/market/util"
+	"github.com/Loopring/relay/metrics"
 	"github.com/Loopring/relay/miner"
 	"github.com/Loopring/relay/ordermanager"
 	"github.com/Loopring/relay/types"
 	"github.com/ethereum/go-ethereum/common"
 	"math/big"
-	"sort"
+)
+
+var (
+	roundCandidateRings = metrics.NewGaugeVec("relay_matcher_round_candidate_rings", "Candidate rings of market in the latest round.", "market")
+	roundSubmittedRings = metrics.NewGaugeVec("relay_matcher_round_submitted_rings", "Rings of market sent to submitter in the latest round.", "market")
+	candidateRings      = metrics.NewCounterVec("relay_matcher_candidate_rings_total", "Candidate rings of market.", "market")
+	submittedRings      = metrics.NewCounterVec("relay_matcher_submitted_rings_total", "Rings of market sent to submitter.", "market")
 )
 
 type Market struct {
@@ -49,8 +57,6 @@ func (market *Market) match() {
 	// lgh: market.protocolImpl.DelegateAddress 就是配置文件中的 common.protocolImpl.address 去获取对应的信息后设置好的
 	market.getOrdersForMatching(market.protocolImpl.DelegateAddress)
 
-	matchedOrderHashes := make(map[common.Hash]bool) //true:fullfilled, false:partfilled
-	ringSubmitInfos := []*types.RingSubmitInfo{}
 	candidateRingList := CandidateRingList{} // 候选环
 
 	//step 1: evaluate received 接收评估
@@ -91,7 +97,52 @@ func (market *Market) match() {
 	}
 
 	log.Debugf("match round:%s, market: A %s -> B %s , candidateRingList.length:%d", market.matcher.lastRoundNumber, market.TokenA.Hex(), market.TokenB.Hex(), len(candidateRingList))
-	//the ring that can get max received
+	orders := make(map[common.Hash]*types.OrderState)
+	for hash, order := range market.AtoBOrders {
+		orders[hash] = order
+	}
+	for hash, order := range market.BtoAOrders {
+		orders[hash] = order
+	}
+	ringSubmitInfos, matchedOrderHashes := market.selectRings(candidateRingList, orders)
+
+	for orderHash, _ := range market.AtoBOrders {
+		if fullFilled, exists := matchedOrderHashes[orderHash]; exists && fullFilled {
+			market.AtoBOrderHashesExcludeNextRound = append(market.AtoBOrderHashesExcludeNextRound, orderHash)
+		}
+	}
+
+	for orderHash, _ := range market.BtoAOrders {
+		if fullFilled, exists := matchedOrderHashes[orderHash]; exists && fullFilled {
+			market.BtoAOrderHashesExcludeNextRound = append(market.BtoAOrderHashesExcludeNextRound, orderHash)
+		}
+	}
+	market.observeRound(len(candidateRingList), len(ringSubmitInfos))
+	if len(ringSubmitInfos) > 0 {
+		log.Debugf("形成新环 : TokenA %s -> TokenB %s，分发 Miner_NewRing 事件",market.TokenA.Hex(), market.TokenB.Hex())
+		eventemitter.Emit(eventemitter.Miner_NewRing, ringSubmitInfos)
+	}else{
+		log.Debugf("不足以形成新环 len(ringSubmitInfos) <= 0")
+	}
+}
+
+func (market *Market) observeRound(candidateCount, submittedCount int) {
+	name, err := marketUtilLib.WrapMarketByAddress(market.TokenA.Hex(), market.TokenB.Hex())
+	if nil != err {
+		name = market.TokenA.Hex() + "-" + market.TokenB.Hex()
+	}
+	roundCandidateRings.Set(float64(candidateCount), name)
+	roundSubmittedRings.Set(float64(submittedCount), name)
+	candidateRings.Add(float64(candidateCount), name)
+	submittedRings.Add(float64(submittedCount), name)
+}
+
+//selectRings takes the rings from candidateRingList by the selection strategy and reduces the amount of orders which are filled by them.
+//orders contains all the orders of candidateRingList.
+func (market *Market) selectRings(candidateRingList CandidateRingList, orders map[common.Hash]*types.OrderState) ([]*types.RingSubmitInfo, map[common.Hash]bool) {
+	matchedOrderHashes := make(map[common.Hash]bool) //true:fullfilled, false:partfilled
+	ringSubmitInfos := []*types.RingSubmitInfo{}
+	selector := market.matcher.selectionStrategy(market.matcher.selectionOptions, orders)
 	list := candidateRingList
 	for {
 		if len(list) <= 0 {
@@ -99,21 +150,18 @@ func (market *Market) match() {
 			break
 		}
 
-		sort.Sort(list)
-		candidateRing := list[0]
-		list = list[1:] // 提取一个候选，就从 list 中删除掉
-		orders := []*types.OrderState{}
-		for hash,_ := range candidateRing.filledOrders {
-			if o, exists := market.AtoBOrders[hash]; exists {
-				// 如果当前的订单是 a->b 的
-				orders = append(orders, o)
-			} else {
-				// 否则就是 b->a 的
-				orders = append(orders, market.BtoAOrders[hash])
-			}
+		idx, ok := selector.Next(list)
+		if !ok {
+			break
+		}
+		candidateRing := list[idx]
+		list = append(list[:idx:idx], list[idx+1:]...) // 提取一个候选，就从 list 中删除掉
+		ringOrders := []*types.OrderState{}
+		for _, hash := range candidateRing.orderhashes {
+			ringOrders = append(ringOrders, orders[hash])
 		}
 		// 下面再计算了一次，生成'提交环'
-		if ringForSubmit, err := market.generateRingSubmitInfo(orders...); nil != err {
+		if ringForSubmit, err := market.generateRingSubmitInfo(ringOrders...); nil != err {
 			log.Debugf("generate RingSubmitInfo err:%s", err.Error())
 			continue
 		} else {
@@ -145,7 +193,8 @@ func (market *Market) match() {
 			// lgh: todo 为什么又判断了一次？
 			if ringForSubmit.RawRing.Received.Sign() > 0 {
 				for _, filledOrder := range ringForSubmit.RawRing.Orders {
-					orderState := market.reduceAmountAfterFilled(filledOrder)
+					orderState := orders[filledOrder.OrderState.RawOrder.Hash]
+					reduceOrderStateAfterFilled(orderState, filledOrder)
 					isFullFilled := market.om.IsOrderFullFinished(orderState)
 					matchedOrderHashes[filledOrder.OrderState.RawOrder.Hash] = isFullFilled
 					//market.matcher.rounds.AppendFilledOrderToCurrent(filledOrder, ringForSubmit.RawRing.Hash)
@@ -153,30 +202,14 @@ func (market *Market) match() {
 					list = market.reduceReceivedOfCandidateRing(list, filledOrder, isFullFilled)
 				}
 				AddMinedRing(ringForSubmit)
+				selector.Taken(candidateRing)
 				ringSubmitInfos = append(ringSubmitInfos, ringForSubmit)
 			} else {
 				log.Debugf("ring:%s will not be submitted,because of received:%s", ringForSubmit.RawRing.Hash.Hex(), ringForSubmit.RawRing.Received.String())
 			}
 		}
 	}
-
-	for orderHash, _ := range market.AtoBOrders {
-		if fullFilled, exists := matchedOrderHashes[orderHash]; exists && fullFilled {
-			market.AtoBOrderHashesExcludeNextRound = append(market.AtoBOrderHashesExcludeNextRound, orderHash)
-		}
-	}
-
-	for orderHash, _ := range market.BtoAOrders {
-		if fullFilled, exists := matchedOrderHashes[orderHash]; exists && fullFilled {
-			market.BtoAOrderHashesExcludeNextRound = append(market.BtoAOrderHashesExcludeNextRound, orderHash)
-		}
-	}
-	if len(ringSubmitInfos) > 0 {
-		log.Debugf("形成新环 : TokenA %s -> TokenB %s，分发 Miner_NewRing 事件",market.TokenA.Hex(), market.TokenB.Hex())
-		eventemitter.Emit(eventemitter.Miner_NewRing, ringSubmitInfos)
-	}else{
-		log.Debugf("不足以形成新环 len(ringSubmitInfos) <= 0")
-	}
+	return ringSubmitInfos, matchedOrderHashes
 }
 
 func (market *Market) reduceReceivedOfCandidateRing(list CandidateRingList, filledOrder *types.FilledOrder, isFullFilled bool) CandidateRingList {
@@ -208,6 +241,9 @@ func (market *Market) reduceReceivedOfCandidateRing(list CandidateRingList, fill
 				for hash, amount := range ring.filledOrders {
 					ring.filledOrders[hash] = amount.Mul(amount, rate)
 				}
+				if nil != ring.volume {
+					ring.volume = new(big.Rat).Mul(ring.volume, rate)
+				}
 				resList = append(resList, ring)
 			}
 		} else {
@@ -306,24 +342,10 @@ func (market *Market) reduceRemainedAmountBeforeMatch(orderState *types.OrderSta
 	}
 }
 
-func (market *Market) reduceAmountAfterFilled(filledOrder *types.FilledOrder) *types.OrderState {
-	filledOrderState := filledOrder.OrderState
-	var orderState *types.OrderState
-
-	//only one of DealtAmountB and DealtAmountS is precise
-	if filledOrderState.RawOrder.TokenS == market.TokenA {
-		orderState = market.AtoBOrders[filledOrderState.RawOrder.Hash]
-		orderState.DealtAmountB.Add(orderState.DealtAmountB, ratToInt(filledOrder.FillAmountB))
-		orderState.DealtAmountS.Add(orderState.DealtAmountS, ratToInt(filledOrder.FillAmountS))
-	} else {
-		orderState = market.BtoAOrders[filledOrderState.RawOrder.Hash]
-		orderState.DealtAmountB.Add(orderState.DealtAmountB, ratToInt(filledOrder.FillAmountB))
-		orderState.DealtAmountS.Add(orderState.DealtAmountS, ratToInt(filledOrder.FillAmountS))
-	}
+func reduceOrderStateAfterFilled(orderState *types.OrderState, filledOrder *types.FilledOrder) {
+	orderState.DealtAmountB.Add(orderState.DealtAmountB, ratToInt(filledOrder.FillAmountB))
+	orderState.DealtAmountS.Add(orderState.DealtAmountS, ratToInt(filledOrder.FillAmountS))
 	log.Debugf("order status after matched, orderhash:%s,filledAmountS:%s, DealtAmountS:%s, ", orderState.RawOrder.Hash.Hex(), filledOrder.FillAmountS.String(), orderState.DealtAmountS.String())
-	//reduced account balance
-
-	return orderState
 }
 
 // lgh: 撮合的时候，进入这里的 orders 总是 2，且分别是 a->b，b->a
@@ -346,17 +368,12 @@ func (market *Market) GenerateCandidateRing(orders ...*types.OrderState) (*Candi
 	if err := market.matcher.evaluator.ComputeRing(ringTmp); nil != err {
 		return nil, err
 	} else {
-		candidateRing := &CandidateRing{
-			cost: ringTmp.LegalCost, // 当前的环矿工应该承担多少 以太坊的 油费
-			received: ringTmp.Received, // 当前的环矿工最终的收益
-			filledOrders: make(map[common.Hash]*big.Rat)} // 存储每个订单的 hash 值并使之对应到 真实要卖的
-
-		for _, filledOrder := range ringTmp.Orders {
-			log.Debugf("match, orderhash:%s, filledOrder.FilledAmountS:%s", filledOrder.OrderState.RawOrder.Hash.Hex(), filledOrder.FillAmountS.FloatString(3))
-			// lgh: 做了个赋值 每个订单的 hash 值并使之对应到 真实要卖的
-			candidateRing.filledOrders[filledOrder.OrderState.RawOrder.Hash]= filledOrder.FillAmountS
+		var volume *big.Rat
+		if volume, err = market.matcher.evaluator.LegalVolume(ringTmp); nil != err {
+			log.Debugf("compute legal volume of ring err:%s", err.Error())
 		}
-		return candidateRing, nil
+		candidateRing := NewCandidateRing(ringTmp, volume)
+		return &candidateRing, nil
 	}
 }
 
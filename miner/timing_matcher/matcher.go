@@ -31,6 +31,7 @@ import (
 	marketLib "github.com/Loopring/relay/market"
 	marketUtilLib "github.com/Loopring/relay/market/util"
 	"strings"
+	"sync/atomic"
 )
 
 /**
@@ -46,31 +47,38 @@ type TimingMatcher struct {
 	duration        *big.Int
 	lagBlocks       int64
 	roundOrderCount int
+	ringMaxLength   int
 	reservedTime    int64
 	maxFailedCount  int64
 
+	selectionStrategy SelectionStrategy
+	selectionOptions  SelectionOptions
+
 	maxCacheRoundsLength int
 	delayedNumber        int64
 	accountManager       *marketLib.AccountManager
 	isOrdersReady        bool
+	paused               int32
 	db                   dao.RdsService
 
 	stopFuncs []func()
 }
 
 func NewTimingMatcher(
-	matcherOptions *config.TimingMatcher,
+	minerOptions config.MinerOptions,
 	submitter *miner.RingSubmitter,
 	evaluator *miner.Evaluator,
 	om ordermanager.OrderManager,
 	accountManager *marketLib.AccountManager,
 	rds dao.RdsService) *TimingMatcher {
 
+	matcherOptions := minerOptions.TimingMatcher
 	matcher := &TimingMatcher{}
 	matcher.submitter = submitter
 	matcher.evaluator = evaluator
 	matcher.accountManager = accountManager
 	matcher.roundOrderCount = matcherOptions.RoundOrdersCount
+	matcher.ringMaxLength = minerOptions.RingMaxLength
 	//matcher.rounds = NewRoundStates(matcherOptions.MaxCacheRoundsLength)
 	matcher.isOrdersReady = false
 	matcher.db = rds
@@ -86,6 +94,13 @@ func NewTimingMatcher(
 		matcher.maxFailedCount = 3
 	}
 
+	if strategy, err := GetSelectionStrategy(matcherOptions.SelectionStrategy); nil != err {
+		log.Fatalf("err:%s", err.Error())
+	} else {
+		matcher.selectionStrategy = strategy
+	}
+	matcher.selectionOptions = SelectionOptions{BatchSize: matcherOptions.SelectionBatchSize}
+
 	matcher.markets = []*Market{}
 	matcher.duration = big.NewInt(matcherOptions.Duration)
 	matcher.delayedNumber = matcherOptions.DelayedNumber
@@ -168,6 +183,20 @@ func (matcher *TimingMatcher) Stop() {
 	}
 }
 
+func (matcher *TimingMatcher) Pause() {
+	atomic.StoreInt32(&matcher.paused, 1)
+	log.Infof("timing matcher paused")
+}
+
+func (matcher *TimingMatcher) Resume() {
+	atomic.StoreInt32(&matcher.paused, 0)
+	log.Infof("timing matcher resumed")
+}
+
+func (matcher *TimingMatcher) IsPaused() bool {
+	return atomic.LoadInt32(&matcher.paused) == 1
+}
+
 func (matcher *TimingMatcher) GetAccountAvailableAmount(address, tokenAddress, spender common.Address) (*big.Rat, error) {
 	//log.Debugf("address: %s , token: %s , spender: %s", address.Hex(), tokenAddress.Hex(), spender.Hex())
 	if balance, allowance, err :=
@@ -0,0 +1,276 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package timing_matcher
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/Loopring/relay/eventemiter"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/miner"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/**
+每个market只能撮合两个订单的环，这里把同一协议下所有market本轮的订单构成token图，
+token为点，订单(tokenS->tokenB)为边，在图中查找长度为3到ringMaxLength的环路
+*/
+
+const maxCombinationsPerCycle = 64
+
+type tokenGraph struct {
+	edges map[common.Address]map[common.Address][]*types.OrderState
+}
+
+//newTokenGraph keeps at most maxOrdersPerEdge orders with the best price on every edge, 0 means no limit
+func newTokenGraph(orders []*types.OrderState, maxOrdersPerEdge int) *tokenGraph {
+	g := &tokenGraph{edges: make(map[common.Address]map[common.Address][]*types.OrderState)}
+	for _, order := range orders {
+		tokenS := order.RawOrder.TokenS
+		tokenB := order.RawOrder.TokenB
+		if _, exists := g.edges[tokenS]; !exists {
+			g.edges[tokenS] = make(map[common.Address][]*types.OrderState)
+		}
+		g.edges[tokenS][tokenB] = append(g.edges[tokenS][tokenB], order)
+	}
+
+	for _, next := range g.edges {
+		for tokenB, edgeOrders := range next {
+			sort.SliceStable(edgeOrders, func(i, j int) bool {
+				return orderPrice(edgeOrders[i]).Cmp(orderPrice(edgeOrders[j])) > 0
+			})
+			if maxOrdersPerEdge > 0 && len(edgeOrders) > maxOrdersPerEdge {
+				edgeOrders = edgeOrders[0:maxOrdersPerEdge]
+			}
+			next[tokenB] = edgeOrders
+		}
+	}
+	return g
+}
+
+//the bigger amountS/amountB, the easier the ring can be formed
+func orderPrice(order *types.OrderState) *big.Rat {
+	return new(big.Rat).SetFrac(order.RawOrder.AmountS, order.RawOrder.AmountB)
+}
+
+func (g *tokenGraph) nextTokens(token common.Address) []common.Address {
+	tokens := []common.Address{}
+	for tokenB, _ := range g.edges[token] {
+		tokens = append(tokens, tokenB)
+	}
+	sortTokens(tokens)
+	return tokens
+}
+
+//cycles returns the token cycles whose length is in [minLength, maxLength].
+//every cycle starts with its smallest token, so the same cycle will not be returned twice.
+func (g *tokenGraph) cycles(minLength, maxLength int) [][]common.Address {
+	res := [][]common.Address{}
+	starts := []common.Address{}
+	for token, _ := range g.edges {
+		starts = append(starts, token)
+	}
+	sortTokens(starts)
+	for _, start := range starts {
+		g.searchCycles(start, []common.Address{start}, minLength, maxLength, &res)
+	}
+	return res
+}
+
+func (g *tokenGraph) searchCycles(start common.Address, path []common.Address, minLength, maxLength int, res *[][]common.Address) {
+	for _, next := range g.nextTokens(path[len(path)-1]) {
+		if next == start {
+			if len(path) >= minLength {
+				cycle := make([]common.Address, len(path))
+				copy(cycle, path)
+				*res = append(*res, cycle)
+			}
+			continue
+		}
+		if len(path) >= maxLength || bytes.Compare(next.Bytes(), start.Bytes()) < 0 || containsToken(path, next) {
+			continue
+		}
+		nextPath := make([]common.Address, len(path), len(path)+1)
+		copy(nextPath, path)
+		g.searchCycles(start, append(nextPath, next), minLength, maxLength, res)
+	}
+}
+
+//orderCombinations returns at most limit groups of orders that can form a ring along the cycle
+func (g *tokenGraph) orderCombinations(cycle []common.Address, limit int) [][]*types.OrderState {
+	res := [][]*types.OrderState{}
+	g.searchOrders(cycle, []*types.OrderState{}, limit, &res)
+	return res
+}
+
+func (g *tokenGraph) searchOrders(cycle []common.Address, selected []*types.OrderState, limit int, res *[][]*types.OrderState) {
+	if len(*res) >= limit {
+		return
+	}
+	idx := len(selected)
+	if idx == len(cycle) {
+		if miner.RingPriceValid(selected...) {
+			orders := make([]*types.OrderState, len(selected))
+			copy(orders, selected)
+			*res = append(*res, orders)
+		}
+		return
+	}
+	tokenS := cycle[idx]
+	tokenB := cycle[(idx+1)%len(cycle)]
+	for _, order := range g.edges[tokenS][tokenB] {
+		//todo:same as market.match, remove it after contract fix bug
+		if containsOwner(selected, order.RawOrder.Owner) {
+			continue
+		}
+		next := make([]*types.OrderState, len(selected), len(selected)+1)
+		copy(next, selected)
+		g.searchOrders(cycle, append(next, order), limit, res)
+	}
+}
+
+//FindRings returns the groups of orders that can form a ring, and the length of ring is in [2, maxLength].
+//it is used by the ones that don't match by Market, such as simulator.
+func FindRings(orders []*types.OrderState, maxLength, maxOrdersPerEdge int) [][]*types.OrderState {
+	res := [][]*types.OrderState{}
+	graph := newTokenGraph(orders, maxOrdersPerEdge)
+	for _, cycle := range graph.cycles(2, maxLength) {
+		res = append(res, graph.orderCombinations(cycle, maxCombinationsPerCycle)...)
+	}
+	return res
+}
+
+func sortTokens(tokens []common.Address) {
+	sort.Slice(tokens, func(i, j int) bool {
+		return bytes.Compare(tokens[i].Bytes(), tokens[j].Bytes()) < 0
+	})
+}
+
+func containsToken(tokens []common.Address, token common.Address) bool {
+	for _, t := range tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+func containsOwner(orders []*types.OrderState, owner common.Address) bool {
+	for _, o := range orders {
+		if o.RawOrder.Owner == owner {
+			return true
+		}
+	}
+	return false
+}
+
+func (matcher *TimingMatcher) isOrderFailedTooMany(orderhash common.Hash) bool {
+	if failedCount, err := OrderExecuteFailedCount(orderhash); nil == err && failedCount > matcher.maxFailedCount {
+		log.Debugf("orderhash:%s has been failed to submit %d times", orderhash.Hex(), failedCount)
+		return true
+	}
+	return false
+}
+
+//matchMultiHop must be called after all markets have matched in this round, it uses the orders of them.
+func (matcher *TimingMatcher) matchMultiHop() {
+	if matcher.ringMaxLength <= 2 {
+		return
+	}
+
+	protocolMarkets := make(map[*ethaccessor.ProtocolAddress][]*Market)
+	for _, market := range matcher.markets {
+		protocolMarkets[market.protocolImpl] = append(protocolMarkets[market.protocolImpl], market)
+	}
+
+	for _, markets := range protocolMarkets {
+		matcher.matchMultiHopOfProtocol(markets)
+	}
+}
+
+func (matcher *TimingMatcher) matchMultiHopOfProtocol(markets []*Market) {
+	//all markets of a protocol can generate the same ring, use the first one
+	proxy := markets[0]
+
+	orders := make(map[common.Hash]*types.OrderState)
+	orderMarkets := make(map[common.Hash]*Market)
+	addOrder := func(market *Market, order *types.OrderState) {
+		if _, exists := orders[order.RawOrder.Hash]; exists {
+			return
+		}
+		if market.om.IsOrderFullFinished(order) || matcher.isOrderFailedTooMany(order.RawOrder.Hash) {
+			return
+		}
+		orders[order.RawOrder.Hash] = order
+		orderMarkets[order.RawOrder.Hash] = market
+	}
+	for _, market := range markets {
+		for _, order := range market.AtoBOrders {
+			addOrder(market, order)
+		}
+		for _, order := range market.BtoAOrders {
+			addOrder(market, order)
+		}
+	}
+
+	orderList := []*types.OrderState{}
+	for _, order := range orders {
+		orderList = append(orderList, order)
+	}
+	graph := newTokenGraph(orderList, matcher.roundOrderCount)
+
+	candidateRingList := CandidateRingList{}
+	for _, cycle := range graph.cycles(3, matcher.ringMaxLength) {
+		for _, ringOrders := range graph.orderCombinations(cycle, maxCombinationsPerCycle) {
+			if candidateRing, err := proxy.GenerateCandidateRing(ringOrders...); nil != err {
+				log.Errorf("err:%s", err.Error())
+			} else if candidateRing.received.Sign() > 0 {
+				candidateRingList = append(candidateRingList, *candidateRing)
+			} else {
+				log.Debugf("timing_matchher, multi-hop ring received not enough, received:%s, cost:%s ", candidateRing.received.FloatString(0), candidateRing.cost.FloatString(0))
+			}
+		}
+	}
+
+	log.Debugf("match round:%s, multi-hop candidateRingList.length:%d", matcher.lastRoundNumber, len(candidateRingList))
+	if len(candidateRingList) <= 0 {
+		return
+	}
+
+	ringSubmitInfos, matchedOrderHashes := proxy.selectRings(candidateRingList, orders)
+	for orderhash, fullFilled := range matchedOrderHashes {
+		if !fullFilled {
+			continue
+		}
+		market := orderMarkets[orderhash]
+		if orders[orderhash].RawOrder.TokenS == market.TokenA {
+			market.AtoBOrderHashesExcludeNextRound = append(market.AtoBOrderHashesExcludeNextRound, orderhash)
+		} else {
+			market.BtoAOrderHashesExcludeNextRound = append(market.BtoAOrderHashesExcludeNextRound, orderhash)
+		}
+	}
+
+	if len(ringSubmitInfos) > 0 {
+		eventemitter.Emit(eventemitter.Miner_NewRing, ringSubmitInfos)
+	}
+}
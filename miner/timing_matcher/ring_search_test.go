@@ -0,0 +1,100 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package timing_matcher
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var (
+	lrc  = common.HexToAddress("0x01")
+	weth = common.HexToAddress("0x02")
+	eos  = common.HexToAddress("0x03")
+	rdn  = common.HexToAddress("0x04")
+)
+
+func newTestOrder(hash, owner string, tokenS, tokenB common.Address, amountS, amountB int64) *types.OrderState {
+	state := &types.OrderState{}
+	state.RawOrder.Hash = common.HexToHash(hash)
+	state.RawOrder.Owner = common.HexToAddress(owner)
+	state.RawOrder.TokenS = tokenS
+	state.RawOrder.TokenB = tokenB
+	state.RawOrder.AmountS = big.NewInt(amountS)
+	state.RawOrder.AmountB = big.NewInt(amountB)
+	return state
+}
+
+func TestTokenGraph_Cycles(t *testing.T) {
+	orders := []*types.OrderState{
+		newTestOrder("0x11", "0xa1", lrc, weth, 1000, 1),
+		newTestOrder("0x12", "0xa2", weth, eos, 1, 100),
+		newTestOrder("0x13", "0xa3", eos, lrc, 100, 1000),
+		newTestOrder("0x14", "0xa4", weth, lrc, 1, 1000),
+		newTestOrder("0x15", "0xa5", eos, rdn, 1, 1),
+	}
+	g := newTokenGraph(orders, 0)
+
+	cycles := g.cycles(3, 4)
+	if len(cycles) != 1 {
+		t.Fatalf("expect 1 cycle, got %d", len(cycles))
+	}
+	if cycles[0][0] != lrc || cycles[0][1] != weth || cycles[0][2] != eos {
+		t.Fatalf("unexpected cycle:%v", cycles[0])
+	}
+
+	if cycles := g.cycles(2, 2); len(cycles) != 1 {
+		t.Fatalf("expect the pair lrc-weth, got %d cycles", len(cycles))
+	}
+
+	if cycles := g.cycles(3, 2); len(cycles) != 0 {
+		t.Fatalf("expect no cycle, got %d", len(cycles))
+	}
+}
+
+func TestTokenGraph_OrderCombinations(t *testing.T) {
+	orders := []*types.OrderState{
+		newTestOrder("0x11", "0xa1", lrc, weth, 1000, 1),
+		newTestOrder("0x12", "0xa2", weth, eos, 1, 100),
+		newTestOrder("0x13", "0xa3", eos, lrc, 100, 1000),
+		//price of this order is too low to form a ring
+		newTestOrder("0x14", "0xa4", eos, lrc, 90, 1000),
+		//same owner as the first order
+		newTestOrder("0x15", "0xa1", eos, lrc, 200, 1000),
+	}
+	g := newTokenGraph(orders, 0)
+	combinations := g.orderCombinations([]common.Address{lrc, weth, eos}, maxCombinationsPerCycle)
+	if len(combinations) != 1 {
+		t.Fatalf("expect 1 combination, got %d", len(combinations))
+	}
+	ring := combinations[0]
+	if ring[0].RawOrder.Hash != common.HexToHash("0x11") ||
+		ring[1].RawOrder.Hash != common.HexToHash("0x12") ||
+		ring[2].RawOrder.Hash != common.HexToHash("0x13") {
+		t.Fatalf("unexpected orders of ring")
+	}
+
+	g = newTokenGraph(orders, 1)
+	if len(g.edges[eos][lrc]) != 1 || g.edges[eos][lrc][0].RawOrder.Hash != common.HexToHash("0x15") {
+		t.Fatalf("edge should keep the order with best price")
+	}
+}
@@ -0,0 +1,327 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package timing_matcher
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/**
+每一轮撮合得到候选环之后，由SelectionStrategy决定候选环被选中提交的顺序，
+选中一个环之后会扣减其他候选环中相同订单的量，然后再选择下一个。
+*/
+
+const (
+	SelectionGreedy     = "greedy"
+	SelectionMaxVolume  = "max_volume"
+	SelectionRoundRobin = "round_robin"
+	SelectionOptimal    = "optimal"
+
+	defaultSelectionBatchSize = 12
+)
+
+//RingSelector is created for every selection, so it can keep the state of this selection
+type RingSelector interface {
+	//Next returns the index of the ring in list that should be submitted next, false means stop selecting.
+	//list can be reordered by it.
+	Next(list CandidateRingList) (int, bool)
+	//Taken is called after the ring has been selected for submitting
+	Taken(ring CandidateRing)
+}
+
+//SelectionStrategy creates RingSelector, orders contains all the orders of the candidate rings
+type SelectionStrategy func(options SelectionOptions, orders map[common.Hash]*types.OrderState) RingSelector
+
+type SelectionOptions struct {
+	BatchSize int
+}
+
+var (
+	strategiesMtx sync.RWMutex
+	strategies    = map[string]SelectionStrategy{
+		SelectionGreedy:     newGreedySelector,
+		SelectionMaxVolume:  newMaxVolumeSelector,
+		SelectionRoundRobin: newRoundRobinSelector,
+		SelectionOptimal:    newOptimalSelector,
+	}
+)
+
+//RegisterSelectionStrategy adds a strategy that can be used by config, it must be called before NewTimingMatcher
+func RegisterSelectionStrategy(name string, strategy SelectionStrategy) {
+	strategiesMtx.Lock()
+	defer strategiesMtx.Unlock()
+	strategies[name] = strategy
+}
+
+func GetSelectionStrategy(name string) (SelectionStrategy, error) {
+	strategiesMtx.RLock()
+	defer strategiesMtx.RUnlock()
+	if "" == name {
+		name = SelectionGreedy
+	}
+	if strategy, exists := strategies[name]; exists {
+		return strategy, nil
+	} else {
+		return nil, fmt.Errorf("ring selection strategy:%s not supported", name)
+	}
+}
+
+func (ring CandidateRing) OrderHashes() []common.Hash {
+	return ring.orderhashes
+}
+
+func (ring CandidateRing) Received() *big.Rat {
+	return ring.received
+}
+
+func (ring CandidateRing) Cost() *big.Rat {
+	return ring.cost
+}
+
+//Volume is the legal currency value of tokens exchanged in the ring
+func (ring CandidateRing) Volume() *big.Rat {
+	return ring.volume
+}
+
+func (ring CandidateRing) FillAmountS(orderhash common.Hash) *big.Rat {
+	return ring.filledOrders[orderhash]
+}
+
+func (ring CandidateRing) key() string {
+	key := ""
+	for _, hash := range ring.orderhashes {
+		key += hash.Hex()
+	}
+	return key
+}
+
+//greedy: the ring that can get max received
+type greedySelector struct{}
+
+func newGreedySelector(options SelectionOptions, orders map[common.Hash]*types.OrderState) RingSelector {
+	return &greedySelector{}
+}
+
+func (s *greedySelector) Next(list CandidateRingList) (int, bool) {
+	if len(list) <= 0 {
+		return -1, false
+	}
+	sort.Sort(list)
+	return 0, true
+}
+
+func (s *greedySelector) Taken(ring CandidateRing) {}
+
+//max_volume: the ring that exchanges max value, received decides when volumes are equal
+type maxVolumeSelector struct{}
+
+func newMaxVolumeSelector(options SelectionOptions, orders map[common.Hash]*types.OrderState) RingSelector {
+	return &maxVolumeSelector{}
+}
+
+func (s *maxVolumeSelector) Next(list CandidateRingList) (int, bool) {
+	if len(list) <= 0 {
+		return -1, false
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if cmp := ratOrZero(list[i].volume).Cmp(ratOrZero(list[j].volume)); cmp != 0 {
+			return cmp > 0
+		}
+		return list[i].received.Cmp(list[j].received) > 0
+	})
+	return 0, true
+}
+
+func (s *maxVolumeSelector) Taken(ring CandidateRing) {}
+
+//round_robin: the ring with the oldest order first, and every owner can be served only once before the others
+type roundRobinSelector struct {
+	orders map[common.Hash]*types.OrderState
+	served map[common.Address]bool
+}
+
+func newRoundRobinSelector(options SelectionOptions, orders map[common.Hash]*types.OrderState) RingSelector {
+	return &roundRobinSelector{orders: orders, served: make(map[common.Address]bool)}
+}
+
+func (s *roundRobinSelector) oldestTime(ring CandidateRing) int64 {
+	var oldest int64 = -1
+	for _, hash := range ring.orderhashes {
+		if order, exists := s.orders[hash]; exists {
+			createTime := order.RawOrder.CreateTime
+			if createTime <= 0 && nil != order.RawOrder.ValidSince {
+				createTime = order.RawOrder.ValidSince.Int64()
+			}
+			if oldest < 0 || createTime < oldest {
+				oldest = createTime
+			}
+		}
+	}
+	return oldest
+}
+
+func (s *roundRobinSelector) isServed(ring CandidateRing) bool {
+	for _, hash := range ring.orderhashes {
+		if order, exists := s.orders[hash]; exists && s.served[order.RawOrder.Owner] {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *roundRobinSelector) Next(list CandidateRingList) (int, bool) {
+	if len(list) <= 0 {
+		return -1, false
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		ti, tj := s.oldestTime(list[i]), s.oldestTime(list[j])
+		if ti != tj {
+			return ti < tj
+		}
+		return list[i].received.Cmp(list[j].received) > 0
+	})
+	for idx, ring := range list {
+		if !s.isServed(ring) {
+			return idx, true
+		}
+	}
+	//all owners have been served, start next round
+	s.served = make(map[common.Address]bool)
+	return 0, true
+}
+
+func (s *roundRobinSelector) Taken(ring CandidateRing) {
+	for _, hash := range ring.orderhashes {
+		if order, exists := s.orders[hash]; exists {
+			s.served[order.RawOrder.Owner] = true
+		}
+	}
+}
+
+//optimal: search the group of rings that has the max received and no order is used by two rings of it.
+//it is exponential, so it falls back to greedy when the count of candidate rings is bigger than BatchSize.
+type optimalSelector struct {
+	batchSize int
+	planned   bool
+	plan      []string
+	greedy    *greedySelector
+}
+
+func newOptimalSelector(options SelectionOptions, orders map[common.Hash]*types.OrderState) RingSelector {
+	s := &optimalSelector{batchSize: options.BatchSize}
+	if s.batchSize <= 0 {
+		s.batchSize = defaultSelectionBatchSize
+	}
+	return s
+}
+
+func (s *optimalSelector) Next(list CandidateRingList) (int, bool) {
+	if !s.planned {
+		s.planned = true
+		if len(list) > s.batchSize {
+			s.greedy = &greedySelector{}
+		} else {
+			for _, idx := range optimalRings(list) {
+				s.plan = append(s.plan, list[idx].key())
+			}
+		}
+	}
+	if nil != s.greedy {
+		return s.greedy.Next(list)
+	}
+	for len(s.plan) > 0 {
+		key := s.plan[0]
+		s.plan = s.plan[1:]
+		for idx, ring := range list {
+			if ring.key() == key {
+				return idx, true
+			}
+		}
+	}
+	return -1, false
+}
+
+func (s *optimalSelector) Taken(ring CandidateRing) {}
+
+//optimalRings returns the indexes of rings that have the max sum of received and don't share orders
+func optimalRings(list CandidateRingList) []int {
+	positive := []int{}
+	for idx, ring := range list {
+		if ring.received.Sign() > 0 {
+			positive = append(positive, idx)
+		}
+	}
+	sort.SliceStable(positive, func(i, j int) bool {
+		return list[positive[i]].received.Cmp(list[positive[j]].received) > 0
+	})
+
+	//remains[i] is the sum of received from positive[i] to the end, used as the upper bound
+	remains := make([]*big.Rat, len(positive)+1)
+	remains[len(positive)] = new(big.Rat)
+	for i := len(positive) - 1; i >= 0; i-- {
+		remains[i] = new(big.Rat).Add(remains[i+1], list[positive[i]].received)
+	}
+
+	best := []int{}
+	bestReceived := new(big.Rat)
+	used := make(map[common.Hash]bool)
+	var search func(i int, selected []int, received *big.Rat)
+	search = func(i int, selected []int, received *big.Rat) {
+		if received.Cmp(bestReceived) > 0 {
+			bestReceived = received
+			best = append([]int{}, selected...)
+		}
+		if i >= len(positive) || new(big.Rat).Add(received, remains[i]).Cmp(bestReceived) <= 0 {
+			return
+		}
+		ring := list[positive[i]]
+		conflict := false
+		for _, hash := range ring.orderhashes {
+			if used[hash] {
+				conflict = true
+				break
+			}
+		}
+		if !conflict {
+			for _, hash := range ring.orderhashes {
+				used[hash] = true
+			}
+			search(i+1, append(selected, positive[i]), new(big.Rat).Add(received, ring.received))
+			for _, hash := range ring.orderhashes {
+				delete(used, hash)
+			}
+		}
+		search(i+1, selected, received)
+	}
+	search(0, []int{}, new(big.Rat))
+	return best
+}
+
+func ratOrZero(r *big.Rat) *big.Rat {
+	if nil == r {
+		return new(big.Rat)
+	}
+	return r
+}
@@ -0,0 +1,144 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package timing_matcher
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func newTestCandidateRing(received, volume int64, hashes ...string) CandidateRing {
+	ring := CandidateRing{
+		received:     big.NewRat(received, 1),
+		cost:         big.NewRat(0, 1),
+		volume:       big.NewRat(volume, 1),
+		filledOrders: make(map[common.Hash]*big.Rat),
+	}
+	for _, hash := range hashes {
+		ring.orderhashes = append(ring.orderhashes, common.HexToHash(hash))
+		ring.filledOrders[common.HexToHash(hash)] = big.NewRat(1, 1)
+	}
+	return ring
+}
+
+//selectAll removes rings that share orders with the taken one, as selectRings does for full filled orders
+func selectAll(selector RingSelector, list CandidateRingList) []CandidateRing {
+	res := []CandidateRing{}
+	for len(list) > 0 {
+		idx, ok := selector.Next(list)
+		if !ok {
+			break
+		}
+		ring := list[idx]
+		res = append(res, ring)
+		selector.Taken(ring)
+		remains := CandidateRingList{}
+		for i, r := range list {
+			if i == idx {
+				continue
+			}
+			shared := false
+			for _, hash := range r.orderhashes {
+				if _, exists := ring.filledOrders[hash]; exists {
+					shared = true
+				}
+			}
+			if !shared {
+				remains = append(remains, r)
+			}
+		}
+		list = remains
+	}
+	return res
+}
+
+func sumReceived(rings []CandidateRing) int64 {
+	sum := new(big.Rat)
+	for _, ring := range rings {
+		sum.Add(sum, ring.received)
+	}
+	return sum.Num().Int64()
+}
+
+func TestSelectionStrategies(t *testing.T) {
+	list := func() CandidateRingList {
+		return CandidateRingList{
+			newTestCandidateRing(10, 1, "0x01", "0x02"),
+			newTestCandidateRing(7, 5, "0x01", "0x03"),
+			newTestCandidateRing(7, 2, "0x02", "0x04"),
+		}
+	}
+	options := SelectionOptions{BatchSize: 4}
+
+	greedy, _ := GetSelectionStrategy("")
+	if received := sumReceived(selectAll(greedy(options, nil), list())); received != 10 {
+		t.Fatalf("greedy should receive 10, got %d", received)
+	}
+
+	optimal, _ := GetSelectionStrategy(SelectionOptimal)
+	if received := sumReceived(selectAll(optimal(options, nil), list())); received != 14 {
+		t.Fatalf("optimal should receive 14, got %d", received)
+	}
+
+	maxVolume, _ := GetSelectionStrategy(SelectionMaxVolume)
+	if rings := selectAll(maxVolume(options, nil), list()); rings[0].volume.Cmp(big.NewRat(5, 1)) != 0 {
+		t.Fatalf("max_volume should take the ring with volume 5 first")
+	}
+
+	if _, err := GetSelectionStrategy("unknown"); nil == err {
+		t.Fatalf("unknown strategy should return error")
+	}
+}
+
+func TestRoundRobinSelector(t *testing.T) {
+	orders := make(map[common.Hash]*types.OrderState)
+	addOrder := func(hash, owner string, createTime int64) {
+		state := newTestOrder(hash, owner, lrc, weth, 1, 1)
+		state.RawOrder.CreateTime = createTime
+		orders[state.RawOrder.Hash] = state
+	}
+	addOrder("0x01", "0xa1", 100)
+	addOrder("0x02", "0xa2", 300)
+	addOrder("0x03", "0xa1", 200)
+	addOrder("0x04", "0xa3", 400)
+
+	list := CandidateRingList{
+		newTestCandidateRing(1, 1, "0x03", "0x04"),
+		newTestCandidateRing(9, 1, "0x02", "0x04"),
+		newTestCandidateRing(5, 1, "0x01", "0x02"),
+	}
+	selector := newRoundRobinSelector(SelectionOptions{}, orders)
+
+	idx, _ := selector.Next(list)
+	first := list[idx]
+	if first.orderhashes[0] != common.HexToHash("0x01") {
+		t.Fatalf("the ring with the oldest order should be taken first")
+	}
+	selector.Taken(first)
+	list = append(list[:idx:idx], list[idx+1:]...)
+
+	//owner 0xa1 and 0xa2 have been served and both rings left contain one of them, so a new round starts
+	idx, _ = selector.Next(list)
+	if list[idx].orderhashes[0] != common.HexToHash("0x03") {
+		t.Fatalf("the ring with the oldest order should be taken when all owners have been served")
+	}
+}
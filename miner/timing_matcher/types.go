@@ -19,6 +19,7 @@
 package timing_matcher
 
 import (
+	"github.com/Loopring/relay/types"
 	"github.com/ethereum/go-ethereum/common"
 	"math/big"
 )
@@ -237,9 +238,26 @@ import (
 //}
 
 type CandidateRing struct {
+	orderhashes  []common.Hash //in ring order, filledOrders can't keep it
 	filledOrders map[common.Hash]*big.Rat
 	received     *big.Rat
 	cost         *big.Rat
+	volume       *big.Rat
+}
+
+//NewCandidateRing must be called after the ring has been computed by evaluator
+func NewCandidateRing(ringState *types.Ring, volume *big.Rat) CandidateRing {
+	candidateRing := CandidateRing{
+		cost:         ringState.LegalCost,
+		received:     ringState.Received,
+		volume:       volume,
+		filledOrders: make(map[common.Hash]*big.Rat)}
+
+	for _, filledOrder := range ringState.Orders {
+		candidateRing.orderhashes = append(candidateRing.orderhashes, filledOrder.OrderState.RawOrder.Hash)
+		candidateRing.filledOrders[filledOrder.OrderState.RawOrder.Hash] = filledOrder.FillAmountS
+	}
+	return candidateRing
 }
 
 type CandidateRingList []CandidateRing
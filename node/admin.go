@@ -0,0 +1,136 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package node
+
+import (
+	"errors"
+
+	"github.com/Loopring/relay/admin"
+	"github.com/Loopring/relay/cache"
+	"github.com/Loopring/relay/crypto"
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/Loopring/relay/market/util"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ipfs/go-ipfs-api"
+)
+
+const readyzCacheKey = "lpr_readyz"
+
+var errNoMiner = errors.New("miner isn't running in relay mode")
+
+func (n *Node) registerAdmin() {
+	checks := []admin.Check{
+		{Name: "mysql", Critical: true, Check: n.rdsService.Ping},
+		{Name: "redis", Critical: true, Check: func() error {
+			_, err := cache.Exists(readyzCacheKey)
+			return err
+		}},
+		{Name: "eth_nodes", Critical: true, Check: func() error {
+			if len(ethaccessor.AvailableNodes()) == 0 {
+				return errors.New("there isn't an usable ethnode")
+			}
+			return nil
+		}},
+		//ipfs is only required when orders are broadcast
+		{Name: "ipfs", Critical: n.globalConfig.Gateway.IsBroadcast, Check: func() error {
+			if !shell.NewShell(n.globalConfig.Ipfs.Url()).IsUp() {
+				return errors.New("ipfs node is down")
+			}
+			return nil
+		}},
+	}
+	if nil != n.relayNode {
+		checks = append(checks, admin.Check{Name: "extractor_sync", Critical: true, Check: func() error {
+			if !n.relayNode.extractorService.IsSyncComplete() {
+				return errors.New("extractor is syncing")
+			}
+			return nil
+		}})
+	}
+	n.adminService = admin.NewAdminService(n.globalConfig.Admin, n, checks)
+}
+
+//Components lists the components enabled by the config, the ones able to report their states fill Detail
+func (n *Node) Components() []admin.ComponentStatus {
+	res := []admin.ComponentStatus{
+		{Name: "ordermanager"},
+		{Name: "marketcap"},
+	}
+	if nil != n.relayNode {
+		res = append(res,
+			admin.ComponentStatus{Name: "accountmanager"},
+			admin.ComponentStatus{Name: "txmanager"},
+			admin.ComponentStatus{Name: "tickercollector"},
+			admin.ComponentStatus{Name: "jsonrpc", Detail: "port:" + n.globalConfig.Jsonrpc.Port},
+			admin.ComponentStatus{Name: "socketio", Detail: "port:" + n.globalConfig.Websocket.Port},
+		)
+		if n.globalConfig.Extractor.Open {
+			extractorDetail := "syncing"
+			if n.relayNode.extractorService.IsSyncComplete() {
+				extractorDetail = "synced"
+			}
+			res = append(res, admin.ComponentStatus{Name: "extractor", Detail: extractorDetail})
+		}
+		if "" != n.globalConfig.Websocket.WsPort {
+			res = append(res, admin.ComponentStatus{Name: "websocket", Detail: "port:" + n.globalConfig.Websocket.WsPort})
+		}
+		if "" != n.globalConfig.Rest.Port {
+			res = append(res, admin.ComponentStatus{Name: "rest", Detail: "port:" + n.globalConfig.Rest.Port})
+		}
+	}
+	if nil != n.mineNode {
+		minerDetail := "matching"
+		if n.mineNode.miner.IsMatcherPaused() {
+			minerDetail = "paused"
+		}
+		res = append(res, admin.ComponentStatus{Name: "miner", Detail: minerDetail})
+	}
+	if "" != n.globalConfig.Metrics.Port {
+		res = append(res, admin.ComponentStatus{Name: "metrics", Detail: "port:" + n.globalConfig.Metrics.Port})
+	}
+	return res
+}
+
+func (n *Node) PauseMatcher() error {
+	if nil == n.mineNode {
+		return errNoMiner
+	}
+	n.mineNode.miner.PauseMatcher()
+	return nil
+}
+
+func (n *Node) ResumeMatcher() error {
+	if nil == n.mineNode {
+		return errNoMiner
+	}
+	n.mineNode.miner.ResumeMatcher()
+	return nil
+}
+
+func (n *Node) ReloadTokens() error {
+	return util.ReloadTokens(n.globalConfig.Market)
+}
+
+func (n *Node) UnlockAccount(address common.Address, passphrase string) error {
+	if nil == n.mineNode {
+		return errNoMiner
+	}
+	return crypto.UnlockKSAccount(accounts.Account{Address: address}, passphrase)
+}
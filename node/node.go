@@ -20,12 +20,15 @@ package node
 
 import (
 	"sync"
+	"time"
 
 	"fmt"
+	"github.com/Loopring/relay/admin"
 	"github.com/Loopring/relay/cache"
 	"github.com/Loopring/relay/config"
 	"github.com/Loopring/relay/crypto"
 	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/eventemiter"
 	"github.com/Loopring/relay/ethaccessor"
 	"github.com/Loopring/relay/extractor"
 	"github.com/Loopring/relay/gateway"
@@ -33,10 +36,12 @@ import (
 	"github.com/Loopring/relay/market"
 	"github.com/Loopring/relay/market/util"
 	"github.com/Loopring/relay/marketcap"
+	"github.com/Loopring/relay/metrics"
 	"github.com/Loopring/relay/miner"
 	"github.com/Loopring/relay/miner/timing_matcher"
 	"github.com/Loopring/relay/ordermanager"
 	"github.com/Loopring/relay/txmanager"
+	"github.com/Loopring/relay/types"
 	"github.com/Loopring/relay/usermanager"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"go.uber.org/zap"
@@ -50,13 +55,18 @@ const (
 type Node struct {
 	globalConfig      *config.GlobalConfig
 	rdsService        dao.RdsService
-	ipfsSubService    gateway.IPFSSubService
+	orderBroadcaster  gateway.OrderBroadcaster
 	orderManager      ordermanager.OrderManager
 	userManager       usermanager.UserManager
 	marketCapProvider marketcap.MarketCapProvider // 市值
 	accountManager    market.AccountManager
 	relayNode         *RelayNode
 	mineNode          *MineNode
+	metricsService    *metrics.MetricsService
+	adminService      *admin.AdminService
+
+	//syncs the tickers used by the exchange price feed on the node without relay
+	feedTickerCollector *market.CollectorImpl
 
 	stop   chan struct{}
 	lock   sync.RWMutex
@@ -68,27 +78,35 @@ type RelayNode struct {
 	trendManager     market.TrendManager
 	tickerCollector  market.CollectorImpl
 	jsonRpcService   gateway.JsonrpcServiceImpl
-	websocketService gateway.WebsocketServiceImpl
+	websocketService *gateway.WebsocketServiceImpl
+	restService      *gateway.RestServiceImpl
 	socketIOService  gateway.SocketIOServiceImpl
 	walletService    gateway.WalletServiceImpl
 	txManager        txmanager.TransactionManager
+	orderBook        *gateway.OrderBook
+	rateLimiter      *gateway.RateLimiter
 }
 
 func (n *RelayNode) Start() {
 	n.txManager.Start()
+	n.orderBook.Start()
 	n.extractorService.Start()
 
 	//gateway.NewJsonrpcService("8080").Start()
 	fmt.Println("step in relay node start")
 	n.tickerCollector.Start()
 	go n.jsonRpcService.Start()
-	//n.websocketService.Start()
+	go n.websocketService.Start()
+	go n.restService.Start()
 	go n.socketIOService.Start()
 
 }
 
 func (n *RelayNode) Stop() {
 	n.txManager.Stop()
+	n.orderBook.Stop()
+	n.websocketService.Stop()
+	n.restService.Stop()
 }
 
 type MineNode struct {
@@ -108,6 +126,8 @@ func NewNode(logger *zap.Logger, globalConfig *config.GlobalConfig) *Node {
 	n.globalConfig = globalConfig
 
 	// register
+	n.registerEventEmitter()
+	n.registerMetrics()
 	n.registerMysql() // lgh:初始化数据库引擎句柄和创建对应的表格，使用了 gorm 框架
 	fmt.Println("准备初始化 redis")
 	cache.NewCache(n.globalConfig.Redis) // lgh:初始化Redis,内存存储三方框架
@@ -132,6 +152,7 @@ func NewNode(logger *zap.Logger, globalConfig *config.GlobalConfig) *Node {
 		n.registerMineNode()
 		n.registerRelayNode()
 	}
+	n.registerAdmin()
 
 	return n
 }
@@ -142,10 +163,13 @@ func (n *Node) registerRelayNode() {
 	n.registerTransactionManager() // lgh:事务管理器
 	n.registerTrendManager()   // lgh: 趋势数据管理器，市场变化趋势信息
 	n.registerTickerCollector() // lgh: 负责统计24小时市场变化统计数据。目前支持的平台有OKEX，币安
+	n.registerOrderBook()
 	n.registerWalletService() // lgh: 初始化钱包服务实例
+	n.registerRateLimiter()
 	n.registerJsonRpcService()// lgh: 初始化 json-rpc 端口和绑定钱包WalletServiceHandler，start 的时候启动服务
 	n.registerWebsocketService() // lgh: 初始化 webSocket
 	n.registerSocketIOService()
+	n.registerRestService()
 	txmanager.NewTxView(n.rdsService)
 }
 
@@ -158,17 +182,27 @@ func (n *Node) registerMineNode() {
 }
 
 func (n *Node) Start() {
+	n.metricsService.Start()
+	n.adminService.Start()
 	n.orderManager.Start()
+	if nil != n.feedTickerCollector {
+		n.feedTickerCollector.Start()
+	}
 	n.marketCapProvider.Start()
 
 	if n.globalConfig.Mode != MODEL_MINER {
 		n.accountManager.Start()
 		n.relayNode.Start()
-		go ethaccessor.IncludeGasPriceEvaluator()
+		if nil != n.orderBroadcaster {
+			if err := n.orderBroadcaster.Start(); nil != err {
+				log.Errorf("failed to start %s broadcaster, err:%s", n.orderBroadcaster.Name(), err.Error())
+			}
+		}
+		go ethaccessor.StartGasOracle()
 	}
 	if n.globalConfig.Mode != MODEL_RELAY {
 		n.mineNode.Start()
-		ethaccessor.IncludeGasPriceEvaluator()
+		ethaccessor.StartGasOracle()
 	}
 }
 
@@ -186,6 +220,15 @@ func (n *Node) Wait() {
 func (n *Node) Stop() {
 	n.lock.RLock()
 	n.mineNode.Stop()
+	n.metricsService.Stop()
+	n.adminService.Stop()
+	ethaccessor.StopGasOracle()
+	if nil != n.orderBroadcaster {
+		n.orderBroadcaster.Stop()
+	}
+	if err := eventemitter.CloseDurable(); nil != err {
+		log.Errorf("failed to close event store, err:%s", err.Error())
+	}
 	//
 	//n.p2pListener.Stop()
 	//n.chainListener.Stop()
@@ -202,6 +245,47 @@ func (n *Node) registerCrypto(ks *keystore.KeyStore) {
 	crypto.Initialize(c)
 }
 
+//registerEventEmitter must be called before the subscribers are registered
+func (n *Node) registerEventEmitter() {
+	options := n.globalConfig.EventEmitter
+	if "" == options.DurableDir {
+		return
+	}
+	store, err := eventemitter.NewFileEventStore(eventemitter.FileEventStoreOptions{
+		Dir:         options.DurableDir,
+		SegmentSize: options.SegmentSize * 1024 * 1024,
+		Retention:   time.Duration(options.Retention) * time.Hour,
+	})
+	if nil != err {
+		log.Fatalf("failed to open event store:%s, err:%s", options.DurableDir, err.Error())
+	}
+	eventemitter.InitializeDurable(store, eventemitter.DurableOptions{
+		Topics:          options.DurableTopics,
+		RetryInterval:   time.Duration(options.RetryInterval) * time.Second,
+		MaxRedeliveries: options.MaxRedeliveries,
+		FlushInterval:   time.Duration(options.FlushInterval) * time.Second,
+	})
+	eventemitter.RegisterEventType(
+		&types.OrderFilledEvent{},
+		&types.OrderCancelledEvent{},
+		&types.CutoffEvent{},
+		&types.CutoffPairEvent{},
+		&types.RingMinedEvent{},
+		&types.ForkedEvent{},
+		&types.BlockEvent{},
+		&types.TransferEvent{},
+		&types.ApprovalEvent{},
+		&types.WethDepositEvent{},
+		&types.WethWithdrawalEvent{},
+		&types.SubmitRingMethodEvent{},
+		&types.OrderState{},
+	)
+}
+
+func (n *Node) registerMetrics() {
+	n.metricsService = metrics.NewMetricsService(n.globalConfig.Metrics)
+}
+
 func (n *Node) registerMysql() {
 	n.rdsService = dao.NewRdsService(n.globalConfig.Mysql)
 	n.rdsService.Prepare()
@@ -218,8 +302,15 @@ func (n *Node) registerExtractor() {
 	n.relayNode.extractorService = extractor.NewExtractorService(n.globalConfig.Extractor, n.rdsService)
 }
 
-func (n *Node) registerIPFSSubService() {
-	n.ipfsSubService = gateway.NewIPFSSubService(n.globalConfig.Ipfs)
+func (n *Node) registerOrderBroadcaster() {
+	if !n.globalConfig.Gateway.IsBroadcast {
+		return
+	}
+	broadcaster, err := gateway.NewOrderBroadcaster(&n.globalConfig.Gateway, &n.globalConfig.Ipfs)
+	if nil != err {
+		log.Fatalf("err:%s", err.Error())
+	}
+	n.orderBroadcaster = broadcaster
 }
 
 func (n *Node) registerOrderManager() {
@@ -239,28 +330,41 @@ func (n *Node) registerTransactionManager() {
 }
 
 func (n *Node) registerTickerCollector() {
-	n.relayNode.tickerCollector = *market.NewCollector(n.globalConfig.Market.CronJobLock)
+	n.relayNode.tickerCollector = *market.NewCollector(n.globalConfig.Market.CronJobLock, n.globalConfig.Market.Exchanges...)
+}
+
+func (n *Node) registerOrderBook() {
+	n.relayNode.orderBook = gateway.NewOrderBook(n.orderManager, &n.accountManager)
 }
 
 func (n *Node) registerWalletService() {
 	n.relayNode.walletService = *gateway.NewWalletService(n.relayNode.trendManager, n.orderManager,
-		n.accountManager, n.marketCapProvider, n.relayNode.tickerCollector, n.rdsService, n.globalConfig.Market.OldVersionWethAddress)
+		n.accountManager, n.marketCapProvider, n.relayNode.tickerCollector, n.rdsService, n.globalConfig.Market.OldVersionWethAddress, n.relayNode.orderBook,
+		gateway.NewSessionManager(n.globalConfig.Gateway.Session))
+}
+
+func (n *Node) registerRateLimiter() {
+	n.relayNode.rateLimiter = gateway.NewRateLimiter(n.globalConfig.RateLimit)
 }
 
 func (n *Node) registerJsonRpcService() {
-	n.relayNode.jsonRpcService = *gateway.NewJsonrpcService(n.globalConfig.Jsonrpc.Port, &n.relayNode.walletService)
+	n.relayNode.jsonRpcService = *gateway.NewJsonrpcService(n.globalConfig.Jsonrpc.Port, &n.relayNode.walletService, n.relayNode.rateLimiter)
 }
 
 func (n *Node) registerWebsocketService() {
-	n.relayNode.websocketService = *gateway.NewWebsocketService(n.globalConfig.Websocket.Port, n.relayNode.trendManager, n.accountManager, n.marketCapProvider)
+	n.relayNode.websocketService = gateway.NewWebsocketService(n.globalConfig.Websocket.WsPort, &n.relayNode.walletService)
 }
 
 func (n *Node) registerSocketIOService() {
-	n.relayNode.socketIOService = *gateway.NewSocketIOService(n.globalConfig.Websocket.Port, n.relayNode.walletService)
+	n.relayNode.socketIOService = *gateway.NewSocketIOService(n.globalConfig.Websocket.Port, n.relayNode.walletService, n.relayNode.rateLimiter)
+}
+
+func (n *Node) registerRestService() {
+	n.relayNode.restService = gateway.NewRestService(n.globalConfig.Rest.Port, &n.relayNode.walletService, n.relayNode.rateLimiter)
 }
 
 func (n *Node) registerMiner() {
-	//ethaccessor.IncludeGasPriceEvaluator()
+	//ethaccessor.StartGasOracle()
 	// lgh: 初始化环提交者
 	submitter, err := miner.NewSubmitter(n.globalConfig.Miner, n.rdsService, n.marketCapProvider)
 	if nil != err {
@@ -268,7 +372,7 @@ func (n *Node) registerMiner() {
 	}
 	evaluator := miner.NewEvaluator(n.marketCapProvider, n.globalConfig.Miner)
 	matcher := timing_matcher.NewTimingMatcher(
-		n.globalConfig.Miner.TimingMatcher,
+		n.globalConfig.Miner,
 		submitter, evaluator, n.orderManager, &n.accountManager, n.rdsService)
 	evaluator.SetMatcher(matcher)
 	// lgh: 一个矿工实体包含有 提交者，匹配者，计费者
@@ -276,15 +380,30 @@ func (n *Node) registerMiner() {
 }
 
 func (n *Node) registerGateway() {
-	gateway.Initialize(&n.globalConfig.GatewayFilters, &n.globalConfig.Gateway, &n.globalConfig.Ipfs, n.orderManager, n.marketCapProvider, n.accountManager)
+	n.registerOrderBroadcaster()
+	gateway.Initialize(&n.globalConfig.GatewayFilters, &n.globalConfig.Gateway, n.orderBroadcaster, n.orderManager, n.marketCapProvider, n.accountManager)
 }
 
 func (n *Node) registerUserManager() {
 	n.userManager = usermanager.NewUserManager(&n.globalConfig.UserManager, n.rdsService)
 }
 
+//registerMarketCap doesn't use the ticker collector and trend manager of relay node, they subscribe events and start jobs.
+//The exchange feed reads the tickers in redis and the fill feed reads the fills in mysql, which are saved by relay nodes.
+//The node without relay syncs the tickers by itself if market.cron_job_lock is true, and its fill feed is stale unless
+//it shares the database with a relay node, the stale prices are dropped by the aggregate provider.
 func (n *Node) registerMarketCap() {
-	n.marketCapProvider = marketcap.NewMarketCapProvider(n.globalConfig.MarketCap)
+	if marketcap.Provider_Aggregate == n.globalConfig.MarketCap.Provider {
+		collector := market.NewCollector(false, n.globalConfig.Market.Exchanges...)
+		if MODEL_MINER == n.globalConfig.Mode {
+			collector = market.NewCollector(n.globalConfig.Market.CronJobLock, n.globalConfig.Market.Exchanges...)
+			n.feedTickerCollector = collector
+		}
+		feeds := marketcap.NewPriceFeeds(n.globalConfig.MarketCap, collector, market.NewLatestFillPrices(n.rdsService))
+		n.marketCapProvider = marketcap.NewAggregateCapProvider(n.globalConfig.MarketCap, feeds)
+	} else {
+		n.marketCapProvider = marketcap.NewMarketCapProvider(n.globalConfig.MarketCap)
+	}
 }
 
 
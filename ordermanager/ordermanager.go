@@ -37,6 +37,7 @@ type OrderManager interface {
 	Stop()
 	MinerOrders(protocol, tokenS, tokenB common.Address, length int, reservedTime, startBlockNumber, endBlockNumber int64, filterOrderHashLists ...*types.OrderDelayList) []*types.OrderState
 	GetOrderBook(protocol, tokenS, tokenB common.Address, length int) ([]types.OrderState, error)
+	GetActiveOrderBook(protocol, tokenS, tokenB common.Address, fromId, limit int) ([]types.OrderState, int, error)
 	GetOrders(query map[string]interface{}, statusList []types.OrderStatus, pageIndex, pageSize int) (dao.PageResult, error)
 	GetOrderByHash(hash common.Hash) (*types.OrderState, error)
 	UpdateBroadcastTimeByHash(hash common.Hash, bt int) error
@@ -104,28 +105,28 @@ func (om *OrderManagerImpl) Start() {
 	om.warningWatcher = &eventemitter.Watcher{Concurrent: false, Handle: om.handleWarning}
 	om.submitRingMethodWatcher = &eventemitter.Watcher{Concurrent: false, Handle: om.handleSubmitRingMethod}
 
-	eventemitter.On(eventemitter.NewOrder, om.newOrderWatcher)
-	eventemitter.On(eventemitter.RingMined, om.ringMinedWatcher)
-	eventemitter.On(eventemitter.OrderFilled, om.fillOrderWatcher)
-	eventemitter.On(eventemitter.CancelOrder, om.cancelOrderWatcher)
-	eventemitter.On(eventemitter.CutoffAll, om.cutoffOrderWatcher)
-	eventemitter.On(eventemitter.CutoffPair, om.cutoffPairWatcher)
+	eventemitter.OnDurable(eventemitter.NewOrder, "ordermanager", om.newOrderWatcher)
+	eventemitter.OnDurable(eventemitter.RingMined, "ordermanager", om.ringMinedWatcher)
+	eventemitter.OnDurable(eventemitter.OrderFilled, "ordermanager", om.fillOrderWatcher)
+	eventemitter.OnDurable(eventemitter.CancelOrder, "ordermanager", om.cancelOrderWatcher)
+	eventemitter.OnDurable(eventemitter.CutoffAll, "ordermanager", om.cutoffOrderWatcher)
+	eventemitter.OnDurable(eventemitter.CutoffPair, "ordermanager", om.cutoffPairWatcher)
 	//eventemitter.On(eventemitter.SyncChainComplete, om.syncWatcher)
-	eventemitter.On(eventemitter.ChainForkDetected, om.forkWatcher)
-	eventemitter.On(eventemitter.ExtractorWarning, om.warningWatcher)
-	eventemitter.On(eventemitter.Miner_SubmitRing_Method, om.submitRingMethodWatcher)
+	eventemitter.OnDurable(eventemitter.ChainForkDetected, "ordermanager", om.forkWatcher)
+	eventemitter.OnDurable(eventemitter.ExtractorWarning, "ordermanager", om.warningWatcher)
+	eventemitter.OnDurable(eventemitter.Miner_SubmitRing_Method, "ordermanager", om.submitRingMethodWatcher)
 }
 
 func (om *OrderManagerImpl) Stop() {
-	eventemitter.Un(eventemitter.NewOrder, om.newOrderWatcher)
-	eventemitter.Un(eventemitter.RingMined, om.ringMinedWatcher)
-	eventemitter.Un(eventemitter.OrderFilled, om.fillOrderWatcher)
-	eventemitter.Un(eventemitter.CancelOrder, om.cancelOrderWatcher)
-	eventemitter.Un(eventemitter.CutoffAll, om.cutoffOrderWatcher)
+	eventemitter.UnDurable(eventemitter.NewOrder, "ordermanager", om.newOrderWatcher)
+	eventemitter.UnDurable(eventemitter.RingMined, "ordermanager", om.ringMinedWatcher)
+	eventemitter.UnDurable(eventemitter.OrderFilled, "ordermanager", om.fillOrderWatcher)
+	eventemitter.UnDurable(eventemitter.CancelOrder, "ordermanager", om.cancelOrderWatcher)
+	eventemitter.UnDurable(eventemitter.CutoffAll, "ordermanager", om.cutoffOrderWatcher)
 	//eventemitter.Un(eventemitter.SyncChainComplete, om.syncWatcher)
-	eventemitter.Un(eventemitter.ChainForkDetected, om.forkWatcher)
-	eventemitter.Un(eventemitter.ExtractorWarning, om.warningWatcher)
-	eventemitter.Un(eventemitter.Miner_SubmitRing_Method, om.submitRingMethodWatcher)
+	eventemitter.UnDurable(eventemitter.ChainForkDetected, "ordermanager", om.forkWatcher)
+	eventemitter.UnDurable(eventemitter.ExtractorWarning, "ordermanager", om.warningWatcher)
+	eventemitter.UnDurable(eventemitter.Miner_SubmitRing_Method, "ordermanager", om.submitRingMethodWatcher)
 
 	//om.ordersValidForMiner = false
 }
@@ -535,6 +536,27 @@ func (om *OrderManagerImpl) GetOrderBook(protocol, tokenS, tokenB common.Address
 	return list, nil
 }
 
+//GetActiveOrderBook returns a page of the unexpired orders of the book, and the id to load the next page from
+func (om *OrderManagerImpl) GetActiveOrderBook(protocol, tokenS, tokenB common.Address, fromId, limit int) ([]types.OrderState, int, error) {
+	var list []types.OrderState
+	models, err := om.rds.GetActiveOrderBook(protocol, tokenS, tokenB, fromId, limit)
+	if err != nil {
+		return list, fromId, err
+	}
+
+	lastId := fromId
+	for _, v := range models {
+		lastId = v.ID
+		var state types.OrderState
+		if err := v.ConvertUp(&state); err != nil {
+			continue
+		}
+		list = append(list, state)
+	}
+
+	return list, lastId, nil
+}
+
 // lgh: 只获取 types.OrderStatus{types.ORDER_NEW, types.ORDER_PARTIAL} 的。内部做了状态的过滤
 func (om *OrderManagerImpl) GetOrders(query map[string]interface{}, statusList []types.OrderStatus, pageIndex, pageSize int) (dao.PageResult, error) {
 	var (
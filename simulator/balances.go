@@ -0,0 +1,100 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package simulator
+
+import (
+	"math/big"
+
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+//accountBalances replaces the balances and allowances on chain, it implements miner.Matcher for evaluator
+type accountBalances struct {
+	balances map[common.Address]map[common.Address]*big.Rat
+}
+
+func newAccountBalances() *accountBalances {
+	return &accountBalances{balances: make(map[common.Address]map[common.Address]*big.Rat)}
+}
+
+func (b *accountBalances) Start() {}
+
+func (b *accountBalances) Stop() {}
+
+func (b *accountBalances) Pause() {}
+
+func (b *accountBalances) Resume() {}
+
+func (b *accountBalances) IsPaused() bool { return false }
+
+//GetAccountAvailableAmount ignores spender, allowance is always treated as enough
+func (b *accountBalances) GetAccountAvailableAmount(address, tokenAddress, spender common.Address) (*big.Rat, error) {
+	return new(big.Rat).Set(b.balance(address, tokenAddress)), nil
+}
+
+func (b *accountBalances) balance(owner, token common.Address) *big.Rat {
+	if _, exists := b.balances[owner]; !exists {
+		b.balances[owner] = make(map[common.Address]*big.Rat)
+	}
+	if _, exists := b.balances[owner][token]; !exists {
+		b.balances[owner][token] = new(big.Rat)
+	}
+	return b.balances[owner][token]
+}
+
+func (b *accountBalances) set(owner, token common.Address, amount *big.Rat) {
+	b.balance(owner, token).Set(amount)
+}
+
+func (b *accountBalances) add(owner, token common.Address, amount *big.Rat) {
+	balance := b.balance(owner, token)
+	balance.Add(balance, amount)
+}
+
+func (b *accountBalances) sub(owner, token common.Address, amount *big.Rat) {
+	balance := b.balance(owner, token)
+	balance.Sub(balance, amount)
+	if balance.Sign() < 0 {
+		balance.SetInt64(0)
+	}
+}
+
+//fillDefault gives every owner enough tokens to fill all of its orders, if the balance isn't set by snapshot
+func (b *accountBalances) fillDefault(orders []*types.OrderState, lrcAddress, feeReceipt common.Address) {
+	required := newAccountBalances()
+	totalLrcFee := new(big.Rat)
+	for _, order := range orders {
+		owner := order.RawOrder.Owner
+		required.add(owner, order.RawOrder.TokenS, new(big.Rat).SetInt(order.RawOrder.AmountS))
+		if nil != order.RawOrder.LrcFee {
+			required.add(owner, lrcAddress, new(big.Rat).SetInt(order.RawOrder.LrcFee))
+			totalLrcFee.Add(totalLrcFee, new(big.Rat).SetInt(order.RawOrder.LrcFee))
+		}
+	}
+	required.add(feeReceipt, lrcAddress, totalLrcFee)
+
+	for owner, tokens := range required.balances {
+		for token, amount := range tokens {
+			if _, exists := b.balances[owner][token]; !exists {
+				b.set(owner, token, amount)
+			}
+		}
+	}
+}
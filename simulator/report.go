@@ -0,0 +1,181 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type RingResult struct {
+	Time        int64
+	Ringhash    common.Hash
+	Orderhashes []common.Hash
+	LegalFee    *big.Rat
+	LegalCost   *big.Rat
+	Received    *big.Rat
+	Gas         *big.Int
+	GasPrice    *big.Int
+}
+
+type Report struct {
+	Strategy      string
+	Rounds        int
+	Orders        int
+	MatchedOrders int
+	Rings         []*RingResult
+
+	LegalFee  *big.Rat
+	LegalCost *big.Rat
+	Received  *big.Rat
+	Gas       *big.Int
+	GasCost   *big.Int //wei
+
+	HistoryFills       int
+	HistoryRings       int
+	HistoryLegalLrcFee *big.Rat
+
+	matched map[common.Hash]bool
+}
+
+func newReport(strategy string, ordersCount int) *Report {
+	if "" == strategy {
+		strategy = "greedy"
+	}
+	return &Report{
+		Strategy:           strategy,
+		Orders:             ordersCount,
+		Rings:              []*RingResult{},
+		LegalFee:           new(big.Rat),
+		LegalCost:          new(big.Rat),
+		Received:           new(big.Rat),
+		Gas:                new(big.Int),
+		GasCost:            new(big.Int),
+		HistoryLegalLrcFee: new(big.Rat),
+		matched:            make(map[common.Hash]bool),
+	}
+}
+
+func (r *Report) addRing(clock int64, ringState *types.Ring) {
+	res := &RingResult{
+		Time:      clock,
+		Ringhash:  ringState.Hash,
+		LegalFee:  ringState.LegalFee,
+		LegalCost: ringState.LegalCost,
+		Received:  ringState.Received,
+		Gas:       ringState.Gas,
+		GasPrice:  ringState.GasPrice,
+	}
+	for _, filledOrder := range ringState.Orders {
+		orderhash := filledOrder.OrderState.RawOrder.Hash
+		res.Orderhashes = append(res.Orderhashes, orderhash)
+		if !r.matched[orderhash] {
+			r.matched[orderhash] = true
+			r.MatchedOrders++
+		}
+	}
+	r.Rings = append(r.Rings, res)
+
+	r.LegalFee.Add(r.LegalFee, ringState.LegalFee)
+	r.LegalCost.Add(r.LegalCost, ringState.LegalCost)
+	r.Received.Add(r.Received, ringState.Received)
+	r.Gas.Add(r.Gas, ringState.Gas)
+	r.GasCost.Add(r.GasCost, new(big.Int).Mul(ringState.Gas, ringState.GasPrice))
+}
+
+//Print writes the report as text, every ring is printed if withRings is true
+func (r *Report) Print(w io.Writer, withRings bool) {
+	fmt.Fprintf(w, "strategy:        %s\n", r.Strategy)
+	fmt.Fprintf(w, "rounds:          %d\n", r.Rounds)
+	fmt.Fprintf(w, "orders:          %d, matched:%d\n", r.Orders, r.MatchedOrders)
+	fmt.Fprintf(w, "rings:           %d\n", len(r.Rings))
+	fmt.Fprintf(w, "legal fee:       %s\n", r.LegalFee.FloatString(4))
+	fmt.Fprintf(w, "legal gas cost:  %s\n", r.LegalCost.FloatString(4))
+	fmt.Fprintf(w, "received:        %s\n", r.Received.FloatString(4))
+	fmt.Fprintf(w, "gas:             %s\n", r.Gas.String())
+	fmt.Fprintf(w, "gas cost(wei):   %s\n", r.GasCost.String())
+	fmt.Fprintf(w, "history fills:   %d, rings:%d, legal lrc fee:%s\n", r.HistoryFills, r.HistoryRings, r.HistoryLegalLrcFee.FloatString(4))
+	if withRings {
+		for _, ring := range r.Rings {
+			fmt.Fprintf(w, "time:%d ringhash:%s orders:%d legalFee:%s legalCost:%s received:%s gas:%s gasPrice:%s\n",
+				ring.Time, ring.Ringhash.Hex(), len(ring.Orderhashes), ring.LegalFee.FloatString(4), ring.LegalCost.FloatString(4), ring.Received.FloatString(4), ring.Gas.String(), ring.GasPrice.String())
+		}
+	}
+}
+
+func (r *Report) MarshalJSON() ([]byte, error) {
+	type ringJSON struct {
+		Time        int64         `json:"time"`
+		Ringhash    common.Hash   `json:"ringhash"`
+		Orderhashes []common.Hash `json:"orderhashes"`
+		LegalFee    string        `json:"legalFee"`
+		LegalCost   string        `json:"legalCost"`
+		Received    string        `json:"received"`
+		Gas         string        `json:"gas"`
+		GasPrice    string        `json:"gasPrice"`
+	}
+	type reportJSON struct {
+		Strategy           string     `json:"strategy"`
+		Rounds             int        `json:"rounds"`
+		Orders             int        `json:"orders"`
+		MatchedOrders      int        `json:"matchedOrders"`
+		Rings              []ringJSON `json:"rings"`
+		LegalFee           string     `json:"legalFee"`
+		LegalCost          string     `json:"legalCost"`
+		Received           string     `json:"received"`
+		Gas                string     `json:"gas"`
+		GasCost            string     `json:"gasCost"`
+		HistoryFills       int        `json:"historyFills"`
+		HistoryRings       int        `json:"historyRings"`
+		HistoryLegalLrcFee string     `json:"historyLegalLrcFee"`
+	}
+	res := reportJSON{
+		Strategy:           r.Strategy,
+		Rounds:             r.Rounds,
+		Orders:             r.Orders,
+		MatchedOrders:      r.MatchedOrders,
+		Rings:              []ringJSON{},
+		LegalFee:           r.LegalFee.FloatString(4),
+		LegalCost:          r.LegalCost.FloatString(4),
+		Received:           r.Received.FloatString(4),
+		Gas:                r.Gas.String(),
+		GasCost:            r.GasCost.String(),
+		HistoryFills:       r.HistoryFills,
+		HistoryRings:       r.HistoryRings,
+		HistoryLegalLrcFee: r.HistoryLegalLrcFee.FloatString(4),
+	}
+	for _, ring := range r.Rings {
+		res.Rings = append(res.Rings, ringJSON{
+			Time:        ring.Time,
+			Ringhash:    ring.Ringhash,
+			Orderhashes: ring.Orderhashes,
+			LegalFee:    ring.LegalFee.FloatString(4),
+			LegalCost:   ring.LegalCost.FloatString(4),
+			Received:    ring.Received.FloatString(4),
+			Gas:         ring.Gas.String(),
+			GasPrice:    ring.GasPrice.String(),
+		})
+	}
+	return json.Marshal(res)
+}
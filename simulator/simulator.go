@@ -0,0 +1,329 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/ethaccessor"
+	"github.com/Loopring/relay/marketcap"
+	"github.com/Loopring/relay/miner"
+	"github.com/Loopring/relay/miner/timing_matcher"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/**
+离线撮合模拟：按快照中订单的创建时间推进模拟时钟，每轮取出有效的订单，
+使用与timing_matcher相同的成环、选择策略以及evaluator计算收益，
+环被选中后立即认为已经上链，更新订单的成交量和模拟的余额
+*/
+
+type Options struct {
+	Miner          config.MinerOptions
+	DustOrderValue int64
+	Currency       string
+	GasPrice       *big.Int
+	StartTime      int64 //unix seconds, 0 means the create time of the first order
+	EndTime        int64 //unix seconds, 0 means the create time of the last order
+	LrcAddress     common.Address
+	EthAddress     common.Address
+}
+
+type Simulator struct {
+	options    Options
+	evaluator  *miner.Evaluator
+	mc         marketcap.MarketCapProvider
+	strategy   timing_matcher.SelectionStrategy
+	balances   *accountBalances
+	orders     []*types.OrderState
+	fills      []*dao.FillEvent
+	feeReceipt common.Address
+}
+
+func NewSimulator(options Options, orders []*types.OrderState, fills []*dao.FillEvent, prices []*TokenPrice, balances []*Balance) (*Simulator, error) {
+	if len(orders) <= 0 {
+		return nil, errors.New("there isn't any order in snapshot")
+	}
+	if nil == options.GasPrice || options.GasPrice.Sign() <= 0 {
+		return nil, errors.New("gas price must be set")
+	}
+	if nil == options.Miner.TimingMatcher {
+		options.Miner.TimingMatcher = &config.TimingMatcher{}
+	}
+	s := &Simulator{}
+	s.options = options
+	s.orders = orders
+	s.fills = fills
+	s.feeReceipt = common.HexToAddress(options.Miner.FeeReceipt)
+	sort.SliceStable(s.orders, func(i, j int) bool {
+		return s.orders[i].RawOrder.CreateTime < s.orders[j].RawOrder.CreateTime
+	})
+
+	if strategy, err := timing_matcher.GetSelectionStrategy(options.Miner.TimingMatcher.SelectionStrategy); nil != err {
+		return nil, err
+	} else {
+		s.strategy = strategy
+	}
+
+	s.mc = marketcap.NewFixedCapProvider(options.Currency, options.EthAddress, ConvertPrices(prices))
+	if _, err := s.mc.GetEthCap(); nil != err {
+		return nil, fmt.Errorf("price of eth:%s, err:%s", options.EthAddress.Hex(), err.Error())
+	}
+	if _, err := s.mc.GetMarketCap(options.LrcAddress); nil != err {
+		return nil, fmt.Errorf("price of lrc:%s, err:%s", options.LrcAddress.Hex(), err.Error())
+	}
+
+	s.balances = newAccountBalances()
+	for _, balance := range balances {
+		amount, ok := new(big.Rat).SetString(balance.Amount)
+		if !ok {
+			return nil, fmt.Errorf("invalid balance:%s of owner:%s", balance.Amount, balance.Owner)
+		}
+		s.balances.set(common.HexToAddress(balance.Owner), common.HexToAddress(balance.Token), amount)
+	}
+	s.balances.fillDefault(s.orders, options.LrcAddress, s.feeReceipt)
+
+	protocols := make(map[common.Address]*ethaccessor.ProtocolAddress)
+	for _, order := range s.orders {
+		if _, exists := protocols[order.RawOrder.Protocol]; !exists {
+			protocols[order.RawOrder.Protocol] = &ethaccessor.ProtocolAddress{
+				Version:         "simulated",
+				ContractAddress: order.RawOrder.Protocol,
+				LrcTokenAddress: options.LrcAddress,
+				DelegateAddress: order.RawOrder.DelegateAddress,
+			}
+		}
+	}
+	protocolAddresses := []*ethaccessor.ProtocolAddress{}
+	for _, impl := range protocols {
+		protocolAddresses = append(protocolAddresses, impl)
+	}
+	ethaccessor.InitializeOffline(protocolAddresses, options.GasPrice)
+
+	s.evaluator = miner.NewEvaluator(s.mc, options.Miner)
+	s.evaluator.SetMatcher(s.balances)
+	return s, nil
+}
+
+func (s *Simulator) Run() *Report {
+	report := newReport(s.options.Miner.TimingMatcher.SelectionStrategy, len(s.orders))
+	s.reportHistory(report)
+
+	start := s.options.StartTime
+	if start <= 0 {
+		start = s.orders[0].RawOrder.CreateTime
+	}
+	end := s.options.EndTime
+	if end <= 0 {
+		end = s.orders[len(s.orders)-1].RawOrder.CreateTime
+	}
+	//duration of timing matcher is millisecond
+	step := s.options.Miner.TimingMatcher.Duration / 1000
+	if step <= 0 {
+		step = 1
+	}
+
+	for clock := start; ; clock += step {
+		report.Rounds++
+		if active := s.activeOrders(clock); len(active) > 1 {
+			s.matchRound(report, clock, active)
+		}
+		if clock >= end {
+			break
+		}
+	}
+	return report
+}
+
+func (s *Simulator) activeOrders(clock int64) []*types.OrderState {
+	active := []*types.OrderState{}
+	for _, order := range s.orders {
+		if order.RawOrder.CreateTime > clock {
+			break
+		}
+		if nil != order.RawOrder.ValidSince && order.RawOrder.ValidSince.Int64() > clock {
+			continue
+		}
+		if nil != order.RawOrder.ValidUntil && order.RawOrder.ValidUntil.Int64() > 0 && order.RawOrder.ValidUntil.Int64() <= clock {
+			continue
+		}
+		if s.isOrderFullFinished(order) {
+			continue
+		}
+		active = append(active, order)
+	}
+	return active
+}
+
+func (s *Simulator) matchRound(report *Report, clock int64, active []*types.OrderState) {
+	orders := make(map[common.Hash]*types.OrderState)
+	for _, order := range active {
+		orders[order.RawOrder.Hash] = order
+	}
+
+	candidates := timing_matcher.CandidateRingList{}
+	for _, ringOrders := range timing_matcher.FindRings(active, s.options.Miner.RingMaxLength, s.options.Miner.TimingMatcher.RoundOrdersCount) {
+		if candidate, err := s.generateCandidateRing(ringOrders); nil == err {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	selector := s.strategy(timing_matcher.SelectionOptions{BatchSize: s.options.Miner.TimingMatcher.SelectionBatchSize}, orders)
+	for len(candidates) > 0 {
+		idx, ok := selector.Next(candidates)
+		if !ok {
+			break
+		}
+		candidate := candidates[idx]
+		candidates = append(candidates[:idx:idx], candidates[idx+1:]...)
+
+		ringOrders := ringOrdersOf(candidate, orders)
+		ringState, err := s.computeRing(ringOrders)
+		if nil != err || ringState.Received.Sign() <= 0 {
+			continue
+		}
+		s.settle(ringState, orders)
+		selector.Taken(candidate)
+		report.addRing(clock, ringState)
+
+		candidates = s.refreshCandidateRings(candidates, orders)
+	}
+}
+
+//refreshCandidateRings computes the rings again after some orders have been filled
+func (s *Simulator) refreshCandidateRings(list timing_matcher.CandidateRingList, orders map[common.Hash]*types.OrderState) timing_matcher.CandidateRingList {
+	res := timing_matcher.CandidateRingList{}
+	for _, ring := range list {
+		ringOrders := ringOrdersOf(ring, orders)
+		finished := false
+		for _, order := range ringOrders {
+			if s.isOrderFullFinished(order) {
+				finished = true
+				break
+			}
+		}
+		if finished {
+			continue
+		}
+		if candidate, err := s.generateCandidateRing(ringOrders); nil == err {
+			res = append(res, candidate)
+		}
+	}
+	return res
+}
+
+func ringOrdersOf(ring timing_matcher.CandidateRing, orders map[common.Hash]*types.OrderState) []*types.OrderState {
+	ringOrders := []*types.OrderState{}
+	for _, hash := range ring.OrderHashes() {
+		ringOrders = append(ringOrders, orders[hash])
+	}
+	return ringOrders
+}
+
+func (s *Simulator) generateCandidateRing(orders []*types.OrderState) (timing_matcher.CandidateRing, error) {
+	ringState, err := s.computeRing(orders)
+	if nil != err {
+		return timing_matcher.CandidateRing{}, err
+	}
+	if ringState.Received.Sign() <= 0 {
+		return timing_matcher.CandidateRing{}, errors.New("received not enough")
+	}
+	volume, _ := s.evaluator.LegalVolume(ringState)
+	return timing_matcher.NewCandidateRing(ringState, volume), nil
+}
+
+//computeRing is the same as Market.generateRingSubmitInfo, but uses the simulated balances
+func (s *Simulator) computeRing(orders []*types.OrderState) (*types.Ring, error) {
+	filledOrders := []*types.FilledOrder{}
+	for _, order := range orders {
+		lrcBalance, _ := s.balances.GetAccountAvailableAmount(order.RawOrder.Owner, s.options.LrcAddress, order.RawOrder.DelegateAddress)
+		tokenSBalance, _ := s.balances.GetAccountAvailableAmount(order.RawOrder.Owner, order.RawOrder.TokenS, order.RawOrder.DelegateAddress)
+		if tokenSBalance.Sign() <= 0 || s.isValueDusted(order.RawOrder.TokenS, tokenSBalance) {
+			return nil, fmt.Errorf("owner:%s token:%s balance is not enough", order.RawOrder.Owner.Hex(), order.RawOrder.TokenS.Hex())
+		}
+		filledOrders = append(filledOrders, types.ConvertOrderStateToFilledOrder(*order, lrcBalance, tokenSBalance, s.options.LrcAddress))
+	}
+	ringState := miner.NewRing(filledOrders)
+	if err := s.evaluator.ComputeRing(ringState); nil != err {
+		return nil, err
+	}
+	ringState.Hash = ringState.GenerateHash(s.feeReceipt)
+	return ringState, nil
+}
+
+//settle treats the ring as mined at once, the lrc fee is paid to feeReceipt or by feeReceipt when miner chooses margin split
+func (s *Simulator) settle(ringState *types.Ring, orders map[common.Hash]*types.OrderState) {
+	for _, filledOrder := range ringState.Orders {
+		state := orders[filledOrder.OrderState.RawOrder.Hash]
+		state.DealtAmountS.Add(state.DealtAmountS, ratToInt(filledOrder.FillAmountS))
+		state.DealtAmountB.Add(state.DealtAmountB, ratToInt(filledOrder.FillAmountB))
+
+		owner := state.RawOrder.Owner
+		s.balances.sub(owner, state.RawOrder.TokenS, filledOrder.FillAmountS)
+		s.balances.add(owner, state.RawOrder.TokenB, filledOrder.FillAmountB)
+		if filledOrder.FeeSelection == 0 {
+			s.balances.sub(owner, s.options.LrcAddress, filledOrder.LrcFee)
+			s.balances.add(s.feeReceipt, s.options.LrcAddress, filledOrder.LrcFee)
+		} else {
+			s.balances.sub(s.feeReceipt, s.options.LrcAddress, filledOrder.LrcFee)
+			s.balances.add(owner, s.options.LrcAddress, filledOrder.LrcFee)
+		}
+	}
+}
+
+func (s *Simulator) isValueDusted(token common.Address, amount *big.Rat) bool {
+	if value, err := s.mc.LegalCurrencyValue(token, amount); nil != err {
+		return false
+	} else {
+		return value.Cmp(new(big.Rat).SetInt64(s.options.DustOrderValue)) <= 0
+	}
+}
+
+//isOrderFullFinished is the same as OrderManager.IsOrderFullFinished
+func (s *Simulator) isOrderFullFinished(state *types.OrderState) bool {
+	remainedAmountS, _ := state.RemainedAmount()
+	return s.isValueDusted(state.RawOrder.TokenS, remainedAmountS)
+}
+
+func (s *Simulator) reportHistory(report *Report) {
+	ringhashes := make(map[string]bool)
+	for _, fill := range s.fills {
+		if fill.Fork {
+			continue
+		}
+		report.HistoryFills++
+		ringhashes[fill.RingHash] = true
+		if lrcFee, ok := new(big.Rat).SetString(fill.LrcFee); ok {
+			if legalFee, err := s.mc.LegalCurrencyValue(s.options.LrcAddress, lrcFee); nil == err {
+				report.HistoryLegalLrcFee.Add(report.HistoryLegalLrcFee, legalFee)
+			}
+		}
+	}
+	report.HistoryRings = len(ringhashes)
+}
+
+func ratToInt(rat *big.Rat) *big.Int {
+	return new(big.Int).Div(rat.Num(), rat.Denom())
+}
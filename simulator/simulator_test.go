@@ -0,0 +1,140 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package simulator_test
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Loopring/relay/config"
+	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/log"
+	"github.com/Loopring/relay/simulator"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+)
+
+var (
+	lrcAddress  = common.HexToAddress("0xef68e7c694f40c8202821edf525de3782458639f")
+	wethAddress = common.HexToAddress("0x2956356cd2a2bf3202f771f50d3d14a367b48070")
+	protocol    = common.HexToAddress("0x8d8812b72d1e4ffcec158d25f56748b7d67c1e78")
+	delegate    = common.HexToAddress("0x17233e07c67d086464fd408148c3abb56245fa64")
+)
+
+func newOrder(hash, owner string, tokenS, tokenB common.Address, amountS, amountB string, createTime int64) *types.OrderState {
+	state := &types.OrderState{}
+	state.RawOrder.Hash = common.HexToHash(hash)
+	state.RawOrder.Owner = common.HexToAddress(owner)
+	state.RawOrder.Protocol = protocol
+	state.RawOrder.DelegateAddress = delegate
+	state.RawOrder.TokenS = tokenS
+	state.RawOrder.TokenB = tokenB
+	state.RawOrder.AmountS, _ = new(big.Int).SetString(amountS, 10)
+	state.RawOrder.AmountB, _ = new(big.Int).SetString(amountB, 10)
+	state.RawOrder.LrcFee, _ = new(big.Int).SetString("10000000000000000000", 10)
+	state.RawOrder.MarginSplitPercentage = 100
+	state.RawOrder.ValidSince = big.NewInt(createTime)
+	state.RawOrder.ValidUntil = big.NewInt(createTime + 3600)
+	state.RawOrder.CreateTime = createTime
+	state.DealtAmountS = big.NewInt(0)
+	state.DealtAmountB = big.NewInt(0)
+	state.SplitAmountS = big.NewInt(0)
+	state.SplitAmountB = big.NewInt(0)
+	state.CancelledAmountS = big.NewInt(0)
+	state.CancelledAmountB = big.NewInt(0)
+	return state
+}
+
+func TestSimulator_Run(t *testing.T) {
+	log.Initialize(config.LogOptions{ZapOpts: zap.NewProductionConfig()})
+
+	options := simulator.Options{}
+	options.Miner.FeeReceipt = "0x4bad3053d574cd54513babe21db3f09bea1d387d"
+	options.Miner.RingMaxLength = 4
+	options.Miner.Subsidy = 1
+	options.Miner.WalletSplit = 0.8
+	options.Miner.RateRatioCVSThreshold = 1000000000000000
+	options.Miner.MinGasLimit = 1000000000
+	options.Miner.MaxGasLimit = 100000000000
+	options.Miner.TimingMatcher = &config.TimingMatcher{Duration: 10000, RoundOrdersCount: 2}
+	options.Currency = "USD"
+	options.DustOrderValue = 1
+	options.GasPrice = big.NewInt(20000000000)
+	options.LrcAddress = lrcAddress
+	options.EthAddress = wethAddress
+
+	prices := []*simulator.TokenPrice{
+		{Address: lrcAddress.Hex(), Symbol: "LRC", Decimals: 18, Price: 0.5},
+		{Address: wethAddress.Hex(), Symbol: "WETH", Decimals: 18, Price: 500},
+	}
+	orders := []*types.OrderState{
+		newOrder("0x01", "0xa1", lrcAddress, wethAddress, "1000000000000000000000", "1000000000000000000", 1000),
+		newOrder("0x02", "0xa2", wethAddress, lrcAddress, "1100000000000000000", "1000000000000000000000", 1020),
+	}
+	fills := []*dao.FillEvent{
+		{RingHash: "0x11", LrcFee: "10000000000000000000"},
+		{RingHash: "0x11", LrcFee: "10000000000000000000"},
+	}
+
+	s, err := simulator.NewSimulator(options, orders, fills, prices, []*simulator.Balance{})
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	report := s.Run()
+	if len(report.Rings) != 1 || report.MatchedOrders != 2 {
+		t.Fatalf("expect 1 ring with 2 orders, got %d rings, %d orders", len(report.Rings), report.MatchedOrders)
+	}
+	if report.Received.Sign() <= 0 {
+		t.Fatalf("received should be positive, got %s", report.Received.FloatString(4))
+	}
+	if report.Rounds != 3 {
+		t.Fatalf("expect 3 rounds, got %d", report.Rounds)
+	}
+	if report.HistoryRings != 1 || report.HistoryFills != 2 || report.HistoryLegalLrcFee.Cmp(big.NewRat(10, 1)) != 0 {
+		t.Fatalf("unexpected history, rings:%d, fills:%d, lrcFee:%s", report.HistoryRings, report.HistoryFills, report.HistoryLegalLrcFee.FloatString(2))
+	}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "simulator")
+	if nil != err {
+		t.Fatalf("err:%s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+
+	csvFile := filepath.Join(dir, "fills.csv")
+	ioutil.WriteFile(csvFile, []byte("ring_hash,order_hash,lrc_fee,fork,block_number\n0x11,0x01,100,0,10\n0x11,0x02,200,1,10\n"), 0644)
+	if fills, err := simulator.LoadFills(csvFile); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	} else if len(fills) != 2 || fills[1].LrcFee != "200" || !fills[1].Fork || fills[0].BlockNumber != 10 {
+		t.Fatalf("unexpected fills:%+v", fills)
+	}
+
+	jsonFile := filepath.Join(dir, "prices.json")
+	ioutil.WriteFile(jsonFile, []byte(`[{"address":"0xef68e7c694f40c8202821edf525de3782458639f","symbol":"LRC","decimals":18,"price":0.5}]`), 0644)
+	if prices, err := simulator.LoadPrices(jsonFile); nil != err {
+		t.Fatalf("err:%s", err.Error())
+	} else if len(prices) != 1 || prices[0].Symbol != "LRC" || prices[0].Decimals != 18 || prices[0].Price != 0.5 {
+		t.Fatalf("unexpected prices:%+v", prices[0])
+	}
+}
@@ -0,0 +1,266 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package simulator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Loopring/relay/dao"
+	"github.com/Loopring/relay/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+/**
+快照文件是数据库表的导出，支持json数组和带表头的csv两种格式，
+字段名可以是gorm的列名、json tag或者结构体字段名，不区分大小写
+*/
+
+//TokenPrice is the price of one token in legal currency, used instead of marketcap
+type TokenPrice struct {
+	Address  string  `json:"address"`
+	Symbol   string  `json:"symbol"`
+	Decimals int     `json:"decimals"`
+	Price    float64 `json:"price"`
+}
+
+//Balance is the balance of owner before simulating, Amount is the raw amount without decimals
+type Balance struct {
+	Owner  string `json:"owner"`
+	Token  string `json:"token"`
+	Amount string `json:"amount"`
+}
+
+func LoadOrders(file string) ([]*dao.Order, error) {
+	orders := []*dao.Order{}
+	err := loadSnapshot(file, func() interface{} {
+		order := &dao.Order{}
+		orders = append(orders, order)
+		return order
+	})
+	return orders, err
+}
+
+func LoadFills(file string) ([]*dao.FillEvent, error) {
+	fills := []*dao.FillEvent{}
+	err := loadSnapshot(file, func() interface{} {
+		fill := &dao.FillEvent{}
+		fills = append(fills, fill)
+		return fill
+	})
+	return fills, err
+}
+
+func LoadPrices(file string) ([]*TokenPrice, error) {
+	prices := []*TokenPrice{}
+	err := loadSnapshot(file, func() interface{} {
+		price := &TokenPrice{}
+		prices = append(prices, price)
+		return price
+	})
+	return prices, err
+}
+
+func LoadBalances(file string) ([]*Balance, error) {
+	balances := []*Balance{}
+	err := loadSnapshot(file, func() interface{} {
+		balance := &Balance{}
+		balances = append(balances, balance)
+		return balance
+	})
+	return balances, err
+}
+
+//ConvertOrders converts the orders to the state before any of them has been filled
+func ConvertOrders(models []*dao.Order) ([]*types.OrderState, error) {
+	states := []*types.OrderState{}
+	for _, model := range models {
+		state := &types.OrderState{}
+		if err := model.ConvertUp(state); nil != err {
+			return nil, fmt.Errorf("order:%s, err:%s", model.OrderHash, err.Error())
+		}
+		state.DealtAmountS = big.NewInt(0)
+		state.DealtAmountB = big.NewInt(0)
+		state.SplitAmountS = big.NewInt(0)
+		state.SplitAmountB = big.NewInt(0)
+		state.CancelledAmountS = big.NewInt(0)
+		state.CancelledAmountB = big.NewInt(0)
+		state.Status = types.ORDER_NEW
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func ConvertPrices(prices []*TokenPrice) []*types.CurrencyMarketCap {
+	caps := []*types.CurrencyMarketCap{}
+	for _, price := range prices {
+		c := &types.CurrencyMarketCap{}
+		c.Address = common.HexToAddress(price.Address)
+		c.Id = price.Symbol
+		c.Name = price.Symbol
+		c.Symbol = price.Symbol
+		c.Decimals = new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(price.Decimals)), nil)
+		c.PriceUsd = new(big.Rat).SetFloat64(price.Price)
+		c.PriceCny = new(big.Rat).Set(c.PriceUsd)
+		c.PriceBtc = new(big.Rat).Set(c.PriceUsd)
+		caps = append(caps, c)
+	}
+	return caps
+}
+
+func loadSnapshot(file string, newRecord func() interface{}) error {
+	data, err := ioutil.ReadFile(file)
+	if nil != err {
+		return err
+	}
+	var rows []map[string]string
+	if strings.HasSuffix(strings.ToLower(file), ".csv") {
+		rows, err = csvRows(data)
+	} else {
+		rows, err = jsonRows(data)
+	}
+	if nil != err {
+		return fmt.Errorf("snapshot:%s, err:%s", file, err.Error())
+	}
+	for idx, row := range rows {
+		if err := setFields(newRecord(), row); nil != err {
+			return fmt.Errorf("snapshot:%s, row:%d, err:%s", file, idx, err.Error())
+		}
+	}
+	return nil
+}
+
+func jsonRows(data []byte) ([]map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var records []map[string]interface{}
+	if err := decoder.Decode(&records); nil != err {
+		return nil, err
+	}
+	rows := []map[string]string{}
+	for _, record := range records {
+		row := make(map[string]string)
+		for k, v := range record {
+			if nil != v {
+				row[strings.ToLower(k)] = fmt.Sprint(v)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func csvRows(data []byte) ([]map[string]string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	header, err := reader.Read()
+	if nil != err {
+		return nil, err
+	}
+	rows := []map[string]string{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if nil != err {
+			return nil, err
+		}
+		row := make(map[string]string)
+		for idx, name := range header {
+			if idx < len(record) {
+				row[strings.ToLower(strings.TrimSpace(name))] = record[idx]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+//fieldNames returns the names that can be used in snapshot for the field
+func fieldNames(field reflect.StructField) []string {
+	names := []string{strings.ToLower(field.Name)}
+	for _, item := range strings.Split(field.Tag.Get("gorm"), ";") {
+		if strings.HasPrefix(item, "column:") {
+			names = append(names, strings.ToLower(strings.TrimPrefix(item, "column:")))
+		}
+	}
+	if jsonName := strings.Split(field.Tag.Get("json"), ",")[0]; "" != jsonName && "-" != jsonName {
+		names = append(names, strings.ToLower(jsonName))
+	}
+	return names
+}
+
+func setFields(record interface{}, row map[string]string) error {
+	v := reflect.ValueOf(record).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		for _, name := range fieldNames(t.Field(i)) {
+			value, exists := row[name]
+			if !exists || "" == value || "NULL" == value {
+				continue
+			}
+			if err := setValue(v.Field(i), value); nil != err {
+				return fmt.Errorf("field:%s, value:%s, err:%s", t.Field(i).Name, value, err.Error())
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func setValue(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(value); nil != err {
+			return err
+		} else {
+			field.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, err := strconv.ParseInt(value, 10, 64); nil != err {
+			return err
+		} else {
+			field.SetInt(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseUint(value, 10, 64); nil != err {
+			return err
+		} else {
+			field.SetUint(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(value, 64); nil != err {
+			return err
+		} else {
+			field.SetFloat(f)
+		}
+	default:
+		return fmt.Errorf("unsupported kind:%s", field.Kind().String())
+	}
+	return nil
+}
@@ -57,47 +57,47 @@ func (tm *TransactionManager) Start() {
 	log.Debugf("transaction manager start...")
 
 	tm.approveEventWatcher = &eventemitter.Watcher{Concurrent: false, Handle: tm.SaveApproveEvent}
-	eventemitter.On(eventemitter.Approve, tm.approveEventWatcher)
+	eventemitter.OnDurable(eventemitter.Approve, "txmanager", tm.approveEventWatcher)
 
 	tm.orderCancelledEventWatcher = &eventemitter.Watcher{Concurrent: false, Handle: tm.SaveOrderCancelledEvent}
-	eventemitter.On(eventemitter.CancelOrder, tm.orderCancelledEventWatcher)
+	eventemitter.OnDurable(eventemitter.CancelOrder, "txmanager", tm.orderCancelledEventWatcher)
 
 	tm.cutoffAllEventWatcher = &eventemitter.Watcher{Concurrent: false, Handle: tm.SaveCutoffAllEvent}
-	eventemitter.On(eventemitter.CutoffAll, tm.cutoffAllEventWatcher)
+	eventemitter.OnDurable(eventemitter.CutoffAll, "txmanager", tm.cutoffAllEventWatcher)
 
 	tm.cutoffPairEventWatcher = &eventemitter.Watcher{Concurrent: false, Handle: tm.SaveCutoffPairEvent}
-	eventemitter.On(eventemitter.CutoffPair, tm.cutoffPairEventWatcher)
+	eventemitter.OnDurable(eventemitter.CutoffPair, "txmanager", tm.cutoffPairEventWatcher)
 
 	tm.wethDepositEventWatcher = &eventemitter.Watcher{Concurrent: false, Handle: tm.SaveWethDepositEvent}
-	eventemitter.On(eventemitter.WethDeposit, tm.wethDepositEventWatcher)
+	eventemitter.OnDurable(eventemitter.WethDeposit, "txmanager", tm.wethDepositEventWatcher)
 
 	tm.wethWithdrawalEventWatcher = &eventemitter.Watcher{Concurrent: false, Handle: tm.SaveWethWithdrawalEvent}
-	eventemitter.On(eventemitter.WethWithdrawal, tm.wethWithdrawalEventWatcher)
+	eventemitter.OnDurable(eventemitter.WethWithdrawal, "txmanager", tm.wethWithdrawalEventWatcher)
 
 	tm.transferEventWatcher = &eventemitter.Watcher{Concurrent: false, Handle: tm.SaveTransferEvent}
-	eventemitter.On(eventemitter.Transfer, tm.transferEventWatcher)
+	eventemitter.OnDurable(eventemitter.Transfer, "txmanager", tm.transferEventWatcher)
 
 	tm.ethTransferEventWatcher = &eventemitter.Watcher{Concurrent: false, Handle: tm.SaveEthTransferEvent}
-	eventemitter.On(eventemitter.EthTransferEvent, tm.ethTransferEventWatcher)
+	eventemitter.OnDurable(eventemitter.EthTransferEvent, "txmanager", tm.ethTransferEventWatcher)
 
 	tm.orderFilledEventWatcher = &eventemitter.Watcher{Concurrent: false, Handle: tm.SaveOrderFilledEvent}
-	eventemitter.On(eventemitter.OrderFilled, tm.orderFilledEventWatcher)
+	eventemitter.OnDurable(eventemitter.OrderFilled, "txmanager", tm.orderFilledEventWatcher)
 
 	tm.forkDetectedEventWatcher = &eventemitter.Watcher{Concurrent: false, Handle: tm.ForkProcess}
-	eventemitter.On(eventemitter.ChainForkDetected, tm.forkDetectedEventWatcher)
+	eventemitter.OnDurable(eventemitter.ChainForkDetected, "txmanager", tm.forkDetectedEventWatcher)
 }
 
 func (tm *TransactionManager) Stop() {
-	eventemitter.Un(eventemitter.Approve, tm.approveEventWatcher)
-	eventemitter.Un(eventemitter.CancelOrder, tm.orderCancelledEventWatcher)
-	eventemitter.Un(eventemitter.CutoffAll, tm.cutoffAllEventWatcher)
-	eventemitter.Un(eventemitter.CutoffPair, tm.cutoffPairEventWatcher)
-	eventemitter.Un(eventemitter.WethDeposit, tm.wethDepositEventWatcher)
-	eventemitter.Un(eventemitter.WethWithdrawal, tm.wethWithdrawalEventWatcher)
-	eventemitter.Un(eventemitter.Transfer, tm.transferEventWatcher)
-	eventemitter.Un(eventemitter.EthTransferEvent, tm.ethTransferEventWatcher)
-	eventemitter.Un(eventemitter.OrderFilled, tm.orderFilledEventWatcher)
-	eventemitter.Un(eventemitter.ChainForkDetected, tm.forkDetectedEventWatcher)
+	eventemitter.UnDurable(eventemitter.Approve, "txmanager", tm.approveEventWatcher)
+	eventemitter.UnDurable(eventemitter.CancelOrder, "txmanager", tm.orderCancelledEventWatcher)
+	eventemitter.UnDurable(eventemitter.CutoffAll, "txmanager", tm.cutoffAllEventWatcher)
+	eventemitter.UnDurable(eventemitter.CutoffPair, "txmanager", tm.cutoffPairEventWatcher)
+	eventemitter.UnDurable(eventemitter.WethDeposit, "txmanager", tm.wethDepositEventWatcher)
+	eventemitter.UnDurable(eventemitter.WethWithdrawal, "txmanager", tm.wethWithdrawalEventWatcher)
+	eventemitter.UnDurable(eventemitter.Transfer, "txmanager", tm.transferEventWatcher)
+	eventemitter.UnDurable(eventemitter.EthTransferEvent, "txmanager", tm.ethTransferEventWatcher)
+	eventemitter.UnDurable(eventemitter.OrderFilled, "txmanager", tm.orderFilledEventWatcher)
+	eventemitter.UnDurable(eventemitter.ChainForkDetected, "txmanager", tm.forkDetectedEventWatcher)
 }
 
 // todo: check and test
@@ -48,6 +48,21 @@ func StatusStr(status TxStatus) string {
 	return ret
 }
 
+//the events of unconfirmed block may be rolled back by chain fork, the final block won't be rolled back by extractor
+type BlockFinality uint8
+
+const (
+	BLOCK_UNCONFIRMED BlockFinality = 0
+	BLOCK_FINAL       BlockFinality = 1
+)
+
+func FinalityStr(finality BlockFinality) string {
+	if finality == BLOCK_FINAL {
+		return "final"
+	}
+	return "unconfirmed"
+}
+
 func StrToTxStatus(txType string) TxStatus {
 	var ret TxStatus
 	switch txType {
@@ -82,6 +97,7 @@ type TxInfo struct {
 	GasPrice        *big.Int       `json:"gas_price"`
 	Nonce           *big.Int       `json:"nonce"`
 	Identify        string         `json:"identify"`
+	Finality        BlockFinality  `json:"finality"`
 }
 
 type TokenRegisterEvent struct {
@@ -206,6 +222,9 @@ type RingSubmitResultEvent struct {
 	BlockNumber  *big.Int
 	UsedGas      *big.Int
 	Err          error
+
+	//the orders make the ring revert in dry run, only they are penalised by matcher
+	OffendingOrders []common.Hash
 }
 
 type ForkedEvent struct {
@@ -219,6 +238,7 @@ type BlockEvent struct {
 	BlockNumber *big.Int
 	BlockHash   common.Hash
 	BlockTime   int64
+	Finality    BlockFinality
 }
 
 type ExtractorWarningEvent struct{}
@@ -232,6 +252,16 @@ type DepthUpdateEvent struct {
 	Market          string
 }
 
+//DepthDeltaEvent contains the price levels changed since the previous sequence of the market,
+//each level is [price, amount, size] and zero amount means the level has been removed
+type DepthDeltaEvent struct {
+	DelegateAddress string     `json:"delegateAddress"`
+	Market          string     `json:"market"`
+	Sequence        uint64     `json:"sequence"`
+	Buy             [][]string `json:"buy"`
+	Sell            [][]string `json:"sell"`
+}
+
 type BalanceUpdateEvent struct {
 	DelegateAddress string
 	Owner           string
@@ -172,7 +172,12 @@ func (o *Order) GenerateAndSetSignature(singerAddr common.Address) error {
 }
 
 func (o *Order) ValidateSignatureValues() bool {
-	return crypto.ValidateSignatureValues(byte(o.V), o.R.Bytes(), o.S.Bytes())
+	//v of an order is 27 or 28, the recovery id is 0 or 1
+	v := byte(o.V)
+	if v >= 27 {
+		v -= 27
+	}
+	return crypto.ValidateSignatureValues(v, o.R.Bytes(), o.S.Bytes())
 }
 
 func (o *Order) SignerAddress() (common.Address, error) {
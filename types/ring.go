@@ -182,6 +182,11 @@ type RingSubmitInfo struct {
 	ProtocolGasPrice *big.Int
 
 	SubmitTxHash common.Hash
+	Nonce        *big.Int
+
+	//the tx which is replaced by SubmitTxHash with the same nonce, ReplaceType is resubmit or cancel
+	ReplacedTxHash common.Hash
+	ReplaceType    string
 }
 
 //
@@ -34,6 +34,8 @@ type Token struct {
 	Decimals *big.Int       `json:"decimals"`
 	IsMarket bool           `json:"isMarket"`
 	IcoPrice *big.Rat       `json:"icoPrice"`
+	//the symbol listed by exchanges, the key is the name of exchange or default
+	ExchangeSymbols map[string]string `json:"exchangeSymbols"`
 }
 
 type CurrencyMarketCap struct {